@@ -0,0 +1,128 @@
+package artifact
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Artifact is a set of files collected from the workdir of a job
+type Artifact struct {
+	// Name is the name of the artifact, the same as the job, e.g. build (linux)
+	Name string `json:"name" yaml:"name"`
+	// Origin is the name of the job the matrix job is expanded from, e.g. build
+	Origin string `json:"origin,omitempty" yaml:"origin,omitempty"`
+	//
+	Files []*File `json:"files" yaml:"files"`
+	// Size is the total size of the files, unit: byte
+	Size int64 `json:"size" yaml:"size"`
+	//
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+}
+
+// File is a file of the artifact
+type File struct {
+	// Path is the path relative to the workdir, e.g. dist/app
+	Path string `json:"path" yaml:"path"`
+	//
+	Size int64 `json:"size" yaml:"size"`
+}
+
+// Store stores the artifacts of the pipelines
+type Store interface {
+	// Save collects the files matching the patterns in workdir as the artifact of the pipeline
+	Save(pipeline string, artifact *Artifact, workdir string, patterns []string) (*Artifact, error)
+	// Restore restores the files of the artifact into workdir
+	Restore(pipeline, name, workdir string) error
+	// List lists the artifacts of the pipeline
+	List(pipeline string) ([]*Artifact, error)
+	// Get returns the artifact of the pipeline
+	Get(pipeline, name string) (*Artifact, error)
+	// Open opens the file of the artifact
+	Open(pipeline, name, path string) (io.ReadCloser, error)
+	// Archive writes the files of the artifact to w as tar.gz
+	Archive(pipeline, name string, w io.Writer) error
+	// Delete deletes the artifacts of the pipeline
+	Delete(pipeline string) error
+}
+
+// Match returns true if the artifact belongs to the job, by name or by the origin of matrix jobs
+func (a *Artifact) Match(job string) bool {
+	return a.Name == job || (a.Origin != "" && a.Origin == job)
+}
+
+// Collect returns the files matching the patterns in workdir, relative to workdir
+//
+//	patterns are globs relative to workdir, e.g. dist, bin/*, coverage.out
+//	a directory matches all of its files
+func Collect(workdir string, patterns []string) ([]string, error) {
+	files := map[string]bool{}
+
+	for _, pattern := range patterns {
+		if err := ValidatePath(pattern); err != nil {
+			return nil, err
+		}
+
+		matches, err := filepath.Glob(filepath.Join(workdir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid artifacts path(%s): %s", pattern, err)
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match artifacts path(%s)", pattern)
+		}
+
+		for _, match := range matches {
+			err := filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+
+				if !info.Mode().IsRegular() {
+					return nil
+				}
+
+				rel, err := filepath.Rel(workdir, path)
+				if err != nil {
+					return err
+				}
+
+				files[filepath.ToSlash(rel)] = true
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to collect artifacts path(%s): %s", pattern, err)
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// ValidatePath checks the artifacts path is relative and does not escape its root
+func ValidatePath(path string) error {
+	if path == "" {
+		return fmt.Errorf("artifacts path should not be empty")
+	}
+
+	if filepath.IsAbs(path) {
+		return fmt.Errorf("artifacts path(%s) should be relative to workdir", path)
+	}
+
+	clean := filepath.ToSlash(filepath.Clean(path))
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("artifacts path(%s) should not be outside of workdir", path)
+	}
+
+	return nil
+}
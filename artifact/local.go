@@ -0,0 +1,257 @@
+package artifact
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type localStore struct {
+	sync.RWMutex
+	root string
+}
+
+// NewLocalStore creates an artifact store on the local filesystem
+//
+//	layout: <root>/<pipeline>/<artifact>/artifact.json
+//	        <root>/<pipeline>/<artifact>/files/<path>
+func NewLocalStore(root string) Store {
+	return &localStore{
+		root: root,
+	}
+}
+
+func (s *localStore) dir(pipeline, name string) string {
+	return filepath.Join(s.root, url.PathEscape(pipeline), url.PathEscape(name))
+}
+
+func (s *localStore) Save(pipeline string, artifact *Artifact, workdir string, patterns []string) (*Artifact, error) {
+	paths, err := Collect(workdir, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	dir := s.dir(pipeline, artifact.Name)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to reset artifact(%s): %s", artifact.Name, err)
+	}
+
+	saved := &Artifact{
+		Name:      artifact.Name,
+		Origin:    artifact.Origin,
+		Files:     []*File{},
+		CreatedAt: time.Now(),
+	}
+
+	for _, path := range paths {
+		size, err := copyFile(filepath.Join(workdir, path), filepath.Join(dir, "files", path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to save artifact(%s) file(%s): %s", artifact.Name, path, err)
+		}
+
+		saved.Files = append(saved.Files, &File{Path: path, Size: size})
+		saved.Size += size
+	}
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "artifact.json"), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save artifact(%s): %s", artifact.Name, err)
+	}
+
+	return saved, nil
+}
+
+func (s *localStore) Restore(pipeline, name, workdir string) error {
+	a, err := s.Get(pipeline, name)
+	if err != nil {
+		return err
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	dir := s.dir(pipeline, name)
+	for _, f := range a.Files {
+		if _, err := copyFile(filepath.Join(dir, "files", f.Path), filepath.Join(workdir, f.Path)); err != nil {
+			return fmt.Errorf("failed to restore artifact(%s) file(%s): %s", name, f.Path, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *localStore) List(pipeline string) ([]*Artifact, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	entries, err := os.ReadDir(filepath.Join(s.root, url.PathEscape(pipeline)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Artifact{}, nil
+		}
+
+		return nil, err
+	}
+
+	artifacts := []*Artifact{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		a, err := s.read(filepath.Join(s.root, url.PathEscape(pipeline), entry.Name()))
+		if err != nil {
+			// an artifact being saved has no metadata yet
+			continue
+		}
+
+		artifacts = append(artifacts, a)
+	}
+
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].Name < artifacts[j].Name
+	})
+
+	return artifacts, nil
+}
+
+func (s *localStore) Get(pipeline, name string) (*Artifact, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	a, err := s.read(s.dir(pipeline, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("artifact(%s) not found", name)
+		}
+
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (s *localStore) Open(pipeline, name, path string) (io.ReadCloser, error) {
+	a, err := s.Get(pipeline, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range a.Files {
+		if f.Path == path {
+			return os.Open(filepath.Join(s.dir(pipeline, name), "files", f.Path))
+		}
+	}
+
+	return nil, fmt.Errorf("file(%s) not found in artifact(%s)", path, name)
+}
+
+func (s *localStore) Archive(pipeline, name string, w io.Writer) error {
+	a, err := s.Get(pipeline, name)
+	if err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for _, f := range a.Files {
+		if err := s.archiveFile(tw, filepath.Join(s.dir(pipeline, name), "files", f.Path), f.Path); err != nil {
+			return fmt.Errorf("failed to archive artifact(%s) file(%s): %s", name, f.Path, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+func (s *localStore) archiveFile(tw *tar.Writer, src, path string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = path
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+func (s *localStore) Delete(pipeline string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	return os.RemoveAll(filepath.Join(s.root, url.PathEscape(pipeline)))
+}
+
+func (s *localStore) read(dir string) (*Artifact, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "artifact.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Artifact{}
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, fmt.Errorf("invalid artifact(%s): %s", dir, err)
+	}
+
+	return a, nil
+}
+
+// copyFile copies the file with its mode, creating the parent directories
+func copyFile(src, dst string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return 0, err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	return io.Copy(out, in)
+}
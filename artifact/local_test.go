@@ -0,0 +1,149 @@
+package artifact
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	workdir := t.TempDir()
+	writeFile(t, filepath.Join(workdir, "dist", "app"), "app")
+	writeFile(t, filepath.Join(workdir, "dist", "lib", "lib.so"), "lib")
+	writeFile(t, filepath.Join(workdir, "coverage.out"), "coverage")
+	writeFile(t, filepath.Join(workdir, "README.md"), "readme")
+
+	store := NewLocalStore(t.TempDir())
+
+	saved, err := store.Save("p1", &Artifact{Name: "build (linux)", Origin: "build"}, workdir, []string{"dist", "*.out"})
+	if err != nil {
+		t.Fatalf("Failed to save artifact: %v", err)
+	}
+
+	expected := []string{"coverage.out", "dist/app", "dist/lib/lib.so"}
+	if len(saved.Files) != len(expected) {
+		t.Fatalf("Expected %d files, got %d", len(expected), len(saved.Files))
+	}
+	for i, path := range expected {
+		if saved.Files[i].Path != path {
+			t.Errorf("Expected file '%s', got '%s'", path, saved.Files[i].Path)
+		}
+	}
+	if saved.Size != int64(len("app")+len("lib")+len("coverage")) {
+		t.Errorf("Unexpected size %d", saved.Size)
+	}
+
+	t.Run("list", func(t *testing.T) {
+		artifacts, err := store.List("p1")
+		if err != nil {
+			t.Fatalf("Failed to list artifacts: %v", err)
+		}
+
+		if len(artifacts) != 1 || !artifacts[0].Match("build") || !artifacts[0].Match("build (linux)") {
+			t.Fatalf("Unexpected artifacts: %v", artifacts)
+		}
+
+		artifacts, err = store.List("unknown")
+		if err != nil || len(artifacts) != 0 {
+			t.Fatalf("Expected no artifacts, got %v (err: %v)", artifacts, err)
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		target := t.TempDir()
+		if err := store.Restore("p1", "build (linux)", target); err != nil {
+			t.Fatalf("Failed to restore artifact: %v", err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(target, "dist", "lib", "lib.so"))
+		if err != nil || string(data) != "lib" {
+			t.Errorf("Expected restored file content 'lib', got '%s' (err: %v)", data, err)
+		}
+
+		if _, err := os.Stat(filepath.Join(target, "README.md")); !os.IsNotExist(err) {
+			t.Error("Expected README.md not to be restored")
+		}
+	})
+
+	t.Run("open", func(t *testing.T) {
+		file, err := store.Open("p1", "build (linux)", "dist/app")
+		if err != nil {
+			t.Fatalf("Failed to open file: %v", err)
+		}
+		defer file.Close()
+
+		data, _ := io.ReadAll(file)
+		if string(data) != "app" {
+			t.Errorf("Expected 'app', got '%s'", data)
+		}
+
+		if _, err := store.Open("p1", "build (linux)", "../artifact.json"); err == nil {
+			t.Error("Expected error for file outside of artifact")
+		}
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		if err := store.Archive("p1", "build (linux)", buf); err != nil {
+			t.Fatalf("Failed to archive: %v", err)
+		}
+
+		gr, err := gzip.NewReader(buf)
+		if err != nil {
+			t.Fatalf("Invalid gzip: %v", err)
+		}
+
+		tr := tar.NewReader(gr)
+		names := []string{}
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Invalid tar: %v", err)
+			}
+			names = append(names, header.Name)
+		}
+
+		if len(names) != len(expected) {
+			t.Errorf("Expected %v in archive, got %v", expected, names)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := store.Delete("p1"); err != nil {
+			t.Fatalf("Failed to delete: %v", err)
+		}
+
+		if _, err := store.Get("p1", "build (linux)"); err == nil {
+			t.Error("Expected artifact to be deleted")
+		}
+	})
+}
+
+func TestCollect_Invalid(t *testing.T) {
+	workdir := t.TempDir()
+
+	for _, pattern := range []string{"", "/etc/passwd", "../secret", "missing/*"} {
+		if _, err := Collect(workdir, []string{pattern}); err == nil {
+			t.Errorf("Expected error for pattern '%s'", pattern)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-idp/pipeline/artifact"
+	"github.com/go-idp/pipeline/cache"
+	"github.com/go-idp/pipeline/job"
+)
+
+// SetArtifactStore sets the artifact store of the pipeline, default: a local store in the temp dir
+func (p *Pipeline) SetArtifactStore(store artifact.Store) *Pipeline {
+	p.artifacts = store
+	return p
+}
+
+// SetCacheStore sets the cache store of the jobs and steps, default: a local store in the user cache dir
+func (p *Pipeline) SetCacheStore(store cache.Store) *Pipeline {
+	p.caches = store
+	return p
+}
+
+// defaultArtifactStore returns the local store used when no artifact store is set
+func defaultArtifactStore() artifact.Store {
+	return artifact.NewLocalStore(filepath.Join(os.TempDir(), "go-idp", "pipeline", "artifacts"))
+}
+
+// validateDependencies checks every dependency refers to a job with artifacts that finishes before the job
+//
+//	the job of dependency should be in a previous stage, or in the needs of the job
+func (p *Pipeline) validateDependencies() error {
+	stages := p.allStages()
+
+	for si, s := range stages {
+		for _, j := range s.Jobs {
+			for _, dependency := range j.Dependencies {
+				found := false
+				for di, ds := range stages {
+					for _, dj := range ds.Jobs {
+						if dj.Name != dependency && dj.Origin() != dependency {
+							continue
+						}
+
+						found = true
+						if len(dj.Artifacts) == 0 {
+							return fmt.Errorf("[workflow][prepare] job(%s) depends on job(%s) without artifacts", j.Name, dependency)
+						}
+
+						if di >= si && !needs(j, dependency) {
+							return fmt.Errorf("[workflow][prepare] job(%s) depends on job(%s) of the same or a later stage, add it to needs", j.Name, dependency)
+						}
+					}
+				}
+
+				if !found {
+					return fmt.Errorf("[workflow][prepare] job(%s) depends on unknown job(%s)", j.Name, dependency)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func needs(j *job.Job, name string) bool {
+	for _, n := range j.Needs {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
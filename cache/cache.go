@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-idp/pipeline/interpolate"
+)
+
+// Cache is the cache config of a job or a step
+//
+//	e.g.
+//	  cache:
+//	    key: go-${{ hashFiles('go.sum') }}
+//	    paths:
+//	      - ~/go/pkg/mod
+//	    restore_keys:
+//	      - go-
+type Cache struct {
+	// Key is the key template of the cache, supports ${VAR}, ${VAR:-default} and ${{ hashFiles('pattern', ...) }}
+	Key string `json:"key" yaml:"key"`
+	// Paths are the paths to save, relative to the workdir, absolute or starting with ~
+	Paths []string `json:"paths" yaml:"paths"`
+	// RestoreKeys are the key prefixes to restore from when the key misses, the newest cache wins
+	RestoreKeys []string `json:"restore_keys" yaml:"restore_keys"`
+}
+
+// Result is the outcome of the cache of a job or a step
+type Result struct {
+	// Key is the rendered key
+	Key string `json:"key" yaml:"key"`
+	// Hit is true when the cache of the key is restored
+	Hit bool `json:"hit" yaml:"hit"`
+	// RestoredKey is the key the cache is restored from, empty on miss
+	RestoredKey string `json:"restored_key" yaml:"restored_key"`
+	// Saved is true when the cache is saved after the run
+	Saved bool `json:"saved" yaml:"saved"`
+}
+
+// Validate checks the cache config
+func (c *Cache) Validate() error {
+	if c.Key == "" {
+		return fmt.Errorf("cache key is required")
+	}
+
+	if len(c.Paths) == 0 {
+		return fmt.Errorf("cache paths is required")
+	}
+
+	return nil
+}
+
+// RenderKey renders the key template with the files of workdir and the environment,
+//
+//	undefined variables are rendered as empty
+func RenderKey(template, workdir string, environment map[string]string) (string, error) {
+	key, err := interpolate.Expand(template, &interpolate.Options{
+		Lookup: func(name string) (string, bool) {
+			return environment[name], true
+		},
+		Functions: interpolate.Functions(workdir),
+		Strict:    true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(key) == "" {
+		return "", fmt.Errorf("cache key(%s) is empty after rendering", template)
+	}
+
+	return key, nil
+}
+
+// Restore restores the cache into workdir, from the key or the restore keys
+func Restore(store Store, c *Cache, workdir string, environment map[string]string) (*Result, error) {
+	key, err := RenderKey(c.Key, workdir, environment)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Key: key}
+
+	keys := []string{key}
+	for _, restoreKey := range c.RestoreKeys {
+		k, err := RenderKey(restoreKey, workdir, environment)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, k)
+	}
+
+	restored, err := store.Restore(keys, workdir)
+	if err != nil {
+		return result, err
+	}
+
+	result.RestoredKey = restored
+	result.Hit = restored == key
+	return result, nil
+}
+
+// Save saves the paths of workdir as the cache of the key, skipped on hit
+func Save(store Store, c *Cache, result *Result, workdir string) error {
+	if result == nil || result.Hit {
+		return nil
+	}
+
+	if err := store.Save(result.Key, workdir, c.Paths); err != nil {
+		return err
+	}
+
+	result.Saved = true
+	return nil
+}
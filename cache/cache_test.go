@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-idp/pipeline/interpolate"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+}
+
+func TestRenderKey(t *testing.T) {
+	workdir := t.TempDir()
+	writeFile(t, filepath.Join(workdir, "go.sum"), "v1")
+
+	hash, err := interpolate.HashFiles(workdir, "go.sum")
+	if err != nil {
+		t.Fatalf("Failed to hash files: %v", err)
+	}
+	if hash == "" {
+		t.Fatalf("Expected hash of go.sum")
+	}
+
+	key, err := RenderKey("go-${OS}-${{ hashFiles('go.sum') }}", workdir, map[string]string{"OS": "linux"})
+	if err != nil {
+		t.Fatalf("Failed to render key: %v", err)
+	}
+	if key != "go-linux-"+hash {
+		t.Errorf("Expected key go-linux-%s, got %s", hash, key)
+	}
+
+	writeFile(t, filepath.Join(workdir, "go.sum"), "v2")
+	changed, _ := RenderKey("go-${{ hashFiles('go.sum') }}", workdir, nil)
+	if changed == "go-"+hash {
+		t.Errorf("Expected key to change with go.sum")
+	}
+
+	if _, err := RenderKey("${EMPTY}", workdir, nil); err == nil {
+		t.Errorf("Expected error for empty key")
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	store := NewLocalStore(t.TempDir())
+	c := &Cache{
+		Key:         "deps-${{ hashFiles('deps.lock') }}",
+		Paths:       []string{"vendor"},
+		RestoreKeys: []string{"deps-"},
+	}
+
+	workdir := t.TempDir()
+	writeFile(t, filepath.Join(workdir, "deps.lock"), "v1")
+
+	result, err := Restore(store, c, workdir, nil)
+	if err != nil {
+		t.Fatalf("Failed to restore cache: %v", err)
+	}
+	if result.Hit || result.RestoredKey != "" {
+		t.Fatalf("Expected miss on empty store, got %+v", result)
+	}
+
+	writeFile(t, filepath.Join(workdir, "vendor", "lib", "a.txt"), "a")
+	if err := Save(store, c, result, workdir); err != nil {
+		t.Fatalf("Failed to save cache: %v", err)
+	}
+	if !result.Saved {
+		t.Errorf("Expected cache to be saved")
+	}
+
+	t.Run("exact key should hit", func(t *testing.T) {
+		other := t.TempDir()
+		writeFile(t, filepath.Join(other, "deps.lock"), "v1")
+
+		result, err := Restore(store, c, other, nil)
+		if err != nil {
+			t.Fatalf("Failed to restore cache: %v", err)
+		}
+		if !result.Hit {
+			t.Fatalf("Expected hit, got %+v", result)
+		}
+
+		data, err := os.ReadFile(filepath.Join(other, "vendor", "lib", "a.txt"))
+		if err != nil || string(data) != "a" {
+			t.Errorf("Expected restored file, got %q (%v)", data, err)
+		}
+
+		if err := Save(store, c, result, other); err != nil || result.Saved {
+			t.Errorf("Expected save to be skipped on hit")
+		}
+	})
+
+	t.Run("restore keys should fall back to the newest cache", func(t *testing.T) {
+		newer := t.TempDir()
+		writeFile(t, filepath.Join(newer, "vendor", "lib", "a.txt"), "newer")
+		if err := store.Save("deps-newer", newer, []string{"vendor"}); err != nil {
+			t.Fatalf("Failed to save cache: %v", err)
+		}
+		future := time.Now().Add(time.Hour)
+		os.Chtimes(store.(*localStore).file("deps-newer"), future, future)
+
+		other := t.TempDir()
+		writeFile(t, filepath.Join(other, "deps.lock"), "v2")
+
+		result, err := Restore(store, c, other, nil)
+		if err != nil {
+			t.Fatalf("Failed to restore cache: %v", err)
+		}
+		if result.Hit || result.RestoredKey != "deps-newer" {
+			t.Fatalf("Expected miss restored from deps-newer, got %+v", result)
+		}
+
+		data, _ := os.ReadFile(filepath.Join(other, "vendor", "lib", "a.txt"))
+		if string(data) != "newer" {
+			t.Errorf("Expected newer cache, got %q", data)
+		}
+	})
+}
+
+func TestEntryPath(t *testing.T) {
+	for _, entry := range []string{"workdir/../x", "root/../../etc/passwd", "other/x"} {
+		if _, err := entryPath("/tmp/w", entry); err == nil {
+			t.Errorf("Expected error for entry %s", entry)
+		}
+	}
+
+	if path, err := entryPath("/tmp/w", "workdir/a/b"); err != nil || path != "/tmp/w/a/b" {
+		t.Errorf("Expected /tmp/w/a/b, got %s (%v)", path, err)
+	}
+}
@@ -0,0 +1,298 @@
+package cache
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store stores the caches as tarballs
+type Store interface {
+	// Restore restores the cache of the first key found into workdir, and returns the key,
+	//	the first key must match exactly, the others are prefixes matching the newest cache
+	//	returns empty key on miss
+	Restore(keys []string, workdir string) (string, error)
+	// Save saves the paths as the cache of the key
+	Save(key, workdir string, paths []string) error
+}
+
+// DefaultDir returns the default cache directory of the runner
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, "go-idp", "pipeline", "cache")
+}
+
+type localStore struct {
+	dir string
+}
+
+// NewLocalStore creates a cache store on the local filesystem, a cache is a tar.gz file named by its key
+func NewLocalStore(dir string) Store {
+	return &localStore{
+		dir: dir,
+	}
+}
+
+func (s *localStore) file(key string) string {
+	return filepath.Join(s.dir, url.PathEscape(key)+".tar.gz")
+}
+
+func (s *localStore) Restore(keys []string, workdir string) (string, error) {
+	for i, key := range keys {
+		if i == 0 {
+			if _, err := os.Stat(s.file(key)); err != nil {
+				continue
+			}
+
+			return key, extract(s.file(key), workdir)
+		}
+
+		found, err := s.newest(key)
+		if err != nil {
+			return "", err
+		}
+
+		if found != "" {
+			return found, extract(s.file(found), workdir)
+		}
+	}
+
+	return "", nil
+}
+
+// newest returns the key of the newest cache with the prefix
+func (s *localStore) newest(prefix string) (string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	newest := ""
+	var newestTime int64
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".tar.gz")
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		key, err := url.PathUnescape(name)
+		if err != nil || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		if newest == "" || info.ModTime().UnixNano() > newestTime {
+			newest = key
+			newestTime = info.ModTime().UnixNano()
+		}
+	}
+
+	return newest, nil
+}
+
+func (s *localStore) Save(key, workdir string, paths []string) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+
+	// write to a temp file first, so that a concurrent restore never reads a partial cache
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := archive(tmp, workdir, paths); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.file(key))
+}
+
+// entryRoot maps the path to the entries of the tarball
+//
+//	relative paths are stored under workdir/, the others under root/
+func entryRoot(workdir, path string) (src, entry string, err error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", err
+		}
+
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path), "root" + filepath.ToSlash(filepath.Clean(path)), nil
+	}
+
+	return filepath.Join(workdir, path), "workdir/" + filepath.ToSlash(filepath.Clean(path)), nil
+}
+
+// entryPath returns the path of the entry of the tarball
+func entryPath(workdir, entry string) (string, error) {
+	clean := filepath.ToSlash(filepath.Clean(entry))
+	if strings.Contains("/"+clean+"/", "/../") {
+		return "", fmt.Errorf("invalid cache entry(%s)", entry)
+	}
+
+	if rest, ok := strings.CutPrefix(clean, "workdir/"); ok {
+		return filepath.Join(workdir, rest), nil
+	}
+
+	if rest, ok := strings.CutPrefix(clean, "root/"); ok {
+		return filepath.Join("/", rest), nil
+	}
+
+	return "", fmt.Errorf("invalid cache entry(%s)", entry)
+}
+
+func archive(w io.Writer, workdir string, paths []string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for _, path := range paths {
+		src, entry, err := entryRoot(workdir, path)
+		if err != nil {
+			return err
+		}
+
+		// missing paths are skipped, e.g. no dependencies yet
+		if _, err := os.Lstat(src); os.IsNotExist(err) {
+			continue
+		}
+
+		err = filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(src, file)
+			if err != nil {
+				return err
+			}
+
+			link := ""
+			if info.Mode()&os.ModeSymlink != 0 {
+				if link, err = os.Readlink(file); err != nil {
+					return err
+				}
+			}
+
+			header, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(filepath.Join(entry, rel))
+
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			_, err = io.Copy(tw, f)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to archive cache path(%s): %s", path, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+func extract(file, workdir string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("invalid cache(%s): %s", file, err)
+	}
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid cache(%s): %s", file, err)
+		}
+
+		path, err := entryPath(workdir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, os.FileMode(header.Mode).Perm()|0700); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			os.Remove(path)
+			if err := os.Symlink(header.Linkname, path); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			// the file may be read-only, e.g. in go module cache
+			os.Remove(path)
+
+			out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return err
+			}
+
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
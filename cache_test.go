@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-idp/pipeline/cache"
+	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-idp/pipeline/step"
+)
+
+func TestPipelineCache(t *testing.T) {
+	store := cache.NewLocalStore(t.TempDir())
+
+	run := func(workdir string) *job.Job {
+		j := &job.Job{
+			Name: "build",
+			Cache: &cache.Cache{
+				Key:   "build-${{ hashFiles('deps.lock') }}",
+				Paths: []string{"deps"},
+			},
+			Steps: []*step.Step{
+				{
+					Name:    "build",
+					Command: "test -f deps/ok || (mkdir -p deps && echo ok > deps/ok && echo built > built)",
+				},
+			},
+		}
+
+		pipeline := &Pipeline{
+			Name:    "test pipeline cache",
+			Workdir: workdir,
+			Stages: []*stage.Stage{
+				{Name: "build", Jobs: []*job.Job{j}},
+			},
+		}
+		pipeline.SetCacheStore(store)
+
+		if err := pipeline.Run(context.Background()); err != nil {
+			t.Fatalf("Failed to run pipeline: %v", err)
+		}
+
+		return j
+	}
+
+	first := t.TempDir()
+	j := run(first)
+	if j.State.Cache == nil || j.State.Cache.Hit || !j.State.Cache.Saved {
+		t.Fatalf("Expected first run to miss and save, got %+v", j.State.Cache)
+	}
+
+	second := t.TempDir()
+	j = run(second)
+	if j.State.Cache == nil || !j.State.Cache.Hit || j.State.Cache.Saved {
+		t.Fatalf("Expected second run to hit, got %+v", j.State.Cache)
+	}
+}
+
+func TestPipelineCacheValidation(t *testing.T) {
+	pipeline := &Pipeline{
+		Name: "test pipeline cache validation",
+		Stages: []*stage.Stage{
+			{
+				Name: "build",
+				Jobs: []*job.Job{
+					{
+						Name:  "build",
+						Cache: &cache.Cache{Key: "build"},
+						Steps: []*step.Step{{Name: "build", Command: "true"}},
+					},
+				},
+			},
+		},
+	}
+
+	if err := pipeline.Run(context.Background()); err == nil {
+		t.Fatalf("Expected error for cache without paths")
+	}
+}
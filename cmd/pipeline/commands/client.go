@@ -1,18 +1,11 @@
 package commands
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/go-idp/pipeline"
 	"github.com/go-idp/pipeline/svc/client"
 	"github.com/go-zoox/cli"
-	"github.com/go-zoox/core-utils/regexp"
-	"github.com/go-zoox/debug"
-	"github.com/go-zoox/fetch"
-	"github.com/go-zoox/fs"
-	"github.com/go-zoox/fs/type/yaml"
-	"github.com/go-zoox/logger"
 )
 
 func RegisterClient(app *cli.MultipleProgram) {
@@ -53,34 +46,25 @@ func RegisterClient(app *cli.MultipleProgram) {
 				EnvVars: []string{"SERVER_PATH"},
 				Value:   "/",
 			},
+			&cli.StringSliceFlag{
+				Name:  "param",
+				Usage: "Specifies the value of a parameter of the pipeline, example: version=1.0.0",
+			},
 		},
 		Action: func(ctx *cli.Context) error {
 			config := ctx.String("config")
 
-			// support for remote config
-			if ok := regexp.Match(`^https?://`, config); ok {
-				url := config
-				config = fs.TmpFilePath() + ".yaml"
-				response, err := fetch.Get(url)
-				if err != nil {
-					return fmt.Errorf("failed to fetch config(url: %s): %s", url, err)
-				}
-
-				if err := fs.WriteFile(config, []byte(response.String())); err != nil {
-					return fmt.Errorf("failed to write config(file: %s): %s", config, err)
-				}
-
-				if !debug.IsDebugMode() {
-					defer fs.RemoveFile(config)
-				} else {
-					logger.Infof("load config from %s to %s", url, config)
-				}
+			// local or remote config, with its includes
+			pl, err := pipeline.Load(config)
+			if err != nil {
+				return err
 			}
 
-			pipeline := pipeline.Pipeline{}
-			if err := yaml.Read(config, &pipeline); err != nil {
-				return fmt.Errorf("failed to read config(file: %s): %s", config, err)
+			params, err := pipeline.ParseParams(ctx.StringSlice("param"))
+			if err != nil {
+				return err
 			}
+			pl.SetParams(params)
 
 			cfg := &client.Config{
 				Server:   ctx.String("server"),
@@ -99,7 +83,7 @@ func RegisterClient(app *cli.MultipleProgram) {
 			}
 			defer s.Close()
 
-			return s.Run(&pipeline)
+			return s.Run(pl)
 		},
 	})
 }
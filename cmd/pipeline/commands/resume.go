@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/go-idp/pipeline"
+	"github.com/go-zoox/cli"
+	"github.com/go-zoox/core-utils/fmt"
+	"github.com/go-zoox/fs"
+)
+
+func RegisterResume(app *cli.MultipleProgram) {
+	app.Register("resume", &cli.Command{
+		Name:      "resume",
+		Usage:     "resume a failed pipeline, only the failed and not-yet-run stages, jobs and steps run again",
+		ArgsUsage: "<workdir|run-id>",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "param",
+				Usage: "Specifies the value of a parameter of the pipeline, the secrets are not persisted, example: token=xxx",
+			},
+			&cli.StringFlag{
+				Name:    "events-file",
+				Usage:   "Writes the events of the run to the file as JSON lines, e.g. stage.started, step.finished",
+				EnvVars: []string{"PIPELINE_EVENTS_FILE"},
+			},
+			&cli.StringFlag{
+				Name:    "report",
+				Usage:   "Writes the report of the run to the file, the states of the stages, jobs and steps",
+				EnvVars: []string{"PIPELINE_REPORT"},
+			},
+			&cli.StringFlag{
+				Name:  "report-format",
+				Usage: "Specifies the format of the report, options: json, junit, default: junit for .xml files, json otherwise",
+			},
+			&cli.BoolFlag{
+				Name:    "fail-on-warnings",
+				Usage:   "Exits with 1 if the pipeline succeeded with warnings, i.e. with allowed failures",
+				EnvVars: []string{"PIPELINE_FAIL_ON_WARNINGS"},
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			target := fs.CurrentDir()
+			if ctx.Args().Present() {
+				target = ctx.Args().First()
+			}
+
+			rs, err := pipeline.ReadRunState(target)
+			if err != nil {
+				return err
+			}
+
+			if rs.State != nil && rs.State.Status == "succeeded" {
+				return fmt.Errorf("run %s is already succeeded, nothing to resume", rs.ID)
+			}
+
+			p, err := rs.Pipeline()
+			if err != nil {
+				return err
+			}
+
+			params, err := pipeline.ParseParams(ctx.StringSlice("param"))
+			if err != nil {
+				return err
+			}
+			p.SetParams(params)
+
+			closeEvents, err := setEventsFile(p, ctx.String("events-file"))
+			if err != nil {
+				return err
+			}
+			defer closeEvents()
+
+			fmt.Printf("resume run %s (workdir: %s)\n", rs.ID, rs.Workdir)
+			err = p.Run(context.Background(), func(cfg *pipeline.RunConfig) {
+				cfg.Resume = rs
+			})
+			err = writeReport(p, ctx.String("report"), ctx.String("report-format"), err)
+			return withExitCode(p, withWarnings(p, ctx.Bool("fail-on-warnings"), err))
+		},
+	})
+}
@@ -2,19 +2,18 @@ package commands
 
 import (
 	"context"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/go-zoox/core-utils/fmt"
-	"github.com/go-zoox/core-utils/regexp"
 	"github.com/go-zoox/debug"
-	"github.com/go-zoox/fetch"
 	"github.com/go-zoox/fs"
-	"github.com/go-zoox/logger"
 
 	"github.com/go-idp/pipeline"
+	"github.com/go-idp/pipeline/event"
+	"github.com/go-idp/pipeline/secret"
 	"github.com/go-zoox/cli"
-	"github.com/go-zoox/fs/type/yaml"
 )
 
 func RegisterRun(app *cli.MultipleProgram) {
@@ -48,6 +47,10 @@ func RegisterRun(app *cli.MultipleProgram) {
 				Usage:   "Specifies the environment, example: KEY=VALUE",
 				EnvVars: []string{"ENV"},
 			},
+			&cli.StringSliceFlag{
+				Name:  "param",
+				Usage: "Specifies the value of a parameter of the pipeline, example: version=1.0.0",
+			},
 			&cli.StringSliceFlag{
 				Name:    "allow-env",
 				Usage:   "Specifies the allowed environment variables, example: GITHUB_CI",
@@ -58,43 +61,72 @@ func RegisterRun(app *cli.MultipleProgram) {
 				Usage:   "Specifies the allowed all environment variables",
 				EnvVars: []string{"ALLOW_ALL_ENV"},
 			},
+			&cli.StringSliceFlag{
+				Name:  "only",
+				Usage: "Runs only the selected stages, jobs or steps, example: build, build/test, build/test/lint",
+			},
+			&cli.StringSliceFlag{
+				Name:  "skip",
+				Usage: "Skips the selected stages, jobs or steps, example: build, build/test, build/test/lint",
+			},
+			&cli.StringFlag{
+				Name:  "from",
+				Usage: "Specifies the first stage to run, the stages before it are skipped",
+			},
+			&cli.StringFlag{
+				Name:  "until",
+				Usage: "Specifies the last stage to run, the stages after it are skipped",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Prints the resolved execution plan without running anything",
+			},
+			&cli.StringFlag{
+				Name:  "plan-format",
+				Usage: "Specifies the format of the plan with --dry-run, options: tree, yaml, json",
+				Value: "tree",
+			},
+			&cli.StringFlag{
+				Name:    "events-file",
+				Usage:   "Writes the events of the run to the file as JSON lines, e.g. stage.started, step.finished",
+				EnvVars: []string{"PIPELINE_EVENTS_FILE"},
+			},
+			&cli.StringFlag{
+				Name:    "report",
+				Usage:   "Writes the report of the run to the file, the states of the stages, jobs and steps",
+				EnvVars: []string{"PIPELINE_REPORT"},
+			},
+			&cli.StringFlag{
+				Name:  "report-format",
+				Usage: "Specifies the format of the report, options: json, junit, default: junit for .xml files, json otherwise",
+			},
+			&cli.BoolFlag{
+				Name:    "fail-on-warnings",
+				Usage:   "Exits with 1 if the pipeline succeeded with warnings, i.e. with allowed failures",
+				EnvVars: []string{"PIPELINE_FAIL_ON_WARNINGS"},
+			},
 		},
 		Action: func(ctx *cli.Context) error {
-			fmt.Fprintf(os.Stdout, `
+			dryRun := ctx.Bool("dry-run")
+			if !dryRun {
+				fmt.Fprintf(os.Stdout, `
   _____       _______  ___    ___  _          ___         
  / ___/__    /  _/ _ \/ _ \  / _ \(_)__  ___ / (_)__  ___ 
 / (_ / _ \  _/ // // / ___/ / ___/ / _ \/ -_) / / _ \/ -_)
 \___/\___/ /___/____/_/    /_/  /_/ .__/\__/_/_/_//_/\__/ 
                                  /_/                      v%s
 `+"\n\n", pipeline.Version)
+			}
+
 			config := ctx.String("config")
 			if config == "" {
 				return fmt.Errorf("config is required")
 			}
 
-			// support for remote config
-			if ok := regexp.Match(`^https?://`, config); ok {
-				url := config
-				config = fs.TmpFilePath() + ".yaml"
-				response, err := fetch.Get(url)
-				if err != nil {
-					return fmt.Errorf("failed to fetch config(url: %s): %s", url, err)
-				}
-
-				if err := fs.WriteFile(config, []byte(response.String())); err != nil {
-					return fmt.Errorf("failed to write config(file: %s): %s", config, err)
-				}
-
-				if !debug.IsDebugMode() {
-					defer fs.RemoveFile(config)
-				} else {
-					logger.Infof("load config from %s to %s", url, config)
-				}
-			}
-
-			p := &pipeline.Pipeline{}
-			if err := yaml.Read(config, p); err != nil {
-				return fmt.Errorf("failed to read config(file: %s): %s", config, err)
+			// local or remote config, with its includes
+			p, err := pipeline.Load(config)
+			if err != nil {
+				return err
 			}
 
 			if workdir := ctx.String("workdir"); workdir != "" {
@@ -114,6 +146,11 @@ func RegisterRun(app *cli.MultipleProgram) {
 			if ctx.Bool("allow-all-env") {
 				for _, e := range os.Environ() {
 					kv := strings.Split(e, "=")
+					// secrets are only given by ${{ secrets.NAME }}, masked in the logs
+					if strings.HasPrefix(kv[0], secret.EnvPrefix) {
+						continue
+					}
+
 					if len(kv) >= 1 {
 						environment[kv[0]] = kv[1]
 					}
@@ -131,15 +168,144 @@ func RegisterRun(app *cli.MultipleProgram) {
 				p.SetEnvironment(environment)
 			}
 
+			params, err := pipeline.ParseParams(ctx.StringSlice("param"))
+			if err != nil {
+				return err
+			}
+			p.SetParams(params)
+
 			if debug.IsDebugMode() {
 				fmt.PrintJSON(p)
 			}
 
-			return p.Run(context.Background())
+			filter := func(cfg *pipeline.RunConfig) {
+				cfg.Filter = &pipeline.Filter{
+					Only:  ctx.StringSlice("only"),
+					Skip:  ctx.StringSlice("skip"),
+					From:  ctx.String("from"),
+					Until: ctx.String("until"),
+				}
+			}
+
+			if dryRun {
+				// the logs of setup are not part of the plan
+				p.SetStdout(io.Discard)
+
+				plan, err := p.Plan(filter)
+				if err != nil {
+					return err
+				}
+
+				output, err := plan.Render(ctx.String("plan-format"))
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintf(os.Stdout, "%s", output)
+				return nil
+			}
+
+			closeEvents, err := setEventsFile(p, ctx.String("events-file"))
+			if err != nil {
+				return err
+			}
+			defer closeEvents()
+
+			err = p.Run(context.Background(), filter)
+			err = writeReport(p, ctx.String("report"), ctx.String("report-format"), err)
+			return withExitCode(p, withWarnings(p, ctx.Bool("fail-on-warnings"), err))
 		},
 	})
 }
 
+// writeReport writes the report of the run to the file, if path is set, even if the run failed,
+//
+//	runErr is the error of the run, returned as is
+func writeReport(p *pipeline.Pipeline, path, format string, runErr error) error {
+	if path == "" {
+		return runErr
+	}
+
+	if format == "" {
+		format = "json"
+		if strings.HasSuffix(path, ".xml") {
+			format = "junit"
+		}
+	}
+
+	err := func() error {
+		report, err := p.Report()
+		if err != nil {
+			return err
+		}
+
+		output, err := report.Render(format)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(path, []byte(output), 0644)
+	}()
+	if err != nil {
+		err = fmt.Errorf("failed to write report(path: %s): %s", path, err)
+		if runErr != nil {
+			// the error of the run wins, e.g. the pipeline failed before it started
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return runErr
+		}
+
+		return err
+	}
+
+	return runErr
+}
+
+// exitError is the error of a failed run, the command exits with the exit code of the failed step
+type exitError struct {
+	error
+	code int
+}
+
+// ExitCode returns the exit code of the command
+func (e *exitError) ExitCode() int {
+	return e.code
+}
+
+// withExitCode makes the command exit with the exit code of the failed step, e.g. 137 if it is killed by SIGKILL,
+//
+//	the command exits with 1 if the exit code is unknown, e.g. the pipeline timed out
+func withExitCode(p *pipeline.Pipeline, err error) error {
+	if err == nil || p.State == nil || p.State.ExitCode <= 0 {
+		return err
+	}
+
+	return &exitError{error: err, code: p.State.ExitCode}
+}
+
+// withWarnings fails the command if the pipeline succeeded with warnings and failOnWarnings is set
+func withWarnings(p *pipeline.Pipeline, failOnWarnings bool, err error) error {
+	if err != nil || !failOnWarnings || p.State == nil || !p.State.HasWarnings() {
+		return err
+	}
+
+	return fmt.Errorf("pipeline succeeded with warnings, allowed failures: %s", strings.Join(p.State.Warnings, ", "))
+}
+
+// setEventsFile writes the events of the pipeline to the file as JSON lines, if path is set
+func setEventsFile(p *pipeline.Pipeline, path string) (func() error, error) {
+	if path == "" {
+		return func() error { return nil }, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create events file(path: %s): %s", path, err)
+	}
+
+	p.SetEventSink(event.NewJSONWriter(f))
+	return f.Close, nil
+}
+
 func findConfig() string {
 	// @1 .pipeline.yaml
 	if ok := fs.IsExist(".pipeline.yaml"); ok {
@@ -4,6 +4,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/go-idp/pipeline/secret"
 	"github.com/go-idp/pipeline/svc/server"
 	"github.com/go-zoox/cli"
 )
@@ -60,6 +61,33 @@ func RegisterServer(app *cli.MultipleProgram) {
 				EnvVars: []string{"MAX_CONCURRENT"},
 				Value:   2,
 			},
+			&cli.StringFlag{
+				Name:    "secrets-file",
+				Usage:   "Specifies the encrypted file of the secrets, default: <workdir>/.pipeline_secrets",
+				EnvVars: []string{"PIPELINE_SECRETS_FILE"},
+			},
+			&cli.StringFlag{
+				Name:    "secrets-key",
+				Usage:   "Specifies the key of the secrets file, the secrets are read from PIPELINE_SECRET_* environment variables without it",
+				EnvVars: []string{"PIPELINE_SECRETS_KEY"},
+			},
+			&cli.StringFlag{
+				Name:    "store",
+				Usage:   "Specifies the store of the history and the queue, options: memory, sqlite, sqlite:<path>, default path: <workdir>/.pipeline.db",
+				EnvVars: []string{"PIPELINE_STORE"},
+				Value:   "memory",
+			},
+			&cli.IntFlag{
+				Name:    "log-max-size",
+				Usage:   "Specifies the maximum size of the logs of a run in MB, the following logs are dropped, 0 means unlimited",
+				EnvVars: []string{"PIPELINE_LOG_MAX_SIZE"},
+				Value:   100,
+			},
+			&cli.StringSliceFlag{
+				Name:    "allowed-include",
+				Usage:   "Specifies the url prefix of the includes allowed in the configs submitted by the clients, e.g. https://example.com/templates/, no include is allowed by default",
+				EnvVars: []string{"PIPELINE_ALLOWED_INCLUDES"},
+			},
 		},
 		Action: func(ctx *cli.Context) error {
 			environment := map[string]string{}
@@ -73,6 +101,11 @@ func RegisterServer(app *cli.MultipleProgram) {
 			if ctx.Bool("allow-all-env") {
 				for _, key := range os.Environ() {
 					kv := strings.Split(key, "=")
+					// secrets and the key of the secrets file are never given to the pipelines
+					if strings.HasPrefix(kv[0], secret.EnvPrefix) || kv[0] == "PIPELINE_SECRETS_KEY" {
+						continue
+					}
+
 					if len(kv) >= 1 {
 						if _, ok := environment[kv[0]]; !ok {
 							environment[kv[0]] = kv[1]
@@ -94,6 +127,15 @@ func RegisterServer(app *cli.MultipleProgram) {
 				Password: ctx.String("password"),
 				//
 				MaxConcurrent: ctx.Int("max-concurrent"),
+				//
+				SecretsFile: ctx.String("secrets-file"),
+				SecretsKey:  ctx.String("secrets-key"),
+				//
+				Store: ctx.String("store"),
+				//
+				LogMaxSize: ctx.Int("log-max-size"),
+				//
+				AllowedIncludes: ctx.StringSlice("allowed-include"),
 			}
 
 			s := server.New(cfg)
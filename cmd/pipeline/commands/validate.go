@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/go-idp/pipeline"
+	"github.com/go-zoox/cli"
+	"github.com/go-zoox/core-utils/fmt"
+)
+
+func RegisterValidate(app *cli.MultipleProgram) {
+	app.Register("validate", &cli.Command{
+		Name:  "validate",
+		Usage: "validate a pipeline config without running it",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				Aliases: []string{"c"},
+				Usage:   "Specifies the configuration",
+				EnvVars: []string{"PIPELINE_CONFIG"},
+				Value:   findConfig(),
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			config := ctx.String("config")
+			if ctx.Args().Present() {
+				config = ctx.Args().First()
+			}
+			if config == "" {
+				return fmt.Errorf("config is required")
+			}
+
+			if _, err := pipeline.ValidateConfig(config); err != nil {
+				errs, ok := err.(pipeline.ValidationErrors)
+				if !ok {
+					return err
+				}
+
+				for _, e := range errs {
+					fmt.Fprintf(os.Stderr, "%s:%s\n", config, e)
+				}
+
+				return fmt.Errorf("%d error(s) found in %s", len(errs), config)
+			}
+
+			fmt.Printf("%s is valid\n", config)
+			return nil
+		},
+	})
+}
@@ -14,6 +14,8 @@ func main() {
 	})
 
 	commands.RegisterRun(app)
+	commands.RegisterValidate(app)
+	commands.RegisterResume(app)
 
 	commands.RegisterServer(app)
 	commands.RegisterClient(app)
@@ -0,0 +1,366 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-idp/pipeline/expression"
+	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-zoox/logger"
+)
+
+// dagNode is a job in the dependency graph of the pipeline
+type dagNode struct {
+	Stage      *stage.Stage
+	StageIndex int
+	//
+	Job      *job.Job
+	JobIndex int
+	//
+	Needs []*dagNode
+}
+
+// Name returns the display name of the node, e.g. build/compile
+func (n *dagNode) Name() string {
+	return fmt.Sprintf("%s/%s", n.Stage.Name, n.Job.Name)
+}
+
+// dag is the dependency graph over all jobs of the pipeline
+type dag struct {
+	Nodes []*dagNode
+	//
+	stages []*stage.Stage
+}
+
+// hasNeeds returns true if any job of the pipeline declares needs
+func (p *Pipeline) hasNeeds() bool {
+	for _, s := range p.Stages {
+		for _, j := range s.Jobs {
+			if len(j.Needs) > 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// buildDAG builds the dependency graph of all jobs in the pipeline
+//
+//	jobs with needs depend on the named jobs only
+//	jobs without needs depend on every job of the previous stage,
+//	and on the previous job of the same stage when the stage runs serially
+func (p *Pipeline) buildDAG() (*dag, error) {
+	d := &dag{
+		stages: p.Stages,
+	}
+
+	byName := map[string][]*dagNode{}
+	// byMatrix is the jobs expanded from a matrix job, needs on the matrix job waits for all of them
+	byMatrix := map[string][]*dagNode{}
+	byStage := make([][]*dagNode, len(p.Stages))
+	for si, s := range p.Stages {
+		for ji, j := range s.Jobs {
+			n := &dagNode{
+				Stage:      s,
+				StageIndex: si,
+				Job:        j,
+				JobIndex:   ji,
+			}
+
+			d.Nodes = append(d.Nodes, n)
+			byName[j.Name] = append(byName[j.Name], n)
+			if origin := j.Origin(); origin != "" {
+				byMatrix[origin] = append(byMatrix[origin], n)
+			}
+			byStage[si] = append(byStage[si], n)
+		}
+	}
+
+	var previous []*dagNode
+	for si, s := range p.Stages {
+		for ji, n := range byStage[si] {
+			if len(n.Job.Needs) == 0 {
+				if s.RunMode == stage.RunModeSerial && ji > 0 {
+					n.Needs = []*dagNode{byStage[si][ji-1]}
+				} else {
+					n.Needs = previous
+				}
+				continue
+			}
+
+			for _, name := range n.Job.Needs {
+				if expanded, ok := byMatrix[name]; ok {
+					if _, conflict := byName[name]; conflict {
+						return nil, fmt.Errorf("[workflow][prepare] job(%s) needs ambiguous job(%s), job names must be unique when used in needs", n.Name(), name)
+					}
+
+					n.Needs = append(n.Needs, expanded...)
+					continue
+				}
+
+				candidates, ok := byName[name]
+				if !ok {
+					return nil, fmt.Errorf("[workflow][prepare] job(%s) needs unknown job(%s)", n.Name(), name)
+				}
+
+				if len(candidates) > 1 {
+					return nil, fmt.Errorf("[workflow][prepare] job(%s) needs ambiguous job(%s), job names must be unique when used in needs", n.Name(), name)
+				}
+
+				n.Needs = append(n.Needs, candidates[0])
+			}
+		}
+
+		if len(byStage[si]) > 0 {
+			previous = byStage[si]
+		}
+	}
+
+	if cycle := d.findCycle(); len(cycle) > 0 {
+		names := make([]string, len(cycle))
+		for i, n := range cycle {
+			names[i] = n.Name()
+		}
+
+		return nil, fmt.Errorf("[workflow][prepare] dependency cycle found between jobs: %s", strings.Join(names, " -> "))
+	}
+
+	return d, nil
+}
+
+// findCycle returns the nodes of the first cycle found, or nil if the graph is acyclic
+func (d *dag) findCycle() []*dagNode {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	marks := map[*dagNode]int{}
+	path := []*dagNode{}
+
+	var visit func(n *dagNode) []*dagNode
+	visit = func(n *dagNode) []*dagNode {
+		marks[n] = visiting
+		path = append(path, n)
+
+		for _, dep := range n.Needs {
+			switch marks[dep] {
+			case visiting:
+				for i, p := range path {
+					if p == dep {
+						return append(append([]*dagNode{}, path[i:]...), dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		marks[n] = visited
+		return nil
+	}
+
+	for _, n := range d.Nodes {
+		if marks[n] == unvisited {
+			if cycle := visit(n); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// Run runs every job as soon as all of its needs are done
+//
+//	a job whose needs failed or were skipped after a failure only runs if its if allows it, e.g. failure() or always()
+func (d *dag) Run(ctx context.Context, plog *logger.Logger) error {
+	// runCtx is cancelled on the first failure to stop the running jobs,
+	//	jobs started after a failure (e.g. if: failure()) run with ctx instead
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := map[*dagNode]chan struct{}{}
+	for _, n := range d.Nodes {
+		done[n] = make(chan struct{})
+	}
+
+	var mu sync.Mutex
+	var runErr error
+	// failed is true when the job or one of its ancestors failed
+	failed := map[*dagNode]bool{}
+	started := map[*stage.Stage]bool{}
+	// deadlines is the timeout of each stage, counted from the start of its first job
+	deadlines := map[*stage.Stage]time.Time{}
+	remaining := map[*stage.Stage]int{}
+	skipped := map[*stage.Stage]int{}
+	for _, n := range d.Nodes {
+		remaining[n.Stage]++
+	}
+
+	// stages without jobs have nothing to wait for
+	for _, s := range d.stages {
+		if len(s.Jobs) == 0 {
+			s.State.Status = "succeeded"
+			s.State.SucceedAt = time.Now()
+			s.Finish(nil)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, n := range d.Nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done[n])
+
+			upstreamFailed := false
+			for _, dep := range n.Needs {
+				<-done[dep]
+
+				mu.Lock()
+				upstreamFailed = upstreamFailed || failed[dep]
+				mu.Unlock()
+			}
+
+			// another branch failed before this job started
+			afterFailure := upstreamFailed || runCtx.Err() != nil
+
+			prefix := fmt.Sprintf("[stage(%d/%d): %s]", n.StageIndex+1, len(d.stages), n.Stage.Name)
+
+			timeout, err := d.runNode(ctx, runCtx, n, prefix, afterFailure, func() time.Time {
+				mu.Lock()
+				defer mu.Unlock()
+
+				if !started[n.Stage] {
+					started[n.Stage] = true
+					plog.Infof("%s start", prefix)
+					n.Stage.Start()
+					if n.Stage.Timeout > 0 {
+						plog.Infof("%s timeout: %d seconds", prefix, n.Stage.Timeout)
+						deadlines[n.Stage] = time.Now().Add(time.Duration(n.Stage.Timeout) * time.Second)
+					}
+				}
+
+				return deadlines[n.Stage]
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			remaining[n.Stage]--
+			if n.Job.State.Status == "skipped" {
+				skipped[n.Stage]++
+			}
+
+			if err != nil {
+				failed[n] = true
+				if runErr == nil {
+					runErr = err
+					cancel()
+				}
+
+				if n.Stage.State.Status != "failed" {
+					n.Stage.State.Status = "failed"
+					n.Stage.State.Error = err.Error()
+					n.Stage.State.FailedAt = time.Now()
+					// only the deadline of the stage itself is a timeout, not a cancel after another failure
+					if timeout {
+						n.Stage.State.Error = fmt.Sprintf("stage timeout after %d seconds: %s", n.Stage.Timeout, err.Error())
+					}
+				}
+			} else if afterFailure && n.Job.State.Status == "skipped" {
+				failed[n] = true
+			}
+
+			if remaining[n.Stage] == 0 && n.Stage.State.Status != "failed" {
+				if skipped[n.Stage] == len(n.Stage.Jobs) {
+					n.Stage.State.Status = "skipped"
+				} else {
+					n.Stage.State.Status = "succeeded"
+					n.Stage.State.SucceedAt = time.Now()
+				}
+			}
+
+			if remaining[n.Stage] == 0 && started[n.Stage] {
+				plog.Infof("%s done", prefix)
+			}
+
+			if remaining[n.Stage] == 0 {
+				n.Stage.Finish(nil)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return runErr
+}
+
+// runNode runs the job of the node, evaluating the if of its stage first
+//
+//	afterFailure is true when one of its needs failed or another job failed before it started
+//	onStart starts the stage and returns its deadline, zero if the stage has no timeout
+//	timeout is true when the job was stopped by the deadline of its stage
+func (d *dag) runNode(ctx, runCtx context.Context, n *dagNode, prefix string, afterFailure bool, onStart func() time.Time) (timeout bool, err error) {
+	runConfig := func(c *job.RunConfig) {
+		c.Total = len(n.Stage.Jobs)
+		c.Current = n.JobIndex + 1
+		c.Parent = prefix
+	}
+
+	// filtered and resumed jobs are done without starting their stage
+	if n.Job.Excluded() || n.Job.Restored() {
+		return false, n.Job.Run(ctx, runConfig)
+	}
+
+	failed := afterFailure
+	if n.Stage.If != "" {
+		ok, err := expression.Condition(n.Stage.If, &expression.Context{
+			Environment: n.Stage.Environment,
+			Failed:      failed,
+		})
+		if err != nil {
+			return false, fmt.Errorf("%s %s", prefix, err)
+		}
+
+		if !ok {
+			n.Job.Skip()
+			return false, nil
+		}
+
+		// the stage explicitly runs, its jobs decide for themselves from here
+		failed = false
+	}
+
+	jctx := runCtx
+	if afterFailure {
+		jctx = ctx
+	}
+
+	parent := jctx
+	if deadline := onStart(); !deadline.IsZero() {
+		var cancel context.CancelFunc
+		jctx, cancel = context.WithDeadline(parent, deadline)
+		defer cancel()
+	}
+
+	err = n.Job.Run(jctx, runConfig, func(c *job.RunConfig) {
+		c.Failed = failed
+	})
+	if err != nil && errors.Is(jctx.Err(), context.DeadlineExceeded) && parent.Err() == nil {
+		return true, err
+	}
+
+	return false, err
+}
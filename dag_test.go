@@ -0,0 +1,363 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-idp/pipeline/step"
+)
+
+func TestPipelineNeeds(t *testing.T) {
+	t.Run("job with needs should not wait for unrelated jobs of previous stage", func(t *testing.T) {
+		pipeline := &Pipeline{
+			Name:    "test pipeline needs",
+			Workdir: t.TempDir(),
+			Stages: []*stage.Stage{
+				{
+					Name: "build",
+					Jobs: []*job.Job{
+						{
+							Name: "slow",
+							Steps: []*step.Step{
+								{
+									Name: "wait for deploy",
+									// deploy (stage 2) must finish while this job is still running
+									Command: "for i in $(seq 1 50); do [ -f deploy.done ] && exit 0; sleep 0.1; done; exit 1",
+								},
+							},
+						},
+						{
+							Name: "fast",
+							Steps: []*step.Step{
+								{
+									Name:    "echo",
+									Command: "echo fast",
+								},
+							},
+						},
+					},
+				},
+				{
+					Name: "deploy",
+					Jobs: []*job.Job{
+						{
+							Name:  "deploy",
+							Needs: []string{"fast"},
+							Steps: []*step.Step{
+								{
+									Name:    "touch",
+									Command: "touch deploy.done",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		if err := pipeline.Run(context.Background()); err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+
+		if pipeline.State.Status != "succeeded" {
+			t.Errorf("Expected status 'succeeded', got '%s'", pipeline.State.Status)
+		}
+
+		for _, s := range pipeline.Stages {
+			if s.State.Status != "succeeded" {
+				t.Errorf("Expected stage(%s) status 'succeeded', got '%s'", s.Name, s.State.Status)
+			}
+		}
+	})
+
+	t.Run("job without needs should still wait for previous stage", func(t *testing.T) {
+		pipeline := &Pipeline{
+			Name:    "test pipeline needs stage order",
+			Workdir: t.TempDir(),
+			Stages: []*stage.Stage{
+				{
+					Name: "build",
+					Jobs: []*job.Job{
+						{
+							Name: "compile",
+							Steps: []*step.Step{
+								{
+									Name:    "touch",
+									Command: "sleep 0.5 && touch compile.done",
+								},
+							},
+						},
+					},
+				},
+				{
+					Name: "test",
+					Jobs: []*job.Job{
+						{
+							Name:  "lint",
+							Needs: []string{"compile"},
+							Steps: []*step.Step{
+								{
+									Name:    "echo",
+									Command: "echo lint",
+								},
+							},
+						},
+						{
+							Name: "unit",
+							Steps: []*step.Step{
+								{
+									Name:    "check",
+									Command: "test -f compile.done",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		if err := pipeline.Run(context.Background()); err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+	})
+
+	t.Run("failed job should stop its dependents", func(t *testing.T) {
+		pipeline := &Pipeline{
+			Name:    "test pipeline needs failure",
+			Workdir: t.TempDir(),
+			Stages: []*stage.Stage{
+				{
+					Name: "build",
+					Jobs: []*job.Job{
+						{
+							Name: "compile",
+							Steps: []*step.Step{
+								{
+									Name:    "fail",
+									Command: "exit 1",
+								},
+							},
+						},
+					},
+				},
+				{
+					Name: "deploy",
+					Jobs: []*job.Job{
+						{
+							Name:  "deploy",
+							Needs: []string{"compile"},
+							Steps: []*step.Step{
+								{
+									Name:    "echo",
+									Command: "echo deploy",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		if err := pipeline.Run(context.Background()); err == nil {
+			t.Fatal("Expected error, but got nil")
+		}
+
+		if pipeline.State.Status != "failed" {
+			t.Errorf("Expected status 'failed', got '%s'", pipeline.State.Status)
+		}
+
+		if pipeline.Stages[0].State.Status != "failed" {
+			t.Errorf("Expected stage(build) status 'failed', got '%s'", pipeline.Stages[0].State.Status)
+		}
+
+		if status := pipeline.Stages[1].Jobs[0].State.Status; status != "skipped" {
+			t.Errorf("Expected job(deploy) not to run, got status '%s'", status)
+		}
+	})
+	t.Run("needs on matrix job should wait for all of its combinations", func(t *testing.T) {
+		pipeline := &Pipeline{
+			Name:    "test pipeline needs matrix",
+			Workdir: t.TempDir(),
+			Stages: []*stage.Stage{
+				{
+					Name: "build",
+					Jobs: []*job.Job{
+						{
+							Name: "build",
+							Matrix: &job.Matrix{
+								Axes: map[string][]string{
+									"os": {"linux", "darwin"},
+								},
+							},
+							Steps: []*step.Step{
+								{
+									Name:    "touch",
+									Command: "sleep 0.2 && touch build-$MATRIX_OS.done",
+								},
+							},
+						},
+					},
+				},
+				{
+					Name: "release",
+					Jobs: []*job.Job{
+						{
+							Name:  "release",
+							Needs: []string{"build"},
+							Steps: []*step.Step{
+								{
+									Name:    "check",
+									Command: "test -f build-linux.done && test -f build-darwin.done",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		if err := pipeline.Run(context.Background()); err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+	})
+}
+
+func TestPipelineNeedsValidation(t *testing.T) {
+	newPipeline := func(needsA, needsB []string) *Pipeline {
+		return &Pipeline{
+			Name:    "test pipeline needs validation",
+			Workdir: t.TempDir(),
+			Stages: []*stage.Stage{
+				{
+					Name: "stage",
+					Jobs: []*job.Job{
+						{
+							Name:  "a",
+							Needs: needsA,
+							Steps: []*step.Step{{Name: "a", Command: "echo a"}},
+						},
+						{
+							Name:  "b",
+							Needs: needsB,
+							Steps: []*step.Step{{Name: "b", Command: "echo b"}},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("unknown job should be rejected", func(t *testing.T) {
+		err := newPipeline([]string{"missing"}, nil).Run(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "unknown job(missing)") {
+			t.Fatalf("Expected unknown job error, got: %v", err)
+		}
+	})
+
+	t.Run("cycle should be rejected", func(t *testing.T) {
+		err := newPipeline([]string{"b"}, []string{"a"}).Run(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "dependency cycle") {
+			t.Fatalf("Expected cycle error, got: %v", err)
+		}
+	})
+
+	t.Run("self dependency should be rejected", func(t *testing.T) {
+		err := newPipeline([]string{"a"}, nil).Run(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "stage/a -> stage/a") {
+			t.Fatalf("Expected cycle error, got: %v", err)
+		}
+	})
+}
+
+func TestPipelineNeedsStageTimeout(t *testing.T) {
+	t.Run("stage timeout should stop its jobs", func(t *testing.T) {
+		pipeline := &Pipeline{
+			Name:    "test pipeline needs stage timeout",
+			Workdir: t.TempDir(),
+			Stages: []*stage.Stage{
+				{
+					Name:    "build",
+					Timeout: 1,
+					Jobs: []*job.Job{
+						{
+							Name: "compile",
+							// the job and step timeouts are longer, only the stage should stop it
+							Timeout: 10,
+							Steps:   []*step.Step{{Name: "sleep", Command: "sleep 3", Timeout: 10}},
+						},
+						{
+							Name:  "lint",
+							Needs: []string{"compile"},
+							Steps: []*step.Step{{Name: "echo", Command: "echo lint"}},
+						},
+					},
+				},
+			},
+		}
+
+		start := time.Now()
+		if err := pipeline.Run(context.Background()); err == nil {
+			t.Fatal("Expected timeout error, but got nil")
+		}
+		if elapsed := time.Since(start); elapsed > 2500*time.Millisecond {
+			t.Errorf("Expected stage to stop after its timeout, took %s", elapsed)
+		}
+
+		s := pipeline.Stages[0]
+		if s.State.Status != "failed" {
+			t.Errorf("Expected stage status 'failed', got '%s'", s.State.Status)
+		}
+		if !strings.Contains(s.State.Error, "stage timeout after 1 seconds") {
+			t.Errorf("Expected stage timeout error, got: %s", s.State.Error)
+		}
+	})
+
+	t.Run("cancel after another failure should not be reported as timeout", func(t *testing.T) {
+		pipeline := &Pipeline{
+			Name:    "test pipeline needs cancel",
+			Workdir: t.TempDir(),
+			Stages: []*stage.Stage{
+				{
+					Name: "build",
+					Jobs: []*job.Job{
+						{
+							Name:  "prepare",
+							Steps: []*step.Step{{Name: "echo", Command: "echo prepare"}},
+						},
+						{
+							Name:  "broken",
+							Steps: []*step.Step{{Name: "fail", Command: "sleep 0.5 && exit 1"}},
+						},
+					},
+				},
+				{
+					Name:    "test",
+					Timeout: 60,
+					Jobs: []*job.Job{
+						{
+							Name:  "slow",
+							Needs: []string{"prepare"},
+							Steps: []*step.Step{{Name: "sleep", Command: "sleep 5"}},
+						},
+					},
+				},
+			},
+		}
+
+		if err := pipeline.Run(context.Background()); err == nil {
+			t.Fatal("Expected error, but got nil")
+		}
+
+		s := pipeline.Stages[1]
+		if s.State.Status != "failed" {
+			t.Errorf("Expected stage status 'failed', got '%s'", s.State.Status)
+		}
+		if strings.Contains(s.State.Error, "stage timeout") {
+			t.Errorf("Expected no stage timeout error, got: %s", s.State.Error)
+		}
+	})
+}
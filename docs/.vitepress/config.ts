@@ -44,6 +44,8 @@ export default defineConfig({
               items: [
                 { text: 'Overview', link: '/commands/' },
                 { text: 'run', link: '/commands/run' },
+                { text: 'validate', link: '/commands/validate' },
+                { text: 'resume', link: '/commands/resume' },
                 { text: 'server', link: '/commands/server' },
                 { text: 'client', link: '/commands/client' },
               ],
@@ -112,6 +114,8 @@ export default defineConfig({
               items: [
                 { text: '命令概述', link: '/zh/commands/' },
                 { text: 'run 命令', link: '/zh/commands/run' },
+                { text: 'validate 命令', link: '/zh/commands/validate' },
+                { text: 'resume 命令', link: '/zh/commands/resume' },
                 { text: 'server 命令', link: '/zh/commands/server' },
                 { text: 'client 命令', link: '/zh/commands/client' },
               ],
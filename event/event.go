@@ -0,0 +1,132 @@
+package event
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Type is the type of the event
+type Type string
+
+const (
+	PipelineStarted  Type = "pipeline.started"
+	PipelineFinished Type = "pipeline.finished"
+	StageStarted     Type = "stage.started"
+	StageFinished    Type = "stage.finished"
+	JobStarted       Type = "job.started"
+	JobFinished      Type = "job.finished"
+	StepStarted      Type = "step.started"
+	StepFinished     Type = "step.finished"
+	// StepOutput is a line of the stdout or stderr of the step
+	StepOutput Type = "step.output"
+)
+
+// Event is an event of the lifecycle of the pipeline, its stages, jobs and steps
+type Event struct {
+	Type Type `json:"type"`
+	// ID is the State.ID of the pipeline, stage, job or step, e.g. <pipeline>.0.1 is the second job of the first stage
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Status and Error are the outcome of the finished events, e.g. succeeded, failed, failed_allowed, skipped
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Stream (stdout | stderr) and Data are the output line of StepOutput
+	Stream string `json:"stream,omitempty"`
+	Data   string `json:"data,omitempty"`
+	// Outputs are the outputs written to $PIPELINE_OUTPUT, of StepFinished
+	Outputs map[string]string `json:"outputs,omitempty"`
+	// ExitCode, Signal and OOMKilled are the exit of the failed step of the finished events, e.g. 137, SIGKILL
+	ExitCode  int    `json:"exit_code,omitempty"`
+	Signal    string `json:"signal,omitempty"`
+	OOMKilled bool   `json:"oom_killed,omitempty"`
+	// Duration is the duration of the unit of the finished events, unit: second
+	Duration float64 `json:"duration,omitempty"`
+	//
+	Time time.Time `json:"time"`
+}
+
+// New creates an event of the unit
+func New(typ Type, id, name string) *Event {
+	return &Event{
+		Type: typ,
+		ID:   id,
+		Name: name,
+		Time: time.Now(),
+	}
+}
+
+// Finish sets the outcome of the unit from its state,
+//
+//	err is the error returned by the unit, a unit failed by it before its state is updated is failed
+func (e *Event) Finish(status, message string, err error) *Event {
+	e.Status = status
+	e.Error = message
+
+	if err != nil {
+		if e.Status == "" || e.Status == "pending" || e.Status == "running" {
+			e.Status = "failed"
+		}
+
+		if e.Error == "" {
+			e.Error = err.Error()
+		}
+	}
+
+	return e
+}
+
+// SetExit sets the exit and the duration of a finished unit
+func (e *Event) SetExit(code int, signal string, oomKilled bool, duration float64) *Event {
+	e.ExitCode = code
+	e.Signal = signal
+	e.OOMKilled = oomKilled
+	e.Duration = duration
+	return e
+}
+
+// Sink receives the events, Emit is called by the running units concurrently and should not block
+type Sink interface {
+	Emit(e *Event)
+}
+
+// SinkFunc is a function as a sink
+type SinkFunc func(e *Event)
+
+// Emit calls the function
+func (f SinkFunc) Emit(e *Event) {
+	f(e)
+}
+
+// Multi returns a sink emitting the events to all the sinks, nil sinks are ignored
+func Multi(sinks ...Sink) Sink {
+	return SinkFunc(func(e *Event) {
+		for _, s := range sinks {
+			if s != nil {
+				s.Emit(e)
+			}
+		}
+	})
+}
+
+// JSONWriter writes the events as JSON lines
+type JSONWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONWriter creates a sink writing the events as JSON lines to w
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{
+		enc: json.NewEncoder(w),
+	}
+}
+
+// Emit writes the event as a JSON line
+func (w *JSONWriter) Emit(e *Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.enc.Encode(e)
+}
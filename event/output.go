@@ -0,0 +1,63 @@
+package event
+
+import (
+	"bytes"
+	"sync"
+)
+
+// OutputWriter emits the output of a step as StepOutput events, line by line
+type OutputWriter struct {
+	mu     sync.Mutex
+	sink   Sink
+	id     string
+	name   string
+	stream string
+	buf    []byte
+}
+
+// NewOutputWriter creates a writer emitting the lines of the stream (stdout | stderr) of the step
+func NewOutputWriter(sink Sink, id, name, stream string) *OutputWriter {
+	return &OutputWriter{
+		sink:   sink,
+		id:     id,
+		name:   name,
+		stream: stream,
+	}
+}
+
+// Write emits the complete lines, the incomplete last line is held until the next write or Flush
+func (w *OutputWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.emit(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Flush emits the held incomplete line
+func (w *OutputWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.emit(string(w.buf))
+		w.buf = nil
+	}
+}
+
+func (w *OutputWriter) emit(line string) {
+	e := New(StepOutput, w.id, w.name)
+	e.Stream = w.stream
+	e.Data = line
+	w.sink.Emit(e)
+}
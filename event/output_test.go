@@ -0,0 +1,39 @@
+package event
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOutputWriter(t *testing.T) {
+	var lines []string
+	w := NewOutputWriter(SinkFunc(func(e *Event) {
+		lines = append(lines, e.Stream+": "+e.Data)
+	}), "run.0.0.0", "build", "stdout")
+
+	for _, chunk := range []string{"hel", "lo\nwor", "ld\n", "last"} {
+		w.Write([]byte(chunk))
+	}
+
+	if got := strings.Join(lines, "|"); got != "stdout: hello|stdout: world" {
+		t.Errorf("Expected the complete lines, got %s", got)
+	}
+
+	w.Flush()
+	if got := strings.Join(lines, "|"); got != "stdout: hello|stdout: world|stdout: last" {
+		t.Errorf("Expected the held line after flush, got %s", got)
+	}
+}
+
+func TestEventFinish(t *testing.T) {
+	e := New(StageFinished, "run.0", "build").Finish("running", "", errors.New("invalid if"))
+	if e.Status != "failed" || e.Error != "invalid if" {
+		t.Errorf("Expected the unit failed by the error, got %s: %s", e.Status, e.Error)
+	}
+
+	e = New(StepFinished, "run.0.0.0", "lint").Finish("failed_allowed", "exit status 1", nil)
+	if e.Status != "failed_allowed" || e.Error != "exit status 1" {
+		t.Errorf("Expected the state of the unit, got %s: %s", e.Status, e.Error)
+	}
+}
@@ -0,0 +1,44 @@
+package pipeline
+
+import (
+	"github.com/go-idp/pipeline/event"
+)
+
+// EventSink receives the events of the pipeline, its stages, jobs and steps, see package event
+type EventSink = event.Sink
+
+// SetEventSink sets the sink of the events of the pipeline, e.g. event.NewJSONWriter(file) for JSON lines
+func (p *Pipeline) SetEventSink(sink EventSink) *Pipeline {
+	p.events = sink
+	return p
+}
+
+// eventSink returns the sink of the stages, nil if the pipeline has no sink
+func (p *Pipeline) eventSink() event.Sink {
+	if p.events == nil {
+		return nil
+	}
+
+	return event.SinkFunc(p.emit)
+}
+
+// emit emits the event to the sink, the secrets are masked in the event
+func (p *Pipeline) emit(e *event.Event) {
+	if p.events == nil {
+		return
+	}
+
+	m := p.getMasker()
+	e.Error = m.Mask(e.Error)
+	e.Data = m.Mask(e.Data)
+	if len(e.Outputs) > 0 {
+		// the outputs are the state of the step, never changed
+		outputs := make(map[string]string, len(e.Outputs))
+		for k, v := range e.Outputs {
+			outputs[k] = m.Mask(v)
+		}
+		e.Outputs = outputs
+	}
+
+	p.events.Emit(e)
+}
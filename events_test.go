@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-idp/pipeline/event"
+)
+
+func TestPipelineEvents(t *testing.T) {
+	t.Setenv("PIPELINE_SECRET_EVENTS_TOKEN", "s3cr3t-token")
+
+	p, err := LoadYAML([]byte(`
+name: test pipeline events
+stages:
+  - name: build
+    jobs:
+      - name: build
+        steps:
+          - name: print
+            command: |
+              echo hello
+              echo "token=${{ secrets.EVENTS_TOKEN }}"
+              echo "version=1.0.0" >> $PIPELINE_OUTPUT
+          - name: skipped
+            if: failure()
+            command: echo skipped
+`), "")
+	if err != nil {
+		t.Fatalf("Failed to load pipeline: %v", err)
+	}
+
+	var mu sync.Mutex
+	var events []*event.Event
+	p.SetStdout(&bytes.Buffer{})
+	p.SetEventSink(event.SinkFunc(func(e *event.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}))
+
+	if err := p.Run(context.Background(), func(cfg *RunConfig) {
+		cfg.ID = "run"
+	}); err != nil {
+		t.Fatalf("Failed to run pipeline: %v", err)
+	}
+
+	got := []string{}
+	for _, e := range events {
+		if e.Type == event.StepOutput {
+			got = append(got, string(e.Type)+" "+e.ID+" "+e.Data)
+			continue
+		}
+
+		got = append(got, strings.TrimSpace(string(e.Type)+" "+e.ID+" "+e.Status))
+	}
+
+	expected := []string{
+		"pipeline.started run",
+		"stage.started run.0",
+		"job.started run.0.0",
+		"step.started run.0.0.0",
+		"step.output run.0.0.0 hello",
+		"step.output run.0.0.0 token=******",
+		"step.finished run.0.0.0 succeeded",
+		"step.finished run.0.0.1 skipped",
+		"job.finished run.0.0 succeeded",
+		"stage.finished run.0 succeeded",
+		"pipeline.finished run succeeded",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected events:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	for _, e := range events {
+		if e.Type == event.StepFinished && e.Name == "print" && e.Outputs["version"] != "1.0.0" {
+			t.Errorf("Expected the outputs of the step in step.finished, got %v", e.Outputs)
+		}
+	}
+}
+
+func TestPipelineEventsFailed(t *testing.T) {
+	p, err := LoadYAML([]byte(`
+name: test pipeline events
+stages:
+  - name: build
+    jobs:
+      - name: build
+        steps:
+          - name: fail
+            command: exit 3
+`), "")
+	if err != nil {
+		t.Fatalf("Failed to load pipeline: %v", err)
+	}
+
+	var out bytes.Buffer
+	p.SetStdout(&bytes.Buffer{})
+	p.SetEventSink(event.NewJSONWriter(&out))
+
+	if err := p.Run(context.Background()); err == nil {
+		t.Fatalf("Expected the pipeline to fail")
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	var last event.Event
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+
+	if last.Type != event.PipelineFinished || last.Status != "failed" || last.Error == "" {
+		t.Errorf("Expected a failed pipeline.finished event, got %+v", last)
+	}
+
+	failed := 0
+	for _, line := range lines {
+		var e event.Event
+		json.Unmarshal([]byte(line), &e)
+		if strings.HasSuffix(string(e.Type), ".finished") && e.Status == "failed" {
+			failed++
+		}
+	}
+	if failed != 4 {
+		t.Errorf("Expected the step, job, stage and pipeline to fail, got %d failed events:\n%s", failed, out.String())
+	}
+}
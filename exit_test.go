@@ -0,0 +1,53 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestPipelineExitCode(t *testing.T) {
+	p, err := LoadYAML([]byte(`
+name: test pipeline exit code
+stages:
+  - name: build
+    jobs:
+      - name: lint
+        allow_failure: true
+        steps:
+          - name: lint
+            command: exit 1
+      - name: build
+        steps:
+          - name: compile
+            command: echo compile
+          - name: test
+            command: exit 3
+`), "")
+	if err != nil {
+		t.Fatalf("Failed to load pipeline: %v", err)
+	}
+	p.SetStdout(&bytes.Buffer{})
+	p.SetStderr(&bytes.Buffer{})
+
+	if err := p.Run(context.Background()); err == nil {
+		t.Fatal("Expected error, but got nil")
+	}
+
+	s := p.Stages[0]
+	if code := s.Jobs[1].Steps[1].State.ExitCode; code != 3 {
+		t.Errorf("Expected step exit code 3, got %d", code)
+	}
+	if code := s.Jobs[1].State.ExitCode; code != 3 {
+		t.Errorf("Expected job exit code 3, got %d", code)
+	}
+	if code := s.State.ExitCode; code != 3 {
+		t.Errorf("Expected stage exit code 3, got %d", code)
+	}
+	if p.State.ExitCode != 3 {
+		t.Errorf("Expected pipeline exit code 3, got %d", p.State.ExitCode)
+	}
+	if p.State.Duration <= 0 || s.State.Duration <= 0 {
+		t.Errorf("Expected durations, got pipeline %f, stage %f", p.State.Duration, s.State.Duration)
+	}
+}
@@ -0,0 +1,245 @@
+package expression
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Context is the context an expression is evaluated against
+type Context struct {
+	// Environment is the environment variables, read by env.NAME, $NAME or ${NAME}
+	Environment map[string]string
+
+	// Failed is true when a previous step, job or stage has failed
+	Failed bool
+}
+
+// Expression is a parsed expression, e.g. env.BRANCH == 'main' && success()
+type Expression struct {
+	raw  string
+	root node
+}
+
+// Parse parses the expression
+func Parse(raw string) (*Expression, error) {
+	tokens, err := lex(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression(%s): %s", raw, err)
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression(%s): %s", raw, err)
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("invalid expression(%s): unexpected %s at position %d", raw, t.value, t.pos)
+	}
+
+	return &Expression{raw: raw, root: root}, nil
+}
+
+// String returns the raw expression
+func (e *Expression) String() string {
+	return e.raw
+}
+
+// Evaluate evaluates the expression and returns its value
+func (e *Expression) Evaluate(ctx *Context) (any, error) {
+	if ctx == nil {
+		ctx = &Context{}
+	}
+
+	v, err := e.root.eval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate expression(%s): %s", e.raw, err)
+	}
+
+	return v, nil
+}
+
+// Condition evaluates the expression as a condition
+//
+//	an expression without success(), failure() or always() is implicitly
+//	combined with success(), so it never runs after a failure
+//	an empty expression is the same as success()
+func Condition(raw string, ctx *Context) (bool, error) {
+	if ctx == nil {
+		ctx = &Context{}
+	}
+
+	if raw == "" {
+		return !ctx.Failed, nil
+	}
+
+	e, err := Parse(raw)
+	if err != nil {
+		return false, err
+	}
+
+	v, err := e.Evaluate(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if !hasCall(e.root, "success", "failure", "always") && ctx.Failed {
+		return false, nil
+	}
+
+	return truthy(v), nil
+}
+
+func (n *literalNode) eval(ctx *Context) (any, error) {
+	return n.value, nil
+}
+
+func (n *variableNode) eval(ctx *Context) (any, error) {
+	if v, ok := ctx.Environment[n.name]; ok {
+		return v, nil
+	}
+
+	return "", nil
+}
+
+func (n *notNode) eval(ctx *Context) (any, error) {
+	v, err := n.operand.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return !truthy(v), nil
+}
+
+func (n *binaryNode) eval(ctx *Context) (any, error) {
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// short circuit
+	switch n.operator {
+	case "&&":
+		if !truthy(left) {
+			return false, nil
+		}
+	case "||":
+		if truthy(left) {
+			return true, nil
+		}
+	}
+
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.operator {
+	case "&&", "||":
+		return truthy(right), nil
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	}
+
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("operator %s requires numbers, got %q and %q", n.operator, toString(left), toString(right))
+	}
+
+	switch n.operator {
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	}
+
+	return nil, fmt.Errorf("unsupported operator %s", n.operator)
+}
+
+func (n *callNode) eval(ctx *Context) (any, error) {
+	fn, ok := functions[n.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s()", n.name)
+	}
+
+	args := make([]any, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	return fn(ctx, args...)
+}
+
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	}
+
+	return true
+}
+
+func equal(a, b any) bool {
+	if x, ok := a.(float64); ok {
+		if y, ok := toNumber(b); ok {
+			return x == y
+		}
+	}
+
+	if y, ok := b.(float64); ok {
+		if x, ok := toNumber(a); ok {
+			return x == y
+		}
+	}
+
+	return toString(a) == toString(b)
+}
+
+func toNumber(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case string:
+		f, err := strconv.ParseFloat(x, 64)
+		return f, err == nil
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+func toString(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+
+	return fmt.Sprintf("%v", v)
+}
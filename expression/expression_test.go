@@ -0,0 +1,68 @@
+package expression
+
+import (
+	"testing"
+)
+
+func TestCondition(t *testing.T) {
+	env := map[string]string{
+		"BRANCH":        "main",
+		"PIPELINE_NAME": "release",
+		"COUNT":         "3",
+	}
+
+	cases := []struct {
+		expr   string
+		failed bool
+		want   bool
+	}{
+		{"", false, true},
+		{"", true, false},
+		{"env.BRANCH == 'main'", false, true},
+		{"env.BRANCH == 'main'", true, false},
+		{"$BRANCH != 'main'", false, false},
+		{"${PIPELINE_NAME} == \"release\"", false, true},
+		{"env.MISSING", false, false},
+		{"!env.MISSING", false, true},
+		{"env.COUNT > 2 && env.COUNT <= 3", false, true},
+		{"env.COUNT == 3", false, true},
+		{"startsWith(env.BRANCH, 'ma') || contains(env.BRANCH, 'x')", false, true},
+		{"endsWith(env.BRANCH, 'x')", false, false},
+		{"success()", false, true},
+		{"success()", true, false},
+		{"failure()", false, false},
+		{"failure()", true, true},
+		{"always()", true, true},
+		{"failure() && env.BRANCH == 'main'", true, true},
+		{"(failure() || success()) && env.BRANCH == 'dev'", true, false},
+	}
+
+	for _, c := range cases {
+		got, err := Condition(c.expr, &Context{Environment: env, Failed: c.failed})
+		if err != nil {
+			t.Fatalf("Condition(%q) error: %v", c.expr, err)
+		}
+
+		if got != c.want {
+			t.Errorf("Condition(%q, failed=%v) = %v, want %v", c.expr, c.failed, got, c.want)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	cases := []string{
+		"env.BRANCH ==",
+		"(success()",
+		"'unterminated",
+		"BRANCH == 'main'",
+		"unknown()",
+		"success() success()",
+		"env.A = 'b'",
+	}
+
+	for _, c := range cases {
+		if _, err := Parse(c); err == nil {
+			t.Errorf("Parse(%q) expected error, got nil", c)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package expression
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Function is a function callable from expressions
+type Function func(ctx *Context, args ...any) (any, error)
+
+var functions = map[string]Function{
+	// success returns true when nothing has failed before
+	"success": func(ctx *Context, args ...any) (any, error) {
+		return !ctx.Failed, nil
+	},
+	// failure returns true when a previous step, job or stage has failed
+	"failure": func(ctx *Context, args ...any) (any, error) {
+		return ctx.Failed, nil
+	},
+	// always returns true, whatever happened before
+	"always": func(ctx *Context, args ...any) (any, error) {
+		return true, nil
+	},
+	// contains(haystack, needle)
+	"contains": stringFunction("contains", strings.Contains),
+	// startsWith(s, prefix)
+	"startsWith": stringFunction("startsWith", strings.HasPrefix),
+	// endsWith(s, suffix)
+	"endsWith": stringFunction("endsWith", strings.HasSuffix),
+}
+
+func stringFunction(name string, fn func(s, sub string) bool) Function {
+	return func(ctx *Context, args ...any) (any, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s() expects 2 arguments, got %d", name, len(args))
+		}
+
+		return fn(toString(args[0]), toString(args[1])), nil
+	}
+}
@@ -0,0 +1,123 @@
+package expression
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenVariable
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// operators is ordered so that two-char operators are matched first
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!"}
+
+func lex(input string) ([]token, error) {
+	tokens := []token{}
+
+	for i := 0; i < len(input); {
+		c := input[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, value: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, value: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, value: ",", pos: i})
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(input[i+1:], c)
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+
+			tokens = append(tokens, token{kind: tokenString, value: input[i+1 : i+1+end], pos: i})
+			i += end + 2
+		case c == '$':
+			// $NAME or ${NAME}
+			start := i
+			i++
+			braced := i < len(input) && input[i] == '{'
+			if braced {
+				i++
+			}
+
+			nameStart := i
+			for i < len(input) && isIdentChar(input[i]) {
+				i++
+			}
+
+			name := input[nameStart:i]
+			if name == "" {
+				return nil, fmt.Errorf("invalid variable at position %d", start)
+			}
+
+			if braced {
+				if i >= len(input) || input[i] != '}' {
+					return nil, fmt.Errorf("unterminated variable at position %d", start)
+				}
+				i++
+			}
+
+			tokens = append(tokens, token{kind: tokenVariable, value: name, pos: start})
+		case unicode.IsDigit(rune(c)) || (c == '-' && i+1 < len(input) && unicode.IsDigit(rune(input[i+1]))):
+			start := i
+			i++
+			for i < len(input) && (unicode.IsDigit(rune(input[i])) || input[i] == '.') {
+				i++
+			}
+
+			tokens = append(tokens, token{kind: tokenNumber, value: input[start:i], pos: start})
+		case isIdentChar(c):
+			start := i
+			for i < len(input) && (isIdentChar(input[i]) || input[i] == '.' || input[i] == '-') {
+				i++
+			}
+
+			tokens = append(tokens, token{kind: tokenIdent, value: input[start:i], pos: start})
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(input[i:], op) {
+					tokens = append(tokens, token{kind: tokenOperator, value: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(input)})
+	return tokens, nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,249 @@
+package expression
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// node is a node of the expression syntax tree
+type node interface {
+	eval(ctx *Context) (any, error)
+}
+
+type literalNode struct {
+	value any
+}
+
+type variableNode struct {
+	name string
+}
+
+type notNode struct {
+	operand node
+}
+
+type binaryNode struct {
+	operator    string
+	left, right node
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOperator(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return false
+	}
+
+	for _, op := range ops {
+		if t.value == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expression := or
+func (p *parser) parseExpression() (node, error) {
+	return p.parseOr()
+}
+
+// or := and ( "||" and )*
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("||") {
+		op := p.next().value
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{operator: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// and := unary ( "&&" unary )*
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("&&") {
+		op := p.next().value
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{operator: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// unary := "!" unary | comparison
+func (p *parser) parseUnary() (node, error) {
+	if p.isOperator("!") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &notNode{operand: operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+// comparison := primary ( ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) primary )?
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isOperator("==", "!=", "<", "<=", ">", ">=") {
+		op := p.next().value
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &binaryNode{operator: op, left: left, right: right}, nil
+	}
+
+	return left, nil
+}
+
+// primary := literal | variable | call | "(" expression ")"
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenString:
+		return &literalNode{value: t.value}, nil
+	case tokenNumber:
+		v, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at position %d", t.value, t.pos)
+		}
+		return &literalNode{value: v}, nil
+	case tokenVariable:
+		return &variableNode{name: t.value}, nil
+	case tokenLParen:
+		n, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.next().kind != tokenRParen {
+			return nil, fmt.Errorf("missing ) for ( at position %d", t.pos)
+		}
+
+		return n, nil
+	case tokenIdent:
+		switch t.value {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+
+		// env.NAME
+		if strings.HasPrefix(t.value, "env.") {
+			return &variableNode{name: strings.TrimPrefix(t.value, "env.")}, nil
+		}
+
+		if p.peek().kind != tokenLParen {
+			return nil, fmt.Errorf("unknown identifier %s at position %d, use env.%s to read an environment variable", t.value, t.pos, t.value)
+		}
+
+		if _, ok := functions[t.value]; !ok {
+			return nil, fmt.Errorf("unknown function %s() at position %d", t.value, t.pos)
+		}
+
+		p.next()
+		call := &callNode{name: t.value}
+		if p.peek().kind == tokenRParen {
+			p.next()
+			return call, nil
+		}
+
+		for {
+			arg, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+
+			sep := p.next()
+			if sep.kind == tokenRParen {
+				return call, nil
+			}
+
+			if sep.kind != tokenComma {
+				return nil, fmt.Errorf("expected , or ) in call of %s() at position %d", t.value, sep.pos)
+			}
+		}
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	return nil, fmt.Errorf("unexpected %s at position %d", t.value, t.pos)
+}
+
+// hasCall reports whether the tree calls any of the given functions
+func hasCall(n node, names ...string) bool {
+	switch v := n.(type) {
+	case *notNode:
+		return hasCall(v.operand, names...)
+	case *binaryNode:
+		return hasCall(v.left, names...) || hasCall(v.right, names...)
+	case *callNode:
+		for _, name := range names {
+			if v.name == name {
+				return true
+			}
+		}
+
+		for _, arg := range v.args {
+			if hasCall(arg, names...) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,241 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Filter selects the units of the stages to run, the others are skipped,
+//
+//	selectors are paths of names, e.g. build (stage), build/test (job), build/test/lint (step),
+//	jobs are selected by their names before the matrix expansion,
+//	the hooks (on_success, on_failure, finally) are not filtered
+type Filter struct {
+	// Only are the selectors of the units to run, the others are skipped
+	Only []string `json:"only" yaml:"only"`
+	// Skip are the selectors of the units to skip
+	Skip []string `json:"skip" yaml:"skip"`
+	// From is the name of the first stage to run, the stages before it are skipped
+	From string `json:"from" yaml:"from"`
+	// Until is the name of the last stage to run, the stages after it are skipped
+	Until string `json:"until" yaml:"until"`
+}
+
+// IsEmpty returns true if the filter selects everything
+func (f *Filter) IsEmpty() bool {
+	return f == nil || (len(f.Only) == 0 && len(f.Skip) == 0 && f.From == "" && f.Until == "")
+}
+
+// selector is a parsed selector, e.g. [build test lint]
+type selector []string
+
+func parseSelector(text string) (selector, error) {
+	parts := strings.Split(strings.Trim(text, "/"), "/")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("invalid selector %s, expected stage, stage/job or stage/job/step", text)
+	}
+
+	for _, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("invalid selector %s, expected stage, stage/job or stage/job/step", text)
+		}
+	}
+
+	return parts, nil
+}
+
+// matches returns true if the selector selects the path or one of its ancestors
+func (s selector) matches(path ...string) bool {
+	if len(s) > len(path) {
+		return false
+	}
+
+	for i := range s {
+		if s[i] != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseSelectors(texts []string) ([]selector, error) {
+	selectors := []selector{}
+	for _, text := range texts {
+		s, err := parseSelector(text)
+		if err != nil {
+			return nil, err
+		}
+
+		selectors = append(selectors, s)
+	}
+
+	return selectors, nil
+}
+
+// applyFilter excludes the units of the stages not selected by the filter,
+//
+//	a job is excluded when all of its steps are, a stage when all of its jobs are,
+//	the selected jobs can not need or depend on the excluded jobs
+func (p *Pipeline) applyFilter(f *Filter) error {
+	if f.IsEmpty() {
+		return nil
+	}
+
+	only, err := parseSelectors(f.Only)
+	if err != nil {
+		return fmt.Errorf("[workflow][filter] only: %s", err)
+	}
+
+	skip, err := parseSelectors(f.Skip)
+	if err != nil {
+		return fmt.Errorf("[workflow][filter] skip: %s", err)
+	}
+
+	for _, group := range []struct {
+		name      string
+		selectors []selector
+		texts     []string
+	}{
+		{"only", only, f.Only},
+		{"skip", skip, f.Skip},
+	} {
+		for i, s := range group.selectors {
+			if !p.selects(s) {
+				return fmt.Errorf("[workflow][filter] %s: no stage, job or step matches %s", group.name, group.texts[i])
+			}
+		}
+	}
+
+	from, until := 0, len(p.Stages)-1
+	if f.From != "" {
+		if from = p.stageIndex(f.From); from == -1 {
+			return fmt.Errorf("[workflow][filter] from: unknown stage(%s)", f.From)
+		}
+	}
+	if f.Until != "" {
+		if until = p.stageIndex(f.Until); until == -1 {
+			return fmt.Errorf("[workflow][filter] until: unknown stage(%s)", f.Until)
+		}
+	}
+	if from > until {
+		return fmt.Errorf("[workflow][filter] stage(%s) of from is after stage(%s) of until", f.From, f.Until)
+	}
+
+	selected := func(path ...string) bool {
+		for _, s := range skip {
+			if s.matches(path...) {
+				return false
+			}
+		}
+
+		if len(only) == 0 {
+			return true
+		}
+
+		for _, s := range only {
+			if s.matches(path...) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	excluded := 0
+	for si, s := range p.Stages {
+		if si < from || si > until {
+			s.Exclude()
+			excluded++
+			continue
+		}
+
+		stageExcluded := true
+		for _, j := range s.Jobs {
+			jobExcluded := true
+			for _, st := range j.Steps {
+				if selected(s.Name, j.Name, st.Name) {
+					jobExcluded = false
+				} else {
+					st.Exclude()
+				}
+			}
+
+			if jobExcluded {
+				j.Exclude()
+			} else {
+				stageExcluded = false
+			}
+		}
+
+		if stageExcluded {
+			s.Exclude()
+			excluded++
+		}
+	}
+
+	if excluded == len(p.Stages) {
+		return fmt.Errorf("[workflow][filter] all stages are skipped")
+	}
+
+	return p.validateFilter()
+}
+
+// selects returns true if the selector matches any unit of the stages
+func (p *Pipeline) selects(s selector) bool {
+	for _, st := range p.Stages {
+		for _, j := range st.Jobs {
+			for _, step := range j.Steps {
+				if s.matches(st.Name, j.Name, step.Name) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func (p *Pipeline) stageIndex(name string) int {
+	for i, s := range p.Stages {
+		if s.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// validateFilter checks the selected jobs do not need or depend on the excluded jobs
+func (p *Pipeline) validateFilter() error {
+	excluded := map[string]bool{}
+	for _, s := range p.Stages {
+		for _, j := range s.Jobs {
+			if j.Excluded() {
+				excluded[j.Name] = true
+			}
+		}
+	}
+
+	for _, s := range p.Stages {
+		for _, j := range s.Jobs {
+			if j.Excluded() {
+				continue
+			}
+
+			for _, name := range j.Needs {
+				if excluded[name] {
+					return fmt.Errorf("[workflow][filter] job(%s) needs job(%s), which is skipped by the filter", j.Name, name)
+				}
+			}
+
+			for _, name := range j.Dependencies {
+				if excluded[name] {
+					return fmt.Errorf("[workflow][filter] job(%s) depends on job(%s), which is skipped by the filter", j.Name, name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-idp/pipeline/step"
+)
+
+func newFilterPipeline(t *testing.T) *Pipeline {
+	newJob := func(name string, steps ...string) *job.Job {
+		j := &job.Job{Name: name}
+		for _, s := range steps {
+			j.Steps = append(j.Steps, &step.Step{Name: s, Command: "echo " + s})
+		}
+		return j
+	}
+
+	p := &Pipeline{
+		Name:    "test pipeline filter",
+		Workdir: t.TempDir(),
+		Stages: []*stage.Stage{
+			{Name: "build", Jobs: []*job.Job{newJob("compile", "a", "b"), newJob("lint", "lint")}},
+			{Name: "test", Jobs: []*job.Job{newJob("unit", "unit")}},
+			{Name: "deploy", Jobs: []*job.Job{newJob("ship", "ship")}},
+		},
+	}
+	p.SetStdout(&bytes.Buffer{})
+
+	return p
+}
+
+func TestPipelineFilter(t *testing.T) {
+	status := func(p *Pipeline) map[string]string {
+		statuses := map[string]string{}
+		for _, s := range p.Stages {
+			statuses[s.Name] = s.State.Status
+			for _, j := range s.Jobs {
+				statuses[s.Name+"/"+j.Name] = j.State.Status
+				for _, st := range j.Steps {
+					statuses[s.Name+"/"+j.Name+"/"+st.Name] = st.State.Status
+				}
+			}
+		}
+		return statuses
+	}
+
+	cases := []struct {
+		name     string
+		filter   *Filter
+		expected map[string]string
+	}{
+		{
+			name:   "only selects a step, its job and stage run partially",
+			filter: &Filter{Only: []string{"build/compile/b"}},
+			expected: map[string]string{
+				"build":           "succeeded",
+				"build/compile":   "succeeded",
+				"build/compile/a": "skipped",
+				"build/compile/b": "succeeded",
+				"build/lint":      "skipped",
+				"test":            "skipped",
+				"deploy":          "skipped",
+			},
+		},
+		{
+			name:   "skip excludes a job",
+			filter: &Filter{Skip: []string{"build/lint"}},
+			expected: map[string]string{
+				"build":         "succeeded",
+				"build/compile": "succeeded",
+				"build/lint":    "skipped",
+				"test":          "succeeded",
+				"deploy":        "succeeded",
+			},
+		},
+		{
+			name:   "from and until select a range of stages",
+			filter: &Filter{From: "test", Until: "test"},
+			expected: map[string]string{
+				"build":       "skipped",
+				"build/lint":  "skipped",
+				"test":        "succeeded",
+				"test/unit":   "succeeded",
+				"deploy":      "skipped",
+				"deploy/ship": "skipped",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := newFilterPipeline(t)
+			if err := p.Run(context.Background(), func(cfg *RunConfig) {
+				cfg.Filter = c.filter
+			}); err != nil {
+				t.Fatalf("Failed to run pipeline: %v", err)
+			}
+
+			statuses := status(p)
+			for unit, expected := range c.expected {
+				if statuses[unit] != expected {
+					t.Errorf("Expected %s to be %s, got %s", unit, expected, statuses[unit])
+				}
+			}
+		})
+	}
+}
+
+func TestPipelineFilterErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		filter   *Filter
+		setup    func(p *Pipeline)
+		expected string
+	}{
+		{
+			name:     "unknown selector",
+			filter:   &Filter{Only: []string{"build/unknown"}},
+			expected: "only: no stage, job or step matches build/unknown",
+		},
+		{
+			name:     "invalid selector",
+			filter:   &Filter{Skip: []string{"build//a"}},
+			expected: "skip: invalid selector build//a",
+		},
+		{
+			name:     "unknown stage of from",
+			filter:   &Filter{From: "release"},
+			expected: "from: unknown stage(release)",
+		},
+		{
+			name:     "from after until",
+			filter:   &Filter{From: "deploy", Until: "build"},
+			expected: "stage(deploy) of from is after stage(build) of until",
+		},
+		{
+			name:     "all stages skipped",
+			filter:   &Filter{Skip: []string{"build", "test", "deploy"}},
+			expected: "all stages are skipped",
+		},
+		{
+			name:   "needs on a skipped job",
+			filter: &Filter{Only: []string{"deploy"}},
+			setup: func(p *Pipeline) {
+				p.Stages[2].Jobs[0].Needs = []string{"unit"}
+			},
+			expected: "job(ship) needs job(unit), which is skipped by the filter",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := newFilterPipeline(t)
+			if c.setup != nil {
+				c.setup(p)
+			}
+
+			err := p.Run(context.Background(), func(cfg *RunConfig) {
+				cfg.Filter = c.filter
+			})
+			if err == nil {
+				t.Fatalf("Expected error containing %q", c.expected)
+			}
+			if !strings.Contains(err.Error(), c.expected) {
+				t.Errorf("Expected error containing %q, got %v", c.expected, err)
+			}
+		})
+	}
+}
+
+func TestPipelineFilterExtends(t *testing.T) {
+	// the steps of compile are inherited from the template
+	newPipeline := func() *Pipeline {
+		p := newFilterPipeline(t)
+		p.Templates = &Templates{
+			Jobs: map[string]*job.Job{
+				"gojob": {Steps: []*step.Step{{Name: "a", Command: "echo a"}, {Name: "b", Command: "echo b"}}},
+			},
+		}
+		p.Stages[0].Jobs[0] = &job.Job{Name: "compile", Extends: "gojob"}
+
+		return p
+	}
+
+	cases := []struct {
+		name     string
+		filter   *Filter
+		expected map[string]bool
+	}{
+		{
+			name:     "skip another job",
+			filter:   &Filter{Skip: []string{"build/lint"}},
+			expected: map[string]bool{"build/compile/a": false, "build/compile/b": false},
+		},
+		{
+			name:     "only the job with extends",
+			filter:   &Filter{Only: []string{"build/compile"}},
+			expected: map[string]bool{"build/compile/a": false, "build/compile/b": false},
+		},
+		{
+			name:     "only an inherited step",
+			filter:   &Filter{Only: []string{"build/compile/b"}},
+			expected: map[string]bool{"build/compile/a": true, "build/compile/b": false},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := newPipeline()
+			if err := p.Run(context.Background(), func(cfg *RunConfig) {
+				cfg.Filter = c.filter
+			}); err != nil {
+				t.Fatalf("Failed to run pipeline: %v", err)
+			}
+
+			for _, st := range p.Stages[0].Jobs[0].Steps {
+				if skipped := st.State.Status == "skipped"; skipped != c.expected["build/compile/"+st.Name] {
+					t.Errorf("Expected build/compile/%s skipped=%v, got %s", st.Name, c.expected["build/compile/"+st.Name], st.State.Status)
+				}
+			}
+
+			plan, err := newPipeline().Plan(func(cfg *RunConfig) {
+				cfg.Filter = c.filter
+			})
+			if err != nil {
+				t.Fatalf("Failed to plan pipeline: %v", err)
+			}
+
+			steps := plan.Stages[0].Jobs[0].Steps
+			if len(steps) != 2 {
+				t.Fatalf("Expected 2 planned steps of compile, got %d", len(steps))
+			}
+			for _, st := range steps {
+				if st.Skipped != c.expected["build/compile/"+st.Name] {
+					t.Errorf("Expected planned build/compile/%s skipped=%v, got %v", st.Name, c.expected["build/compile/"+st.Name], st.Skipped)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-zoox/logger"
+)
+
+// hook is a group of stages run after the stages of the pipeline
+type hook struct {
+	Name   string
+	Stages []*stage.Stage
+}
+
+// hooks returns the hooks to run for the outcome of the pipeline, in order
+//
+//	on_success or on_failure first, then finally (including post)
+func (p *Pipeline) hooks(failed bool) []*hook {
+	hooks := []*hook{}
+	if failed {
+		hooks = append(hooks, &hook{Name: "on_failure", Stages: p.OnFailure})
+	} else {
+		hooks = append(hooks, &hook{Name: "on_success", Stages: p.OnSuccess})
+	}
+
+	return append(hooks, &hook{Name: "finally", Stages: p.Finally})
+}
+
+// allStages returns the stages of the pipeline and its hooks
+func (p *Pipeline) allStages() []*stage.Stage {
+	stages := append([]*stage.Stage{}, p.Stages...)
+	stages = append(stages, p.OnSuccess...)
+	stages = append(stages, p.OnFailure...)
+	return append(stages, p.Finally...)
+}
+
+// runHooks runs the hooks of the pipeline, runErr is the error of the stages
+//
+//	every hook stage runs regardless of the outcome of the others,
+//	and receives the outcome of the pipeline by environment variables:
+//	  PIPELINE_STATUS: succeeded | failed
+//	  PIPELINE_ERROR: the error of the pipeline
+//	  PIPELINE_FAILED_STAGE, PIPELINE_FAILED_JOB, PIPELINE_FAILED_STEP: where the pipeline failed
+func (p *Pipeline) runHooks(ctx context.Context, plog *logger.Logger, runErr error) []error {
+	env := map[string]string{
+		"PIPELINE_STATUS":       "succeeded",
+		"PIPELINE_ERROR":        "",
+		"PIPELINE_FAILED_STAGE": "",
+		"PIPELINE_FAILED_JOB":   "",
+		"PIPELINE_FAILED_STEP":  "",
+	}
+	if runErr != nil {
+		env["PIPELINE_STATUS"] = "failed"
+		env["PIPELINE_ERROR"] = runErr.Error()
+		env["PIPELINE_FAILED_STAGE"], env["PIPELINE_FAILED_JOB"], env["PIPELINE_FAILED_STEP"] = p.failedAt()
+	}
+
+	errs := []error{}
+	for _, h := range p.hooks(runErr != nil) {
+		if len(h.Stages) == 0 {
+			continue
+		}
+
+		plog.Infof("[workflow][%s] start", h.Name)
+		for i, s := range h.Stages {
+			setEnvironment(s, env)
+
+			err := s.Run(ctx, func(cfg *stage.RunConfig) {
+				cfg.Total = len(h.Stages)
+				cfg.Current = i + 1
+				cfg.Parent = fmt.Sprintf("[%s]", h.Name)
+			})
+			if err != nil {
+				plog.Errorf("[workflow][%s] error: %s", h.Name, err)
+				errs = append(errs, fmt.Errorf("[%s] %s", h.Name, err))
+			}
+		}
+		plog.Infof("[workflow][%s] done", h.Name)
+	}
+
+	return errs
+}
+
+// failedAt returns the names of the first failed stage, job and step
+func (p *Pipeline) failedAt() (stageName, jobName, stepName string) {
+	for _, s := range p.Stages {
+		if s.State == nil || s.State.Status != "failed" {
+			continue
+		}
+
+		for _, j := range s.Jobs {
+			if j.State == nil || j.State.Status != "failed" {
+				continue
+			}
+
+			for _, st := range j.Steps {
+				if st.State != nil && st.State.Status == "failed" {
+					return s.Name, j.Name, st.Name
+				}
+			}
+
+			return s.Name, j.Name, ""
+		}
+
+		return s.Name, "", ""
+	}
+
+	return "", "", ""
+}
+
+// setEnvironment sets the environment variables of the stage, its jobs and steps
+func setEnvironment(s *stage.Stage, env map[string]string) {
+	set := func(target map[string]string) {
+		for k, v := range env {
+			target[k] = v
+		}
+	}
+
+	if s.Environment != nil {
+		set(s.Environment)
+	}
+
+	for _, j := range s.Jobs {
+		if j.Environment != nil {
+			set(j.Environment)
+		}
+
+		for _, st := range j.Steps {
+			if st.Environment != nil {
+				set(st.Environment)
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-idp/pipeline/step"
+	"github.com/go-zoox/fs"
+)
+
+// newHookStage returns a stage running the command in a single job and step
+func newHookStage(name, command string) *stage.Stage {
+	return &stage.Stage{
+		Name: name,
+		Jobs: []*job.Job{
+			{
+				Name: name,
+				Steps: []*step.Step{
+					{
+						Name:    name,
+						Command: command,
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestPipelineHooks(t *testing.T) {
+	t.Run("finally and on_failure should run after failure", func(t *testing.T) {
+		workdir := t.TempDir()
+		pipeline := &Pipeline{
+			Name:    "test pipeline hooks failure",
+			Workdir: workdir,
+			Stages: []*stage.Stage{
+				newHookStage("build", "exit 3"),
+			},
+			OnSuccess: []*stage.Stage{
+				newHookStage("success", "touch success.done"),
+			},
+			OnFailure: []*stage.Stage{
+				newHookStage("failure", `echo "$PIPELINE_STATUS $PIPELINE_FAILED_STAGE/$PIPELINE_FAILED_JOB/$PIPELINE_FAILED_STEP" > failure.txt`),
+			},
+			Finally: []*stage.Stage{
+				newHookStage("cleanup", "touch cleanup.done"),
+			},
+			Post: "touch post.done",
+		}
+
+		err := pipeline.Run(context.Background())
+		if err == nil {
+			t.Fatal("Expected error, but got nil")
+		}
+
+		if strings.Contains(err.Error(), "[finally]") || strings.Contains(err.Error(), "[on_failure]") {
+			t.Errorf("Expected the error of the stages, got: %v", err)
+		}
+
+		if pipeline.State.Status != "failed" {
+			t.Errorf("Expected status 'failed', got '%s'", pipeline.State.Status)
+		}
+
+		if fs.IsExist(filepath.Join(workdir, "success.done")) {
+			t.Error("Expected on_success not to run")
+		}
+
+		data, rerr := os.ReadFile(filepath.Join(workdir, "failure.txt"))
+		if rerr != nil {
+			t.Fatalf("Expected on_failure to run: %v", rerr)
+		}
+		if got := strings.TrimSpace(string(data)); got != "failed build/build/build" {
+			t.Errorf("Expected failure reason 'failed build/build/build', got '%s'", got)
+		}
+
+		for _, file := range []string{"cleanup.done", "post.done"} {
+			if !fs.IsExist(filepath.Join(workdir, file)) {
+				t.Errorf("Expected %s to exist", file)
+			}
+		}
+	})
+
+	t.Run("on_success should run after success", func(t *testing.T) {
+		workdir := t.TempDir()
+		pipeline := &Pipeline{
+			Name:    "test pipeline hooks success",
+			Workdir: workdir,
+			Stages: []*stage.Stage{
+				newHookStage("build", "echo build"),
+			},
+			OnSuccess: []*stage.Stage{
+				newHookStage("success", `test "$PIPELINE_STATUS" = succeeded`),
+			},
+			OnFailure: []*stage.Stage{
+				newHookStage("failure", "exit 1"),
+			},
+		}
+
+		if err := pipeline.Run(context.Background()); err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+
+		if status := pipeline.OnSuccess[0].State.Status; status != "succeeded" {
+			t.Errorf("Expected on_success status 'succeeded', got '%s'", status)
+		}
+
+		if status := pipeline.OnFailure[0].State.Status; status != "running" {
+			t.Errorf("Expected on_failure not to run, got status '%s'", status)
+		}
+	})
+
+	t.Run("failed finally should fail a succeeded pipeline", func(t *testing.T) {
+		pipeline := &Pipeline{
+			Name:    "test pipeline hooks finally error",
+			Workdir: t.TempDir(),
+			Stages: []*stage.Stage{
+				newHookStage("build", "echo build"),
+			},
+			Finally: []*stage.Stage{
+				newHookStage("cleanup", "exit 1"),
+				newHookStage("cleanup2", "echo cleanup2"),
+			},
+		}
+
+		err := pipeline.Run(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "[finally]") {
+			t.Fatalf("Expected finally error, got: %v", err)
+		}
+
+		if pipeline.State.Status != "failed" {
+			t.Errorf("Expected status 'failed', got '%s'", pipeline.State.Status)
+		}
+
+		if status := pipeline.Finally[1].State.Status; status != "succeeded" {
+			t.Errorf("Expected the next finally stage to run, got status '%s'", status)
+		}
+	})
+
+	t.Run("failed finally should not mask the original error", func(t *testing.T) {
+		pipeline := &Pipeline{
+			Name:    "test pipeline hooks masking",
+			Workdir: t.TempDir(),
+			Stages: []*stage.Stage{
+				newHookStage("build", "exit 3"),
+			},
+			Finally: []*stage.Stage{
+				newHookStage("cleanup", "exit 1"),
+			},
+		}
+
+		err := pipeline.Run(context.Background())
+		if err == nil || strings.Contains(err.Error(), "[finally]") {
+			t.Fatalf("Expected the error of the stages, got: %v", err)
+		}
+
+		if len(pipeline.State.HookErrors) != 1 || !strings.Contains(pipeline.State.HookErrors[0], "[finally]") {
+			t.Errorf("Expected the finally error to be reported, got %v", pipeline.State.HookErrors)
+		}
+	})
+
+	t.Run("finally should run after timeout", func(t *testing.T) {
+		workdir := t.TempDir()
+		pipeline := &Pipeline{
+			Name:    "test pipeline hooks timeout",
+			Workdir: workdir,
+			Timeout: 1,
+			Stages: []*stage.Stage{
+				newHookStage("build", "sleep 3"),
+			},
+			Finally: []*stage.Stage{
+				{
+					Name:    "cleanup",
+					Timeout: 10,
+					Jobs: []*job.Job{
+						{
+							Name: "cleanup",
+							Steps: []*step.Step{
+								{
+									Name:    "cleanup",
+									Command: "touch cleanup.done",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		if err := pipeline.Run(context.Background()); err == nil {
+			t.Fatal("Expected error, but got nil")
+		}
+
+		if !fs.IsExist(filepath.Join(workdir, "cleanup.done")) {
+			t.Error("Expected finally to run after timeout")
+		}
+	})
+}
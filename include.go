@@ -0,0 +1,238 @@
+package pipeline
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/go-zoox/encoding/yaml"
+	"github.com/go-zoox/fetch"
+)
+
+// LoadConfig is the config of loading the pipeline from yaml
+type LoadConfig struct {
+	// RestrictIncludes only allows the includes starting with one of AllowedIncludes, e.g. https://example.com/templates/,
+	//	the includes of the included configs too, e.g. for configs submitted to the server,
+	//	so that the clients can not make the server fetch any url
+	RestrictIncludes bool
+	AllowedIncludes  []string
+}
+
+type LoadOption func(cfg *LoadConfig)
+
+// allow returns an error if the include is not allowed
+func (cfg *LoadConfig) allow(target string) error {
+	if !cfg.RestrictIncludes {
+		return nil
+	}
+
+	for _, prefix := range cfg.AllowedIncludes {
+		if prefix != "" && strings.HasPrefix(target, prefix) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("include(%s) is not allowed, only the allowed includes of the server can be used", target)
+}
+
+// Load loads the pipeline from the config, a local path or an http(s) url, with its includes merged
+func Load(config string) (*Pipeline, error) {
+	data, err := readConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadYAML(data, config, []string{config}, &LoadConfig{})
+}
+
+// LoadYAML loads the pipeline from the yaml, with its includes merged,
+//
+//	base is the path or url of the config that relative includes are resolved against,
+//	empty base only allows http(s) includes, e.g. for configs submitted to the server
+func LoadYAML(data []byte, base string, opts ...LoadOption) (*Pipeline, error) {
+	cfg := &LoadConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+
+	chain := []string{}
+	if base != "" {
+		chain = append(chain, base)
+	}
+
+	return loadYAML(data, base, chain, cfg)
+}
+
+func loadYAML(data []byte, base string, chain []string, cfg *LoadConfig) (*Pipeline, error) {
+	p := &Pipeline{}
+	if err := yaml.Decode(data, p); err != nil {
+		return nil, fmt.Errorf("failed to parse config(%s): %s", describe(base), err)
+	}
+
+	if err := p.include(base, chain, cfg); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// include merges the includes into the pipeline, includes can include others, cycles are rejected
+//
+//	the later includes win over the earlier ones, and the pipeline wins over all of them
+func (p *Pipeline) include(base string, chain []string, cfg *LoadConfig) error {
+	includes := p.Include
+	p.Include = nil
+
+	for i := len(includes) - 1; i >= 0; i-- {
+		target, err := resolveInclude(base, includes[i])
+		if err != nil {
+			return err
+		}
+
+		if err := cfg.allow(target); err != nil {
+			return err
+		}
+
+		for _, c := range chain {
+			if c == target {
+				return fmt.Errorf("include cycle: %s -> %s", strings.Join(chain, " -> "), target)
+			}
+		}
+
+		data, err := readConfig(target)
+		if err != nil {
+			return fmt.Errorf("failed to include %s: %s", includes[i], err)
+		}
+
+		included, err := loadYAML(data, target, append(append([]string{}, chain...), target), cfg)
+		if err != nil {
+			return err
+		}
+
+		p.merge(included)
+	}
+
+	return nil
+}
+
+// merge merges the included pipeline, the fields set in the pipeline win,
+//
+//	environment and templates are merged by key,
+//	the stages and hooks of the included pipeline come before those of the pipeline
+func (p *Pipeline) merge(included *Pipeline) {
+	if p.Name == "" {
+		p.Name = included.Name
+	}
+	if p.Workdir == "" {
+		p.Workdir = included.Workdir
+	}
+	if p.Image == "" {
+		p.Image = included.Image
+	}
+	if p.Timeout == 0 {
+		p.Timeout = included.Timeout
+	}
+	if p.Pre == "" {
+		p.Pre = included.Pre
+	}
+	if p.Post == "" {
+		p.Post = included.Post
+	}
+
+	if len(included.Environment) > 0 {
+		p.SetEnvironment(included.Environment)
+	}
+
+	if included.Templates != nil {
+		if p.Templates == nil {
+			p.Templates = &Templates{}
+		}
+
+		inherit(reflect.ValueOf(p.Templates).Elem(), reflect.ValueOf(included.Templates).Elem())
+	}
+
+	// parameters are defined once, by the including config or the first include defining them
+	defined := map[string]bool{}
+	for _, pa := range p.Parameters {
+		defined[pa.Name] = true
+	}
+	for _, pa := range included.Parameters {
+		if !defined[pa.Name] {
+			p.Parameters = append(p.Parameters, pa)
+		}
+	}
+
+	p.Stages = append(included.Stages, p.Stages...)
+	p.OnSuccess = append(included.OnSuccess, p.OnSuccess...)
+	p.OnFailure = append(included.OnFailure, p.OnFailure...)
+	p.Finally = append(included.Finally, p.Finally...)
+}
+
+func isURL(config string) bool {
+	return strings.HasPrefix(config, "http://") || strings.HasPrefix(config, "https://")
+}
+
+// resolveInclude returns the path or url of the include relative to the config
+func resolveInclude(base, include string) (string, error) {
+	if isURL(include) {
+		return include, nil
+	}
+
+	if isURL(base) {
+		b, err := url.Parse(base)
+		if err != nil {
+			return "", fmt.Errorf("invalid url %s: %s", base, err)
+		}
+
+		r, err := url.Parse(include)
+		if err != nil {
+			return "", fmt.Errorf("invalid include %s: %s", include, err)
+		}
+
+		return b.ResolveReference(r).String(), nil
+	}
+
+	if base == "" {
+		return "", fmt.Errorf("local include(%s) is not allowed, only http(s) urls", include)
+	}
+
+	if filepath.IsAbs(include) {
+		return include, nil
+	}
+
+	return filepath.Join(filepath.Dir(base), include), nil
+}
+
+// readConfig reads the config from a local path or an http(s) url
+func readConfig(config string) ([]byte, error) {
+	if !isURL(config) {
+		data, err := os.ReadFile(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config(file: %s): %s", config, err)
+		}
+
+		return data, nil
+	}
+
+	response, err := fetch.Get(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config(url: %s): %s", config, err)
+	}
+
+	if response.Status >= 400 {
+		return nil, fmt.Errorf("failed to fetch config(url: %s): status %d", config, response.Status)
+	}
+
+	return response.Body, nil
+}
+
+func describe(base string) string {
+	if base == "" {
+		return "yaml"
+	}
+
+	return base
+}
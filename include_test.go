@@ -0,0 +1,223 @@
+package pipeline
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("should merge local includes, the including config wins", func(t *testing.T) {
+		dir := t.TempDir()
+		writeConfig(t, dir, "templates/common.yaml", `
+include:
+  - base.yaml
+environment:
+  REGISTRY: ghcr.io
+  TAG: common
+templates:
+  steps:
+    go:
+      image: golang:1.22
+stages:
+  - name: checkout
+    jobs:
+      - name: checkout
+        steps:
+          - name: clone
+            command: git clone repo .
+`)
+		writeConfig(t, dir, "templates/base.yaml", `
+image: alpine
+environment:
+  TAG: base
+  BASE: "true"
+`)
+		config := writeConfig(t, dir, ".pipeline.yaml", `
+name: test load
+include:
+  - ./templates/common.yaml
+environment:
+  TAG: main
+stages:
+  - name: build
+    jobs:
+      - name: build
+        steps:
+          - name: build
+            extends: go
+            command: go build
+`)
+
+		p, err := Load(config)
+		if err != nil {
+			t.Fatalf("Failed to load pipeline: %v", err)
+		}
+
+		if len(p.Stages) != 2 || p.Stages[0].Name != "checkout" || p.Stages[1].Name != "build" {
+			t.Errorf("Expected the included stages before the own stages, got %d stages", len(p.Stages))
+		}
+		if p.Environment["TAG"] != "main" || p.Environment["REGISTRY"] != "ghcr.io" || p.Environment["BASE"] != "true" {
+			t.Errorf("Expected the environment to be merged, got %v", p.Environment)
+		}
+		if p.Image != "alpine" {
+			t.Errorf("Expected image of the nested include, got %s", p.Image)
+		}
+		if p.Templates == nil || p.Templates.Steps["go"] == nil {
+			t.Errorf("Expected the templates of the include")
+		}
+		if len(p.Include) != 0 {
+			t.Errorf("Expected includes to be resolved, got %v", p.Include)
+		}
+	})
+
+	t.Run("should reject include cycles", func(t *testing.T) {
+		dir := t.TempDir()
+		writeConfig(t, dir, "a.yaml", "include: [b.yaml]\n")
+		writeConfig(t, dir, "b.yaml", "include: [a.yaml]\n")
+		config := writeConfig(t, dir, ".pipeline.yaml", "name: test\ninclude: [a.yaml]\n")
+
+		_, err := Load(config)
+		if err == nil || !strings.Contains(err.Error(), "include cycle") {
+			t.Errorf("Expected include cycle error, got %v", err)
+		}
+	})
+
+	t.Run("should include urls, relative to the url of the config", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/ci/pipeline.yaml":
+				w.Write([]byte("name: remote\ninclude: [stages.yaml]\n"))
+			case "/ci/stages.yaml":
+				w.Write([]byte("stages:\n  - name: remote\n    jobs:\n      - name: remote\n        steps:\n          - name: remote\n            command: echo remote\n"))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		p, err := Load(server.URL + "/ci/pipeline.yaml")
+		if err != nil {
+			t.Fatalf("Failed to load pipeline: %v", err)
+		}
+		if len(p.Stages) != 1 || p.Stages[0].Name != "remote" {
+			t.Errorf("Expected the stages of the remote include")
+		}
+
+		if _, err := LoadYAML([]byte("name: x\ninclude: ["+server.URL+"/missing.yaml]\n"), ""); err == nil {
+			t.Errorf("Expected missing include to fail")
+		}
+	})
+
+	t.Run("restricted includes should only allow the allowed urls", func(t *testing.T) {
+		requests := []string{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.URL.Path)
+			switch r.URL.Path {
+			case "/templates/common.yaml":
+				w.Write([]byte("include: [../internal/secret.yaml]\n"))
+			default:
+				w.Write([]byte("image: alpine\n"))
+			}
+		}))
+		defer server.Close()
+
+		restrict := func(allowed ...string) LoadOption {
+			return func(cfg *LoadConfig) {
+				cfg.RestrictIncludes = true
+				cfg.AllowedIncludes = allowed
+			}
+		}
+
+		cases := []struct {
+			name     string
+			include  string
+			allowed  []string
+			expected string
+			fetched  []string
+		}{
+			{"no allowed includes", "/templates/base.yaml", nil, "include(" + server.URL + "/templates/base.yaml) is not allowed", []string{}},
+			{"allowed include", "/templates/base.yaml", []string{server.URL + "/templates/"}, "", []string{"/templates/base.yaml"}},
+			{"not allowed url", "/internal/base.yaml", []string{server.URL + "/templates/"}, "is not allowed", []string{}},
+			{"not allowed nested include", "/templates/common.yaml", []string{server.URL + "/templates/"}, "include(" + server.URL + "/internal/secret.yaml) is not allowed", []string{"/templates/common.yaml"}},
+		}
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				requests = []string{}
+				config := []byte("name: x\ninclude: [" + server.URL + c.include + "]\n")
+
+				_, err := LoadYAML(config, "", restrict(c.allowed...))
+				_, verr := ValidateYAML(config, restrict(c.allowed...))
+				for _, err := range []error{err, verr} {
+					if c.expected == "" && err != nil && strings.Contains(err.Error(), "include") {
+						t.Errorf("Expected the include to be allowed, got %v", err)
+					}
+					if c.expected != "" && (err == nil || !strings.Contains(err.Error(), c.expected)) {
+						t.Errorf("Expected error containing %q, got %v", c.expected, err)
+					}
+				}
+
+				if len(requests) != 2*len(c.fetched) {
+					t.Errorf("Expected requests to %v only, got %v", c.fetched, requests)
+				}
+			})
+		}
+	})
+
+	t.Run("local includes should not be allowed without base", func(t *testing.T) {
+		_, err := LoadYAML([]byte("name: x\ninclude: [/etc/passwd]\n"), "")
+		if err == nil || !strings.Contains(err.Error(), "local include(/etc/passwd) is not allowed") {
+			t.Errorf("Expected local include error, got %v", err)
+		}
+	})
+}
+
+func TestValidateConfigIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "common.yaml", `
+templates:
+  jobs:
+    build:
+      steps:
+        - name: build
+          command: make
+`)
+	config := writeConfig(t, dir, ".pipeline.yaml", `
+name: test validate includes
+include: [common.yaml]
+stages:
+  - name: build
+    jobs:
+      - name: build
+        extends: build
+      - name: test
+        extends: test
+`)
+
+	_, err := ValidateConfig(config)
+	if err == nil {
+		t.Fatalf("Expected unknown template error")
+	}
+
+	errs := err.(ValidationErrors)
+	if len(errs) != 1 || errs[0].Path != "stages[0].jobs[1].extends" || errs[0].Message != "unknown job template test" {
+		t.Errorf("Expected one unknown template error, got %v", err)
+	}
+}
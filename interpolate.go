@@ -0,0 +1,286 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-idp/pipeline/interpolate"
+	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-idp/pipeline/step"
+)
+
+// runtimeVariables are set by the runner after prepare, their references are resolved at run time
+var runtimeVariables = map[string]bool{
+	"PIPELINE_OUTPUT":       true,
+	"PIPELINE_STATUS":       true,
+	"PIPELINE_ERROR":        true,
+	"PIPELINE_FAILED_STAGE": true,
+	"PIPELINE_FAILED_JOB":   true,
+	"PIPELINE_FAILED_STEP":  true,
+}
+
+// isRuntimeVariable returns true if the variable is only known at run time, e.g. PIPELINE_STATUS of hooks
+func isRuntimeVariable(name string) bool {
+	return runtimeVariables[name] ||
+		strings.HasPrefix(name, "PIPELINE_PLUGIN_") ||
+		strings.HasPrefix(name, "PIPELINE_SERVICE_")
+}
+
+// scope is the resolved environment and workdir of a level of the pipeline
+type scope struct {
+	parent      *scope
+	path        string
+	environment map[string]string
+	workdir     string
+	// contexts resolve ${{ context.key }}, e.g. secrets.TOKEN
+	contexts map[string]interpolate.Context
+}
+
+func (s *scope) lookup(name string) (string, bool) {
+	for sc := s; sc != nil; sc = sc.parent {
+		if v, ok := sc.environment[name]; ok {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+func (s *scope) options(strict bool) *interpolate.Options {
+	return &interpolate.Options{
+		Lookup:    s.lookup,
+		Deferred:  isRuntimeVariable,
+		Functions: interpolate.Functions(s.workdir),
+		Contexts:  s.contexts,
+		Strict:    strict,
+	}
+}
+
+// child resolves the environment and workdir of a nested level, in place,
+//
+//	the functions of them are called in the workdir of the parent
+func (s *scope) child(path string, environment map[string]string, workdir *string) (*scope, error) {
+	c := &scope{
+		parent:   s,
+		path:     path,
+		workdir:  s.workdir,
+		contexts: s.contexts,
+	}
+
+	resolved, name, err := interpolate.Environment(environment, s.lookup, *s.options(true))
+	if err != nil {
+		return nil, c.error(fmt.Sprintf("environment.%s", name), err)
+	}
+	for k, v := range resolved {
+		environment[k] = v
+	}
+	c.environment = environment
+
+	if workdir != nil {
+		if err := c.expand("workdir", workdir); err != nil {
+			return nil, err
+		}
+
+		if *workdir != "" {
+			c.workdir = *workdir
+		}
+	}
+
+	return c, nil
+}
+
+func (s *scope) error(field string, err error) error {
+	path := field
+	if s.path != "" {
+		path = s.path + "." + field
+	}
+
+	return fmt.Errorf("[workflow][prepare] %s: %s", path, err)
+}
+
+// expand resolves the field in place, undefined variables are errors
+func (s *scope) expand(field string, text *string) error {
+	value, err := interpolate.Expand(*text, s.options(true))
+	if err != nil {
+		return s.error(field, err)
+	}
+
+	*text = value
+	return nil
+}
+
+// command resolves the expressions of the command in place, e.g. ${{ secrets.TOKEN }},
+//
+//	the variables are left to the shell, which runs with the environment
+func (s *scope) command(field string, text *string) error {
+	opts := s.options(false)
+	opts.Expressions = true
+
+	value, err := interpolate.Expand(*text, opts)
+	if err != nil {
+		return s.error(field, err)
+	}
+
+	*text = value
+	return nil
+}
+
+// interpolate resolves the variables of the pipeline before the stages are set up,
+//
+//	errors are reported with the yaml path, e.g. stages[0].jobs[1].steps[0].image: undefined variable TAG
+func (p *Pipeline) interpolate() error {
+	root, err := (&scope{
+		workdir: p.Workdir,
+		contexts: map[string]interpolate.Context{
+			"secrets": p.secret,
+		},
+	}).child("", p.Environment, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := root.expand("image", &p.Image); err != nil {
+		return err
+	}
+
+	if err := root.command("pre", &p.Pre); err != nil {
+		return err
+	}
+
+	if err := root.command("post", &p.Post); err != nil {
+		return err
+	}
+
+	for _, group := range []struct {
+		path   string
+		stages []*stage.Stage
+	}{
+		{"stages", p.Stages},
+		{"on_success", p.OnSuccess},
+		{"on_failure", p.OnFailure},
+		{"finally", p.Finally},
+	} {
+		for index, s := range group.stages {
+			if err := root.interpolateStage(fmt.Sprintf("%s[%d]", group.path, index), s); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *scope) interpolateStage(path string, st *stage.Stage) error {
+	if st.Environment == nil {
+		st.Environment = map[string]string{}
+	}
+
+	sc, err := s.child(path, st.Environment, &st.Workdir)
+	if err != nil {
+		return err
+	}
+
+	if err := sc.expand("image", &st.Image); err != nil {
+		return err
+	}
+
+	// matrix jobs are expanded first, so that MATRIX_* can be referenced
+	jobs := []*job.Job{}
+	for index, j := range st.Jobs {
+		expanded, err := j.Expand()
+		if err != nil {
+			return sc.error(fmt.Sprintf("jobs[%d]", index), err)
+		}
+
+		for _, mj := range expanded {
+			if err := sc.interpolateJob(fmt.Sprintf("%s.jobs[%d]", path, index), mj); err != nil {
+				return err
+			}
+		}
+
+		jobs = append(jobs, expanded...)
+	}
+	st.Jobs = jobs
+
+	return nil
+}
+
+func (s *scope) interpolateJob(path string, j *job.Job) error {
+	if j.Environment == nil {
+		j.Environment = map[string]string{}
+	}
+
+	sc, err := s.child(path, j.Environment, &j.Workdir)
+	if err != nil {
+		return err
+	}
+
+	if err := sc.expand("image", &j.Image); err != nil {
+		return err
+	}
+
+	if err := sc.registry(&j.ImageRegistryUsername, &j.ImageRegistryPassword, ""); err != nil {
+		return err
+	}
+
+	for index, st := range j.Steps {
+		if err := sc.interpolateStep(fmt.Sprintf("%s.steps[%d]", path, index), st); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *scope) interpolateStep(path string, st *step.Step) error {
+	if st.Environment == nil {
+		st.Environment = map[string]string{}
+	}
+
+	sc, err := s.child(path, st.Environment, &st.Workdir)
+	if err != nil {
+		return err
+	}
+
+	if err := sc.command("command", &st.Command); err != nil {
+		return err
+	}
+
+	if err := sc.expand("image", &st.Image); err != nil {
+		return err
+	}
+
+	if err := sc.registry(&st.ImageRegistryUsername, &st.ImageRegistryPassword, ""); err != nil {
+		return err
+	}
+
+	if st.Plugin != nil {
+		if err := sc.expand("plugin.image", &st.Plugin.Image); err != nil {
+			return err
+		}
+
+		if err := sc.registry(&st.Plugin.ImageRegistryUsername, &st.Plugin.ImageRegistryPassword, "plugin."); err != nil {
+			return err
+		}
+
+		for k, v := range st.Plugin.Settings {
+			if err := sc.expand(fmt.Sprintf("plugin.settings.%s", k), &v); err != nil {
+				return err
+			}
+
+			st.Plugin.Settings[k] = v
+		}
+	}
+
+	return nil
+}
+
+// registry resolves the credentials of the image registry in place, e.g. ${{ secrets.REGISTRY_PASSWORD }}
+func (s *scope) registry(username, password *string, prefix string) error {
+	if err := s.expand(prefix+"image_registry_username", username); err != nil {
+		return err
+	}
+
+	return s.expand(prefix+"image_registry_password", password)
+}
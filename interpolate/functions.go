@@ -0,0 +1,72 @@
+package interpolate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Functions returns the builtin functions, files are relative to workdir
+//
+//	hashFiles('pattern', ...)    the sha256 of the matching files, empty if no files match
+func Functions(workdir string) map[string]Function {
+	return map[string]Function{
+		"hashFiles": func(patterns ...string) (string, error) {
+			return HashFiles(workdir, patterns...)
+		},
+	}
+}
+
+// HashFiles returns the sha256 of the files matching the patterns in workdir, empty if no files match
+func HashFiles(workdir string, patterns ...string) (string, error) {
+	files := map[string]bool{}
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(workdir, pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return "", fmt.Errorf("invalid hashFiles pattern(%s): %s", pattern, err)
+		}
+
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() {
+				files[match] = true
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		return "", nil
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash file(%s): %s", path, err)
+		}
+
+		fh := sha256.New()
+		_, err = io.Copy(fh, f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to hash file(%s): %s", path, err)
+		}
+
+		h.Write(fh.Sum(nil))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,260 @@
+package interpolate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Options is the options of the interpolation
+type Options struct {
+	// Lookup returns the value of the variable, ok is false if it is undefined
+	Lookup func(name string) (value string, ok bool)
+
+	// Deferred returns true if the variable is only known at run time, e.g. PIPELINE_STATUS,
+	//	its references are kept as is
+	Deferred func(name string) bool
+
+	// Functions are the functions called by ${{ name(args) }}, e.g. hashFiles('go.sum')
+	Functions map[string]Function
+
+	// Contexts resolve ${{ context.key }}, e.g. secrets.TOKEN, other contexts are kept as is, e.g. steps
+	Contexts map[string]Context
+
+	// Strict reports undefined variables as errors, otherwise their references are kept as is
+	Strict bool
+
+	// Expressions only resolves ${{ ... }}, ${VAR} and $${VAR} are kept as is,
+	//	e.g. commands are shell scripts, the shell resolves the variables with the environment
+	Expressions bool
+}
+
+// Function is a function called by ${{ name('arg', ...) }}
+type Function func(args ...string) (string, error)
+
+// Context returns the value of the key of ${{ context.key }}
+type Context func(key string) (string, error)
+
+var nameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var callRe = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\((.*)\)$`)
+
+var argRe = regexp.MustCompile(`'([^']*)'|"([^"]*)"`)
+
+var contextRe = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\.([A-Za-z_][A-Za-z0-9_-]*)$`)
+
+// Expand resolves the references of the text
+//
+//	${VAR}             the value of VAR
+//	${VAR:-default}    default if VAR is undefined or empty, default is resolved too, e.g. ${A:-${B:-b}}
+//	${{ fn('arg') }}   the result of the function
+//	${{ ctx.key }}     the value of the key of the context, e.g. secrets.TOKEN,
+//	                   other ${{ ... }} are kept as is, e.g. step outputs
+//	$${VAR}            escapes to the literal ${VAR}
+//
+//	$VAR and shell expansions like ${VAR%.txt} are kept as is,
+//	with Options.Expressions only ${{ ... }} are resolved, and $${{ escapes to the literal ${{
+func Expand(text string, opts *Options) (string, error) {
+	if !strings.Contains(text, "$") {
+		return text, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		rest := text[i:]
+
+		switch {
+		case strings.HasPrefix(rest, "$${{") || (!opts.Expressions && strings.HasPrefix(rest, "$${")):
+			b.WriteString("${")
+			i += 3
+		case strings.HasPrefix(rest, "${{"):
+			end := strings.Index(rest, "}}")
+			if end < 0 {
+				return "", fmt.Errorf("unclosed %s", rest)
+			}
+
+			value, err := call(rest[:end+2], strings.TrimSpace(rest[3:end]), opts)
+			if err != nil {
+				return "", err
+			}
+
+			b.WriteString(value)
+			i += end + 2
+		case !opts.Expressions && strings.HasPrefix(rest, "${"):
+			end := closingBrace(rest)
+			if end < 0 {
+				if opts.Strict {
+					return "", fmt.Errorf("unclosed %s", rest)
+				}
+
+				b.WriteString(rest)
+				i = len(text)
+				continue
+			}
+
+			value, err := variable(rest[:end+1], rest[2:end], opts)
+			if err != nil {
+				return "", err
+			}
+
+			b.WriteString(value)
+			i += end + 1
+		default:
+			b.WriteByte(text[i])
+			i++
+		}
+	}
+
+	return b.String(), nil
+}
+
+// closingBrace returns the index of the brace closing ${, -1 if not found
+func closingBrace(text string) int {
+	depth := 0
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// variable resolves ${body}, raw is returned when the reference is kept as is
+func variable(raw, body string, opts *Options) (string, error) {
+	name, fallback, hasDefault := strings.Cut(body, ":-")
+	if !nameRe.MatchString(name) {
+		if opts.Strict {
+			return "", fmt.Errorf("invalid variable %s, only ${VAR} and ${VAR:-default} are supported", raw)
+		}
+
+		return raw, nil
+	}
+
+	if opts.Deferred != nil && opts.Deferred(name) {
+		return raw, nil
+	}
+
+	if opts.Lookup != nil {
+		if value, ok := opts.Lookup(name); ok && (value != "" || !hasDefault) {
+			return value, nil
+		}
+	}
+
+	if hasDefault {
+		return Expand(fallback, opts)
+	}
+
+	if opts.Strict {
+		return "", fmt.Errorf("undefined variable %s", name)
+	}
+
+	return raw, nil
+}
+
+// call resolves ${{ expr }}, raw is returned when expr is not a function call or a known context
+func call(raw, expr string, opts *Options) (string, error) {
+	if m := contextRe.FindStringSubmatch(expr); m != nil {
+		ctx, ok := opts.Contexts[m[1]]
+		if !ok {
+			return raw, nil
+		}
+
+		value, err := ctx(m[2])
+		if err != nil {
+			return "", fmt.Errorf("%s: %s", raw, err)
+		}
+
+		return value, nil
+	}
+
+	m := callRe.FindStringSubmatch(expr)
+	if m == nil {
+		return raw, nil
+	}
+
+	fn, ok := opts.Functions[m[1]]
+	if !ok {
+		return "", fmt.Errorf("unknown function %s() in %s", m[1], raw)
+	}
+
+	args := []string{}
+	for _, arg := range argRe.FindAllStringSubmatch(m[2], -1) {
+		args = append(args, arg[1]+arg[2])
+	}
+
+	value, err := fn(args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to call %s(): %s", m[1], err)
+	}
+
+	return value, nil
+}
+
+// Environment resolves the values of the environment, values may reference each other and the parent,
+//
+//	a variable referencing itself reads the parent, e.g. {"PATH": "${PATH}:/opt/bin"}
+//	returns the name of the variable failed to resolve with the error
+func Environment(environment map[string]string, parent func(name string) (string, bool), opts Options) (map[string]string, string, error) {
+	resolved := make(map[string]string, len(environment))
+	resolving := map[string]bool{}
+	failed := map[string]error{}
+
+	var resolve func(name string) (string, error)
+
+	opts.Lookup = func(name string) (string, bool) {
+		if _, ok := environment[name]; ok && !resolving[name] {
+			value, err := resolve(name)
+			return value, err == nil
+		}
+
+		if parent != nil {
+			return parent(name)
+		}
+
+		return "", false
+	}
+
+	resolve = func(name string) (string, error) {
+		if value, ok := resolved[name]; ok {
+			return value, nil
+		}
+
+		if err, ok := failed[name]; ok {
+			return "", err
+		}
+
+		resolving[name] = true
+		defer delete(resolving, name)
+
+		value, err := Expand(environment[name], &opts)
+		if err != nil {
+			failed[name] = err
+			return "", err
+		}
+
+		resolved[name] = value
+		return value, nil
+	}
+
+	names := make([]string, 0, len(environment))
+	for name := range environment {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := resolve(name); err != nil {
+			return nil, name, err
+		}
+	}
+
+	return resolved, "", nil
+}
@@ -0,0 +1,149 @@
+package interpolate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	env := map[string]string{
+		"TAG":   "1.0",
+		"EMPTY": "",
+		"NAME":  "app",
+	}
+
+	opts := &Options{
+		Lookup: func(name string) (string, bool) {
+			v, ok := env[name]
+			return v, ok
+		},
+		Deferred: func(name string) bool {
+			return name == "PIPELINE_STATUS"
+		},
+		Functions: map[string]Function{
+			"upper": func(args ...string) (string, error) {
+				return strings.ToUpper(strings.Join(args, "")), nil
+			},
+		},
+		Contexts: map[string]Context{
+			"secrets": func(key string) (string, error) {
+				if key == "TOKEN" {
+					return "s3cr3t", nil
+				}
+				return "", fmt.Errorf("secret %s not found", key)
+			},
+		},
+		Strict: true,
+	}
+
+	cases := []struct {
+		text string
+		want string
+	}{
+		{"plain", "plain"},
+		{"${NAME}:${TAG}", "app:1.0"},
+		{"${MISSING:-default}", "default"},
+		{"${EMPTY:-default}", "default"},
+		{"${MISSING:-${NAME:-x}}-${MISSING:-${OTHER:-y}}", "app-y"},
+		{"$NAME ${PIPELINE_STATUS}", "$NAME ${PIPELINE_STATUS}"},
+		{"$${NAME}", "${NAME}"},
+		{"${{ upper('a', \"b\") }}", "AB"},
+		{"${{ steps.build.outputs.version }}", "${{ steps.build.outputs.version }}"},
+		{"token=${{ secrets.TOKEN }}", "token=s3cr3t"},
+		{"${{ vars.TOKEN }}", "${{ vars.TOKEN }}"},
+	}
+
+	for _, c := range cases {
+		got, err := Expand(c.text, opts)
+		if err != nil {
+			t.Errorf("Expand(%q) error: %v", c.text, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Expand(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+
+	for _, text := range []string{"${MISSING}", "${NAME%.txt}", "${{ unknown() }}", "${NAME", "${{ secrets.MISSING }}"} {
+		if _, err := Expand(text, opts); err == nil {
+			t.Errorf("Expand(%q) expected error", text)
+		}
+	}
+
+	opts.Strict = false
+	for _, text := range []string{"${MISSING}", "${NAME%.txt}", "for f in *; do echo ${f}; done"} {
+		got, err := Expand(text, opts)
+		if err != nil || got != text {
+			t.Errorf("Expand(%q) = %q (%v), want it kept as is", text, got, err)
+		}
+	}
+	opts.Expressions = true
+	for _, c := range []struct {
+		text string
+		want string
+	}{
+		{"for TAG in 2.0 3.0; do echo ${TAG}; done", "for TAG in 2.0 3.0; do echo ${TAG}; done"},
+		{"echo $${NAME} ${NAME", "echo $${NAME} ${NAME"},
+		{"token=${{ secrets.TOKEN }} ${TAG}", "token=s3cr3t ${TAG}"},
+		{"$${{ secrets.TOKEN }}", "${{ secrets.TOKEN }}"},
+	} {
+		got, err := Expand(c.text, opts)
+		if err != nil || got != c.want {
+			t.Errorf("Expand(%q) with expressions only = %q (%v), want %q", c.text, got, err, c.want)
+		}
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	parent := func(name string) (string, bool) {
+		if name == "PATH" {
+			return "/bin", true
+		}
+		return "", false
+	}
+
+	env, _, err := Environment(map[string]string{
+		"IMAGE":    "${REGISTRY}/app",
+		"REGISTRY": "${DOMAIN:-docker.io}",
+		"PATH":     "${PATH}:/opt/bin",
+	}, parent, Options{Strict: true})
+	if err != nil {
+		t.Fatalf("Environment() error: %v", err)
+	}
+
+	if env["IMAGE"] != "docker.io/app" {
+		t.Errorf("Expected IMAGE=docker.io/app, got %s", env["IMAGE"])
+	}
+	if env["PATH"] != "/bin:/opt/bin" {
+		t.Errorf("Expected PATH=/bin:/opt/bin, got %s", env["PATH"])
+	}
+
+	_, name, err := Environment(map[string]string{"A": "${B}", "B": "${MISSING}"}, nil, Options{Strict: true})
+	if err == nil || name != "A" {
+		t.Errorf("Expected error of A, got %s: %v", name, err)
+	}
+}
+
+func TestHashFiles(t *testing.T) {
+	workdir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workdir, "go.sum"), []byte("v1"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	hash, err := HashFiles(workdir, "go.sum", "*.sum")
+	if err != nil || len(hash) != 64 {
+		t.Fatalf("Expected sha256 of go.sum, got %q (%v)", hash, err)
+	}
+
+	value, err := Expand("${{ hashFiles('go.sum') }}", &Options{Functions: Functions(workdir)})
+	if err != nil || value != hash {
+		t.Errorf("Expected %s, got %s (%v)", hash, value, err)
+	}
+
+	if missing, _ := HashFiles(workdir, "missing.lock"); missing != "" {
+		t.Errorf("Expected empty hash for missing files, got %s", missing)
+	}
+}
@@ -0,0 +1,127 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-idp/pipeline/step"
+)
+
+func TestPipelineInterpolate(t *testing.T) {
+	t.Run("should resolve variables of environment and leave those of commands to the shell", func(t *testing.T) {
+		pipeline := &Pipeline{
+			Name:    "test pipeline interpolate",
+			Workdir: t.TempDir(),
+			Environment: map[string]string{
+				"REGISTRY": "${DOMAIN:-docker.io}/go-idp",
+			},
+			Stages: []*stage.Stage{
+				{
+					Name: "build",
+					Jobs: []*job.Job{
+						{
+							Name: "build",
+							Matrix: &job.Matrix{
+								Axes: map[string][]string{"os": {"linux"}},
+							},
+							Environment: map[string]string{
+								"IMAGE": "${REGISTRY}/app-${MATRIX_OS}",
+							},
+							Steps: []*step.Step{
+								{
+									Name:    "build",
+									Command: `test "${IMAGE}:${TAG:-latest}" = "docker.io/go-idp/app-linux:latest" && for f in a; do test "${f}" = a; done`,
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		var out bytes.Buffer
+		pipeline.SetStdout(&out)
+		if err := pipeline.Run(context.Background()); err != nil {
+			t.Fatalf("Failed to run pipeline: %v\n%s", err, out.String())
+		}
+
+		j := pipeline.Stages[0].Jobs[0]
+		if got := j.Environment["IMAGE"]; got != "docker.io/go-idp/app-linux" {
+			t.Errorf("Expected IMAGE=docker.io/go-idp/app-linux, got %s", got)
+		}
+	})
+
+	t.Run("shell variables of commands should not be overridden by the environment", func(t *testing.T) {
+		pipeline := &Pipeline{
+			Name:    "test pipeline interpolate",
+			Workdir: t.TempDir(),
+			Environment: map[string]string{
+				"VERSION": "1.0",
+			},
+			Stages: []*stage.Stage{
+				{
+					Name: "build",
+					Jobs: []*job.Job{
+						{
+							Name: "build",
+							Steps: []*step.Step{
+								{
+									Name:    "build",
+									Command: `test "${VERSION}" = 1.0 && for VERSION in 2.0 3.0; do echo "got ${VERSION}"; done`,
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		var out bytes.Buffer
+		pipeline.SetStdout(&out)
+		if err := pipeline.Run(context.Background()); err != nil {
+			t.Fatalf("Failed to run pipeline: %v\n%s", err, out.String())
+		}
+
+		if !strings.Contains(out.String(), "got 2.0") || !strings.Contains(out.String(), "got 3.0") {
+			t.Errorf("Expected the shell to resolve the loop variable, got:\n%s", out.String())
+		}
+
+		if command := pipeline.Stages[0].Jobs[0].Steps[0].Command; !strings.Contains(command, `"${VERSION}"`) {
+			t.Errorf("Expected the command to be kept as is, got %s", command)
+		}
+	})
+
+	t.Run("undefined variables should be reported with the yaml path", func(t *testing.T) {
+		pipeline := &Pipeline{
+			Name:    "test pipeline interpolate",
+			Workdir: t.TempDir(),
+			Stages: []*stage.Stage{
+				{
+					Name: "build",
+					Jobs: []*job.Job{
+						{
+							Name: "build",
+							Steps: []*step.Step{
+								{Name: "lint", Command: "true"},
+								{Name: "build", Command: "true", Image: "golang:${GO_VERSION}"},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		err := pipeline.Run(context.Background())
+		if err == nil {
+			t.Fatalf("Expected error for undefined variable")
+		}
+
+		if !strings.Contains(err.Error(), "stages[0].jobs[0].steps[1].image: undefined variable GO_VERSION") {
+			t.Errorf("Expected error with yaml path, got %v", err)
+		}
+	})
+}
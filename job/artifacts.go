@@ -0,0 +1,66 @@
+package job
+
+import (
+	"fmt"
+
+	"github.com/go-idp/pipeline/artifact"
+)
+
+// restoreArtifacts restores the artifacts of the dependencies into the workdir
+func (j *Job) restoreArtifacts(prefix string) error {
+	if len(j.Dependencies) == 0 {
+		return nil
+	}
+
+	if j.artifacts == nil {
+		return fmt.Errorf("no artifact store to restore the artifacts of dependencies")
+	}
+
+	artifacts, err := j.artifacts.List(j.pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to list artifacts: %s", err)
+	}
+
+	for _, dependency := range j.Dependencies {
+		found := false
+		for _, a := range artifacts {
+			if !a.Match(dependency) {
+				continue
+			}
+
+			found = true
+			j.logger.Infof("%s restore artifact(%s): %d files", prefix, a.Name, len(a.Files))
+			if err := j.artifacts.Restore(j.pipeline, a.Name, j.Workdir); err != nil {
+				return err
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("artifact of job(%s) not found", dependency)
+		}
+	}
+
+	return nil
+}
+
+// saveArtifacts collects the artifacts of the job from the workdir
+func (j *Job) saveArtifacts(prefix string) error {
+	if len(j.Artifacts) == 0 {
+		return nil
+	}
+
+	if j.artifacts == nil {
+		return fmt.Errorf("no artifact store to save the artifacts")
+	}
+
+	a, err := j.artifacts.Save(j.pipeline, &artifact.Artifact{
+		Name:   j.Name,
+		Origin: j.origin,
+	}, j.Workdir, j.Artifacts)
+	if err != nil {
+		return err
+	}
+
+	j.logger.Infof("%s save artifact(%s): %d files, %d bytes", prefix, a.Name, len(a.Files), a.Size)
+	return nil
+}
@@ -0,0 +1,47 @@
+package job
+
+import (
+	"github.com/go-idp/pipeline/cache"
+)
+
+// restoreCache restores the cache of the job, a failure is only a warning
+func (j *Job) restoreCache(prefix string) {
+	if j.Cache == nil {
+		return
+	}
+
+	if j.cacheStore == nil {
+		j.logger.Warnf("%s cache skipped: no cache store", prefix)
+		return
+	}
+
+	result, err := cache.Restore(j.cacheStore, j.Cache, j.Workdir, j.Environment)
+	j.State.Cache = result
+	if err != nil {
+		j.logger.Warnf("%s failed to restore cache: %s", prefix, err)
+		return
+	}
+
+	switch {
+	case result.Hit:
+		j.logger.Infof("%s cache hit (key: %s)", prefix, result.Key)
+	case result.RestoredKey != "":
+		j.logger.Infof("%s cache miss (key: %s), restored from %s", prefix, result.Key, result.RestoredKey)
+	default:
+		j.logger.Infof("%s cache miss (key: %s)", prefix, result.Key)
+	}
+}
+
+// saveCache saves the cache of the job after it succeeds, a failure is only a warning
+func (j *Job) saveCache(prefix string) {
+	if j.Cache == nil || j.cacheStore == nil || j.State.Cache == nil || j.State.Cache.Hit {
+		return
+	}
+
+	if err := cache.Save(j.cacheStore, j.Cache, j.State.Cache, j.Workdir); err != nil {
+		j.logger.Warnf("%s failed to save cache: %s", prefix, err)
+		return
+	}
+
+	j.logger.Infof("%s cache saved (key: %s)", prefix, j.State.Cache.Key)
+}
@@ -1,8 +1,12 @@
 package job
 
 import (
+	"fmt"
 	"io"
 
+	"github.com/go-idp/pipeline/artifact"
+	"github.com/go-idp/pipeline/cache"
+	"github.com/go-idp/pipeline/event"
 	"github.com/go-idp/pipeline/step"
 	"github.com/go-zoox/logger"
 )
@@ -22,6 +26,27 @@ type Job struct {
 	Environment map[string]string `json:"environment" yaml:"environment"`
 	//
 	Timeout int64 `json:"timeout" yaml:"timeout"`
+	// Needs is the names of the jobs this job depends on, e.g. ["build", "lint"]
+	//	when set, the job starts as soon as all of them succeed, regardless of stage order
+	Needs []string `json:"needs" yaml:"needs"`
+	// If is the condition to run the job, e.g. "env.BRANCH == 'main'", "failure()", "always()"
+	If string `json:"if" yaml:"if"`
+	// Extends is the name of the template in templates.jobs of the pipeline to inherit from, the fields set here win
+	Extends string `json:"extends" yaml:"extends"`
+	//
+	Matrix *Matrix `json:"matrix" yaml:"matrix"`
+	// MaxParallel is the maximum number of matrix jobs running at the same time, default: unlimited
+	MaxParallel int `json:"max_parallel" yaml:"max_parallel"`
+	// Retry is the default retry policy of the steps
+	Retry *step.Retry `json:"retry" yaml:"retry"`
+	// AllowFailure records the failure of the job as failed_allowed, without failing the stage
+	AllowFailure bool `json:"allow_failure" yaml:"allow_failure"`
+	// Artifacts are the paths to collect from the workdir after the job succeeds, relative to the workdir, e.g. ["dist", "bin/*"]
+	Artifacts []string `json:"artifacts" yaml:"artifacts"`
+	// Dependencies are the names of the jobs whose artifacts are restored into the workdir before the job runs
+	Dependencies []string `json:"dependencies" yaml:"dependencies"`
+	// Cache is the cache restored before the job and saved after it succeeds
+	Cache *cache.Cache `json:"cache" yaml:"cache"`
 	//
 	State *State `json:"state" yaml:"state"`
 	//
@@ -29,14 +54,113 @@ type Job struct {
 	stderr io.Writer
 	//
 	logger *logger.Logger
+	// excluded is set by the filter of the run, e.g. --only, --skip
+	excluded bool
+	// restored is set when the state is restored from a previous run, e.g. by resume
+	restored bool
+	//
+	origin  string
+	limiter chan struct{}
+	//
+	outputs *step.Outputs
+	//
+	artifacts artifact.Store
+	pipeline  string
+	//
+	cacheStore cache.Store
+	//
+	events event.Sink
 }
 
-func (s *Job) getLogger() *logger.Logger {
+func (s *Job) getLogger(id string) *logger.Logger {
 	l := logger.New()
-	l.SetStdout(s.stdout)
+	l.SetStdout(step.SegmentJob(s.stdout, id))
 	return l
 }
 
+// Clone returns a copy of the job without state
+func (j *Job) Clone() *Job {
+	c := *j
+	c.State = nil
+
+	if j.Environment != nil {
+		c.Environment = make(map[string]string, len(j.Environment))
+		for k, v := range j.Environment {
+			c.Environment[k] = v
+		}
+	}
+
+	if j.Needs != nil {
+		c.Needs = append([]string{}, j.Needs...)
+	}
+
+	if j.Artifacts != nil {
+		c.Artifacts = append([]string{}, j.Artifacts...)
+	}
+
+	if j.Dependencies != nil {
+		c.Dependencies = append([]string{}, j.Dependencies...)
+	}
+
+	if j.Cache != nil {
+		cache := *j.Cache
+		c.Cache = &cache
+	}
+
+	c.Steps = make([]*step.Step, len(j.Steps))
+	for i, s := range j.Steps {
+		c.Steps[i] = s.Clone()
+	}
+
+	return &c
+}
+
+// Expand expands the matrix of the job into concrete jobs
+//
+//	each job is named after its values, e.g. build (1.21, linux),
+//	and gets them as environment variables, e.g. MATRIX_GO=1.21
+//	a job without matrix expands to itself
+func (j *Job) Expand() ([]*Job, error) {
+	if j.Matrix == nil {
+		return []*Job{j}, nil
+	}
+
+	combinations, err := j.Matrix.Combinations()
+	if err != nil {
+		return nil, fmt.Errorf("invalid matrix of job(%s): %s", j.Name, err)
+	}
+
+	var limiter chan struct{}
+	if j.MaxParallel > 0 {
+		limiter = make(chan struct{}, j.MaxParallel)
+	}
+
+	jobs := make([]*Job, 0, len(combinations))
+	for _, combination := range combinations {
+		mj := j.Clone()
+		mj.Name = matrixJobName(j.Name, combination)
+		mj.Matrix = nil
+		mj.origin = j.Name
+		mj.limiter = limiter
+
+		if mj.Environment == nil {
+			mj.Environment = map[string]string{}
+		}
+		for k, v := range combination {
+			mj.Environment[matrixEnvKey(k)] = v
+		}
+
+		jobs = append(jobs, mj)
+	}
+
+	return jobs, nil
+}
+
+// Origin returns the name of the job the matrix job is expanded from, empty if it is not a matrix job
+func (j *Job) Origin() string {
+	return j.origin
+}
+
 func (j *Job) SetStdout(stdout io.Writer) {
 	for _, step := range j.Steps {
 		step.SetStdout(stdout)
@@ -48,3 +172,32 @@ func (j *Job) SetStderr(stderr io.Writer) {
 		step.SetStderr(stderr)
 	}
 }
+
+// SetArtifactStore sets the artifact store of the job, artifacts are stored by the pipeline id
+func (j *Job) SetArtifactStore(store artifact.Store, pipeline string) {
+	j.artifacts = store
+	j.pipeline = pipeline
+}
+
+// SetCacheStore sets the cache store of the job and its steps
+func (j *Job) SetCacheStore(store cache.Store) {
+	j.cacheStore = store
+
+	for _, step := range j.Steps {
+		step.SetCacheStore(store)
+	}
+}
+
+// SetEventSink sets the sink of the events of the job and its steps
+func (j *Job) SetEventSink(sink event.Sink) {
+	j.events = sink
+
+	for _, step := range j.Steps {
+		step.SetEventSink(sink)
+	}
+}
+
+// SetOutputs sets the outputs scope of the job, the steps of the job read the outputs of other jobs from it
+func (j *Job) SetOutputs(outputs *step.Outputs) {
+	j.outputs = outputs
+}
@@ -0,0 +1,274 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Matrix is the build matrix of the job, every combination of the axes becomes a job
+//
+//	e.g.
+//	  matrix:
+//	    go: ["1.21", "1.22"]
+//	    os: [linux, darwin]
+//	    exclude:
+//	      - go: "1.21"
+//	        os: darwin
+//	    include:
+//	      - go: "1.22"
+//	        os: windows
+type Matrix struct {
+	// Axes are the values of each axis, e.g. {"go": ["1.21", "1.22"]}
+	Axes map[string][]string
+	// Include adds extra keys to the matching combinations, or adds new combinations
+	Include []map[string]string
+	// Exclude removes the matching combinations
+	Exclude []map[string]string
+}
+
+var matrixEnvKeyRe = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// Combinations returns the combinations of the matrix, in a stable order
+func (m *Matrix) Combinations() ([]map[string]string, error) {
+	keys := make([]string, 0, len(m.Axes))
+	for k, values := range m.Axes {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("matrix axis(%s) has no values", k)
+		}
+
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	combinations := []map[string]string{}
+	if len(keys) > 0 {
+		combinations = append(combinations, map[string]string{})
+	}
+
+	for _, k := range keys {
+		next := []map[string]string{}
+		for _, c := range combinations {
+			for _, v := range m.Axes[k] {
+				combination := map[string]string{}
+				for ck, cv := range c {
+					combination[ck] = cv
+				}
+				combination[k] = v
+
+				next = append(next, combination)
+			}
+		}
+		combinations = next
+	}
+
+	// exclude first, then include, so that include can add back excluded combinations
+	filtered := []map[string]string{}
+	for _, c := range combinations {
+		excluded := false
+		for _, e := range m.Exclude {
+			if matrixMatch(c, e) {
+				excluded = true
+				break
+			}
+		}
+
+		if !excluded {
+			filtered = append(filtered, c)
+		}
+	}
+	combinations = filtered
+
+	original := len(combinations)
+	for _, inc := range m.Include {
+		// an include extends every original combination whose axis values it matches,
+		//	an include without axis keys extends all of them, otherwise it is added as a new combination
+		matched := false
+		for _, c := range combinations[:original] {
+			if matrixMatchAxes(c, inc, m.Axes) {
+				for k, v := range inc {
+					c[k] = v
+				}
+				matched = true
+			}
+		}
+
+		if !matched {
+			combination := map[string]string{}
+			for k, v := range inc {
+				combination[k] = v
+			}
+
+			combinations = append(combinations, combination)
+		}
+	}
+
+	if len(combinations) == 0 {
+		return nil, fmt.Errorf("matrix has no combinations")
+	}
+
+	return combinations, nil
+}
+
+// matrixMatch returns true if every key of filter has the same value in combination
+func matrixMatch(combination, filter map[string]string) bool {
+	for k, v := range filter {
+		if combination[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matrixMatchAxes returns true if every axis key of filter has the same value in combination,
+//
+//	a filter without axis keys matches every combination
+func matrixMatchAxes(combination, filter map[string]string, axes map[string][]string) bool {
+	for k, v := range filter {
+		if _, ok := axes[k]; !ok {
+			continue
+		}
+
+		if combination[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matrixEnvKey returns the environment variable of the matrix key, e.g. node-version => MATRIX_NODE_VERSION
+func matrixEnvKey(key string) string {
+	return "MATRIX_" + matrixEnvKeyRe.ReplaceAllString(strings.ToUpper(key), "_")
+}
+
+// matrixJobName returns the name of the matrix job, e.g. build (1.21, linux)
+func matrixJobName(name string, combination map[string]string) string {
+	keys := make([]string, 0, len(combination))
+	for k := range combination {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	values := make([]string, len(keys))
+	for i, k := range keys {
+		values[i] = combination[k]
+	}
+
+	return fmt.Sprintf("%s (%s)", name, strings.Join(values, ", "))
+}
+
+// fromMap parses the raw matrix, e.g. decoded from yaml or json
+func (m *Matrix) fromMap(raw map[string]interface{}) error {
+	m.Axes = map[string][]string{}
+	m.Include = nil
+	m.Exclude = nil
+
+	for k, v := range raw {
+		switch k {
+		case "include", "exclude":
+			list, ok := v.([]interface{})
+			if !ok {
+				return fmt.Errorf("matrix %s should be a list of maps", k)
+			}
+
+			entries := []map[string]string{}
+			for _, item := range list {
+				entry, ok := toStringMap(item)
+				if !ok {
+					return fmt.Errorf("matrix %s should be a list of maps", k)
+				}
+				entries = append(entries, entry)
+			}
+
+			if k == "include" {
+				m.Include = entries
+			} else {
+				m.Exclude = entries
+			}
+		default:
+			list, ok := v.([]interface{})
+			if !ok {
+				return fmt.Errorf("matrix axis(%s) should be a list of values", k)
+			}
+
+			values := make([]string, len(list))
+			for i, item := range list {
+				values[i] = fmt.Sprintf("%v", item)
+			}
+			m.Axes[k] = values
+		}
+	}
+
+	return nil
+}
+
+// toMap returns the raw matrix
+func (m *Matrix) toMap() map[string]interface{} {
+	raw := map[string]interface{}{}
+	for k, v := range m.Axes {
+		raw[k] = v
+	}
+
+	if len(m.Include) > 0 {
+		raw["include"] = m.Include
+	}
+
+	if len(m.Exclude) > 0 {
+		raw["exclude"] = m.Exclude
+	}
+
+	return raw
+}
+
+func toStringMap(v interface{}) (map[string]string, bool) {
+	result := map[string]string{}
+
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, v := range x {
+			result[k] = fmt.Sprintf("%v", v)
+		}
+	case map[interface{}]interface{}:
+		for k, v := range x {
+			result[fmt.Sprintf("%v", k)] = fmt.Sprintf("%v", v)
+		}
+	default:
+		return nil, false
+	}
+
+	return result, true
+}
+
+// UnmarshalYAML implements yaml.InterfaceUnmarshaler
+func (m *Matrix) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	raw := map[string]interface{}{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	return m.fromMap(raw)
+}
+
+// MarshalYAML implements yaml.InterfaceMarshaler
+func (m *Matrix) MarshalYAML() (interface{}, error) {
+	return m.toMap(), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *Matrix) UnmarshalJSON(data []byte) error {
+	raw := map[string]interface{}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	return m.fromMap(raw)
+}
+
+// MarshalJSON implements json.Marshaler
+func (m *Matrix) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.toMap())
+}
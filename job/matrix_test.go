@@ -0,0 +1,177 @@
+package job
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-idp/pipeline/step"
+	"github.com/go-zoox/encoding/yaml"
+)
+
+func TestMatrixCombinations(t *testing.T) {
+	m := &Matrix{
+		Axes: map[string][]string{
+			"go": {"1.21", "1.22"},
+			"os": {"linux", "darwin"},
+		},
+		Exclude: []map[string]string{
+			{"go": "1.21", "os": "darwin"},
+		},
+		Include: []map[string]string{
+			// extends the matching combination
+			{"go": "1.22", "os": "linux", "experimental": "true"},
+			// new combination
+			{"go": "1.23", "os": "windows"},
+		},
+	}
+
+	combinations, err := m.Combinations()
+	if err != nil {
+		t.Fatalf("Combinations() error: %v", err)
+	}
+
+	expected := []map[string]string{
+		{"go": "1.21", "os": "linux"},
+		{"go": "1.22", "os": "linux", "experimental": "true"},
+		{"go": "1.22", "os": "darwin"},
+		{"go": "1.23", "os": "windows"},
+	}
+	if !reflect.DeepEqual(combinations, expected) {
+		t.Fatalf("Combinations() mismatch:\n got: %v\nwant: %v", combinations, expected)
+	}
+}
+
+func TestMatrixIncludeExtraKeys(t *testing.T) {
+	m := &Matrix{
+		Axes: map[string][]string{
+			"go": {"1.21", "1.22"},
+		},
+		Include: []map[string]string{
+			// no axis keys, extends every combination
+			{"cgo": "0"},
+			{"go": "1.22", "race": "true"},
+		},
+	}
+
+	combinations, err := m.Combinations()
+	if err != nil {
+		t.Fatalf("Combinations() error: %v", err)
+	}
+
+	expected := []map[string]string{
+		{"go": "1.21", "cgo": "0"},
+		{"go": "1.22", "cgo": "0", "race": "true"},
+	}
+	if !reflect.DeepEqual(combinations, expected) {
+		t.Fatalf("Combinations() mismatch:\n got: %v\nwant: %v", combinations, expected)
+	}
+
+	// without axes, an include is a combination of its own
+	m = &Matrix{
+		Include: []map[string]string{
+			{"os": "linux"},
+		},
+	}
+
+	combinations, err = m.Combinations()
+	if err != nil {
+		t.Fatalf("Combinations() error: %v", err)
+	}
+
+	expected = []map[string]string{
+		{"os": "linux"},
+	}
+	if !reflect.DeepEqual(combinations, expected) {
+		t.Fatalf("Combinations() mismatch:\n got: %v\nwant: %v", combinations, expected)
+	}
+}
+
+func TestMatrixYAML(t *testing.T) {
+	j := &Job{}
+	err := yaml.Decode([]byte(`
+name: build
+max_parallel: 2
+matrix:
+  go: ["1.21", "1.22"]
+  node-version: [18]
+  exclude:
+    - go: "1.21"
+steps:
+  - name: build
+    command: go build
+`), j)
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+
+	if j.Matrix == nil {
+		t.Fatal("matrix is nil")
+	}
+
+	if !reflect.DeepEqual(j.Matrix.Axes["go"], []string{"1.21", "1.22"}) {
+		t.Fatalf("matrix axis(go) mismatch: %v", j.Matrix.Axes["go"])
+	}
+
+	if !reflect.DeepEqual(j.Matrix.Axes["node-version"], []string{"18"}) {
+		t.Fatalf("matrix axis(node-version) mismatch: %v", j.Matrix.Axes["node-version"])
+	}
+
+	if len(j.Matrix.Exclude) != 1 || j.Matrix.Exclude[0]["go"] != "1.21" {
+		t.Fatalf("matrix exclude mismatch: %v", j.Matrix.Exclude)
+	}
+
+	if j.MaxParallel != 2 {
+		t.Fatalf("max_parallel mismatch: %d", j.MaxParallel)
+	}
+}
+
+func TestJobExpand(t *testing.T) {
+	j := &Job{
+		Name: "build",
+		Environment: map[string]string{
+			"FOO": "bar",
+		},
+		Matrix: &Matrix{
+			Axes: map[string][]string{
+				"go":           {"1.21", "1.22"},
+				"node-version": {"18"},
+			},
+		},
+		Steps: []*step.Step{
+			{Name: "build", Command: "go build"},
+		},
+	}
+
+	jobs, err := j.Expand()
+	if err != nil {
+		t.Fatalf("Expand() error: %v", err)
+	}
+
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(jobs))
+	}
+
+	if jobs[0].Name != "build (1.21, 18)" || jobs[1].Name != "build (1.22, 18)" {
+		t.Fatalf("job names mismatch: %q, %q", jobs[0].Name, jobs[1].Name)
+	}
+
+	if jobs[0].Environment["MATRIX_GO"] != "1.21" || jobs[0].Environment["MATRIX_NODE_VERSION"] != "18" {
+		t.Fatalf("job environment mismatch: %v", jobs[0].Environment)
+	}
+
+	if jobs[0].Environment["FOO"] != "bar" {
+		t.Fatalf("job environment should be inherited: %v", jobs[0].Environment)
+	}
+
+	if j.Environment["MATRIX_GO"] != "" {
+		t.Fatalf("origin job environment should not be modified: %v", j.Environment)
+	}
+
+	if jobs[0].Steps[0] == j.Steps[0] {
+		t.Fatal("steps should be cloned")
+	}
+
+	if jobs[0].Origin() != "build" || jobs[0].Matrix != nil {
+		t.Fatalf("job origin mismatch: %q", jobs[0].Origin())
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/go-idp/pipeline/event"
+	"github.com/go-idp/pipeline/expression"
 	"github.com/go-idp/pipeline/step"
 )
 
@@ -17,19 +19,69 @@ type RunConfig struct {
 	Current int
 	// Parent is the parent name
 	Parent string
+	// Failed is true when a previous unit has failed, used by failure() in if
+	Failed bool
 }
 
 // RunOption is the option for run
 type RunOption func(cfg *RunConfig)
 
 // Run runs the job
-func (j *Job) Run(ctx context.Context, opts ...RunOption) error {
+func (j *Job) Run(ctx context.Context, opts ...RunOption) (err error) {
 	cfg := &RunConfig{}
 	for _, o := range opts {
 		o(cfg)
 	}
 
+	defer func() {
+		j.finish()
+		j.emit(event.JobFinished, err)
+	}()
+
+	if j.restored {
+		j.logger.Infof("%s[job(%d/%d): %s] %s in the previous run (resumed)", cfg.Parent, cfg.Current, cfg.Total, j.Name, j.State.Status)
+		return nil
+	}
+
+	if j.excluded {
+		j.logger.Infof("%s[job(%d/%d): %s] skipped (filtered)", cfg.Parent, cfg.Current, cfg.Total, j.Name)
+		j.Skip()
+		return nil
+	}
+
+	ok, err := expression.Condition(j.If, &expression.Context{
+		Environment: j.Environment,
+		Failed:      cfg.Failed,
+	})
+	if err != nil {
+		return fmt.Errorf("%s[job(%d/%d): %s] %s", cfg.Parent, cfg.Current, cfg.Total, j.Name, err)
+	}
+	if !ok {
+		if j.If != "" {
+			j.logger.Infof("%s[job(%d/%d): %s] skipped (if: %s)", cfg.Parent, cfg.Current, cfg.Total, j.Name, j.If)
+		} else {
+			j.logger.Infof("%s[job(%d/%d): %s] skipped (previous failure)", cfg.Parent, cfg.Current, cfg.Total, j.Name)
+		}
+		j.Skip()
+		return nil
+	}
+
+	// matrix jobs share the limiter of max_parallel
+	if j.limiter != nil {
+		select {
+		case j.limiter <- struct{}{}:
+			defer func() { <-j.limiter }()
+		case <-ctx.Done():
+			j.State.Status = "failed"
+			j.State.Error = ctx.Err().Error()
+			j.State.FailedAt = time.Now()
+			return ctx.Err()
+		}
+	}
+
 	j.logger.Infof("%s[job(%d/%d): %s] start", cfg.Parent, cfg.Current, cfg.Total, j.Name)
+	j.State.StartedAt = time.Now()
+	j.emit(event.JobStarted, nil)
 	if j.Timeout > 0 {
 		j.logger.Infof("%s[job(%d/%d): %s] timeout: %d seconds", cfg.Parent, cfg.Current, cfg.Total, j.Name, j.Timeout)
 	}
@@ -42,27 +94,79 @@ func (j *Job) Run(ctx context.Context, opts ...RunOption) error {
 		defer cancel()
 	}
 
+	prefix := fmt.Sprintf("%s[job(%d/%d): %s]", cfg.Parent, cfg.Current, cfg.Total, j.Name)
+
+	// after a step fails, the remaining steps only run if their if allows it, e.g. failure() or always()
+	var runErr error
+	fail := func(err error) {
+		if runErr != nil {
+			return
+		}
+
+		runErr = err
+		j.State.Status = "failed"
+		j.State.Error = err.Error()
+		j.State.FailedAt = time.Now()
+		// Check if error is due to context timeout
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			j.State.Error = fmt.Sprintf("job timeout after %d seconds: %s", j.Timeout, err.Error())
+		}
+	}
+
+	if err := j.restoreArtifacts(prefix); err != nil {
+		fail(fmt.Errorf("%s failed to restore artifacts: %s", prefix, err))
+	}
+
+	j.restoreCache(prefix)
+
 	for i, s := range j.Steps {
 		err := s.Run(ctx, func(c *step.RunConfig) {
 			c.Total = len(j.Steps)
 			c.Current = i + 1
-			c.Parent = fmt.Sprintf("%s[job(%d/%d): %s]", cfg.Parent, cfg.Current, cfg.Total, j.Name)
+			c.Parent = prefix
+			c.Failed = runErr != nil
 		})
 
 		if err != nil {
-			j.State.Status = "failed"
-			j.State.Error = err.Error()
-			j.State.FailedAt = time.Now()
-			// Check if error is due to context timeout
-			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-				j.State.Error = fmt.Sprintf("job timeout after %d seconds: %s", j.Timeout, err.Error())
-			}
-			return err
+			fail(err)
 		}
 	}
 
+	if runErr == nil {
+		j.saveCache(prefix)
+
+		if err := j.saveArtifacts(prefix); err != nil {
+			fail(fmt.Errorf("%s failed to save artifacts: %s", prefix, err))
+		}
+	}
+
+	if runErr != nil {
+		if j.AllowFailure {
+			j.State.Status = "failed_allowed"
+			j.logger.Warnf("%s[job(%d/%d): %s] failed (allowed): %s", cfg.Parent, cfg.Current, cfg.Total, j.Name, runErr)
+			return nil
+		}
+
+		return runErr
+	}
+
 	j.State.Status = "succeeded"
 	j.State.SucceedAt = time.Now()
 
 	return nil
 }
+
+// emit emits the event of the job to the event sink, if any
+func (j *Job) emit(typ event.Type, err error) {
+	if j.events == nil {
+		return
+	}
+
+	e := event.New(typ, j.State.ID, j.Name)
+	if typ == event.JobFinished {
+		e.Finish(j.State.Status, j.State.Error, err)
+		e.SetExit(j.State.ExitCode, j.State.Signal, j.State.OOMKilled, j.State.Duration)
+	}
+
+	j.events.Emit(e)
+}
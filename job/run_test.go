@@ -2,11 +2,13 @@ package job
 
 import (
 	"context"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-idp/pipeline/step"
+	"github.com/go-zoox/fs"
 )
 
 func TestJobTimeout(t *testing.T) {
@@ -165,3 +167,121 @@ func TestJobTimeoutWithContext(t *testing.T) {
 		}
 	})
 }
+
+func TestJobIf(t *testing.T) {
+	t.Run("steps should be skipped by if and after failure", func(t *testing.T) {
+		workdir := t.TempDir()
+
+		job := &Job{
+			Name:    "test job if",
+			Workdir: workdir,
+			Environment: map[string]string{
+				"BRANCH": "dev",
+			},
+			Steps: []*step.Step{
+				{
+					Name:    "only on main",
+					If:      "env.BRANCH == 'main'",
+					Command: "touch main.done",
+				},
+				{
+					Name:    "fail",
+					Command: "exit 1",
+				},
+				{
+					Name:    "after failure",
+					Command: "touch after.done",
+				},
+				{
+					Name:    "on failure",
+					If:      "failure()",
+					Command: "touch failure.done",
+				},
+				{
+					Name:    "always",
+					If:      "always()",
+					Command: "touch always.done",
+				},
+			},
+		}
+
+		if err := job.Setup("test-job-if"); err != nil {
+			t.Fatalf("Failed to setup job: %v", err)
+		}
+
+		if err := job.Run(context.Background()); err == nil {
+			t.Fatal("Expected error, but got nil")
+		}
+
+		if job.State.Status != "failed" {
+			t.Errorf("Expected status 'failed', got '%s'", job.State.Status)
+		}
+
+		expected := []string{"skipped", "failed", "skipped", "succeeded", "succeeded"}
+		for i, s := range job.Steps {
+			if s.State.Status != expected[i] {
+				t.Errorf("Expected step(%s) status '%s', got '%s'", s.Name, expected[i], s.State.Status)
+			}
+		}
+
+		for file, exists := range map[string]bool{
+			"main.done":    false,
+			"after.done":   false,
+			"failure.done": true,
+			"always.done":  true,
+		} {
+			if ok := fs.IsExist(filepath.Join(workdir, file)); ok != exists {
+				t.Errorf("Expected %s exists to be %v", file, exists)
+			}
+		}
+	})
+
+	t.Run("job should be skipped by if", func(t *testing.T) {
+		job := &Job{
+			Name: "test job skipped",
+			If:   "$PIPELINE_SKIP == 'true'",
+			Environment: map[string]string{
+				"PIPELINE_SKIP": "false",
+			},
+			Steps: []*step.Step{
+				{
+					Name:    "step",
+					Command: "echo hello",
+				},
+			},
+		}
+
+		if err := job.Setup("test-job-skipped"); err != nil {
+			t.Fatalf("Failed to setup job: %v", err)
+		}
+
+		if err := job.Run(context.Background()); err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+
+		if job.State.Status != "skipped" {
+			t.Errorf("Expected status 'skipped', got '%s'", job.State.Status)
+		}
+
+		if job.Steps[0].State.Status != "skipped" {
+			t.Errorf("Expected step status 'skipped', got '%s'", job.Steps[0].State.Status)
+		}
+	})
+
+	t.Run("invalid if should fail setup", func(t *testing.T) {
+		job := &Job{
+			Name: "test job invalid if",
+			If:   "env.BRANCH ==",
+			Steps: []*step.Step{
+				{
+					Name:    "step",
+					Command: "echo hello",
+				},
+			},
+		}
+
+		if err := job.Setup("test-job-invalid-if"); err == nil {
+			t.Fatal("Expected setup error, but got nil")
+		}
+	})
+}
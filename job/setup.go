@@ -5,6 +5,8 @@ import (
 	"os"
 	"time"
 
+	"github.com/go-idp/pipeline/artifact"
+	"github.com/go-idp/pipeline/expression"
 	"github.com/go-idp/pipeline/step"
 )
 
@@ -18,7 +20,7 @@ func (j *Job) Setup(id string, opts ...*Job) error {
 		}
 	}
 
-	j.logger = j.getLogger()
+	j.logger = j.getLogger(id)
 
 	// merge config
 	for _, opt := range opts {
@@ -45,6 +47,24 @@ func (j *Job) Setup(id string, opts ...*Job) error {
 		}
 	}
 
+	if j.If != "" {
+		if _, err := expression.Parse(j.If); err != nil {
+			return fmt.Errorf("invalid if of job(%s): %s", j.Name, err)
+		}
+	}
+
+	if j.Cache != nil {
+		if err := j.Cache.Validate(); err != nil {
+			return fmt.Errorf("invalid cache of job(%s): %s", j.Name, err)
+		}
+	}
+
+	for _, path := range j.Artifacts {
+		if err := artifact.ValidatePath(path); err != nil {
+			return fmt.Errorf("invalid artifacts of job(%s): %s", j.Name, err)
+		}
+	}
+
 	// setup state
 	j.State = &State{
 		ID:     id,
@@ -53,8 +73,13 @@ func (j *Job) Setup(id string, opts ...*Job) error {
 		StartedAt: time.Now(),
 	}
 
+	// the steps of the job share a scope, so that they read the outputs of the same job first, e.g. in matrix jobs
+	outputs := step.NewOutputs(j.outputs)
+
 	// setup steps
 	for index, s := range j.Steps {
+		s.SetOutputs(outputs)
+
 		err := s.Setup(fmt.Sprintf("%s.%d", j.State.ID, index), &step.Step{
 			Workdir: j.Workdir,
 			//
@@ -67,6 +92,8 @@ func (j *Job) Setup(id string, opts ...*Job) error {
 			ImageRegistry:         j.ImageRegistry,
 			ImageRegistryUsername: j.ImageRegistryUsername,
 			ImageRegistryPassword: j.ImageRegistryPassword,
+			//
+			Retry: j.Retry,
 		})
 		if err != nil {
 			return err
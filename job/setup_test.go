@@ -80,3 +80,26 @@ func TestJobSetup_MergeAndPropagateToSteps(t *testing.T) {
 	}
 }
 
+func TestJobSetup_RetryPropagateToSteps(t *testing.T) {
+	own := &step.Retry{Attempts: 5}
+	j := &Job{
+		Name:  "job",
+		Retry: &step.Retry{Attempts: 2},
+		Steps: []*step.Step{
+			{Name: "s1"},
+			// should not be overridden by job.Retry
+			{Name: "s2", Retry: own},
+		},
+	}
+
+	if err := j.Setup("jid"); err != nil {
+		t.Fatalf("Setup() error: %v", err)
+	}
+
+	if j.Steps[0].Retry == nil || j.Steps[0].Retry.Attempts != 2 {
+		t.Fatalf("step s1 retry not inherited: %#v", j.Steps[0].Retry)
+	}
+	if j.Steps[1].Retry != own {
+		t.Fatalf("step s2 retry overridden: %#v", j.Steps[1].Retry)
+	}
+}
@@ -1,14 +1,91 @@
 package job
 
-import "time"
+import (
+	"time"
+
+	"github.com/go-idp/pipeline/cache"
+)
 
 type State struct {
 	ID     string `yaml:"id"`
-	Status string `yaml:"status"` // pending | running | succeeded | failed
+	Status string `yaml:"status"` // pending | running | succeeded | failed | failed_allowed | skipped
 	//
 	StartedAt time.Time `yaml:"started_at"`
 	SucceedAt time.Time `yaml:"succeed_at"`
 	FailedAt  time.Time `yaml:"failed_at"`
 	//
 	Error string `yaml:"error"`
+	//
+	Cache *cache.Result `yaml:"cache"`
+	//
+	// ExitCode, Signal and OOMKilled are the exit of the failed step, the exit code is -1 if the job failed outside of its steps
+	ExitCode  int    `yaml:"exit_code"`
+	Signal    string `yaml:"signal"`
+	OOMKilled bool   `yaml:"oom_killed"`
+	// Duration is the duration of the job, unit: second
+	Duration float64 `yaml:"duration"`
+}
+
+// finish records the duration of the job after it ended, and the exit of its failed step
+func (j *Job) finish() {
+	ended := j.State.SucceedAt
+	if ended.IsZero() {
+		ended = j.State.FailedAt
+	}
+
+	if !ended.IsZero() {
+		j.State.Duration = ended.Sub(j.State.StartedAt).Seconds()
+	}
+
+	if j.State.Status != "failed" && j.State.Status != "failed_allowed" {
+		return
+	}
+
+	j.State.ExitCode = -1
+	for _, s := range j.Steps {
+		if s.State != nil && s.State.Status == "failed" {
+			j.State.ExitCode = s.State.ExitCode
+			j.State.Signal = s.State.Signal
+			j.State.OOMKilled = s.State.OOMKilled
+			return
+		}
+	}
+}
+
+// Exclude excludes the job and all of its steps from the run, e.g. by --skip
+func (j *Job) Exclude() {
+	j.excluded = true
+	for _, s := range j.Steps {
+		s.Exclude()
+	}
+}
+
+// Excluded returns true if the job is excluded from the run
+func (j *Job) Excluded() bool {
+	return j.excluded
+}
+
+// Restore restores the state of the job from a previous run of the pipeline, the job is not run again,
+//
+//	the states of its steps are restored by the caller
+func (j *Job) Restore(state *State) {
+	j.State = state
+	j.restored = true
+}
+
+// Restored returns true if the state of the job is restored from a previous run
+func (j *Job) Restored() bool {
+	return j.restored
+}
+
+// Skip marks the job and all of its steps as skipped
+func (j *Job) Skip() {
+	if j.State == nil {
+		return
+	}
+
+	j.State.Status = "skipped"
+	for _, s := range j.Steps {
+		s.Skip()
+	}
 }
@@ -0,0 +1,220 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParamEnvPrefix is the prefix of the environment variables of the parameters, e.g. PIPELINE_PARAM_VERSION
+const ParamEnvPrefix = "PIPELINE_PARAM_"
+
+// parameter types
+const (
+	ParamString = "string"
+	ParamNumber = "number"
+	ParamBool   = "bool"
+	ParamEnum   = "enum"
+	ParamSecret = "secret"
+)
+
+var paramNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Parameter is an input of the pipeline, given at run time, e.g. pipeline run --param version=1.0.0
+type Parameter struct {
+	Name string `json:"name" yaml:"name"`
+	// Type is the type of the value, options: string, number, bool, enum, secret, default: string
+	Type string `json:"type" yaml:"type"`
+	//
+	Description string `json:"description" yaml:"description"`
+	// Default is the value used if the parameter is not given
+	Default string `json:"default" yaml:"default"`
+	// Required fails the run if the parameter is not given and has no default
+	Required bool `json:"required" yaml:"required"`
+	// Options are the allowed values of the enum parameter
+	Options []string `json:"options" yaml:"options"`
+}
+
+// Env returns the environment variable of the parameter, e.g. PIPELINE_PARAM_IMAGE_TAG for image-tag
+func (pa *Parameter) Env() string {
+	return ParamEnvPrefix + strings.ToUpper(strings.ReplaceAll(pa.Name, "-", "_"))
+}
+
+// Secret returns true if the value of the parameter is secret, it is not persisted with the run state
+func (pa *Parameter) Secret() bool {
+	return pa.Type == ParamSecret
+}
+
+// parse checks the value against the type of the parameter, returns the normalized value
+func (pa *Parameter) parse(value string) (string, error) {
+	switch pa.Type {
+	case "", ParamString, ParamSecret:
+		return value, nil
+	case ParamNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "", fmt.Errorf("invalid number %q", value)
+		}
+		return value, nil
+	case ParamBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid bool %q, expected true or false", value)
+		}
+		return strconv.FormatBool(b), nil
+	case ParamEnum:
+		for _, option := range pa.Options {
+			if value == option {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("invalid value %q, expected one of %s", value, strings.Join(pa.Options, ", "))
+	}
+
+	return "", fmt.Errorf("unsupported type %s, options: string, number, bool, enum, secret", pa.Type)
+}
+
+// validateParams checks the definitions of the parameters
+func (v *validator) validateParams(params []*Parameter) {
+	names := map[string]string{}
+	for index, pa := range params {
+		path := fmt.Sprintf("parameters[%d]", index)
+		if pa.Name == "" {
+			v.add(path+".name", "name is required")
+			continue
+		}
+
+		if !paramNameRe.MatchString(pa.Name) {
+			v.add(path+".name", "invalid parameter name %s, only letters, digits, _ and - are allowed", pa.Name)
+		} else if previous, ok := names[pa.Env()]; ok {
+			v.add(path+".name", "duplicate parameter %s, already used by %s", pa.Name, previous)
+		}
+		names[pa.Env()] = path
+
+		switch pa.Type {
+		case "", ParamString, ParamNumber, ParamBool, ParamSecret:
+			if len(pa.Options) > 0 {
+				v.add(path+".options", "options are only allowed for enum parameters")
+			}
+		case ParamEnum:
+			if len(pa.Options) == 0 {
+				v.add(path+".options", "options are required for enum parameters")
+			}
+		default:
+			v.add(path+".type", "unsupported type %s, options: string, number, bool, enum, secret", pa.Type)
+			continue
+		}
+
+		if pa.Default != "" {
+			if _, err := pa.parse(pa.Default); err != nil {
+				v.add(path+".default", "%s", err)
+			}
+		}
+	}
+}
+
+// SetParams sets the values of the parameters of the run, by name
+func (p *Pipeline) SetParams(params map[string]string) *Pipeline {
+	if p.params == nil {
+		p.params = make(map[string]string)
+	}
+
+	for k, v := range params {
+		p.params[k] = v
+	}
+
+	return p
+}
+
+// Params returns the values of the parameters given with SetParams
+func (p *Pipeline) Params() map[string]string {
+	return p.params
+}
+
+// applyParams checks the values of the parameters and exposes them as PIPELINE_PARAM_* environment variables
+func (p *Pipeline) applyParams() error {
+	v := &validator{}
+
+	defined := map[string]bool{}
+	for index, pa := range p.Parameters {
+		defined[pa.Name] = true
+
+		value, ok := p.params[pa.Name]
+		if !ok {
+			value = pa.Default
+		}
+
+		if !ok && value == "" {
+			if pa.Required {
+				v.add(fmt.Sprintf("parameters[%d]", index), "parameter %s is required", pa.Name)
+			} else {
+				p.Environment[pa.Env()] = ""
+			}
+			continue
+		}
+
+		value, err := pa.parse(value)
+		if err != nil {
+			v.add(fmt.Sprintf("parameters[%d]", index), "parameter %s: %s", pa.Name, err)
+			continue
+		}
+
+		p.Environment[pa.Env()] = value
+	}
+
+	unknown := []string{}
+	for name := range p.params {
+		if !defined[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		v.add("parameters", "unknown parameter %s", name)
+	}
+
+	if len(v.errors) > 0 {
+		return fmt.Errorf("[workflow][prepare] invalid parameters:\n%s", v.errors)
+	}
+
+	return nil
+}
+
+// PersistedParams returns the values of the parameters given with SetParams without the secrets,
+//
+//	e.g. persisted with the run state, or in the queue of the server
+func (p *Pipeline) PersistedParams() map[string]string {
+	secrets := map[string]bool{}
+	for _, pa := range p.Parameters {
+		secrets[pa.Name] = pa.Secret()
+	}
+
+	params := map[string]string{}
+	for k, v := range p.params {
+		if !secrets[k] {
+			params[k] = v
+		}
+	}
+
+	if len(params) == 0 {
+		return nil
+	}
+
+	return params
+}
+
+// ParseParams parses the values of the parameters in KEY=VALUE format, e.g. --param version=1.0.0
+func ParseParams(values []string) (map[string]string, error) {
+	params := map[string]string{}
+	for _, value := range values {
+		k, v, ok := strings.Cut(value, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid param %q, expected KEY=VALUE", value)
+		}
+
+		params[k] = v
+	}
+
+	return params, nil
+}
@@ -0,0 +1,216 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const paramsConfig = `
+name: test pipeline params
+parameters:
+  - name: version
+    required: true
+  - name: replicas
+    type: number
+    default: 2
+  - name: debug
+    type: bool
+    default: false
+  - name: image-tag
+    type: enum
+    options: [latest, stable]
+    default: latest
+  - name: token
+    type: secret
+  - name: notes
+stages:
+  - name: build
+    jobs:
+      - name: build
+        steps:
+          - name: build
+            command: echo ${PIPELINE_PARAM_VERSION}
+`
+
+func TestPipelineParams(t *testing.T) {
+	t.Run("should expose the values and defaults as environment variables", func(t *testing.T) {
+		p, err := LoadYAML([]byte(paramsConfig), "")
+		if err != nil {
+			t.Fatalf("Failed to load pipeline: %v", err)
+		}
+
+		p.SetStdout(&bytes.Buffer{})
+		p.SetParams(map[string]string{"version": "1.0.0", "debug": "1", "token": "s3cr3t"})
+		plan, err := p.Plan()
+		if err != nil {
+			t.Fatalf("Failed to plan pipeline: %v", err)
+		}
+
+		for k, v := range map[string]string{
+			"PIPELINE_PARAM_VERSION":   "1.0.0",
+			"PIPELINE_PARAM_REPLICAS":  "2",
+			"PIPELINE_PARAM_DEBUG":     "true",
+			"PIPELINE_PARAM_IMAGE_TAG": "latest",
+			"PIPELINE_PARAM_NOTES":     "",
+		} {
+			if got, ok := p.Environment[k]; !ok || got != v {
+				t.Errorf("Expected %s=%s, got %s", k, v, got)
+			}
+		}
+
+		// the shell resolves the parameter with the environment
+		if got := plan.Stages[0].Jobs[0].Steps[0].Command; got != "echo ${PIPELINE_PARAM_VERSION}" {
+			t.Errorf("Expected the command to be kept as is, got %s", got)
+		}
+
+		// secrets are hidden in the plan, not in the pipeline
+		if got := plan.Environment["PIPELINE_PARAM_TOKEN"]; got != "******" {
+			t.Errorf("Expected the secret to be masked in the plan, got %s", got)
+		}
+		if got := p.Environment["PIPELINE_PARAM_TOKEN"]; got != "s3cr3t" {
+			t.Errorf("Expected the secret in the environment, got %s", got)
+		}
+	})
+
+	t.Run("should reject invalid values at run time", func(t *testing.T) {
+		p, err := LoadYAML([]byte(paramsConfig), "")
+		if err != nil {
+			t.Fatalf("Failed to load pipeline: %v", err)
+		}
+
+		p.SetStdout(&bytes.Buffer{})
+		p.SetParams(map[string]string{"replicas": "two", "image-tag": "edge", "unknown": "x"})
+		_, err = p.Plan()
+		if err == nil {
+			t.Fatalf("Expected invalid parameters error")
+		}
+
+		for _, expected := range []string{
+			"parameters[0]: parameter version is required",
+			`parameters[1]: parameter replicas: invalid number "two"`,
+			`parameters[3]: parameter image-tag: invalid value "edge", expected one of latest, stable`,
+			"parameters: unknown parameter unknown",
+		} {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("Expected error containing %q, got %v", expected, err)
+			}
+		}
+	})
+
+	t.Run("should validate the definitions", func(t *testing.T) {
+		_, err := ValidateYAML([]byte(`
+name: test pipeline params
+parameters:
+  - name: env
+    type: enum
+  - name: count
+    type: number
+    default: many
+  - name: mode
+    type: list
+  - name: env
+stages:
+  - name: build
+    jobs:
+      - name: build
+        steps:
+          - name: build
+            command: echo
+`))
+		if err == nil {
+			t.Fatalf("Expected validation errors")
+		}
+
+		errs := err.(ValidationErrors)
+		expected := []string{
+			"parameters[0].options: options are required for enum parameters",
+			`parameters[1].default: invalid number "many"`,
+			"parameters[2].type: unsupported type list, options: string, number, bool, enum, secret",
+			"parameters[3].name: duplicate parameter env, already used by parameters[0]",
+		}
+		if len(errs) != len(expected) {
+			t.Fatalf("Expected %d errors, got %v", len(expected), err)
+		}
+		for i, e := range errs {
+			if got := e.Path + ": " + e.Message; got != expected[i] {
+				t.Errorf("Expected %q, got %q", expected[i], got)
+			}
+		}
+	})
+
+	t.Run("should mask the default of a secret parameter", func(t *testing.T) {
+		config := strings.Replace(paramsConfig, "    type: secret\n", "    type: secret\n    default: s3cr3t-default\n", 1)
+		p, err := LoadYAML([]byte(strings.Replace(config, "command: echo ${PIPELINE_PARAM_VERSION}", "command: echo token=${PIPELINE_PARAM_TOKEN}", 1)), "")
+		if err != nil {
+			t.Fatalf("Failed to load pipeline: %v", err)
+		}
+
+		stdout := &bytes.Buffer{}
+		p.SetWorkdir(t.TempDir())
+		p.SetStdout(stdout)
+		p.SetParams(map[string]string{"version": "1.0.0"})
+		if err := p.Run(context.Background()); err != nil {
+			t.Fatalf("Failed to run pipeline: %v", err)
+		}
+
+		if strings.Contains(stdout.String(), "s3cr3t-default") {
+			t.Errorf("Expected the default of the secret to be masked, got:\n%s", stdout.String())
+		}
+		if !strings.Contains(stdout.String(), "token=") {
+			t.Errorf("Expected the output of the command, got:\n%s", stdout.String())
+		}
+	})
+
+	t.Run("should not persist the secrets with the run state", func(t *testing.T) {
+		workdir := filepath.Join(t.TempDir(), "workdir")
+		p, err := LoadYAML([]byte(strings.Replace(paramsConfig, "command: echo ${PIPELINE_PARAM_VERSION}", "command: exit 1", 1)), "")
+		if err != nil {
+			t.Fatalf("Failed to load pipeline: %v", err)
+		}
+
+		p.SetWorkdir(workdir)
+		p.SetStdout(&bytes.Buffer{})
+		p.SetParams(map[string]string{"version": "1.0.0", "token": "s3cr3t"})
+		if err := p.Run(context.Background()); err == nil {
+			t.Fatalf("Expected the run to fail")
+		}
+
+		rs, err := ReadRunState(workdir)
+		if err != nil {
+			t.Fatalf("Failed to read run state: %v", err)
+		}
+
+		if rs.Params["version"] != "1.0.0" {
+			t.Errorf("Expected the parameters in the run state, got %v", rs.Params)
+		}
+		if _, ok := rs.Params["token"]; ok {
+			t.Errorf("Expected the secret not to be persisted")
+		}
+
+		resumed, err := rs.Pipeline()
+		if err != nil {
+			t.Fatalf("Failed to load pipeline of run state: %v", err)
+		}
+		if resumed.Params()["version"] != "1.0.0" {
+			t.Errorf("Expected the parameters of the run state, got %v", resumed.Params())
+		}
+	})
+}
+
+func TestParseParams(t *testing.T) {
+	params, err := ParseParams([]string{"version=1.0.0", "args=a=b", "empty="})
+	if err != nil {
+		t.Fatalf("Failed to parse params: %v", err)
+	}
+
+	if params["version"] != "1.0.0" || params["args"] != "a=b" || params["empty"] != "" {
+		t.Errorf("Unexpected params %v", params)
+	}
+
+	if _, err := ParseParams([]string{"version"}); err == nil {
+		t.Errorf("Expected error for param without value")
+	}
+}
@@ -7,7 +7,11 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/go-idp/pipeline/artifact"
+	"github.com/go-idp/pipeline/cache"
+	"github.com/go-idp/pipeline/event"
 	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/secret"
 	"github.com/go-idp/pipeline/stage"
 	"github.com/go-idp/pipeline/step"
 	"github.com/go-zoox/encoding/yaml"
@@ -31,15 +35,57 @@ type Pipeline struct {
 	//
 	State *State `json:"state" yaml:"state"`
 	//
-	Pre  string `json:"pre" yaml:"pre"`
+	Pre string `json:"pre" yaml:"pre"`
+	// Post is the command run after the stages, regardless of the outcome, as the last finally stage
 	Post string `json:"post" yaml:"post"`
+	// Finally are the stages run after the stages, regardless of the outcome, e.g. tear down services
+	Finally []*stage.Stage `json:"finally" yaml:"finally"`
+	// OnSuccess are the stages run after the stages succeed, before finally
+	OnSuccess []*stage.Stage `json:"on_success" yaml:"on_success"`
+	// OnFailure are the stages run after the stages fail, before finally
+	OnFailure []*stage.Stage `json:"on_failure" yaml:"on_failure"`
+	// Include are the configs merged into the pipeline by Load, local paths relative to the config or http(s) urls
+	Include []string `json:"include" yaml:"include"`
+	// Templates are the named stages, jobs and steps inherited by extends
+	Templates *Templates `json:"templates" yaml:"templates"`
+	// Parameters are the typed inputs of the pipeline, exposed as PIPELINE_PARAM_* environment variables
+	Parameters []*Parameter `json:"parameters" yaml:"parameters"`
 	//
 	stdout io.Writer
 	stderr io.Writer
+	//
+	dag *dag
+	//
+	outputs *step.Outputs
+	//
+	artifacts        artifact.Store
+	defaultArtifacts bool
+	//
+	caches cache.Store
+	// dryRun is set by Plan, nothing is created on disk
+	dryRun bool
+	// config is the configuration before prepare, and filter the filter of the run, persisted to resume the run,
+	//	the passwords in plain text are redacted from config
+	config         string
+	configRedacted bool
+	filter         *Filter
+	// params are the values of the parameters, by name
+	params map[string]string
+	// secrets resolve ${{ secrets.NAME }}, their values are masked by masker in all the outputs
+	secrets     secret.Store
+	masker      *secret.Masker
+	maskWriters []*secret.Writer
+	// events receives the events of the run, e.g. --events-file
+	events event.Sink
 }
 
 type RunConfig struct {
 	ID string
+	// Filter selects the units of the stages to run, the others are skipped
+	Filter *Filter
+	// Resume is the state of a failed run to resume, only its failed and not-yet-run units run again,
+	//	the id and filter of the run are reused
+	Resume *RunState
 }
 
 type RunOption func(cfg *RunConfig)
@@ -77,7 +123,7 @@ func (p *Pipeline) prepare(id string) error {
 	}
 
 	// if workdir is current dir, skip create
-	if p.Workdir != fs.CurrentDir() {
+	if p.Workdir != fs.CurrentDir() && !p.dryRun {
 		if ok := fs.IsExist(p.Workdir); !ok {
 			logger.Infof("[workflow][prepare] create workdir(path: %s)", p.Workdir)
 			if err := fs.Mkdirp(p.Workdir); err != nil {
@@ -108,7 +154,21 @@ func (p *Pipeline) prepare(id string) error {
 		return fmt.Errorf("[workflow][prepare] no stages found, stages is required")
 	}
 
-	// add pre/post stage
+	// misconfigurations fail the pipeline before any stage runs,
+	//	duplicate names are allowed, needs on an ambiguous job fails in buildDAG
+	if err := p.validate(false); err != nil {
+		return fmt.Errorf("[workflow][prepare] invalid pipeline:\n%s", err)
+	}
+
+	if err := p.applyParams(); err != nil {
+		return err
+	}
+
+	if err := p.interpolate(); err != nil {
+		return err
+	}
+
+	// add pre/post stage, post always runs as the last finally stage
 	if p.Pre != "" {
 		p.Stages = append([]*stage.Stage{
 			{
@@ -128,7 +188,7 @@ func (p *Pipeline) prepare(id string) error {
 		}, p.Stages...)
 	}
 	if p.Post != "" {
-		p.Stages = append(p.Stages, &stage.Stage{
+		p.Finally = append(p.Finally, &stage.Stage{
 			Name: "post",
 			Jobs: []*job.Job{
 				{
@@ -144,6 +204,21 @@ func (p *Pipeline) prepare(id string) error {
 		})
 	}
 
+	// the secrets are resolved, mask them in the outputs of the stages from now on
+	p.collectSecrets()
+	p.maskOutput()
+
+	p.outputs = step.NewOutputs(nil)
+
+	if p.artifacts == nil {
+		p.artifacts = defaultArtifactStore()
+		p.defaultArtifacts = true
+	}
+
+	if p.caches == nil {
+		p.caches = cache.NewLocalStore(cache.DefaultDir())
+	}
+
 	// setup state
 	p.State = &State{
 		ID:     id,
@@ -153,28 +228,73 @@ func (p *Pipeline) prepare(id string) error {
 	}
 
 	for index, s := range p.Stages {
-		err := s.Setup(fmt.Sprintf("%s.%d", p.State.ID, index), &stage.Stage{
-			Workdir: p.Workdir,
-			//
-			Environment: p.Environment,
-			//
-			Image: p.Image,
-			//
-			Timeout: p.Timeout,
-		})
+		if err := p.setupStage(s, fmt.Sprintf("%s.%d", p.State.ID, index)); err != nil {
+			return err
+		}
+	}
+
+	for _, h := range []*hook{
+		{Name: "on_success", Stages: p.OnSuccess},
+		{Name: "on_failure", Stages: p.OnFailure},
+		{Name: "finally", Stages: p.Finally},
+	} {
+		for index, s := range h.Stages {
+			if err := p.setupStage(s, fmt.Sprintf("%s.%s.%d", p.State.ID, h.Name, index)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := p.validateDependencies(); err != nil {
+		return err
+	}
+
+	// jobs with needs are scheduled by the dependency graph instead of stage by stage
+	if p.hasNeeds() {
+		d, err := p.buildDAG()
 		if err != nil {
 			return err
 		}
+
+		p.dag = d
 	}
 
 	return nil
 }
 
+func (p *Pipeline) setupStage(s *stage.Stage, id string) error {
+	// the outputs of the steps are shared by the whole pipeline
+	s.SetOutputs(p.outputs)
+	s.SetArtifactStore(p.artifacts, p.State.ID)
+	s.SetCacheStore(p.caches)
+	s.SetEventSink(p.eventSink())
+
+	return s.Setup(id, &stage.Stage{
+		Workdir: p.Workdir,
+		//
+		Environment: p.Environment,
+		//
+		Image: p.Image,
+		//
+		Timeout: p.Timeout,
+	})
+}
+
 func (p *Pipeline) clean() error {
 	logger := p.getLogger()
 	logger.Infof("[workflow][clean] start ...")
 	defer logger.Infof("[workflow][clean] done")
 
+	// a succeeded run has nothing to resume
+	p.removeState()
+
+	// the default artifact store only lives as long as the run
+	if p.defaultArtifacts {
+		if err := p.artifacts.Delete(p.State.ID); err != nil {
+			logger.Warnf("[workflow][clean] failed to clean artifacts: %s", err)
+		}
+	}
+
 	if p.Workdir == "" {
 		return nil
 	}
@@ -205,14 +325,14 @@ func (p *Pipeline) clean() error {
 	return nil
 }
 
-// String returns the string representation of the pipeline
+// String returns the string representation of the pipeline, the secrets are masked
 func (p *Pipeline) String() string {
 	v, err := yaml.Encode(p)
 	if err != nil {
 		return fmt.Sprintf("error: %v", err)
 	}
 
-	return string(v)
+	return p.Redact(string(v))
 }
 
 // SetWorkdir sets the workdir of the pipeline
@@ -252,7 +372,7 @@ func (p *Pipeline) SetTimeout(timeout int64) *Pipeline {
 func (p *Pipeline) SetStdout(stdout io.Writer) *Pipeline {
 	p.stdout = stdout
 
-	for _, stage := range p.Stages {
+	for _, stage := range p.allStages() {
 		stage.SetStdout(stdout)
 	}
 
@@ -263,7 +383,7 @@ func (p *Pipeline) SetStdout(stdout io.Writer) *Pipeline {
 func (p *Pipeline) SetStderr(stderr io.Writer) *Pipeline {
 	p.stderr = stderr
 
-	for _, stage := range p.Stages {
+	for _, stage := range p.allStages() {
 		stage.SetStderr(stderr)
 	}
 
@@ -0,0 +1,353 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/secret"
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-idp/pipeline/step"
+	"github.com/go-zoox/encoding/yaml"
+	"github.com/go-zoox/uuid"
+)
+
+// Plan is the resolved execution plan of the pipeline,
+//
+//	after the inheritance of workdir, image, environment and timeout,
+//	matrix expansion, and the commands generated by plugins and services
+type Plan struct {
+	Name    string `json:"name" yaml:"name"`
+	Workdir string `json:"workdir" yaml:"workdir"`
+	Image   string `json:"image,omitempty" yaml:"image,omitempty"`
+	Timeout int64  `json:"timeout" yaml:"timeout"`
+	// Schedule is how the jobs are scheduled, stages (stage by stage) or dag (by needs)
+	Schedule    string            `json:"schedule" yaml:"schedule"`
+	Environment map[string]string `json:"environment" yaml:"environment"`
+	//
+	Stages    []*PlanStage `json:"stages" yaml:"stages"`
+	OnSuccess []*PlanStage `json:"on_success,omitempty" yaml:"on_success,omitempty"`
+	OnFailure []*PlanStage `json:"on_failure,omitempty" yaml:"on_failure,omitempty"`
+	Finally   []*PlanStage `json:"finally,omitempty" yaml:"finally,omitempty"`
+}
+
+// PlanStage is a stage of the plan
+type PlanStage struct {
+	Name    string `json:"name" yaml:"name"`
+	RunMode string `json:"run_mode" yaml:"run_mode"`
+	Timeout int64  `json:"timeout" yaml:"timeout"`
+	If      string `json:"if,omitempty" yaml:"if,omitempty"`
+	// Skipped is true if the stage is skipped by the filter, e.g. --skip
+	Skipped bool       `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+	Jobs    []*PlanJob `json:"jobs" yaml:"jobs"`
+}
+
+// PlanJob is a job of the plan
+type PlanJob struct {
+	Name string `json:"name" yaml:"name"`
+	// Origin is the name of the job the matrix job is expanded from
+	Origin       string   `json:"origin,omitempty" yaml:"origin,omitempty"`
+	Needs        []string `json:"needs,omitempty" yaml:"needs,omitempty"`
+	Dependencies []string `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
+	If           string   `json:"if,omitempty" yaml:"if,omitempty"`
+	Timeout      int64    `json:"timeout" yaml:"timeout"`
+	Workdir      string   `json:"workdir" yaml:"workdir"`
+	Image        string   `json:"image,omitempty" yaml:"image,omitempty"`
+	// Skipped is true if the job is skipped by the filter, e.g. --skip
+	Skipped bool `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+	//
+	Steps []*PlanStep `json:"steps" yaml:"steps"`
+}
+
+// PlanStep is a step of the plan
+type PlanStep struct {
+	Name string `json:"name" yaml:"name"`
+	// Engine is the effective engine, e.g. host, docker, ssh, idp
+	Engine  string `json:"engine" yaml:"engine"`
+	Image   string `json:"image,omitempty" yaml:"image,omitempty"`
+	Workdir string `json:"workdir" yaml:"workdir"`
+	Shell   string `json:"shell,omitempty" yaml:"shell,omitempty"`
+	Timeout int64  `json:"timeout" yaml:"timeout"`
+	If      string `json:"if,omitempty" yaml:"if,omitempty"`
+	// Skipped is true if the step is skipped by the filter, e.g. --skip
+	Skipped     bool              `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+	Environment map[string]string `json:"environment" yaml:"environment"`
+	// Command is the command to run, generated by the plugin or service if used
+	Command string `json:"command" yaml:"command"`
+}
+
+// Plan sets up the whole pipeline without running anything, and returns the resolved plan,
+//
+//	the pipeline is prepared by Plan, do not run it afterwards
+func (p *Pipeline) Plan(opts ...RunOption) (*Plan, error) {
+	cfg := &RunConfig{
+		ID: uuid.V4(),
+	}
+	for _, o := range opts {
+		o(cfg)
+	}
+
+	p.dryRun = true
+	if err := p.resolveTemplates(); err != nil {
+		return nil, fmt.Errorf("[workflow][prepare] invalid pipeline:\n%s", err)
+	}
+
+	if err := p.applyFilter(cfg.Filter); err != nil {
+		return nil, err
+	}
+
+	if err := p.prepare(cfg.ID); err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Name:        p.Name,
+		Workdir:     p.Workdir,
+		Image:       p.Image,
+		Timeout:     p.Timeout,
+		Schedule:    "stages",
+		Environment: p.Environment,
+	}
+	if p.dag != nil {
+		plan.Schedule = "dag"
+	}
+
+	for _, group := range []struct {
+		target *[]*PlanStage
+		stages []*stage.Stage
+	}{
+		{&plan.Stages, p.Stages},
+		{&plan.OnSuccess, p.OnSuccess},
+		{&plan.OnFailure, p.OnFailure},
+		{&plan.Finally, p.Finally},
+	} {
+		for _, s := range group.stages {
+			ps, err := planStage(s)
+			if err != nil {
+				return nil, err
+			}
+
+			*group.target = append(*group.target, ps)
+		}
+	}
+
+	plan.maskSecrets(p.getMasker())
+
+	return plan, nil
+}
+
+// maskSecrets hides the values of the secrets in the environment and commands of the plan
+func (pl *Plan) maskSecrets(m *secret.Masker) {
+	mask := func(env map[string]string) map[string]string {
+		masked := make(map[string]string, len(env))
+		for k, v := range env {
+			masked[k] = m.Mask(v)
+		}
+
+		return masked
+	}
+
+	pl.Environment = mask(pl.Environment)
+	for _, stages := range [][]*PlanStage{pl.Stages, pl.OnSuccess, pl.OnFailure, pl.Finally} {
+		for _, s := range stages {
+			for _, j := range s.Jobs {
+				for _, st := range j.Steps {
+					st.Environment = mask(st.Environment)
+					st.Command = m.Mask(st.Command)
+				}
+			}
+		}
+	}
+}
+
+func planStage(s *stage.Stage) (*PlanStage, error) {
+	ps := &PlanStage{
+		Name:    s.Name,
+		RunMode: s.RunMode,
+		Timeout: s.Timeout,
+		If:      s.If,
+		Skipped: s.Excluded(),
+	}
+	if ps.RunMode == "" {
+		ps.RunMode = stage.RunModeParallel
+	}
+
+	for _, j := range s.Jobs {
+		pj, err := planJob(j)
+		if err != nil {
+			return nil, err
+		}
+
+		ps.Jobs = append(ps.Jobs, pj)
+	}
+
+	return ps, nil
+}
+
+func planJob(j *job.Job) (*PlanJob, error) {
+	pj := &PlanJob{
+		Name:         j.Name,
+		Origin:       j.Origin(),
+		Needs:        j.Needs,
+		Dependencies: j.Dependencies,
+		If:           j.If,
+		Timeout:      j.Timeout,
+		Workdir:      j.Workdir,
+		Image:        j.Image,
+		Skipped:      j.Excluded(),
+	}
+
+	for _, s := range j.Steps {
+		ps, err := planStep(s)
+		if err != nil {
+			return nil, fmt.Errorf("[workflow][plan] job(%s): %s", j.Name, err)
+		}
+
+		pj.Steps = append(pj.Steps, ps)
+	}
+
+	return pj, nil
+}
+
+func planStep(s *step.Step) (*PlanStep, error) {
+	engine, err := s.EffectiveEngine()
+	if err != nil {
+		return nil, fmt.Errorf("step(%s): %s", s.Name, err)
+	}
+
+	return &PlanStep{
+		Name:        s.Name,
+		Engine:      engine,
+		Image:       s.Image,
+		Workdir:     s.Workdir,
+		Shell:       s.Shell,
+		Timeout:     s.Timeout,
+		If:          s.If,
+		Skipped:     s.Excluded(),
+		Environment: s.Environment,
+		Command:     s.Command,
+	}, nil
+}
+
+// Render renders the plan in the format, yaml, json or tree
+func (pl *Plan) Render(format string) (string, error) {
+	switch format {
+	case "yaml":
+		data, err := yaml.Encode(pl)
+		if err != nil {
+			return "", err
+		}
+
+		return string(data), nil
+	case "json":
+		data, err := json.MarshalIndent(pl, "", "  ")
+		if err != nil {
+			return "", err
+		}
+
+		return string(data) + "\n", nil
+	case "", "tree":
+		return pl.tree(), nil
+	default:
+		return "", fmt.Errorf("unsupported plan format %s, only support yaml | json | tree", format)
+	}
+}
+
+// tree renders the plan as an ascii tree, the environment is listed by keys only
+func (pl *Plan) tree() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "pipeline: %s (workdir: %s, timeout: %ds, schedule: %s)\n", pl.Name, pl.Workdir, pl.Timeout, pl.Schedule)
+
+	groups := []struct {
+		name   string
+		stages []*PlanStage
+	}{
+		{"stages", pl.Stages},
+		{"on_success", pl.OnSuccess},
+		{"on_failure", pl.OnFailure},
+		{"finally", pl.Finally},
+	}
+
+	for gi, group := range groups {
+		if len(group.stages) == 0 {
+			continue
+		}
+
+		last := true
+		for _, next := range groups[gi+1:] {
+			if len(next.stages) > 0 {
+				last = false
+			}
+		}
+
+		gprefix, gindent := branch(last)
+		fmt.Fprintf(&b, "%s%s\n", gprefix, group.name)
+
+		for si, s := range group.stages {
+			sprefix, sindent := branch(si == len(group.stages)-1)
+			fmt.Fprintf(&b, "%s%sstage: %s (run_mode: %s, timeout: %ds%s)%s\n", gindent, sprefix, s.Name, s.RunMode, s.Timeout, condition(s.If), skipped(s.Skipped))
+
+			for ji, j := range s.Jobs {
+				jprefix, jindent := branch(ji == len(s.Jobs)-1)
+				details := fmt.Sprintf("workdir: %s, timeout: %ds", j.Workdir, j.Timeout)
+				if len(j.Needs) > 0 {
+					details += fmt.Sprintf(", needs: %s", strings.Join(j.Needs, ", "))
+				}
+				fmt.Fprintf(&b, "%s%s%sjob: %s (%s%s)%s\n", gindent, sindent, jprefix, j.Name, details, condition(j.If), skipped(j.Skipped))
+
+				for ti, st := range j.Steps {
+					tprefix, tindent := branch(ti == len(j.Steps)-1)
+					indent := gindent + sindent + jindent + tindent
+
+					engine := st.Engine
+					if st.Image != "" {
+						engine += ", image: " + st.Image
+					}
+					fmt.Fprintf(&b, "%s%s%s%sstep: %s (engine: %s, timeout: %ds%s)%s\n", gindent, sindent, jindent, tprefix, st.Name, engine, st.Timeout, condition(st.If), skipped(st.Skipped))
+					fmt.Fprintf(&b, "%s  workdir: %s\n", indent, st.Workdir)
+
+					keys := make([]string, 0, len(st.Environment))
+					for k := range st.Environment {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					fmt.Fprintf(&b, "%s  environment: %s\n", indent, strings.Join(keys, ", "))
+
+					fmt.Fprintf(&b, "%s  command:\n", indent)
+					for _, line := range strings.Split(strings.TrimRight(st.Command, "\n"), "\n") {
+						fmt.Fprintf(&b, "%s    %s\n", indent, line)
+					}
+				}
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// branch returns the prefix of the node and the indent of its children in the tree
+func branch(last bool) (string, string) {
+	if last {
+		return "└── ", "    "
+	}
+
+	return "├── ", "│   "
+}
+
+func condition(expr string) string {
+	if expr == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(", if: %s", expr)
+}
+
+func skipped(ok bool) string {
+	if !ok {
+		return ""
+	}
+
+	return " [skipped]"
+}
@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-idp/pipeline/step"
+	"github.com/go-zoox/fs"
+)
+
+func TestPipelinePlan(t *testing.T) {
+	newPipeline := func(workdir string) *Pipeline {
+		return &Pipeline{
+			Name:    "test pipeline plan",
+			Workdir: workdir,
+			Image:   "alpine:3",
+			Timeout: 600,
+			Environment: map[string]string{
+				"TAG": "v1",
+			},
+			Stages: []*stage.Stage{
+				{
+					Name: "build",
+					Jobs: []*job.Job{
+						{
+							Name: "build",
+							Steps: []*step.Step{
+								{
+									Name:    "compile",
+									Command: "echo ${TAG}",
+								},
+								{
+									Name:    "lint",
+									Command: "golangci-lint run",
+									Plugin: &step.Plugin{
+										Image: "ghcr.io/go-idp/lint:latest",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("should resolve the inherited fields and generated commands", func(t *testing.T) {
+		workdir := filepath.Join(t.TempDir(), "workdir")
+		p := newPipeline(workdir)
+		p.SetStdout(&strings.Builder{})
+
+		plan, err := p.Plan()
+		if err != nil {
+			t.Fatalf("Failed to plan pipeline: %v", err)
+		}
+
+		if fs.IsExist(workdir) {
+			t.Errorf("Expected workdir %s not to be created by plan", workdir)
+		}
+
+		steps := plan.Stages[0].Jobs[0].Steps
+		compile := steps[0]
+		if compile.Engine != "docker" || compile.Image != "alpine:3" {
+			t.Errorf("Expected engine docker with image alpine:3, got %s with %s", compile.Engine, compile.Image)
+		}
+		if compile.Timeout != 600 {
+			t.Errorf("Expected timeout 600, got %d", compile.Timeout)
+		}
+		if compile.Environment["TAG"] != "v1" {
+			t.Errorf("Expected TAG=v1, got %s", compile.Environment["TAG"])
+		}
+		// the variables of commands are resolved by the shell
+		if compile.Command != "echo ${TAG}" {
+			t.Errorf("Expected command echo ${TAG}, got %s", compile.Command)
+		}
+		if compile.Workdir != workdir {
+			t.Errorf("Expected workdir %s, got %s", workdir, compile.Workdir)
+		}
+
+		lint := steps[1]
+		if lint.Image != "ghcr.io/go-idp/lint:latest" {
+			t.Errorf("Expected plugin image, got %s", lint.Image)
+		}
+		if !strings.Contains(lint.Command, "/pipeline/plugin/run") {
+			t.Errorf("Expected the plugin command to be generated, got %s", lint.Command)
+		}
+		if lint.Environment["PIPELINE_PLUGIN_COMMAND"] == "" {
+			t.Errorf("Expected PIPELINE_PLUGIN_COMMAND to be set")
+		}
+	})
+
+	t.Run("should render tree, yaml and json", func(t *testing.T) {
+		p := newPipeline(t.TempDir())
+		p.SetStdout(&strings.Builder{})
+
+		plan, err := p.Plan()
+		if err != nil {
+			t.Fatalf("Failed to plan pipeline: %v", err)
+		}
+
+		tree, err := plan.Render("tree")
+		if err != nil {
+			t.Fatalf("Failed to render tree: %v", err)
+		}
+		for _, expected := range []string{"stage: build", "job: build", "step: compile (engine: docker, image: alpine:3", "echo ${TAG}"} {
+			if !strings.Contains(tree, expected) {
+				t.Errorf("Expected tree to contain %q, got:\n%s", expected, tree)
+			}
+		}
+
+		data, err := plan.Render("json")
+		if err != nil {
+			t.Fatalf("Failed to render json: %v", err)
+		}
+		decoded := &Plan{}
+		if err := json.Unmarshal([]byte(data), decoded); err != nil {
+			t.Fatalf("Failed to decode json plan: %v", err)
+		}
+		if decoded.Stages[0].Jobs[0].Steps[0].Command != "echo ${TAG}" {
+			t.Errorf("Expected json plan to keep the command, got %s", decoded.Stages[0].Jobs[0].Steps[0].Command)
+		}
+
+		yml, err := plan.Render("yaml")
+		if err != nil {
+			t.Fatalf("Failed to render yaml: %v", err)
+		}
+		if !strings.Contains(yml, "engine: docker") {
+			t.Errorf("Expected yaml to contain the engine, got:\n%s", yml)
+		}
+
+		if _, err := plan.Render("xml"); err == nil {
+			t.Errorf("Expected unsupported format to fail")
+		}
+	})
+
+	t.Run("should report the invalid pipeline", func(t *testing.T) {
+		p := newPipeline(t.TempDir())
+		p.SetStdout(&strings.Builder{})
+		p.Stages[0].Jobs[0].Steps[0].Command = "echo ${{ unknown('x') }}"
+
+		if _, err := p.Plan(); err == nil {
+			t.Errorf("Expected plan to fail with unknown function")
+		}
+	})
+}
@@ -0,0 +1,357 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-idp/pipeline/step"
+)
+
+// Report is the outcome of a run of the pipeline, the final states of its stages, jobs and steps
+type Report struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	//
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	// Duration is the duration of the run, unit: second
+	Duration float64 `json:"duration"`
+	// ExitCode, Signal and OOMKilled are the exit of the failed step, the exit code is -1 if unknown
+	ExitCode  int    `json:"exit_code,omitempty"`
+	Signal    string `json:"signal,omitempty"`
+	OOMKilled bool   `json:"oom_killed,omitempty"`
+	//
+	// HasWarnings is true if the pipeline succeeded with allowed failures, listed in Warnings
+	HasWarnings bool     `json:"has_warnings"`
+	Warnings    []string `json:"warnings,omitempty"`
+	HookErrors  []string `json:"hook_errors,omitempty"`
+	//
+	Stages    []*ReportStage `json:"stages"`
+	OnSuccess []*ReportStage `json:"on_success,omitempty"`
+	OnFailure []*ReportStage `json:"on_failure,omitempty"`
+	Finally   []*ReportStage `json:"finally,omitempty"`
+}
+
+// ReportUnit is the outcome of a stage, job or step,
+//
+//	the units not started, e.g. skipped, have no start time
+type ReportUnit struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"` // pending | succeeded | failed | failed_allowed | skipped
+	Error  string `json:"error,omitempty"`
+	//
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	// Duration is the duration of the unit, unit: second
+	Duration float64 `json:"duration"`
+	// ExitCode, Signal and OOMKilled are the exit of the step, or of the failed step of the stage or job
+	ExitCode  int    `json:"exit_code,omitempty"`
+	Signal    string `json:"signal,omitempty"`
+	OOMKilled bool   `json:"oom_killed,omitempty"`
+}
+
+// ReportStage is the outcome of a stage
+type ReportStage struct {
+	ReportUnit
+	Jobs []*ReportJob `json:"jobs"`
+}
+
+// ReportJob is the outcome of a job
+type ReportJob struct {
+	ReportUnit
+	Steps []*ReportStep `json:"steps"`
+}
+
+// ReportStep is the outcome of a step
+type ReportStep struct {
+	ReportUnit
+	// Attempts are the attempts of the step with retry
+	Attempts []*step.Attempt `json:"attempts,omitempty"`
+}
+
+// Report returns the report of the last run of the pipeline, after Run
+func (p *Pipeline) Report() (*Report, error) {
+	if p.State == nil {
+		return nil, fmt.Errorf("the pipeline has not run yet")
+	}
+
+	r := &Report{
+		ID:          p.State.ID,
+		Name:        p.Name,
+		Status:      p.State.Status,
+		Error:       p.State.Error,
+		StartedAt:   p.State.StartedAt,
+		HasWarnings: p.State.HasWarnings(),
+		Warnings:    p.State.Warnings,
+		HookErrors:  p.State.HookErrors,
+		ExitCode:    p.State.ExitCode,
+		Signal:      p.State.Signal,
+		OOMKilled:   p.State.OOMKilled,
+	}
+	if ended := endedAt(p.State.SucceedAt, p.State.FailedAt); ended != nil {
+		r.EndedAt = ended
+		r.Duration = ended.Sub(p.State.StartedAt).Seconds()
+	}
+
+	for _, group := range []struct {
+		target *[]*ReportStage
+		stages []*stage.Stage
+	}{
+		{&r.Stages, p.Stages},
+		{&r.OnSuccess, p.OnSuccess},
+		{&r.OnFailure, p.OnFailure},
+		{&r.Finally, p.Finally},
+	} {
+		for _, s := range group.stages {
+			*group.target = append(*group.target, reportStage(s))
+		}
+	}
+
+	return r, nil
+}
+
+func reportStage(s *stage.Stage) *ReportStage {
+	rs := &ReportStage{Jobs: []*ReportJob{}}
+	if s.State != nil {
+		rs.ReportUnit = reportUnit(s.State.ID, s.State.Status, s.State.Error, s.State.StartedAt, s.State.SucceedAt, s.State.FailedAt)
+		rs.setExit(s.State.ExitCode, s.State.Signal, s.State.OOMKilled)
+	}
+	rs.Name = s.Name
+
+	for _, j := range s.Jobs {
+		rs.Jobs = append(rs.Jobs, reportJob(j))
+	}
+
+	return rs
+}
+
+func reportJob(j *job.Job) *ReportJob {
+	rj := &ReportJob{Steps: []*ReportStep{}}
+	if j.State != nil {
+		rj.ReportUnit = reportUnit(j.State.ID, j.State.Status, j.State.Error, j.State.StartedAt, j.State.SucceedAt, j.State.FailedAt)
+		rj.setExit(j.State.ExitCode, j.State.Signal, j.State.OOMKilled)
+	}
+	rj.Name = j.Name
+
+	for _, s := range j.Steps {
+		rst := &ReportStep{}
+		if s.State != nil {
+			rst.ReportUnit = reportUnit(s.State.ID, s.State.Status, s.State.Error, s.State.StartedAt, s.State.SucceedAt, s.State.FailedAt)
+			rst.setExit(s.State.ExitCode, s.State.Signal, s.State.OOMKilled)
+			rst.Attempts = s.State.Attempts
+		}
+		rst.Name = s.Name
+
+		rj.Steps = append(rj.Steps, rst)
+	}
+
+	return rj
+}
+
+// reportUnit returns the outcome of the unit from its state,
+//
+//	a unit still running when the pipeline ended, e.g. cancelled, is reported as pending
+func reportUnit(id, status, message string, startedAt, succeedAt, failedAt time.Time) ReportUnit {
+	u := ReportUnit{
+		ID:     id,
+		Status: status,
+		Error:  message,
+	}
+
+	ended := endedAt(succeedAt, failedAt)
+	if ended == nil {
+		if status == "running" {
+			u.Status = "pending"
+		}
+
+		return u
+	}
+
+	u.StartedAt = &startedAt
+	u.EndedAt = ended
+	u.Duration = ended.Sub(startedAt).Seconds()
+	return u
+}
+
+// setExit sets the exit of the unit which has run
+func (u *ReportUnit) setExit(code int, signal string, oomKilled bool) {
+	if u.EndedAt == nil {
+		return
+	}
+
+	u.ExitCode = code
+	u.Signal = signal
+	u.OOMKilled = oomKilled
+}
+
+func endedAt(succeedAt, failedAt time.Time) *time.Time {
+	if !succeedAt.IsZero() {
+		return &succeedAt
+	}
+
+	if !failedAt.IsZero() {
+		return &failedAt
+	}
+
+	return nil
+}
+
+// Render renders the report in the format, json or junit
+func (r *Report) Render(format string) (string, error) {
+	switch format {
+	case "", "json":
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return "", err
+		}
+
+		return string(data) + "\n", nil
+	case "junit":
+		data, err := xml.MarshalIndent(r.junit(), "", "  ")
+		if err != nil {
+			return "", err
+		}
+
+		return xml.Header + string(data) + "\n", nil
+	default:
+		return "", fmt.Errorf("unsupported report format %s, only support json | junit", format)
+	}
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Name     string            `xml:"name,attr"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Skipped  int               `xml:"skipped,attr"`
+	Time     string            `xml:"time,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	ID        string           `xml:"id,attr,omitempty"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	Timestamp string           `xml:"timestamp,attr,omitempty"`
+	Cases     []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// junit renders the jobs as test suites and their steps as test cases,
+//
+//	the allowed failures pass with the error in system-err,
+//	a job failed outside of its steps, e.g. by its artifacts, is a failed test case named after the job
+func (r *Report) junit() *junitTestSuites {
+	suites := &junitTestSuites{
+		Name:   r.Name,
+		Time:   seconds(r.Duration),
+		Suites: []*junitTestSuite{},
+	}
+
+	for _, group := range []struct {
+		name   string
+		stages []*ReportStage
+	}{
+		{"", r.Stages},
+		{"on_success", r.OnSuccess},
+		{"on_failure", r.OnFailure},
+		{"finally", r.Finally},
+	} {
+		for _, s := range group.stages {
+			for _, j := range s.Jobs {
+				names := []string{s.Name, j.Name}
+				if group.name != "" {
+					names = append([]string{group.name}, names...)
+				}
+
+				suite := &junitTestSuite{
+					Name: strings.Join(names, "/"),
+					ID:   j.ID,
+					Time: seconds(j.Duration),
+				}
+				if j.StartedAt != nil {
+					suite.Timestamp = j.StartedAt.Format(time.RFC3339)
+				}
+
+				stepFailed := false
+				for _, st := range j.Steps {
+					suite.Cases = append(suite.Cases, junitCase(suite.Name, &st.ReportUnit))
+					stepFailed = stepFailed || st.Status == "failed"
+				}
+
+				if j.Status == "failed" && !stepFailed {
+					suite.Cases = append(suite.Cases, junitCase(suite.Name, &j.ReportUnit))
+				}
+
+				for _, c := range suite.Cases {
+					suite.Tests++
+					if c.Failure != nil {
+						suite.Failures++
+					}
+					if c.Skipped != nil {
+						suite.Skipped++
+					}
+				}
+
+				suites.Tests += suite.Tests
+				suites.Failures += suite.Failures
+				suites.Skipped += suite.Skipped
+				suites.Suites = append(suites.Suites, suite)
+			}
+		}
+	}
+
+	return suites
+}
+
+func junitCase(suite string, u *ReportUnit) *junitTestCase {
+	c := &junitTestCase{
+		Name:      u.Name,
+		ClassName: suite,
+		Time:      seconds(u.Duration),
+	}
+
+	switch u.Status {
+	case "failed":
+		c.Failure = &junitMessage{Message: firstLine(u.Error), Text: u.Error}
+	case "failed_allowed":
+		c.SystemErr = u.Error
+	case "skipped", "pending":
+		c.Skipped = &junitMessage{Message: u.Status}
+	}
+
+	return c
+}
+
+func seconds(d float64) string {
+	return fmt.Sprintf("%.3f", d)
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return line
+}
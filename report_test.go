@@ -0,0 +1,148 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestPipelineReport(t *testing.T) {
+	p, err := LoadYAML([]byte(`
+name: test pipeline report
+stages:
+  - name: build
+    jobs:
+      - name: build
+        steps:
+          - name: flaky
+            retry:
+              attempts: 2
+            command: test -f flaky || (touch flaky && exit 1)
+          - name: lint
+            allow_failure: true
+            command: exit 2
+          - name: test
+            command: exit 3
+          - name: publish
+            command: echo publish
+  - name: deploy
+    jobs:
+      - name: deploy
+        steps:
+          - name: deploy
+            command: echo deploy
+finally:
+  - name: cleanup
+    jobs:
+      - name: cleanup
+        steps:
+          - name: cleanup
+            command: rm -f flaky
+`), "")
+	if err != nil {
+		t.Fatalf("Failed to load pipeline: %v", err)
+	}
+
+	if _, err := p.Report(); err == nil {
+		t.Errorf("Expected no report before run")
+	}
+
+	p.SetWorkdir(t.TempDir())
+	p.SetStdout(&bytes.Buffer{})
+	if err := p.Run(context.Background()); err == nil {
+		t.Fatalf("Expected the pipeline to fail")
+	}
+
+	report, err := p.Report()
+	if err != nil {
+		t.Fatalf("Failed to get report: %v", err)
+	}
+
+	if report.Status != "failed" || report.EndedAt == nil {
+		t.Errorf("Expected a failed report with an end, got %s", report.Status)
+	}
+
+	if report.HasWarnings {
+		t.Errorf("Expected no warnings for a failed run")
+	}
+
+	steps := report.Stages[0].Jobs[0].Steps
+	statuses := []string{}
+	for _, s := range steps {
+		statuses = append(statuses, s.Name+"="+s.Status)
+	}
+	if got := strings.Join(statuses, ","); got != "flaky=succeeded,lint=failed_allowed,test=failed,publish=skipped" {
+		t.Errorf("Unexpected statuses %s", got)
+	}
+
+	if len(steps[0].Attempts) != 2 || steps[0].Attempts[0].Status != "failed" {
+		t.Errorf("Expected the retries of the flaky step, got %+v", steps[0].Attempts)
+	}
+
+	if steps[2].StartedAt == nil || steps[2].Error == "" {
+		t.Errorf("Expected the failed step with its start and error, got %+v", steps[2].ReportUnit)
+	}
+
+	if steps[3].StartedAt != nil || steps[3].Duration != 0 {
+		t.Errorf("Expected the skipped step without times, got %+v", steps[3].ReportUnit)
+	}
+
+	if report.Stages[1].Status != "skipped" || report.Finally[0].Status != "succeeded" {
+		t.Errorf("Expected deploy skipped and cleanup succeeded, got %s, %s", report.Stages[1].Status, report.Finally[0].Status)
+	}
+
+	t.Run("json", func(t *testing.T) {
+		output, err := report.Render("json")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded Report
+		if err := json.Unmarshal([]byte(output), &decoded); err != nil {
+			t.Fatalf("Failed to decode report: %v", err)
+		}
+
+		if decoded.ID != p.State.ID || decoded.Stages[0].Jobs[0].Steps[2].Status != "failed" {
+			t.Errorf("Unexpected json report:\n%s", output)
+		}
+	})
+
+	t.Run("junit", func(t *testing.T) {
+		output, err := report.Render("junit")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var suites junitTestSuites
+		if err := xml.Unmarshal([]byte(output), &suites); err != nil {
+			t.Fatalf("Failed to decode junit: %v\n%s", err, output)
+		}
+
+		names := []string{}
+		for _, s := range suites.Suites {
+			names = append(names, s.Name)
+		}
+		if got := strings.Join(names, ","); got != "build/build,deploy/deploy,finally/cleanup/cleanup" {
+			t.Errorf("Unexpected suites %s", got)
+		}
+
+		if suites.Tests != 6 || suites.Failures != 1 || suites.Skipped != 2 {
+			t.Errorf("Expected 6 tests, 1 failure, 2 skipped, got %d, %d, %d\n%s", suites.Tests, suites.Failures, suites.Skipped, output)
+		}
+
+		build := suites.Suites[0]
+		if build.Cases[1].SystemErr == "" || build.Cases[1].Failure != nil {
+			t.Errorf("Expected the allowed failure to pass with system-err")
+		}
+		if build.Cases[2].Failure == nil || build.Cases[2].ClassName != "build/build" {
+			t.Errorf("Expected the failed test case, got %+v", build.Cases[2])
+		}
+	})
+
+	if _, err := report.Render("html"); err == nil {
+		t.Errorf("Expected unsupported format error")
+	}
+}
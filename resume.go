@@ -0,0 +1,222 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-idp/pipeline/step"
+	"github.com/go-zoox/encoding/yaml"
+	"github.com/go-zoox/fs"
+)
+
+// StateFile is the file in the workdir the state of the run is persisted to, used to resume a failed run
+const StateFile = ".pipeline_state.yaml"
+
+// RunState is the persisted state of a run, the state tree of the stages, jobs and steps,
+//
+//	the hooks (on_success, on_failure, finally) are not persisted, they always run again
+type RunState struct {
+	ID      string `json:"id" yaml:"id"`
+	Workdir string `json:"workdir" yaml:"workdir"`
+	// Config is the configuration of the pipeline before prepare, in yaml
+	Config string `json:"config" yaml:"config"`
+	// Filter is the filter of the run, e.g. --only, applied again on resume
+	Filter *Filter `json:"filter,omitempty" yaml:"filter,omitempty"`
+	// Params are the values of the parameters of the run, without the secrets
+	Params map[string]string `json:"params,omitempty" yaml:"params,omitempty"`
+	// Redacted is true if the passwords in plain text are redacted from Config, the run can not be resumed
+	Redacted bool `json:"redacted,omitempty" yaml:"redacted,omitempty"`
+	//
+	State  *State        `json:"state" yaml:"state"`
+	Stages []*StageState `json:"stages" yaml:"stages"`
+}
+
+// StageState is the persisted state of a stage
+type StageState struct {
+	Name  string       `json:"name" yaml:"name"`
+	State *stage.State `json:"state" yaml:"state"`
+	Jobs  []*JobState  `json:"jobs" yaml:"jobs"`
+}
+
+// JobState is the persisted state of a job
+type JobState struct {
+	Name  string       `json:"name" yaml:"name"`
+	State *job.State   `json:"state" yaml:"state"`
+	Steps []*StepState `json:"steps" yaml:"steps"`
+}
+
+// StepState is the persisted state of a step
+type StepState struct {
+	Name  string      `json:"name" yaml:"name"`
+	State *step.State `json:"state" yaml:"state"`
+}
+
+// runsDir is the dir of the index from run id to workdir, used to resume a run by id
+func runsDir() string {
+	return filepath.Join(os.TempDir(), "go-idp", "pipeline", "runs")
+}
+
+// ReadRunState reads the state of the run persisted in the workdir, target is the workdir or the run id
+func ReadRunState(target string) (*RunState, error) {
+	workdir := target
+	if !fs.IsExist(filepath.Join(workdir, StateFile)) {
+		data, err := os.ReadFile(filepath.Join(runsDir(), filepath.Base(target)))
+		if err != nil {
+			return nil, fmt.Errorf("no run state found in workdir or for run id %s", target)
+		}
+
+		workdir = strings.TrimSpace(string(data))
+	}
+
+	data, err := os.ReadFile(filepath.Join(workdir, StateFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run state(workdir: %s): %s", workdir, err)
+	}
+
+	rs := &RunState{}
+	if err := yaml.Decode(data, rs); err != nil {
+		return nil, fmt.Errorf("failed to parse run state(workdir: %s): %s", workdir, err)
+	}
+
+	// the workdir may be moved after the run
+	rs.Workdir = workdir
+
+	return rs, nil
+}
+
+// Pipeline returns the pipeline of the run, resume it with Run and RunConfig.Resume
+func (rs *RunState) Pipeline() (*Pipeline, error) {
+	if rs.Redacted {
+		return nil, fmt.Errorf("the pipeline of run state has passwords in plain text, which are not persisted, use ${{ secrets.NAME }} instead")
+	}
+
+	p := &Pipeline{}
+	if err := yaml.Decode([]byte(rs.Config), p); err != nil {
+		return nil, fmt.Errorf("failed to parse the pipeline of run state: %s", err)
+	}
+
+	p.Workdir = rs.Workdir
+	// the secrets are not persisted, they should be given again
+	p.SetParams(rs.Params)
+	return p, nil
+}
+
+// done returns true if the unit of the previous run needs not to run again
+func done(status string) bool {
+	return status == "succeeded" || status == "failed_allowed"
+}
+
+// restore restores the states of the units done in the previous run, the others run again
+//
+//	the units are matched by position and name, the pipeline should be the one of the run state
+func (p *Pipeline) restore(rs *RunState) error {
+	if len(rs.Stages) != len(p.Stages) {
+		return fmt.Errorf("[workflow][resume] the pipeline does not match the run state, %d stages expected, got %d", len(rs.Stages), len(p.Stages))
+	}
+
+	for si, s := range p.Stages {
+		ss := rs.Stages[si]
+		if ss.Name != s.Name || len(ss.Jobs) != len(s.Jobs) {
+			return fmt.Errorf("[workflow][resume] the pipeline does not match the run state at stage(%s)", s.Name)
+		}
+
+		for ji, j := range s.Jobs {
+			js := ss.Jobs[ji]
+			if js.Name != j.Name || len(js.Steps) != len(j.Steps) {
+				return fmt.Errorf("[workflow][resume] the pipeline does not match the run state at job(%s/%s)", s.Name, j.Name)
+			}
+
+			for ti, st := range j.Steps {
+				if js.Steps[ti].Name != st.Name {
+					return fmt.Errorf("[workflow][resume] the pipeline does not match the run state at step(%s/%s/%s)", s.Name, j.Name, st.Name)
+				}
+			}
+		}
+	}
+
+	for si, s := range p.Stages {
+		ss := rs.Stages[si]
+		for ji, j := range s.Jobs {
+			js := ss.Jobs[ji]
+			for ti, st := range j.Steps {
+				if state := js.Steps[ti].State; state != nil && done(state.Status) {
+					st.Restore(state)
+				}
+			}
+
+			if js.State != nil && done(js.State.Status) {
+				j.Restore(js.State)
+			}
+		}
+
+		if ss.State != nil && done(ss.State.Status) {
+			s.Restore(ss.State)
+		}
+	}
+
+	return nil
+}
+
+// runState returns the state tree of the run
+func (p *Pipeline) runState() *RunState {
+	rs := &RunState{
+		ID:       p.State.ID,
+		Workdir:  p.Workdir,
+		Config:   p.config,
+		Filter:   p.filter,
+		Params:   p.PersistedParams(),
+		Redacted: p.configRedacted,
+		State:    p.State,
+	}
+
+	for _, s := range p.Stages {
+		ss := &StageState{Name: s.Name, State: s.State}
+		for _, j := range s.Jobs {
+			js := &JobState{Name: j.Name, State: j.State}
+			for _, st := range j.Steps {
+				js.Steps = append(js.Steps, &StepState{Name: st.Name, State: st.State})
+			}
+
+			ss.Jobs = append(ss.Jobs, js)
+		}
+
+		rs.Stages = append(rs.Stages, ss)
+	}
+
+	return rs
+}
+
+// saveState persists the state of the run to the workdir, readable by the owner only, failures only warn
+func (p *Pipeline) saveState() {
+	logger := p.getLogger()
+
+	data, err := yaml.Encode(p.runState())
+	if err != nil {
+		logger.Warnf("[workflow] failed to encode run state: %s", err)
+		return
+	}
+
+	if err := os.WriteFile(filepath.Join(p.Workdir, StateFile), data, 0600); err != nil {
+		logger.Warnf("[workflow] failed to save run state: %s", err)
+		return
+	}
+
+	if err := fs.Mkdirp(runsDir()); err != nil {
+		logger.Warnf("[workflow] failed to index run state: %s", err)
+		return
+	}
+
+	if err := os.WriteFile(filepath.Join(runsDir(), p.State.ID), []byte(p.Workdir), 0644); err != nil {
+		logger.Warnf("[workflow] failed to index run state: %s", err)
+	}
+}
+
+// removeState removes the state of the succeeded run, there is nothing to resume
+func (p *Pipeline) removeState() {
+	os.Remove(filepath.Join(p.Workdir, StateFile))
+	os.Remove(filepath.Join(runsDir(), p.State.ID))
+}
@@ -0,0 +1,213 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-idp/pipeline/step"
+	"github.com/go-zoox/fs"
+)
+
+func TestPipelineResume(t *testing.T) {
+	workdir := filepath.Join(t.TempDir(), "workdir")
+	marker := filepath.Join(t.TempDir(), "fixed")
+
+	p := &Pipeline{
+		Name:    "test pipeline resume",
+		Workdir: workdir,
+		Stages: []*stage.Stage{
+			{
+				Name: "build",
+				Jobs: []*job.Job{
+					{
+						Name: "build",
+						Steps: []*step.Step{
+							{
+								Name:    "version",
+								Command: `echo run >> build.log && echo "version=1.0.0" >> $PIPELINE_OUTPUT`,
+							},
+						},
+					},
+				},
+			},
+			{
+				Name: "test",
+				Jobs: []*job.Job{
+					{
+						Name: "test",
+						Steps: []*step.Step{
+							{
+								Name:    "unit",
+								Command: `echo run >> unit.log && test -f ` + marker,
+							},
+							{
+								Name:    "report",
+								Command: `test "${{ steps.version.outputs.version }}" = "1.0.0"`,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	p.SetStdout(&out)
+	if err := p.Run(context.Background()); err == nil {
+		t.Fatalf("Expected the first run to fail")
+	}
+
+	rs, err := ReadRunState(workdir)
+	if err != nil {
+		t.Fatalf("Failed to read run state: %v", err)
+	}
+	if rs.ID != p.State.ID || rs.State.Status != "failed" {
+		t.Errorf("Expected failed run state of %s, got %s (%s)", p.State.ID, rs.ID, rs.State.Status)
+	}
+	if status := rs.Stages[1].Jobs[0].Steps[1].State.Status; status != "skipped" {
+		t.Errorf("Expected the step after the failure to be skipped, got %s", status)
+	}
+
+	// the run can be found by id as well
+	if byID, err := ReadRunState(rs.ID); err != nil || byID.Workdir != workdir {
+		t.Errorf("Expected run state found by id in %s, got %v", workdir, err)
+	}
+
+	if err := os.WriteFile(marker, []byte("ok"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := rs.Pipeline()
+	if err != nil {
+		t.Fatalf("Failed to load pipeline of run state: %v", err)
+	}
+
+	out.Reset()
+	resumed.SetStdout(&out)
+
+	// copy the logs out of the workdir, it is cleaned after the run succeeds
+	resumed.Stages[1].Jobs[0].Steps[1].Command += " && cp build.log unit.log " + filepath.Dir(marker)
+	if err := resumed.Run(context.Background(), func(cfg *RunConfig) {
+		cfg.Resume = rs
+	}); err != nil {
+		t.Fatalf("Failed to resume pipeline: %v\n%s", err, out.String())
+	}
+
+	if resumed.State.ID != rs.ID {
+		t.Errorf("Expected resumed run to keep id %s, got %s", rs.ID, resumed.State.ID)
+	}
+	if status := resumed.Stages[0].State.Status; status != "succeeded" {
+		t.Errorf("Expected restored stage to be succeeded, got %s", status)
+	}
+
+	build, _ := os.ReadFile(filepath.Join(filepath.Dir(marker), "build.log"))
+	if got := strings.Count(string(build), "run"); got != 1 {
+		t.Errorf("Expected build step to run once, got %d", got)
+	}
+
+	unit, _ := os.ReadFile(filepath.Join(filepath.Dir(marker), "unit.log"))
+	if got := strings.Count(string(unit), "run"); got != 2 {
+		t.Errorf("Expected unit step to run twice, got %d", got)
+	}
+
+	if fs.IsExist(workdir) {
+		t.Errorf("Expected workdir of the succeeded run to be cleaned")
+	}
+	if _, err := ReadRunState(rs.ID); err == nil {
+		t.Errorf("Expected run state of the succeeded run to be removed")
+	}
+}
+
+func TestPipelineResumeMismatch(t *testing.T) {
+	workdir := t.TempDir()
+	p := &Pipeline{
+		Name:    "test pipeline resume mismatch",
+		Workdir: workdir,
+		Stages: []*stage.Stage{
+			{
+				Name: "build",
+				Jobs: []*job.Job{
+					{Name: "build", Steps: []*step.Step{{Name: "build", Command: "exit 1"}}},
+				},
+			},
+		},
+	}
+	p.SetStdout(&bytes.Buffer{})
+	if err := p.Run(context.Background()); err == nil {
+		t.Fatalf("Expected the run to fail")
+	}
+
+	rs, err := ReadRunState(workdir)
+	if err != nil {
+		t.Fatalf("Failed to read run state: %v", err)
+	}
+
+	resumed, err := rs.Pipeline()
+	if err != nil {
+		t.Fatalf("Failed to load pipeline of run state: %v", err)
+	}
+	resumed.SetStdout(&bytes.Buffer{})
+	resumed.Stages[0].Jobs[0].Name = "compile"
+
+	err = resumed.Run(context.Background(), func(cfg *RunConfig) {
+		cfg.Resume = rs
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not match the run state at job(build/compile)") {
+		t.Errorf("Expected mismatch error, got %v", err)
+	}
+}
+
+func TestPipelineResumeRedacted(t *testing.T) {
+	workdir := t.TempDir()
+	p := &Pipeline{
+		Name:    "test pipeline resume redacted",
+		Workdir: workdir,
+		Stages: []*stage.Stage{
+			{
+				Name: "build",
+				Jobs: []*job.Job{
+					{
+						Name:                  "build",
+						ImageRegistryPassword: "supersecret123",
+						Steps:                 []*step.Step{{Name: "build", Command: "exit 1"}},
+					},
+				},
+			},
+		},
+	}
+	p.SetStdout(&bytes.Buffer{})
+	if err := p.Run(context.Background()); err == nil {
+		t.Fatalf("Expected the run to fail")
+	}
+
+	path := filepath.Join(workdir, StateFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read run state: %v", err)
+	}
+	if strings.Contains(string(data), "supersecret123") {
+		t.Errorf("Expected the password not to be persisted, got:\n%s", data)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected run state readable by the owner only, got %v (%v)", info.Mode().Perm(), err)
+	}
+
+	rs, err := ReadRunState(workdir)
+	if err != nil {
+		t.Fatalf("Failed to read run state: %v", err)
+	}
+	if !rs.Redacted {
+		t.Errorf("Expected run state to be redacted")
+	}
+
+	if _, err := rs.Pipeline(); err == nil || !strings.Contains(err.Error(), "secrets.NAME") {
+		t.Errorf("Expected the redacted pipeline not to be resumed, got %v", err)
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/go-idp/pipeline/event"
 	"github.com/go-idp/pipeline/stage"
+	"github.com/go-zoox/encoding/yaml"
 	"github.com/go-zoox/logger"
 	"github.com/go-zoox/uuid"
 )
@@ -24,6 +27,15 @@ func (p *Pipeline) Run(ctx context.Context, opts ...RunOption) error {
 	logger.Infof("[workflow] start to run (name: %s)", p.Name)
 	var runErr error
 	defer func() {
+		e := event.New(event.PipelineFinished, cfg.ID, p.Name)
+		if p.State != nil {
+			e.Finish(p.State.Status, p.State.Error, runErr)
+			e.SetExit(p.State.ExitCode, p.State.Signal, p.State.OOMKilled, p.State.Duration)
+		} else {
+			e.Finish("", "", runErr)
+		}
+		p.emit(e)
+
 		if runErr != nil {
 			logger.Errorf("[workflow] error: %s", runErr)
 			logger.Errorf("[workflow] workdir: %s", p.Workdir)
@@ -34,11 +46,47 @@ func (p *Pipeline) Run(ctx context.Context, opts ...RunOption) error {
 		}
 	}()
 
+	if cfg.Resume != nil {
+		cfg.ID = cfg.Resume.ID
+		cfg.Filter = cfg.Resume.Filter
+	}
+
+	// the configuration before prepare is persisted with the state, to resume the run
+	config, err := yaml.Encode(p)
+	if err != nil {
+		runErr = fmt.Errorf("[workflow] failed to encode pipeline: %s", err)
+		return runErr
+	}
+	p.config = p.Redact(string(config))
+	p.configRedacted = p.config != string(config)
+	p.filter = cfg.Filter
+
+	// the filter matches the jobs and steps inherited by extends as well
+	if err := p.resolveTemplates(); err != nil {
+		runErr = fmt.Errorf("[workflow][prepare] invalid pipeline:\n%s", err)
+		return runErr
+	}
+
+	if err := p.applyFilter(cfg.Filter); err != nil {
+		runErr = err
+		return err
+	}
+
 	if err := p.prepare(cfg.ID); err != nil {
 		runErr = err
 		return err
 	}
 
+	if cfg.Resume != nil {
+		if err := p.restore(cfg.Resume); err != nil {
+			runErr = err
+			return err
+		}
+	}
+
+	p.saveState()
+	p.emit(event.New(event.PipelineStarted, p.State.ID, p.Name))
+
 	plog := p.getLogger()
 	plog.Infof("[workflow] start")
 	plog.Infof("[workflow] version: %s", Version)
@@ -46,6 +94,9 @@ func (p *Pipeline) Run(ctx context.Context, opts ...RunOption) error {
 	plog.Infof("[workflow] workdir: %s", p.Workdir)
 	plog.Infof("[workflow] timeout: %d seconds", p.Timeout)
 
+	// hooks still run after the pipeline is cancelled or timed out, limited by their own timeout
+	hookCtx := context.WithoutCancel(ctx)
+
 	// Create context with timeout for pipeline
 	var cancel context.CancelFunc
 	if p.Timeout > 0 {
@@ -53,35 +104,61 @@ func (p *Pipeline) Run(ctx context.Context, opts ...RunOption) error {
 		defer cancel()
 	}
 
-	for i, s := range p.Stages {
-		err := s.Run(ctx, func(cfg *stage.RunConfig) {
-			cfg.Total = len(p.Stages)
-			cfg.Current = i + 1
-		})
+	if p.dag != nil {
+		plog.Infof("[workflow] schedule: dag (jobs run as soon as their needs succeed)")
+		err = p.dag.Run(ctx, plog)
+	} else {
+		err = p.runStages(ctx)
+	}
 
-		if err != nil {
-			p.State.Status = "failed"
-			p.State.Error = err.Error()
-			p.State.FailedAt = time.Now()
-			// Check if error is due to context timeout
-			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-				p.State.Error = fmt.Sprintf("pipeline timeout after %d seconds: %s", p.Timeout, err.Error())
-			}
-
-			// 输出错误信息
-			plog.Errorf("[workflow] error: %s", err)
-			plog.Errorf("[workflow] workdir: %s", p.Workdir)
-			plog.Errorf("[workflow] logs: check workdir for detailed logs and output files")
-			plog.Errorf("[workflow] workdir preserved for debugging (not cleaned)")
+	// the error of the stages is never masked by the errors of the hooks
+	hookErrs := p.runHooks(hookCtx, plog, err)
+	for _, hookErr := range hookErrs {
+		p.State.HookErrors = append(p.State.HookErrors, p.redactError(hookErr).Error())
+	}
+	if err == nil && len(hookErrs) > 0 {
+		err = hookErrs[0]
+	}
 
-			runErr = err
-			// 失败时不清理 workdir，保留以便调试
-			return err
+	// the outputs held by the masking of the secrets
+	p.flushOutput()
+
+	if err != nil {
+		// the errors are stored and shown, e.g. by the server
+		err = p.redactError(err)
+
+		p.State.Status = "failed"
+		p.State.Error = err.Error()
+		p.State.FailedAt = time.Now()
+		// Check if error is due to context timeout
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			p.State.Error = fmt.Sprintf("pipeline timeout after %d seconds: %s", p.Timeout, err.Error())
 		}
+
+		// 输出错误信息
+		plog.Errorf("[workflow] error: %s", err)
+		plog.Errorf("[workflow] workdir: %s", p.Workdir)
+		plog.Errorf("[workflow] logs: check workdir for detailed logs and output files")
+		plog.Errorf("[workflow] workdir preserved for debugging (not cleaned)")
+
+		p.finish()
+		if p.State.ExitCode > 0 {
+			plog.Errorf("[workflow] exit code: %d", p.State.ExitCode)
+		}
+
+		runErr = err
+		// 失败时不清理 workdir，保留以便调试，并保存状态以便恢复
+		p.saveState()
+		return err
 	}
 
 	p.State.Status = "succeeded"
 	p.State.SucceedAt = time.Now()
+	p.State.Warnings = p.warnings()
+	p.finish()
+	if len(p.State.Warnings) > 0 {
+		plog.Warnf("[workflow] succeeded with warnings, allowed failures: %s", strings.Join(p.State.Warnings, ", "))
+	}
 	plog.Infof("[workflow] done")
 
 	// 成功时清理 workdir
@@ -91,3 +168,22 @@ func (p *Pipeline) Run(ctx context.Context, opts ...RunOption) error {
 
 	return nil
 }
+
+// runStages runs the stages one by one
+//
+//	after a stage fails, the remaining stages only run if their if allows it, e.g. failure() or always()
+func (p *Pipeline) runStages(ctx context.Context) error {
+	var runErr error
+	for i, s := range p.Stages {
+		err := s.Run(ctx, func(cfg *stage.RunConfig) {
+			cfg.Total = len(p.Stages)
+			cfg.Current = i + 1
+			cfg.Failed = runErr != nil
+		})
+		if err != nil && runErr == nil {
+			runErr = err
+		}
+	}
+
+	return runErr
+}
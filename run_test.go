@@ -448,3 +448,214 @@ func TestPipelineErrorHandling(t *testing.T) {
 		}
 	})
 }
+
+func TestPipelineIf(t *testing.T) {
+	t.Run("stages should run by if after failure", func(t *testing.T) {
+		workdir := t.TempDir()
+
+		pipeline := &Pipeline{
+			Name:    "test pipeline if",
+			Workdir: workdir,
+			Stages: []*stage.Stage{
+				{
+					Name: "build",
+					Jobs: []*job.Job{
+						{
+							Name: "build",
+							Steps: []*step.Step{
+								{
+									Name:    "fail",
+									Command: "exit 1",
+								},
+							},
+						},
+					},
+				},
+				{
+					Name: "deploy",
+					Jobs: []*job.Job{
+						{
+							Name: "deploy",
+							Steps: []*step.Step{
+								{
+									Name:    "deploy",
+									Command: "touch deploy.done",
+								},
+							},
+						},
+					},
+				},
+				{
+					Name: "notify",
+					If:   "failure() && env.PIPELINE_NAME == 'test pipeline if'",
+					Jobs: []*job.Job{
+						{
+							Name: "notify",
+							Steps: []*step.Step{
+								{
+									Name:    "notify",
+									Command: "touch notify.done",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		if err := pipeline.Run(context.Background()); err == nil {
+			t.Fatal("Expected error, but got nil")
+		}
+
+		if pipeline.State.Status != "failed" {
+			t.Errorf("Expected status 'failed', got '%s'", pipeline.State.Status)
+		}
+
+		expected := []string{"failed", "skipped", "succeeded"}
+		for i, s := range pipeline.Stages {
+			if s.State.Status != expected[i] {
+				t.Errorf("Expected stage(%s) status '%s', got '%s'", s.Name, expected[i], s.State.Status)
+			}
+		}
+
+		if fs.IsExist(filepath.Join(workdir, "deploy.done")) {
+			t.Error("Expected stage(deploy) not to run")
+		}
+
+		if !fs.IsExist(filepath.Join(workdir, "notify.done")) {
+			t.Error("Expected stage(notify) to run")
+		}
+	})
+}
+
+func TestPipelineAllowFailure(t *testing.T) {
+	pipeline := &Pipeline{
+		Name:    "test pipeline allow failure",
+		Workdir: t.TempDir(),
+		Stages: []*stage.Stage{
+			{
+				Name: "check",
+				Jobs: []*job.Job{
+					{
+						Name:         "lint",
+						AllowFailure: true,
+						Steps: []*step.Step{
+							{
+								Name:    "lint",
+								Command: "exit 1",
+							},
+						},
+					},
+					{
+						Name: "build",
+						Steps: []*step.Step{
+							{
+								Name: "build",
+								// should not be cancelled by the failure of lint
+								Command: "sleep 0.5 && touch build.done",
+							},
+							{
+								Name:         "flaky",
+								Command:      "exit 2",
+								AllowFailure: true,
+							},
+						},
+					},
+				},
+			},
+			{
+				Name: "release",
+				Jobs: []*job.Job{
+					{
+						Name: "release",
+						Steps: []*step.Step{
+							{
+								Name:    "check",
+								Command: "test -f build.done",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := pipeline.Run(context.Background()); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	if pipeline.State.Status != "succeeded" {
+		t.Errorf("Expected status 'succeeded', got '%s'", pipeline.State.Status)
+	}
+
+	if status := pipeline.Stages[0].State.Status; status != "succeeded" {
+		t.Errorf("Expected stage(check) status 'succeeded', got '%s'", status)
+	}
+
+	if status := pipeline.Stages[0].Jobs[0].State.Status; status != "failed_allowed" {
+		t.Errorf("Expected job(lint) status 'failed_allowed', got '%s'", status)
+	}
+
+	if status := pipeline.Stages[0].Jobs[1].Steps[1].State.Status; status != "failed_allowed" {
+		t.Errorf("Expected step(flaky) status 'failed_allowed', got '%s'", status)
+	}
+
+	if !pipeline.State.HasWarnings() {
+		t.Errorf("Expected the pipeline to succeed with warnings")
+	}
+
+	expected := []string{"check/lint", "check/build/flaky"}
+	if len(pipeline.State.Warnings) != len(expected) {
+		t.Fatalf("Expected warnings %v, got %v", expected, pipeline.State.Warnings)
+	}
+	for i, w := range expected {
+		if pipeline.State.Warnings[i] != w {
+			t.Errorf("Expected warning '%s', got '%s'", w, pipeline.State.Warnings[i])
+		}
+	}
+}
+
+func TestPipelineStepOutputs(t *testing.T) {
+	pipeline := &Pipeline{
+		Name:    "test pipeline step outputs",
+		Workdir: t.TempDir(),
+		Stages: []*stage.Stage{
+			{
+				Name: "build",
+				Jobs: []*job.Job{
+					{
+						Name: "version",
+						Steps: []*step.Step{
+							{
+								Name:    "version",
+								Command: `echo "tag=v1.0.0" >> "$PIPELINE_OUTPUT"`,
+							},
+						},
+					},
+				},
+			},
+			{
+				Name: "release",
+				Jobs: []*job.Job{
+					{
+						Name: "release",
+						Steps: []*step.Step{
+							{
+								Name:    "release",
+								Command: `test "${{ steps.version.outputs.tag }}" = v1.0.0`,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := pipeline.Run(context.Background()); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	if tag := pipeline.Stages[0].Jobs[0].Steps[0].State.Outputs["tag"]; tag != "v1.0.0" {
+		t.Errorf("Expected output tag 'v1.0.0', got '%s'", tag)
+	}
+}
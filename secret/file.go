@@ -0,0 +1,168 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+type fileStore struct {
+	sync.Mutex
+	path string
+	aead cipher.AEAD
+}
+
+// NewFileStore creates a store of the secrets encrypted in the file with AES-256-GCM,
+//
+//	the encryption key is derived from the passphrase, the file is created by the first Set,
+//	a wrong passphrase fails the reads, e.g. List
+func NewFileStore(path, passphrase string) (Store, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("the key of the secret store is required")
+	}
+
+	key := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileStore{
+		path: path,
+		aead: aead,
+	}, nil
+}
+
+func (s *fileStore) read() (map[string]string, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+
+		return nil, fmt.Errorf("failed to read secrets(path: %s): %s", s.path, err)
+	}
+
+	size := s.aead.NonceSize()
+	if len(data) < size {
+		return nil, fmt.Errorf("invalid secrets file(path: %s)", s.path)
+	}
+
+	plain, err := s.aead.Open(nil, data[:size], data[size:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets(path: %s), wrong key?", s.path)
+	}
+
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("invalid secrets file(path: %s): %s", s.path, err)
+	}
+
+	return secrets, nil
+}
+
+func (s *fileStore) write(secrets map[string]string) error {
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create secrets dir: %s", err)
+	}
+
+	// write to a temp file first, the secrets are never half written
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, s.aead.Seal(nonce, nonce, plain, nil), 0600); err != nil {
+		return fmt.Errorf("failed to write secrets(path: %s): %s", s.path, err)
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+func (s *fileStore) Get(name string) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	secrets, err := s.read()
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := secrets[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return value, nil
+}
+
+func (s *fileStore) List() ([]string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	secrets, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *fileStore) Set(name, value string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	secrets, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	secrets[name] = value
+	return s.write(secrets)
+}
+
+func (s *fileStore) Delete(name string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	secrets, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := secrets[name]; !ok {
+		return ErrNotFound
+	}
+
+	delete(secrets, name)
+	return s.write(secrets)
+}
@@ -0,0 +1,92 @@
+package secret
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets")
+
+	s, err := NewFileStore(path, "passphrase")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	if names, err := s.List(); err != nil || len(names) != 0 {
+		t.Errorf("Expected no secrets before the file exists, got %v, %v", names, err)
+	}
+
+	if err := s.Set("REGISTRY_PASSWORD", "s3cr3t"); err != nil {
+		t.Fatalf("Failed to set secret: %v", err)
+	}
+	if err := s.Set("TOKEN", "t0k3n"); err != nil {
+		t.Fatalf("Failed to set secret: %v", err)
+	}
+	if err := s.Set("invalid-name", "x"); err == nil {
+		t.Errorf("Expected invalid name error")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cr3t") || strings.Contains(string(data), "REGISTRY_PASSWORD") {
+		t.Errorf("Expected the secrets file to be encrypted")
+	}
+
+	// a new store of the same file and key
+	s, _ = NewFileStore(path, "passphrase")
+	if value, err := s.Get("REGISTRY_PASSWORD"); err != nil || value != "s3cr3t" {
+		t.Errorf("Expected s3cr3t, got %s, %v", value, err)
+	}
+
+	if names, _ := s.List(); strings.Join(names, ",") != "REGISTRY_PASSWORD,TOKEN" {
+		t.Errorf("Unexpected names %v", names)
+	}
+
+	if err := s.Delete("TOKEN"); err != nil {
+		t.Errorf("Failed to delete secret: %v", err)
+	}
+	if _, err := s.Get("TOKEN"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	wrong, _ := NewFileStore(path, "wrong")
+	if _, err := wrong.List(); err == nil {
+		t.Errorf("Expected wrong key to fail")
+	}
+
+	if _, err := NewFileStore(path, ""); err == nil {
+		t.Errorf("Expected empty key to fail")
+	}
+}
+
+func TestEnvStore(t *testing.T) {
+	t.Setenv(EnvPrefix+"TEST_TOKEN", "t0k3n")
+
+	s := NewEnvStore()
+	if value, err := s.Get("TEST_TOKEN"); err != nil || value != "t0k3n" {
+		t.Errorf("Expected t0k3n, got %s, %v", value, err)
+	}
+
+	if _, err := s.Get("TEST_MISSING"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	names, _ := s.List()
+	found := false
+	for _, name := range names {
+		found = found || name == "TEST_TOKEN"
+	}
+	if !found {
+		t.Errorf("Expected TEST_TOKEN in %v", names)
+	}
+
+	if err := s.Set("TEST_TOKEN", "x"); err == nil {
+		t.Errorf("Expected the env store to be read-only")
+	}
+}
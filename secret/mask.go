@@ -0,0 +1,196 @@
+package secret
+
+import (
+	"encoding/base64"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces the secrets in the logs
+const Mask = "******"
+
+// MinLength is the minimum length of the masked values, shorter values would mask too much of the logs
+const MinLength = 4
+
+// Masker masks the values of the secrets, and their base64 forms, in text and writers
+type Masker struct {
+	mu sync.RWMutex
+	// forms are the masked forms of the values, the longest first
+	forms []string
+	known map[string]bool
+}
+
+// NewMasker creates a masker of the values
+func NewMasker(values ...string) *Masker {
+	m := &Masker{
+		known: map[string]bool{},
+	}
+	m.Add(values...)
+	return m
+}
+
+// Add adds the values to mask, multi-line values are masked line by line too
+func (m *Masker) Add(values ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, value := range values {
+		for _, form := range forms(value) {
+			if len(form) >= MinLength && !m.known[form] {
+				m.known[form] = true
+				m.forms = append(m.forms, form)
+			}
+		}
+	}
+
+	sort.SliceStable(m.forms, func(i, j int) bool {
+		return len(m.forms[i]) > len(m.forms[j])
+	})
+}
+
+// forms returns the value, its lines and their base64 encodings,
+//
+//	the base64 of the value embedded in other bytes at any offset, e.g. the auth of user:password
+func forms(value string) []string {
+	values := []string{value}
+	if lines := strings.Split(strings.TrimSpace(value), "\n"); len(lines) > 1 {
+		for _, line := range lines {
+			values = append(values, strings.TrimSpace(line))
+		}
+	}
+
+	result := []string{}
+	for _, v := range values {
+		if len(v) < MinLength {
+			continue
+		}
+
+		result = append(result, v)
+		for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding} {
+			result = append(result, encoding.EncodeToString([]byte(v)))
+
+			for offset := 0; offset < 3; offset++ {
+				raw := encoding.WithPadding(base64.NoPadding).EncodeToString(append(make([]byte, offset), v...))
+				// the leading chars depend on the bytes before the value, the trailing one on those after it
+				start := []int{0, 2, 3}[offset]
+				end := len(raw)
+				if (offset+len(v))%3 != 0 {
+					end--
+				}
+
+				if end > start {
+					result = append(result, raw[start:end])
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// Mask replaces the secrets in the text with ******
+func (m *Masker) Mask(text string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, form := range m.forms {
+		if strings.Contains(text, form) {
+			text = strings.ReplaceAll(text, form, Mask)
+		}
+	}
+
+	return text
+}
+
+// partial returns the length of the longest suffix of the text which may be the beginning of a secret
+func (m *Masker) partial(text string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	longest := 0
+	for _, form := range m.forms {
+		for n := min(len(form)-1, len(text)); n > longest; n-- {
+			if strings.HasSuffix(text, form[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+
+	return longest
+}
+
+// Writer is a writer masking the secrets written to it,
+//
+//	the end of a write which may be the beginning of a secret is held until the next write or Flush
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	masker  *Masker
+	pending string
+}
+
+// Writer returns a writer masking the secrets written to w
+func (m *Masker) Writer(w io.Writer) *Writer {
+	return &Writer{
+		w:      w,
+		masker: m,
+	}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	text := w.masker.Mask(w.pending + string(p))
+	n := w.masker.partial(text)
+	w.pending = text[len(text)-n:]
+
+	if out := text[:len(text)-n]; out != "" {
+		if _, err := io.WriteString(w.w, out); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush writes the held end of the previous writes, w is flushed too if it holds output, e.g. an incomplete line
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.pending != "" {
+		pending := w.pending
+		w.pending = ""
+
+		if _, err := io.WriteString(w.w, pending); err != nil {
+			return err
+		}
+	}
+
+	if f, ok := w.w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+
+	return nil
+}
+
+// Segment returns the masking writer of the output of the unit, nil if w does not separate the output of the units, see step.Segmenter
+func (w *Writer) Segment(stage, job, step string) io.Writer {
+	s, ok := w.w.(interface {
+		Segment(stage, job, step string) io.Writer
+	})
+	if !ok {
+		return nil
+	}
+
+	segment := s.Segment(stage, job, step)
+	if segment == nil {
+		return nil
+	}
+
+	return w.masker.Writer(segment)
+}
@@ -0,0 +1,104 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMasker(t *testing.T) {
+	m := NewMasker("s3cr3t-token", "abc", "line-one\nline-two")
+
+	cases := []struct {
+		text string
+		want string
+	}{
+		{"token=s3cr3t-token", "token=******"},
+		{"b64=" + base64.StdEncoding.EncodeToString([]byte("s3cr3t-token")), "b64=******"},
+		{"short abc is not masked", "short abc is not masked"},
+		{"line-two", "******"},
+	}
+
+	for _, c := range cases {
+		if got := m.Mask(c.text); got != c.want {
+			t.Errorf("Mask(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+
+	// the secret embedded in base64 of other bytes, e.g. the auth of a docker config
+	for _, prefix := range []string{"", "u:", "user:"} {
+		auth := base64.StdEncoding.EncodeToString([]byte(prefix + "s3cr3t-token" + "!"))
+		masked := m.Mask(auth)
+		if !strings.Contains(masked, Mask) {
+			t.Errorf("Expected base64 of %q to be masked, got %s", prefix+"s3cr3t-token!", masked)
+		}
+	}
+}
+
+func TestMaskerWriter(t *testing.T) {
+	m := NewMasker("s3cr3t-token")
+
+	var out bytes.Buffer
+	w := m.Writer(&out)
+
+	// the secret is split over the writes
+	for _, chunk := range []string{"token=s3cr", "3t-to", "ken\n", "done s3c"} {
+		if n, err := w.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+
+	if got := out.String(); got != "token=******\ndone " {
+		t.Errorf("Expected the output before flush to be masked, got %q", got)
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := out.String(); got != "token=******\ndone s3c" {
+		t.Errorf("Expected the held output after flush, got %q", got)
+	}
+
+	// secrets added later are masked too
+	m.Add("another-secret")
+	out.Reset()
+	w.Write([]byte("another-secret\n"))
+	if got := out.String(); got != "******\n" {
+		t.Errorf("Expected the added secret to be masked, got %q", got)
+	}
+}
+
+type segmentedBuffer struct {
+	bytes.Buffer
+	segments map[string]*bytes.Buffer
+}
+
+func (b *segmentedBuffer) Segment(stage, job, step string) io.Writer {
+	b.segments[step] = &bytes.Buffer{}
+	return b.segments[step]
+}
+
+func TestMaskerWriterSegment(t *testing.T) {
+	m := NewMasker("s3cr3t-token")
+
+	out := &segmentedBuffer{segments: map[string]*bytes.Buffer{}}
+	segment := m.Writer(out).Segment("run.0", "run.0.1", "run.0.1.2")
+	if segment == nil {
+		t.Fatal("Expected the writer of the segment")
+	}
+
+	segment.Write([]byte("token=s3cr3t-token\n"))
+	if got := out.segments["run.0.1.2"].String(); got != "token=******\n" {
+		t.Errorf("Expected the output of the segment to be masked, got %q", got)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no output outside the segment, got %q", out.String())
+	}
+
+	if m.Writer(&bytes.Buffer{}).Segment("run.0", "run.0.1", "run.0.1.2") != nil {
+		t.Error("Expected no segment of a writer not separating the units")
+	}
+}
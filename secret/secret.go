@@ -0,0 +1,82 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// EnvPrefix is the prefix of the environment variables of the secrets, e.g. PIPELINE_SECRET_REGISTRY_PASSWORD
+const EnvPrefix = "PIPELINE_SECRET_"
+
+// ErrNotFound is returned by the stores when the secret does not exist
+var ErrNotFound = errors.New("secret not found")
+
+var nameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Store stores the secrets referenced by ${{ secrets.NAME }}
+type Store interface {
+	// Get returns the value of the secret, ErrNotFound if it does not exist
+	Get(name string) (string, error)
+	// List lists the names of the secrets, never the values
+	List() ([]string, error)
+	// Set creates or updates the secret
+	Set(name, value string) error
+	// Delete deletes the secret
+	Delete(name string) error
+}
+
+// ValidateName checks the name of the secret, letters, digits and _ are allowed
+func ValidateName(name string) error {
+	if !nameRe.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q, only letters, digits and _ are allowed", name)
+	}
+
+	return nil
+}
+
+type envStore struct {
+	prefix string
+}
+
+// NewEnvStore creates a read-only store of the environment variables of the runner,
+//
+//	the secret NAME is the environment variable PIPELINE_SECRET_NAME
+func NewEnvStore() Store {
+	return &envStore{
+		prefix: EnvPrefix,
+	}
+}
+
+func (s *envStore) Get(name string) (string, error) {
+	value, ok := os.LookupEnv(s.prefix + name)
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return value, nil
+}
+
+func (s *envStore) List() ([]string, error) {
+	names := []string{}
+	for _, e := range os.Environ() {
+		k, _, _ := strings.Cut(e, "=")
+		if name, ok := strings.CutPrefix(k, s.prefix); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *envStore) Set(name, value string) error {
+	return fmt.Errorf("the secrets of the environment are read-only, set %s%s instead", s.prefix, name)
+}
+
+func (s *envStore) Delete(name string) error {
+	return fmt.Errorf("the secrets of the environment are read-only, unset %s%s instead", s.prefix, name)
+}
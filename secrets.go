@@ -0,0 +1,140 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-idp/pipeline/secret"
+)
+
+// SetSecretStore sets the store of the secrets referenced by ${{ secrets.NAME }},
+//
+//	default: the environment of the runner, e.g. PIPELINE_SECRET_NAME
+func (p *Pipeline) SetSecretStore(store secret.Store) *Pipeline {
+	p.secrets = store
+	return p
+}
+
+func (p *Pipeline) getMasker() *secret.Masker {
+	if p.masker == nil {
+		p.masker = secret.NewMasker()
+	}
+
+	return p.masker
+}
+
+// secret resolves ${{ secrets.NAME }}, the value is masked in the logs from now on
+func (p *Pipeline) secret(name string) (string, error) {
+	if p.secrets == nil {
+		p.secrets = secret.NewEnvStore()
+	}
+
+	value, err := p.secrets.Get(name)
+	if err != nil {
+		if errors.Is(err, secret.ErrNotFound) {
+			return "", fmt.Errorf("undefined secret %s", name)
+		}
+
+		return "", fmt.Errorf("failed to get secret %s: %s", name, err)
+	}
+
+	p.getMasker().Add(value)
+	return value, nil
+}
+
+// collectSecrets adds the secrets given in plain text to the masker,
+//
+//	the values of the secret parameters, or their defaults, and the passwords of the image registries
+func (p *Pipeline) collectSecrets() {
+	m := p.getMasker()
+
+	for _, pa := range p.Parameters {
+		if !pa.Secret() {
+			continue
+		}
+
+		value, ok := p.params[pa.Name]
+		if !ok {
+			value = pa.Default
+		}
+		if value != "" {
+			m.Add(value)
+		}
+	}
+
+	plain := func(value string) {
+		// references are masked when they are resolved
+		if value != "" && !strings.Contains(value, "${{") {
+			m.Add(value)
+		}
+	}
+
+	for _, s := range p.allStages() {
+		for _, j := range s.Jobs {
+			plain(j.ImageRegistryPassword)
+
+			for _, st := range j.Steps {
+				plain(st.ImageRegistryPassword)
+				if st.Plugin != nil {
+					plain(st.Plugin.ImageRegistryPassword)
+				}
+			}
+		}
+	}
+}
+
+// Redact masks the secrets known by the pipeline in the text, e.g. the yaml of the pipeline to store
+func (p *Pipeline) Redact(text string) string {
+	p.collectSecrets()
+	return p.getMasker().Mask(text)
+}
+
+// maskOutput masks the secrets in the stdout and stderr of the stages, jobs and steps
+func (p *Pipeline) maskOutput() {
+	m := p.getMasker()
+
+	stdout := m.Writer(p.stdout)
+	stderr := stdout
+	if p.stderr != p.stdout {
+		stderr = m.Writer(p.stderr)
+	}
+
+	p.SetStdout(stdout)
+	p.SetStderr(stderr)
+	p.maskWriters = []*secret.Writer{stdout, stderr}
+}
+
+// flushOutput writes the output held by the masking writers
+func (p *Pipeline) flushOutput() {
+	for _, w := range p.maskWriters {
+		w.Flush()
+	}
+}
+
+// redactError masks the secrets in the error, errors.Is still works on it
+func (p *Pipeline) redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	message := p.getMasker().Mask(err.Error())
+	if message == err.Error() {
+		return err
+	}
+
+	return &redactedError{err: err, message: message}
+}
+
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-idp/pipeline/secret"
+)
+
+func TestPipelineSecrets(t *testing.T) {
+	store, err := secret.NewFileStore(filepath.Join(t.TempDir(), "secrets"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("TOKEN", "s3cr3t-token")
+
+	t.Run("should resolve the secrets and mask them in the output", func(t *testing.T) {
+		p, err := LoadYAML([]byte(`
+name: test pipeline secrets
+environment:
+  TOKEN: ${{ secrets.TOKEN }}
+stages:
+  - name: build
+    jobs:
+      - name: build
+        steps:
+          - name: print
+            command: |
+              echo "env=$TOKEN"
+              echo "cmd=${{ secrets.TOKEN }}"
+              printf '%s' "$TOKEN" | base64
+`), "")
+		if err != nil {
+			t.Fatalf("Failed to load pipeline: %v", err)
+		}
+
+		var out bytes.Buffer
+		p.SetStdout(&out)
+		p.SetSecretStore(store)
+		if err := p.Run(context.Background()); err != nil {
+			t.Fatalf("Failed to run pipeline: %v\n%s", err, out.String())
+		}
+
+		for _, leaked := range []string{"s3cr3t-token", base64.StdEncoding.EncodeToString([]byte("s3cr3t-token"))} {
+			if strings.Contains(out.String(), leaked) {
+				t.Errorf("Expected %s to be masked, got:\n%s", leaked, out.String())
+			}
+		}
+
+		for _, expected := range []string{"env=******", "cmd=******"} {
+			if !strings.Contains(out.String(), expected) {
+				t.Errorf("Expected output containing %s, got:\n%s", expected, out.String())
+			}
+		}
+
+		if strings.Contains(p.String(), "s3cr3t-token") {
+			t.Errorf("Expected the secret to be masked in the yaml of the pipeline")
+		}
+	})
+
+	t.Run("undefined secrets should fail the pipeline", func(t *testing.T) {
+		p, err := LoadYAML([]byte(`
+name: test pipeline secrets
+stages:
+  - name: build
+    jobs:
+      - name: build
+        steps:
+          - name: build
+            image: alpine
+            image_registry_password: ${{ secrets.MISSING }}
+            command: echo
+`), "")
+		if err != nil {
+			t.Fatalf("Failed to load pipeline: %v", err)
+		}
+
+		p.SetStdout(&bytes.Buffer{})
+		p.SetSecretStore(store)
+		_, err = p.Plan()
+		if err == nil || !strings.Contains(err.Error(), "stages[0].jobs[0].steps[0].image_registry_password: ${{ secrets.MISSING }}: undefined secret MISSING") {
+			t.Errorf("Expected undefined secret error, got %v", err)
+		}
+	})
+
+	t.Run("plain passwords should be redacted", func(t *testing.T) {
+		config := `
+name: test pipeline secrets
+stages:
+  - name: build
+    jobs:
+      - name: build
+        image_registry_password: plain-password
+        steps:
+          - name: build
+            command: echo
+`
+		p, err := LoadYAML([]byte(config), "")
+		if err != nil {
+			t.Fatalf("Failed to load pipeline: %v", err)
+		}
+
+		if got := p.Redact(config); strings.Contains(got, "plain-password") || !strings.Contains(got, "image_registry_password: ******") {
+			t.Errorf("Expected the password to be redacted, got:\n%s", got)
+		}
+	})
+}
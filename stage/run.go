@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/go-idp/pipeline/expression"
 	"github.com/go-idp/pipeline/job"
 	"golang.org/x/sync/errgroup"
 )
@@ -18,19 +19,54 @@ type RunConfig struct {
 	Current int
 	// Parent is the parent name
 	Parent string
+	// Failed is true when a previous unit has failed, used by failure() in if
+	Failed bool
 }
 
 // RunOption is the option for run
 type RunOption func(cfg *RunConfig)
 
 // Run runs the stage
-func (s *Stage) Run(ctx context.Context, opts ...RunOption) error {
+func (s *Stage) Run(ctx context.Context, opts ...RunOption) (err error) {
 	cfg := &RunConfig{}
 	for _, o := range opts {
 		o(cfg)
 	}
 
+	defer func() {
+		s.Finish(err)
+	}()
+
+	if s.restored {
+		s.logger.Infof("%s[stage(%d/%d): %s] %s in the previous run (resumed)", cfg.Parent, cfg.Current, cfg.Total, s.Name, s.State.Status)
+		return nil
+	}
+
+	if s.excluded {
+		s.logger.Infof("%s[stage(%d/%d): %s] skipped (filtered)", cfg.Parent, cfg.Current, cfg.Total, s.Name)
+		s.Skip()
+		return nil
+	}
+
+	ok, err := expression.Condition(s.If, &expression.Context{
+		Environment: s.Environment,
+		Failed:      cfg.Failed,
+	})
+	if err != nil {
+		return fmt.Errorf("%s[stage(%d/%d): %s] %s", cfg.Parent, cfg.Current, cfg.Total, s.Name, err)
+	}
+	if !ok {
+		if s.If != "" {
+			s.logger.Infof("%s[stage(%d/%d): %s] skipped (if: %s)", cfg.Parent, cfg.Current, cfg.Total, s.Name, s.If)
+		} else {
+			s.logger.Infof("%s[stage(%d/%d): %s] skipped (previous failure)", cfg.Parent, cfg.Current, cfg.Total, s.Name)
+		}
+		s.Skip()
+		return nil
+	}
+
 	s.logger.Infof("%s[stage(%d/%d): %s] start", cfg.Parent, cfg.Current, cfg.Total, s.Name)
+	s.Start()
 	if s.Timeout > 0 {
 		s.logger.Infof("%s[stage(%d/%d): %s] timeout: %d seconds", cfg.Parent, cfg.Current, cfg.Total, s.Name, s.Timeout)
 	}
@@ -50,13 +86,17 @@ func (s *Stage) Run(ctx context.Context, opts ...RunOption) error {
 		// serial
 		s.logger.Infof("%s[stage(%d/%d): %s] run mode: serial", cfg.Parent, cfg.Current, cfg.Total, s.Name)
 
+		// after a job fails, the remaining jobs only run if their if allows it, e.g. failure() or always()
+		var runErr error
 		for i, j := range s.Jobs {
 			err := j.Run(ctx, func(c *job.RunConfig) {
 				c.Total = len(s.Jobs)
 				c.Current = i + 1
 				c.Parent = fmt.Sprintf("%s[stage(%d/%d): %s]", cfg.Parent, cfg.Current, cfg.Total, s.Name)
+				c.Failed = runErr != nil
 			})
-			if err != nil {
+			if err != nil && runErr == nil {
+				runErr = err
 				s.State.Status = "failed"
 				s.State.Error = err.Error()
 				s.State.FailedAt = time.Now()
@@ -64,9 +104,12 @@ func (s *Stage) Run(ctx context.Context, opts ...RunOption) error {
 				if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
 					s.State.Error = fmt.Sprintf("stage timeout after %d seconds: %s", s.Timeout, err.Error())
 				}
-				return err
 			}
 		}
+
+		if runErr != nil {
+			return runErr
+		}
 	} else {
 		// parallel
 		s.logger.Infof("%s[stage(%d/%d): %s] run mode: parallel", cfg.Parent, cfg.Current, cfg.Total, s.Name)
@@ -95,6 +138,17 @@ func (s *Stage) Run(ctx context.Context, opts ...RunOption) error {
 		}
 	}
 
+	skipped := 0
+	for _, j := range s.Jobs {
+		if j.State.Status == "skipped" {
+			skipped++
+		}
+	}
+	if len(s.Jobs) > 0 && skipped == len(s.Jobs) {
+		s.State.Status = "skipped"
+		return nil
+	}
+
 	s.State.Status = "succeeded"
 	s.State.SucceedAt = time.Now()
 
@@ -2,12 +2,14 @@ package stage
 
 import (
 	"context"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-idp/pipeline/job"
 	"github.com/go-idp/pipeline/step"
+	"github.com/go-zoox/fs"
 )
 
 func TestStageTimeout(t *testing.T) {
@@ -192,3 +194,51 @@ func TestStageTimeoutWithContext(t *testing.T) {
 		}
 	})
 }
+
+func TestStageMatrix(t *testing.T) {
+	t.Run("matrix jobs should respect max_parallel", func(t *testing.T) {
+		workdir := t.TempDir()
+
+		stage := &Stage{
+			Name:    "test stage matrix",
+			Workdir: workdir,
+			RunMode: RunModeParallel,
+			Jobs: []*job.Job{
+				{
+					Name:        "build",
+					MaxParallel: 1,
+					Matrix: &job.Matrix{
+						Axes: map[string][]string{
+							"version": {"1", "2", "3"},
+						},
+					},
+					Steps: []*step.Step{
+						{
+							Name: "build",
+							// fails if another matrix job holds the lock
+							Command: "mkdir lock && touch build-$MATRIX_VERSION.done && sleep 0.2 && rmdir lock",
+						},
+					},
+				},
+			},
+		}
+
+		if err := stage.Setup("test-stage-matrix"); err != nil {
+			t.Fatalf("Failed to setup stage: %v", err)
+		}
+
+		if len(stage.Jobs) != 3 {
+			t.Fatalf("Expected 3 jobs, got %d", len(stage.Jobs))
+		}
+
+		if err := stage.Run(context.Background()); err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+
+		for _, version := range []string{"1", "2", "3"} {
+			if !fs.IsExist(filepath.Join(workdir, "build-"+version+".done")) {
+				t.Errorf("Expected matrix job(version: %s) to run", version)
+			}
+		}
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/go-idp/pipeline/expression"
 	"github.com/go-idp/pipeline/job"
 )
 
@@ -45,6 +46,12 @@ func (s *Stage) Setup(id string, opts ...*Stage) error {
 		}
 	}
 
+	if s.If != "" {
+		if _, err := expression.Parse(s.If); err != nil {
+			return fmt.Errorf("invalid if of stage(%s): %s", s.Name, err)
+		}
+	}
+
 	// setup state
 	s.State = &State{
 		ID:     id,
@@ -53,6 +60,18 @@ func (s *Stage) Setup(id string, opts ...*Stage) error {
 		StartedAt: time.Now(),
 	}
 
+	// expand matrix jobs
+	jobs := []*job.Job{}
+	for _, j := range s.Jobs {
+		expanded, err := j.Expand()
+		if err != nil {
+			return err
+		}
+
+		jobs = append(jobs, expanded...)
+	}
+	s.Jobs = jobs
+
 	// setup jobs
 	for index, j := range s.Jobs {
 		err := j.Setup(fmt.Sprintf("%s.%d", s.State.ID, index), &job.Job{
@@ -2,8 +2,13 @@ package stage
 
 import (
 	"io"
+	"time"
 
+	"github.com/go-idp/pipeline/artifact"
+	"github.com/go-idp/pipeline/cache"
+	"github.com/go-idp/pipeline/event"
 	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/step"
 	"github.com/go-zoox/logger"
 )
 
@@ -19,6 +24,10 @@ type Stage struct {
 	Timeout int64 `json:"timeout" yaml:"timeout"`
 	// RunMode is the mode to run the jobs, e.g. "serial", "parallel", default: parallel
 	RunMode string `json:"run_mode" yaml:"run_mode"`
+	// If is the condition to run the stage, e.g. "env.BRANCH == 'main'", "failure()", "always()"
+	If string `json:"if" yaml:"if"`
+	// Extends is the name of the template in templates.stages of the pipeline to inherit from, the fields set here win
+	Extends string `json:"extends" yaml:"extends"`
 	//
 	State *State `json:"state" yaml:"state"`
 	//
@@ -26,6 +35,12 @@ type Stage struct {
 	stderr io.Writer
 	//
 	logger *logger.Logger
+	// excluded is set by the filter of the run, e.g. --only, --skip
+	excluded bool
+	// restored is set when the state is restored from a previous run, e.g. by resume
+	restored bool
+	//
+	events event.Sink
 }
 
 func (s *Stage) getLogger() *logger.Logger {
@@ -45,3 +60,84 @@ func (s *Stage) SetStderr(stderr io.Writer) {
 		job.SetStderr(stderr)
 	}
 }
+
+// SetArtifactStore sets the artifact store of the jobs
+func (s *Stage) SetArtifactStore(store artifact.Store, pipeline string) {
+	for _, job := range s.Jobs {
+		job.SetArtifactStore(store, pipeline)
+	}
+}
+
+// SetCacheStore sets the cache store of the jobs
+func (s *Stage) SetCacheStore(store cache.Store) {
+	for _, job := range s.Jobs {
+		job.SetCacheStore(store)
+	}
+}
+
+// SetEventSink sets the sink of the events of the stage and its jobs
+func (s *Stage) SetEventSink(sink event.Sink) {
+	s.events = sink
+
+	for _, job := range s.Jobs {
+		job.SetEventSink(sink)
+	}
+}
+
+// SetOutputs sets the outputs scope of the jobs
+func (s *Stage) SetOutputs(outputs *step.Outputs) {
+	for _, job := range s.Jobs {
+		job.SetOutputs(outputs)
+	}
+}
+
+// Start records the start of the stage and emits StageStarted,
+//
+//	Start and Finish are called by Run, or by the dag scheduler running the jobs of the stage
+func (s *Stage) Start() {
+	s.State.StartedAt = time.Now()
+	s.emit(event.StageStarted, nil)
+}
+
+// Finish records the duration of the stage and the exit of its failed job, and emits StageFinished,
+//
+//	err is the error of the stage
+func (s *Stage) Finish(err error) {
+	ended := s.State.SucceedAt
+	if ended.IsZero() {
+		ended = s.State.FailedAt
+	}
+
+	if !ended.IsZero() {
+		s.State.Duration = ended.Sub(s.State.StartedAt).Seconds()
+	}
+
+	if s.State.Status == "failed" {
+		s.State.ExitCode = -1
+		for _, j := range s.Jobs {
+			if j.State != nil && j.State.Status == "failed" {
+				s.State.ExitCode = j.State.ExitCode
+				s.State.Signal = j.State.Signal
+				s.State.OOMKilled = j.State.OOMKilled
+				break
+			}
+		}
+	}
+
+	s.emit(event.StageFinished, err)
+}
+
+// emit emits the event of the stage to the event sink, if any
+func (s *Stage) emit(typ event.Type, err error) {
+	if s.events == nil {
+		return
+	}
+
+	e := event.New(typ, s.State.ID, s.Name)
+	if typ == event.StageFinished {
+		e.Finish(s.State.Status, s.State.Error, err)
+		e.SetExit(s.State.ExitCode, s.State.Signal, s.State.OOMKilled, s.State.Duration)
+	}
+
+	s.events.Emit(e)
+}
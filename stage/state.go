@@ -4,11 +4,56 @@ import "time"
 
 type State struct {
 	ID     string `json:"id" yaml:"id"`
-	Status string `json:"status" yaml:"status"` // pending | running | succeeded | failed
+	Status string `json:"status" yaml:"status"` // pending | running | succeeded | failed | skipped
 	//
 	StartedAt time.Time `json:"started_at" yaml:"started_at"`
 	SucceedAt time.Time `json:"succeed_at" yaml:"succeed_at"`
 	FailedAt  time.Time `json:"failed_at" yaml:"failed_at"`
 	//
 	Error string `json:"error" yaml:"error"`
+	//
+	// ExitCode, Signal and OOMKilled are the exit of the failed job, the exit code is -1 if unknown
+	ExitCode  int    `json:"exit_code" yaml:"exit_code"`
+	Signal    string `json:"signal" yaml:"signal"`
+	OOMKilled bool   `json:"oom_killed" yaml:"oom_killed"`
+	// Duration is the duration of the stage, unit: second
+	Duration float64 `json:"duration" yaml:"duration"`
+}
+
+// Exclude excludes the stage and all of its jobs from the run, e.g. by --skip
+func (s *Stage) Exclude() {
+	s.excluded = true
+	for _, j := range s.Jobs {
+		j.Exclude()
+	}
+}
+
+// Excluded returns true if the stage is excluded from the run
+func (s *Stage) Excluded() bool {
+	return s.excluded
+}
+
+// Restore restores the state of the stage from a previous run of the pipeline, the stage is not run again,
+//
+//	the states of its jobs are restored by the caller
+func (s *Stage) Restore(state *State) {
+	s.State = state
+	s.restored = true
+}
+
+// Restored returns true if the state of the stage is restored from a previous run
+func (s *Stage) Restored() bool {
+	return s.restored
+}
+
+// Skip marks the stage and all of its jobs as skipped
+func (s *Stage) Skip() {
+	if s.State == nil {
+		return
+	}
+
+	s.State.Status = "skipped"
+	for _, j := range s.Jobs {
+		j.Skip()
+	}
 }
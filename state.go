@@ -1,14 +1,80 @@
 package pipeline
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type State struct {
 	ID     string `yaml:"id"`
-	Status string `yaml:"status"` // pending | running | succeeded | failed
+	Status string `yaml:"status"` // pending | running | succeeded | failed | skipped
 	//
 	StartedAt time.Time `yaml:"started_at"`
 	SucceedAt time.Time `yaml:"succeed_at"`
 	FailedAt  time.Time `yaml:"failed_at"`
 	//
 	Error string `yaml:"error"`
+	//
+	// Warnings are the allowed failures, e.g. lint/eslint, the pipeline succeeded with warnings if not empty
+	Warnings []string `yaml:"warnings"`
+	// HookErrors are the errors of on_success, on_failure and finally stages
+	HookErrors []string `yaml:"hook_errors"`
+	//
+	// ExitCode, Signal and OOMKilled are the exit of the failed stage, the exit code is -1 if unknown
+	ExitCode  int    `yaml:"exit_code"`
+	Signal    string `yaml:"signal"`
+	OOMKilled bool   `yaml:"oom_killed"`
+	// Duration is the duration of the run, unit: second
+	Duration float64 `yaml:"duration"`
+}
+
+// HasWarnings returns true if the pipeline succeeded with allowed failures
+func (s *State) HasWarnings() bool {
+	return s.Status == "succeeded" && len(s.Warnings) > 0
+}
+
+// finish sets the duration, and the exit of the first failed stage if the pipeline failed
+func (p *Pipeline) finish() {
+	ended := p.State.SucceedAt
+	if ended.IsZero() {
+		ended = p.State.FailedAt
+	}
+	if !ended.IsZero() {
+		p.State.Duration = ended.Sub(p.State.StartedAt).Seconds()
+	}
+
+	if p.State.Status != "failed" {
+		return
+	}
+
+	p.State.ExitCode = -1
+	for _, s := range p.allStages() {
+		if s.State != nil && s.State.Status == "failed" {
+			p.State.ExitCode = s.State.ExitCode
+			p.State.Signal = s.State.Signal
+			p.State.OOMKilled = s.State.OOMKilled
+			return
+		}
+	}
+}
+
+// warnings returns the jobs and steps whose failure is allowed
+func (p *Pipeline) warnings() []string {
+	warnings := []string{}
+	for _, s := range p.allStages() {
+		for _, j := range s.Jobs {
+			if j.State != nil && j.State.Status == "failed_allowed" {
+				warnings = append(warnings, fmt.Sprintf("%s/%s", s.Name, j.Name))
+				continue
+			}
+
+			for _, st := range j.Steps {
+				if st.State != nil && st.State.Status == "failed_allowed" {
+					warnings = append(warnings, fmt.Sprintf("%s/%s/%s", s.Name, j.Name, st.Name))
+				}
+			}
+		}
+	}
+
+	return warnings
 }
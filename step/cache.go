@@ -0,0 +1,47 @@
+package step
+
+import (
+	"github.com/go-idp/pipeline/cache"
+)
+
+// restoreCache restores the cache of the step, a failure is only a warning
+func (s *Step) restoreCache(prefix string) {
+	if s.Cache == nil {
+		return
+	}
+
+	if s.cacheStore == nil {
+		s.logger.Warnf("%s cache skipped: no cache store", prefix)
+		return
+	}
+
+	result, err := cache.Restore(s.cacheStore, s.Cache, s.Workdir, s.Environment)
+	s.State.Cache = result
+	if err != nil {
+		s.logger.Warnf("%s failed to restore cache: %s", prefix, err)
+		return
+	}
+
+	switch {
+	case result.Hit:
+		s.logger.Infof("%s cache hit (key: %s)", prefix, result.Key)
+	case result.RestoredKey != "":
+		s.logger.Infof("%s cache miss (key: %s), restored from %s", prefix, result.Key, result.RestoredKey)
+	default:
+		s.logger.Infof("%s cache miss (key: %s)", prefix, result.Key)
+	}
+}
+
+// saveCache saves the cache of the step after it succeeds, a failure is only a warning
+func (s *Step) saveCache(prefix string) {
+	if s.Cache == nil || s.cacheStore == nil || s.State.Cache == nil || s.State.Cache.Hit {
+		return
+	}
+
+	if err := cache.Save(s.cacheStore, s.Cache, s.State.Cache, s.Workdir); err != nil {
+		s.logger.Warnf("%s failed to save cache: %s", prefix, err)
+		return
+	}
+
+	s.logger.Infof("%s cache saved (key: %s)", prefix, s.State.Cache.Key)
+}
@@ -0,0 +1,56 @@
+package step
+
+import (
+	"strings"
+)
+
+// signals are the common signals terminating the commands, by number,
+//
+//	with the description in the errors of the host engine, e.g. signal: killed
+var signals = map[int]struct {
+	Name        string
+	Description string
+}{
+	1:  {"SIGHUP", "hangup"},
+	2:  {"SIGINT", "interrupt"},
+	3:  {"SIGQUIT", "quit"},
+	4:  {"SIGILL", "illegal instruction"},
+	6:  {"SIGABRT", "aborted"},
+	8:  {"SIGFPE", "floating point exception"},
+	9:  {"SIGKILL", "killed"},
+	11: {"SIGSEGV", "segmentation fault"},
+	13: {"SIGPIPE", "broken pipe"},
+	14: {"SIGALRM", "alarm clock"},
+	15: {"SIGTERM", "terminated"},
+}
+
+// exitOf returns the exit code and the terminating signal of the command error,
+//
+//	a command terminated by a signal exits with 128 + the signal number, as in the shells and containers,
+//	the exit code is -1 if unknown, e.g. the command failed to start
+func exitOf(err error) (code int, signal string) {
+	if err == nil {
+		return 0, ""
+	}
+
+	code = exitCodeOf(err)
+
+	// the host engine: signal: killed
+	if code == -1 {
+		if _, description, ok := strings.Cut(err.Error(), "signal: "); ok {
+			for number, s := range signals {
+				if s.Description == description {
+					return 128 + number, s.Name
+				}
+			}
+		}
+
+		return code, ""
+	}
+
+	if s, ok := signals[code-128]; ok {
+		return code, s.Name
+	}
+
+	return code, ""
+}
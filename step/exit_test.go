@@ -0,0 +1,77 @@
+package step
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStepExit(t *testing.T) {
+	t.Run("step should record the exit code", func(t *testing.T) {
+		step := &Step{
+			Name:    "exit step",
+			Workdir: t.TempDir(),
+			Command: "exit 3",
+		}
+
+		if err := step.Setup("test-step-exit"); err != nil {
+			t.Fatalf("Failed to setup step: %v", err)
+		}
+
+		if err := step.Run(context.Background()); err == nil {
+			t.Fatal("Expected error, but got nil")
+		}
+
+		if step.State.ExitCode != 3 {
+			t.Errorf("Expected exit code 3, got %d", step.State.ExitCode)
+		}
+		if step.State.Signal != "" {
+			t.Errorf("Expected no signal, got '%s'", step.State.Signal)
+		}
+		if step.State.Duration <= 0 {
+			t.Errorf("Expected duration, got %f", step.State.Duration)
+		}
+	})
+
+	t.Run("step should record the terminating signal", func(t *testing.T) {
+		step := &Step{
+			Name:    "killed step",
+			Workdir: t.TempDir(),
+			Command: "kill -9 $$",
+		}
+
+		if err := step.Setup("test-step-exit-signal"); err != nil {
+			t.Fatalf("Failed to setup step: %v", err)
+		}
+
+		if err := step.Run(context.Background()); err == nil {
+			t.Fatal("Expected error, but got nil")
+		}
+
+		if step.State.ExitCode != 137 {
+			t.Errorf("Expected exit code 137, got %d", step.State.ExitCode)
+		}
+		if step.State.Signal != "SIGKILL" {
+			t.Errorf("Expected signal 'SIGKILL', got '%s'", step.State.Signal)
+		}
+	})
+
+	t.Run("succeeded step should exit with 0", func(t *testing.T) {
+		step := &Step{
+			Name:    "ok step",
+			Workdir: t.TempDir(),
+			Command: "true",
+		}
+
+		if err := step.Setup("test-step-exit-ok"); err != nil {
+			t.Fatalf("Failed to setup step: %v", err)
+		}
+
+		if err := step.Run(context.Background()); err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+
+		if step.State.ExitCode != 0 {
+			t.Errorf("Expected exit code 0, got %d", step.State.ExitCode)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package step
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// oomWatcher watches the oom events of the container of a step, the container is removed when it exits,
+//
+//	so it cannot be inspected afterwards
+type oomWatcher struct {
+	killed chan struct{}
+	cancel context.CancelFunc
+}
+
+// watchOOM starts to watch the oom events of the container, nil if the docker engine is not reachable
+func watchOOM(container string) *oomWatcher {
+	c, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages, errs := c.Events(ctx, events.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", string(events.ContainerEventType)),
+			filters.Arg("event", string(events.ActionOOM)),
+			filters.Arg("container", container),
+		),
+	})
+
+	w := &oomWatcher{
+		killed: make(chan struct{}),
+		cancel: cancel,
+	}
+
+	go func() {
+		defer c.Close()
+
+		select {
+		case <-messages:
+			close(w.killed)
+		case <-errs:
+		}
+	}()
+
+	return w
+}
+
+// Killed returns true if the container was killed by out of memory,
+//
+//	the event may arrive after the command exits, it is waited for up to grace
+func (w *oomWatcher) Killed(grace time.Duration) bool {
+	if w == nil {
+		return false
+	}
+
+	select {
+	case <-w.killed:
+		return true
+	case <-time.After(grace):
+		return false
+	}
+}
+
+// Stop stops watching
+func (w *oomWatcher) Stop() {
+	if w == nil {
+		return
+	}
+
+	w.cancel()
+}
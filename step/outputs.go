@@ -0,0 +1,152 @@
+package step
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Outputs stores the outputs of the steps by step name
+//
+//	outputs are written to the scope and all of its parents,
+//	and read from the nearest scope, so that a step of the same job wins
+type Outputs struct {
+	sync.RWMutex
+	parent *Outputs
+	values map[string]map[string]string
+}
+
+// NewOutputs creates an outputs scope, parent is optional
+func NewOutputs(parent *Outputs) *Outputs {
+	return &Outputs{
+		parent: parent,
+		values: map[string]map[string]string{},
+	}
+}
+
+// Set sets the outputs of the step
+func (o *Outputs) Set(step string, values map[string]string) {
+	for scope := o; scope != nil; scope = scope.parent {
+		scope.Lock()
+		scope.values[step] = values
+		scope.Unlock()
+	}
+}
+
+// Get returns the output of the step
+func (o *Outputs) Get(step, key string) (string, bool) {
+	for scope := o; scope != nil; scope = scope.parent {
+		scope.RLock()
+		values, ok := scope.values[step]
+		scope.RUnlock()
+
+		if ok {
+			v, ok := values[key]
+			return v, ok
+		}
+	}
+
+	return "", false
+}
+
+// outputExpressionRe matches ${{ ... }}
+var outputExpressionRe = regexp.MustCompile(`\$\{\{(.*?)\}\}`)
+
+// outputReferenceRe matches steps.<name>.outputs.<key>
+var outputReferenceRe = regexp.MustCompile(`^steps\.([^.\s]+)\.outputs\.([A-Za-z0-9_-]+)$`)
+
+// secretReferenceRe matches secrets.<name>, resolved when the pipeline is prepared
+var secretReferenceRe = regexp.MustCompile(`^secrets\.[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateOutputReferences checks the ${{ steps.<name>.outputs.<key> }} and ${{ secrets.<name> }} references of the text
+func ValidateOutputReferences(text string) error {
+	for _, m := range outputExpressionRe.FindAllStringSubmatch(text, -1) {
+		ref := strings.TrimSpace(m[1])
+		if !outputReferenceRe.MatchString(ref) && !secretReferenceRe.MatchString(ref) {
+			return fmt.Errorf("invalid reference %s, only ${{ steps.<name>.outputs.<key> }} and ${{ secrets.<name> }} are supported", m[0])
+		}
+	}
+
+	return nil
+}
+
+// resolveOutputReferences replaces the ${{ steps.<name>.outputs.<key> }} references of the text,
+//
+//	a missing output is replaced with empty string
+func resolveOutputReferences(text string, outputs *Outputs) string {
+	if !strings.Contains(text, "${{") {
+		return text
+	}
+
+	return outputExpressionRe.ReplaceAllStringFunc(text, func(expr string) string {
+		ref := outputReferenceRe.FindStringSubmatch(strings.TrimSpace(outputExpressionRe.FindStringSubmatch(expr)[1]))
+		if ref == nil || outputs == nil {
+			return ""
+		}
+
+		v, _ := outputs.Get(ref[1], ref[2])
+		return v
+	})
+}
+
+// outputFile returns the path of the output file of the step, exposed as $PIPELINE_OUTPUT
+func (s *Step) outputFile() string {
+	return filepath.Join(s.Workdir, fmt.Sprintf(".pipeline-output-%s", s.State.ID))
+}
+
+// prepareOutputFile creates an empty output file for the attempt
+func (s *Step) prepareOutputFile() error {
+	if err := os.WriteFile(s.outputFile(), []byte{}, 0644); err != nil {
+		return fmt.Errorf("failed to create output file: %s", err)
+	}
+
+	return nil
+}
+
+// collectOutputs parses the output file into State.Outputs, and removes it
+func (s *Step) collectOutputs() error {
+	path := s.outputFile()
+	defer os.Remove(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to read output file: %s", err)
+	}
+
+	s.State.Outputs = parseOutputs(data)
+	if s.outputs != nil {
+		s.outputs.Set(s.Name, s.State.Outputs)
+	}
+
+	return nil
+}
+
+// parseOutputs parses key=value lines, empty lines and lines without = are ignored
+func parseOutputs(data []byte) map[string]string {
+	outputs := map[string]string{}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+
+		outputs[key] = value
+	}
+
+	return outputs
+}
@@ -0,0 +1,94 @@
+package step
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseOutputs(t *testing.T) {
+	outputs := parseOutputs([]byte("version=1.2.3\r\n\ninvalid line\n tag = v1=latest\n=empty\n"))
+
+	expected := map[string]string{
+		"version": "1.2.3",
+		"tag":     " v1=latest",
+	}
+	if len(outputs) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, outputs)
+	}
+	for k, v := range expected {
+		if outputs[k] != v {
+			t.Errorf("Expected output %s '%s', got '%s'", k, v, outputs[k])
+		}
+	}
+}
+
+func TestOutputReferences(t *testing.T) {
+	parent := NewOutputs(nil)
+	parent.Set("build", map[string]string{"version": "1.0.0"})
+
+	scope := NewOutputs(parent)
+	scope.Set("check", map[string]string{"changed": "true"})
+
+	if _, ok := parent.Get("check", "changed"); !ok {
+		t.Error("Expected outputs to be published to the parent scope")
+	}
+
+	got := resolveOutputReferences("v${{ steps.build.outputs.version }}-${{steps.check.outputs.changed}}-${{ steps.missing.outputs.key }}", scope)
+	if got != "v1.0.0-true-" {
+		t.Errorf("Expected 'v1.0.0-true-', got '%s'", got)
+	}
+
+	if err := ValidateOutputReferences("echo ${{ steps.build.outputs.version }}"); err != nil {
+		t.Errorf("Expected no error, but got: %v", err)
+	}
+
+	if err := ValidateOutputReferences("echo ${{ env.FOO }}"); err == nil {
+		t.Error("Expected error for invalid reference, but got nil")
+	}
+}
+
+func TestStepOutputs(t *testing.T) {
+	outputs := NewOutputs(nil)
+
+	producer := &Step{
+		Name:    "producer",
+		Workdir: t.TempDir(),
+		Command: `echo "version=1.2.3" >> "$PIPELINE_OUTPUT"`,
+	}
+	producer.SetOutputs(outputs)
+	if err := producer.Setup("test-step-outputs.0"); err != nil {
+		t.Fatalf("Failed to setup step: %v", err)
+	}
+	if err := producer.Run(context.Background()); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	if producer.State.Outputs["version"] != "1.2.3" {
+		t.Fatalf("Expected output version '1.2.3', got %v", producer.State.Outputs)
+	}
+
+	consumer := &Step{
+		Name:    "consumer",
+		Workdir: t.TempDir(),
+		If:      "'${{ steps.producer.outputs.version }}' == '1.2.3'",
+		Command: `test "$VERSION" = 1.2.3 && test "${{ steps.producer.outputs.version }}" = 1.2.3`,
+		Environment: map[string]string{
+			"VERSION": "${{ steps.producer.outputs.version }}",
+		},
+	}
+	consumer.SetOutputs(outputs)
+	if err := consumer.Setup("test-step-outputs.1"); err != nil {
+		t.Fatalf("Failed to setup step: %v", err)
+	}
+	if err := consumer.Run(context.Background()); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	if consumer.State.Status != "succeeded" {
+		t.Errorf("Expected status 'succeeded', got '%s'", consumer.State.Status)
+	}
+
+	if consumer.Environment["VERSION"] != "${{ steps.producer.outputs.version }}" {
+		t.Error("Expected the environment of the step not to be modified")
+	}
+}
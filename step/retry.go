@@ -0,0 +1,126 @@
+package step
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	cmderrors "github.com/go-zoox/command/errors"
+)
+
+// RetryBackoffFixed waits the same delay before every retry
+const RetryBackoffFixed = "fixed"
+
+// RetryBackoffExponential doubles the delay before every retry
+const RetryBackoffExponential = "exponential"
+
+// Retry is the retry policy of the step
+type Retry struct {
+	// Attempts is the maximum number of attempts, including the first one, default: 1 (no retry)
+	Attempts int `json:"attempts" yaml:"attempts"`
+
+	// Backoff is the strategy of the delay between attempts, e.g. "fixed", "exponential", default: fixed
+	Backoff string `json:"backoff" yaml:"backoff"`
+
+	// Delay is the delay before the first retry, unit: second, default: 0 (retry immediately)
+	Delay int64 `json:"delay" yaml:"delay"`
+
+	// MaxDelay is the maximum delay of exponential backoff, unit: second, default: 0 (no limit)
+	MaxDelay int64 `json:"max_delay" yaml:"max_delay"`
+
+	// ExitCodes only retries when the command exits with one of them, e.g. [128, 255]
+	ExitCodes []int `json:"exit_codes" yaml:"exit_codes"`
+
+	// OnTimeout only retries when the attempt times out
+	//	when neither exit_codes nor on_timeout is set, any failure is retried
+	OnTimeout bool `json:"on_timeout" yaml:"on_timeout"`
+}
+
+// Attempt is the outcome of an attempt of the step
+type Attempt struct {
+	Attempt int    `json:"attempt" yaml:"attempt"`
+	Status  string `json:"status" yaml:"status"` // succeeded | failed
+	//
+	StartedAt time.Time `json:"started_at" yaml:"started_at"`
+	EndedAt   time.Time `json:"ended_at" yaml:"ended_at"`
+	//
+	ExitCode int    `json:"exit_code" yaml:"exit_code"`
+	Timeout  bool   `json:"timeout" yaml:"timeout"`
+	Error    string `json:"error,omitempty" yaml:"error"`
+	//
+	Signal    string `json:"signal,omitempty" yaml:"signal"`
+	OOMKilled bool   `json:"oom_killed,omitempty" yaml:"oom_killed"`
+}
+
+// Validate checks the retry policy
+func (r *Retry) Validate() error {
+	if r.Attempts < 0 {
+		return fmt.Errorf("retry attempts should not be negative")
+	}
+
+	if r.Delay < 0 || r.MaxDelay < 0 {
+		return fmt.Errorf("retry delay should not be negative")
+	}
+
+	switch r.Backoff {
+	case "", RetryBackoffFixed, RetryBackoffExponential:
+	default:
+		return fmt.Errorf("unsupported retry backoff %s, only support fixed | exponential", r.Backoff)
+	}
+
+	return nil
+}
+
+// delay returns the delay before the given retry, starting from 1
+func (r *Retry) delay(retry int) time.Duration {
+	delay := time.Duration(r.Delay) * time.Second
+	if r.Backoff != RetryBackoffExponential {
+		return delay
+	}
+
+	for i := 1; i < retry; i++ {
+		delay *= 2
+
+		if r.MaxDelay > 0 && delay > time.Duration(r.MaxDelay)*time.Second {
+			return time.Duration(r.MaxDelay) * time.Second
+		}
+	}
+
+	return delay
+}
+
+// match returns true if the failed attempt should be retried
+func (r *Retry) match(attempt *Attempt) bool {
+	if len(r.ExitCodes) == 0 && !r.OnTimeout {
+		return true
+	}
+
+	if r.OnTimeout && attempt.Timeout {
+		return true
+	}
+
+	for _, code := range r.ExitCodes {
+		if !attempt.Timeout && attempt.ExitCode == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// exitCodeOf returns the exit code of the command error, -1 if unknown
+func exitCodeOf(err error) int {
+	var exitErr *cmderrors.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return -1
+}
+
+// isTimeout returns true if the command error is caused by timeout
+func isTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), "timeout to run command")
+}
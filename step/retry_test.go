@@ -0,0 +1,137 @@
+package step
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStepRetry(t *testing.T) {
+	t.Run("step should succeed after retries", func(t *testing.T) {
+		step := &Step{
+			Name:    "flaky step",
+			Workdir: t.TempDir(),
+			// fails twice, then succeeds
+			Command: "n=$(cat count 2>/dev/null || echo 0); n=$((n+1)); echo $n > count; [ $n -ge 3 ]",
+			Retry: &Retry{
+				Attempts: 3,
+			},
+		}
+
+		if err := step.Setup("test-step-retry"); err != nil {
+			t.Fatalf("Failed to setup step: %v", err)
+		}
+
+		if err := step.Run(context.Background()); err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+
+		if step.State.Status != "succeeded" {
+			t.Errorf("Expected status 'succeeded', got '%s'", step.State.Status)
+		}
+
+		if len(step.State.Attempts) != 3 {
+			t.Fatalf("Expected 3 attempts, got %d", len(step.State.Attempts))
+		}
+
+		expected := []string{"failed", "failed", "succeeded"}
+		for i, attempt := range step.State.Attempts {
+			if attempt.Status != expected[i] {
+				t.Errorf("Expected attempt %d status '%s', got '%s'", i+1, expected[i], attempt.Status)
+			}
+		}
+
+		if step.State.Attempts[0].ExitCode != 1 {
+			t.Errorf("Expected attempt 1 exit code 1, got %d", step.State.Attempts[0].ExitCode)
+		}
+	})
+
+	t.Run("step should not retry unmatched exit code", func(t *testing.T) {
+		step := &Step{
+			Name:    "unmatched exit code",
+			Command: "exit 3",
+			Retry: &Retry{
+				Attempts:  3,
+				ExitCodes: []int{2},
+			},
+		}
+
+		if err := step.Setup("test-step-retry-exit-code"); err != nil {
+			t.Fatalf("Failed to setup step: %v", err)
+		}
+
+		if err := step.Run(context.Background()); err == nil {
+			t.Fatal("Expected error, but got nil")
+		}
+
+		if len(step.State.Attempts) != 1 {
+			t.Fatalf("Expected 1 attempt, got %d", len(step.State.Attempts))
+		}
+
+		if step.State.Attempts[0].ExitCode != 3 {
+			t.Errorf("Expected exit code 3, got %d", step.State.Attempts[0].ExitCode)
+		}
+	})
+
+	t.Run("step should retry on timeout", func(t *testing.T) {
+		step := &Step{
+			Name:    "timeout step",
+			Command: "sleep 3",
+			Timeout: 1,
+			Retry: &Retry{
+				Attempts:  2,
+				OnTimeout: true,
+			},
+		}
+
+		if err := step.Setup("test-step-retry-timeout"); err != nil {
+			t.Fatalf("Failed to setup step: %v", err)
+		}
+
+		if err := step.Run(context.Background()); err == nil {
+			t.Fatal("Expected error, but got nil")
+		}
+
+		if len(step.State.Attempts) != 2 {
+			t.Fatalf("Expected 2 attempts, got %d", len(step.State.Attempts))
+		}
+
+		for _, attempt := range step.State.Attempts {
+			if !attempt.Timeout {
+				t.Errorf("Expected attempt %d to time out", attempt.Attempt)
+			}
+		}
+	})
+
+	t.Run("invalid backoff should fail setup", func(t *testing.T) {
+		step := &Step{
+			Name:    "invalid backoff",
+			Command: "echo hello",
+			Retry: &Retry{
+				Attempts: 2,
+				Backoff:  "linear",
+			},
+		}
+
+		if err := step.Setup("test-step-retry-invalid"); err == nil {
+			t.Fatal("Expected setup error, but got nil")
+		}
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	fixed := &Retry{Delay: 2}
+	for retry := 1; retry <= 3; retry++ {
+		if d := fixed.delay(retry); d != 2*time.Second {
+			t.Errorf("fixed delay(%d) = %s, want 2s", retry, d)
+		}
+	}
+
+	exponential := &Retry{Delay: 1, Backoff: RetryBackoffExponential, MaxDelay: 5}
+	expected := []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		if d := exponential.delay(i + 1); d != want {
+			t.Errorf("exponential delay(%d) = %s, want %s", i+1, d, want)
+		}
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"time"
 
+	"github.com/go-idp/pipeline/event"
+	"github.com/go-idp/pipeline/expression"
 	"github.com/go-zoox/command"
 	"github.com/go-zoox/command/config"
 	"github.com/go-zoox/core-utils/strings"
 	"github.com/go-zoox/crypto/base64"
+	"github.com/go-zoox/uuid"
 )
 
 // RunConfig is the config for run
@@ -21,19 +25,60 @@ type RunConfig struct {
 	Current int
 	// Parent is the parent name
 	Parent string
+	// Failed is true when a previous unit has failed, used by failure() in if
+	Failed bool
 }
 
 // RunOption is the option for run
 type RunOption func(cfg *RunConfig)
 
 // Run runs the step
-func (s *Step) Run(ctx context.Context, opts ...RunOption) error {
+func (s *Step) Run(ctx context.Context, opts ...RunOption) (err error) {
 	cfg := &RunConfig{}
 	for _, o := range opts {
 		o(cfg)
 	}
 
+	if s.State == nil {
+		return fmt.Errorf("you should setup before run")
+	}
+
+	defer func() {
+		s.State.finish()
+		s.emit(event.StepFinished, err)
+	}()
+
+	if s.restored {
+		s.logger.Infof("%s[step(%d/%d): %s] %s in the previous run (resumed)", cfg.Parent, cfg.Current, cfg.Total, s.Name, s.State.Status)
+		return nil
+	}
+
+	if s.excluded {
+		s.logger.Infof("%s[step(%d/%d): %s] skipped (filtered)", cfg.Parent, cfg.Current, cfg.Total, s.Name)
+		s.Skip()
+		return nil
+	}
+
+	ok, err := expression.Condition(resolveOutputReferences(s.If, s.outputs), &expression.Context{
+		Environment: s.Environment,
+		Failed:      cfg.Failed,
+	})
+	if err != nil {
+		return fmt.Errorf("%s[step(%d/%d): %s] %s", cfg.Parent, cfg.Current, cfg.Total, s.Name, err)
+	}
+	if !ok {
+		if s.If != "" {
+			s.logger.Infof("%s[step(%d/%d): %s] skipped (if: %s)", cfg.Parent, cfg.Current, cfg.Total, s.Name, s.If)
+		} else {
+			s.logger.Infof("%s[step(%d/%d): %s] skipped (previous failure)", cfg.Parent, cfg.Current, cfg.Total, s.Name)
+		}
+		s.Skip()
+		return nil
+	}
+
 	s.logger.Infof("%s[step(%d/%d): %s] start", cfg.Parent, cfg.Current, cfg.Total, s.Name)
+	s.State.StartedAt = time.Now()
+	s.emit(event.StepStarted, nil)
 	if s.Timeout > 0 {
 		s.logger.Infof("%s[step(%d/%d): %s] timeout: %d seconds", cfg.Parent, cfg.Current, cfg.Total, s.Name, s.Timeout)
 	}
@@ -43,22 +88,146 @@ func (s *Step) Run(ctx context.Context, opts ...RunOption) error {
 		s.logger.Infof("%s[step(%d/%d): %s] use plugin => %s", cfg.Parent, cfg.Current, cfg.Total, s.Name, s.Plugin.Image)
 	}
 
-	if s.State == nil {
-		return fmt.Errorf("you should setup before run")
+	ccfg, err := s.commandConfig()
+	if err != nil {
+		return err
 	}
 
-	// Create context with timeout for step
-	var cancel context.CancelFunc
-	if s.Timeout > 0 {
-		ctx, cancel = context.WithTimeout(ctx, time.Duration(s.Timeout)*time.Second)
-		defer cancel()
+	// outputs are only supported by the engines sharing the workdir with the runner
+	withOutputs := ccfg.Engine == "host" || ccfg.Engine == "docker"
+	if withOutputs {
+		ccfg.Environment["PIPELINE_OUTPUT"] = s.outputFile()
+	}
+
+	s.restoreCache(fmt.Sprintf("%s[step(%d/%d): %s]", cfg.Parent, cfg.Current, cfg.Total, s.Name))
+
+	attempts := 1
+	if s.Retry != nil && s.Retry.Attempts > 1 {
+		attempts = s.Retry.Attempts
+	}
+
+	// unknown until the command runs
+	s.State.ExitCode = -1
+
+	for i := 1; i <= attempts; i++ {
+		if withOutputs {
+			if err = s.prepareOutputFile(); err != nil {
+				break
+			}
+		}
+
+		if i > 1 {
+			delay := s.Retry.delay(i - 1)
+			s.logger.Infof("%s[step(%d/%d): %s] retry (attempt %d/%d) in %s", cfg.Parent, cfg.Current, cfg.Total, s.Name, i, attempts, delay)
+
+			cancelled := false
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				err = ctx.Err()
+				cancelled = true
+			}
+			if cancelled {
+				break
+			}
+		}
+
+		attempt := &Attempt{
+			Attempt:   i,
+			StartedAt: time.Now(),
+		}
+
+		var oomKilled bool
+		oomKilled, err = s.runCommand(ctx, ccfg)
+
+		attempt.EndedAt = time.Now()
+		if err != nil {
+			attempt.Status = "failed"
+			attempt.Error = err.Error()
+			attempt.ExitCode, attempt.Signal = exitOf(err)
+			attempt.OOMKilled = oomKilled
+			attempt.Timeout = isTimeout(err)
+		} else {
+			attempt.Status = "succeeded"
+		}
+
+		s.State.ExitCode = attempt.ExitCode
+		s.State.Signal = attempt.Signal
+		s.State.OOMKilled = attempt.OOMKilled
+		if attempt.OOMKilled {
+			s.logger.Warnf("%s[step(%d/%d): %s] the container was killed by out of memory (exit code: %d)", cfg.Parent, cfg.Current, cfg.Total, s.Name, attempt.ExitCode)
+		}
+
+		if s.Retry != nil {
+			s.State.Attempts = append(s.State.Attempts, attempt)
+		}
+
+		if err == nil || i == attempts {
+			break
+		}
+
+		if !s.Retry.match(attempt) {
+			s.logger.Infof("%s[step(%d/%d): %s] attempt %d/%d failed (exit code: %d, timeout: %v), not retryable: %s", cfg.Parent, cfg.Current, cfg.Total, s.Name, i, attempts, attempt.ExitCode, attempt.Timeout, err)
+			break
+		}
+
+		s.logger.Infof("%s[step(%d/%d): %s] attempt %d/%d failed (exit code: %d, timeout: %v): %s", cfg.Parent, cfg.Current, cfg.Total, s.Name, i, attempts, attempt.ExitCode, attempt.Timeout, err)
+	}
+
+	// outputs are collected even if the step failed, e.g. for failure() steps
+	if withOutputs {
+		if oerr := s.collectOutputs(); oerr != nil && err == nil {
+			err = oerr
+		}
+	}
+
+	if err != nil {
+		s.State.Status = "failed"
+		s.State.Error = err.Error()
+		s.State.FailedAt = time.Now()
+		// Check if error is due to context timeout
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			s.State.Error = fmt.Sprintf("step timeout after %d seconds: %s", s.Timeout, err.Error())
+		}
+		// s.State.ExitCode = cmd.Cancel()
+
+		if s.AllowFailure {
+			s.State.Status = "failed_allowed"
+			s.logger.Warnf("%s[step(%d/%d): %s] failed (allowed): %s", cfg.Parent, cfg.Current, cfg.Total, s.Name, err)
+			return nil
+		}
+
+		return fmt.Errorf("failed to run command: %s", err)
+	}
+	s.saveCache(fmt.Sprintf("%s[step(%d/%d): %s]", cfg.Parent, cfg.Current, cfg.Total, s.Name))
+
+	s.State.Status = "succeeded"
+	s.State.SucceedAt = time.Now()
+
+	return nil
+}
+
+// EffectiveEngine returns the engine the step runs on after setup, e.g. host, docker, ssh, idp
+func (s *Step) EffectiveEngine() (string, error) {
+	ccfg, err := s.commandConfig()
+	if err != nil {
+		return "", err
+	}
+
+	return ccfg.Engine, nil
+}
+
+// commandConfig returns the command config of the step
+func (s *Step) commandConfig() (*config.Config, error) {
+	// the outputs of previous steps are resolved right before the step runs
+	environment := make(map[string]string, len(s.Environment)+1)
+	for k, v := range s.Environment {
+		environment[k] = resolveOutputReferences(v, s.outputs)
 	}
 
 	ccfg := &config.Config{
-		Context: ctx,
-		//
-		Command:     s.Command,
-		Environment: s.Environment,
+		Command:     resolveOutputReferences(s.Command, s.outputs),
+		Environment: environment,
 		//
 		WorkDir: s.Workdir,
 		//
@@ -118,7 +287,7 @@ func (s *Step) Run(ctx context.Context, opts ...RunOption) error {
 
 				if ccfg.SSHUser == "private_key" {
 					if ccfg.SSHPass == "" {
-						return fmt.Errorf("private_key should be set for ssh engine, when user is private_key")
+						return nil, fmt.Errorf("private_key should be set for ssh engine, when user is private_key")
 					}
 
 					ccfg.SSHPrivateKey = base64.Decode(ccfg.SSHPass)
@@ -129,37 +298,90 @@ func (s *Step) Run(ctx context.Context, opts ...RunOption) error {
 				ccfg.Server = fmt.Sprintf("wss://%s", agentX.Host)
 				ccfg.Engine = "idp"
 			default:
-				return fmt.Errorf("unsupported engine: %s (uri: %s)", ccfg.Engine, s.Engine)
+				return nil, fmt.Errorf("unsupported engine: %s (uri: %s)", ccfg.Engine, s.Engine)
 			}
 		}
 	}
 
-	cmd, err := command.New(ccfg)
+	return ccfg, nil
+}
+
+// runCommand runs the command once, the timeout of the step applies to each attempt,
+//
+//	oomKilled is true if the container of the docker engine was killed by out of memory
+func (s *Step) runCommand(ctx context.Context, ccfg *config.Config) (oomKilled bool, err error) {
+	// Create context with timeout for step
+	var cancel context.CancelFunc
+	if s.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(s.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	c := *ccfg
+	c.Context = ctx
+
+	// the container is named to watch its oom events
+	var oom *oomWatcher
+	if c.Engine == "docker" {
+		c.ID = fmt.Sprintf("go-idp_pipeline_%s", uuid.V4())
+		oom = watchOOM(c.ID)
+		defer oom.Stop()
+	}
+
+	cmd, err := command.New(&c)
 	if err != nil {
-		return fmt.Errorf("failed to create command: %s", err)
+		return false, fmt.Errorf("failed to create command: %s", err)
 	}
 
-	if err := cmd.SetStdout(s.stdout); err != nil {
-		return fmt.Errorf("failed to set stdout: %s", err)
+	// the output of the command is separated by the step, e.g. in the log store of the server
+	stdout, flushStdout := segment(s.stdout, s.State.ID)
+	stderr, flushStderr := segment(s.stderr, s.State.ID)
+	defer flushStdout()
+	defer flushStderr()
+
+	if s.events != nil {
+		o := event.NewOutputWriter(s.events, s.State.ID, s.Name, "stdout")
+		e := event.NewOutputWriter(s.events, s.State.ID, s.Name, "stderr")
+		defer o.Flush()
+		defer e.Flush()
+
+		stdout = io.MultiWriter(stdout, o)
+		stderr = io.MultiWriter(stderr, e)
+	}
+
+	if err := cmd.SetStdout(stdout); err != nil {
+		return false, fmt.Errorf("failed to set stdout: %s", err)
 	}
 
-	if err := cmd.SetStderr(s.stderr); err != nil {
-		return fmt.Errorf("failed to set stderr: %s", err)
+	if err := cmd.SetStderr(stderr); err != nil {
+		return false, fmt.Errorf("failed to set stderr: %s", err)
 	}
 
 	if err := cmd.Run(); err != nil {
-		s.State.Status = "failed"
-		s.State.Error = err.Error()
-		s.State.FailedAt = time.Now()
-		// Check if error is due to context timeout
-		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-			s.State.Error = fmt.Sprintf("step timeout after %d seconds: %s", s.Timeout, err.Error())
+		// the oom event may arrive after the container exits
+		grace := time.Duration(0)
+		if _, signal := exitOf(err); signal == "SIGKILL" {
+			grace = time.Second
 		}
-		// s.State.ExitCode = cmd.Cancel()
-		return fmt.Errorf("failed to run command: %s", err)
+
+		return oom.Killed(grace), err
 	}
-	s.State.Status = "succeeded"
-	s.State.SucceedAt = time.Now()
 
-	return nil
+	return false, nil
+}
+
+// emit emits the event of the step to the event sink, if any
+func (s *Step) emit(typ event.Type, err error) {
+	if s.events == nil {
+		return
+	}
+
+	e := event.New(typ, s.State.ID, s.Name)
+	if typ == event.StepFinished {
+		e.Finish(s.State.Status, s.State.Error, err)
+		e.SetExit(s.State.ExitCode, s.State.Signal, s.State.OOMKilled, s.State.Duration)
+		e.Outputs = s.State.Outputs
+	}
+
+	s.events.Emit(e)
 }
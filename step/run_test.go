@@ -172,3 +172,27 @@ func TestStepTimeoutWithContext(t *testing.T) {
 		}
 	})
 }
+
+func TestStepAllowFailure(t *testing.T) {
+	step := &Step{
+		Name:         "allowed failure",
+		Command:      "exit 1",
+		AllowFailure: true,
+	}
+
+	if err := step.Setup("test-step-allow-failure"); err != nil {
+		t.Fatalf("Failed to setup step: %v", err)
+	}
+
+	if err := step.Run(context.Background()); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	if step.State.Status != "failed_allowed" {
+		t.Errorf("Expected status 'failed_allowed', got '%s'", step.State.Status)
+	}
+
+	if step.State.Error == "" {
+		t.Error("Expected error to be recorded")
+	}
+}
@@ -0,0 +1,65 @@
+package step
+
+import (
+	"io"
+	"strings"
+)
+
+// Segmenter is a stdout or stderr separating the output of the units, e.g. the log store of the server
+type Segmenter interface {
+	io.Writer
+	// Segment returns the writer of the output of the unit, nil if the output is not separated,
+	//	the ids are the State.ID of its stage, job and step, step is empty for the output of the job itself
+	Segment(stage, job, step string) io.Writer
+}
+
+// Segment returns the writer of the output of the step with the id, w itself if it does not separate the units
+func Segment(w io.Writer, id string) io.Writer {
+	segment, _ := segment(w, id)
+	return segment
+}
+
+// SegmentJob returns the writer of the output of the job with the id, w itself if it does not separate the units
+func SegmentJob(w io.Writer, id string) io.Writer {
+	if s, ok := w.(Segmenter); ok {
+		if segment := s.Segment(parentID(id), id, ""); segment != nil {
+			return segment
+		}
+	}
+
+	return w
+}
+
+// segment returns the writer of the output of the step, and the flush of its held incomplete last line,
+//
+//	e.g. the output without a trailing newline, w is shared by the other units and not flushed
+func segment(w io.Writer, id string) (io.Writer, func()) {
+	s, ok := w.(Segmenter)
+	if !ok {
+		return w, func() {}
+	}
+
+	job := parentID(id)
+	segment := s.Segment(parentID(job), job, id)
+	if segment == nil {
+		return w, func() {}
+	}
+
+	return segment, func() {
+		switch f := segment.(type) {
+		case interface{ Flush() }:
+			f.Flush()
+		case interface{ Flush() error }:
+			f.Flush()
+		}
+	}
+}
+
+// parentID returns the State.ID of the parent unit, e.g. <pipeline>.0 of the job <pipeline>.0.1
+func parentID(id string) string {
+	if i := strings.LastIndex(id, "."); i >= 0 {
+		return id[:i]
+	}
+
+	return ""
+}
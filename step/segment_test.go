@@ -0,0 +1,92 @@
+package step
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type segmentedOutput struct {
+	mu       sync.Mutex
+	all      bytes.Buffer
+	segments map[string]*bytes.Buffer
+	flushed  bool
+}
+
+func (o *segmentedOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.all.Write(p)
+}
+
+func (o *segmentedOutput) Segment(stage, job, step string) io.Writer {
+	return &segmentOutput{output: o, key: strings.Join([]string{stage, job, step}, "|")}
+}
+
+type segmentOutput struct {
+	output *segmentedOutput
+	key    string
+}
+
+func (s *segmentOutput) Write(p []byte) (int, error) {
+	s.output.mu.Lock()
+	defer s.output.mu.Unlock()
+
+	if s.output.segments[s.key] == nil {
+		s.output.segments[s.key] = &bytes.Buffer{}
+	}
+	return s.output.segments[s.key].Write(p)
+}
+
+func (s *segmentOutput) Flush() {
+	s.output.mu.Lock()
+	defer s.output.mu.Unlock()
+
+	s.output.flushed = true
+}
+
+func TestStepSegment(t *testing.T) {
+	t.Run("output of the step should be written to its segment", func(t *testing.T) {
+		output := &segmentedOutput{segments: map[string]*bytes.Buffer{}}
+		step := &Step{
+			Name:    "segment step",
+			Workdir: t.TempDir(),
+			Command: "echo hello; printf world",
+		}
+		step.SetStdout(output)
+		step.SetStderr(output)
+
+		if err := step.Setup("run.0.1.2"); err != nil {
+			t.Fatalf("Failed to setup step: %v", err)
+		}
+
+		if err := step.Run(context.Background()); err != nil {
+			t.Fatalf("Failed to run step: %v", err)
+		}
+
+		got := output.segments["run.0|run.0.1|run.0.1.2"]
+		if got == nil {
+			t.Fatalf("Expected the segment of the step, got %v", output.segments)
+		}
+		if !strings.Contains(got.String(), "hello\nworld") {
+			t.Errorf("Expected the output of the command in the segment, got %q", got.String())
+		}
+		if output.all.Len() != 0 {
+			t.Errorf("Expected no output outside the segment, got %q", output.all.String())
+		}
+		if !output.flushed {
+			t.Error("Expected the segment to be flushed")
+		}
+	})
+
+	t.Run("plain writer should be used as is", func(t *testing.T) {
+		var buf bytes.Buffer
+		if Segment(&buf, "run.0.1.2") != &buf {
+			t.Error("Expected the writer itself")
+		}
+	})
+}
@@ -1,5 +1,7 @@
 package step
 
+import "fmt"
+
 // Service is the service configuration of the step
 type Service struct {
 	// Version is the version of the service
@@ -15,3 +17,30 @@ type Service struct {
 	// Name is the name of the service
 	Name string `json:"name" yaml:"name"`
 }
+
+// Validate checks the service configuration
+func (s *Service) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("service name is required for docker-compose")
+	}
+
+	if s.Version == "" {
+		return fmt.Errorf("service version is required for docker-compose")
+	}
+
+	switch s.Version {
+	case "v1":
+	case "v2":
+		return fmt.Errorf("service version %s is working in progress currently", s.Version)
+	default:
+		return fmt.Errorf("unsupported service version %s, only support v1 | v2", s.Version)
+	}
+
+	switch s.Type {
+	case "docker-compose", "docker-swarm", "kubernetes":
+	default:
+		return fmt.Errorf("unsupported service type %s, only support docker-compose | docker-swarm | kubernetes", s.Type)
+	}
+
+	return nil
+}
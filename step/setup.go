@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/go-idp/pipeline/expression"
 	"github.com/go-zoox/core-utils/strings"
 	"github.com/go-zoox/fs"
 	"github.com/go-zoox/logger"
@@ -21,7 +22,7 @@ func (s *Step) Setup(id string, opts ...*Step) error {
 		}
 	}
 
-	s.logger = s.getLogger()
+	s.logger = s.getLogger(id)
 
 	// merge config
 	for _, opt := range opts {
@@ -70,6 +71,10 @@ func (s *Step) Setup(id string, opts ...*Step) error {
 		if s.DataDirOuter == "" {
 			s.DataDirOuter = opt.DataDirOuter
 		}
+
+		if s.Retry == nil {
+			s.Retry = opt.Retry
+		}
 	}
 
 	// environment
@@ -82,6 +87,35 @@ func (s *Step) Setup(id string, opts ...*Step) error {
 		s.Timeout = 86400
 	}
 
+	if s.If != "" {
+		// outputs are only known at run time, e.g. "'${{ steps.check.outputs.changed }}' == 'true'"
+		if _, err := expression.Parse(resolveOutputReferences(s.If, nil)); err != nil {
+			return fmt.Errorf("invalid if of step(%s): %s", s.Name, err)
+		}
+	}
+
+	references := []string{s.Command, s.If}
+	for _, v := range s.Environment {
+		references = append(references, v)
+	}
+	for _, text := range references {
+		if err := ValidateOutputReferences(text); err != nil {
+			return fmt.Errorf("invalid outputs of step(%s): %s", s.Name, err)
+		}
+	}
+
+	if s.Cache != nil {
+		if err := s.Cache.Validate(); err != nil {
+			return fmt.Errorf("invalid cache of step(%s): %s", s.Name, err)
+		}
+	}
+
+	if s.Retry != nil {
+		if err := s.Retry.Validate(); err != nil {
+			return fmt.Errorf("invalid retry of step(%s): %s", s.Name, err)
+		}
+	}
+
 	// if language is set, will use the language
 	if s.Language != nil {
 		if s.Plugin != nil {
@@ -155,12 +189,8 @@ func (s *Step) Setup(id string, opts ...*Step) error {
 	}
 
 	if s.Service != nil {
-		if s.Service.Name == "" {
-			return fmt.Errorf("service name is required for docker-compose")
-		}
-
-		if s.Service.Version == "" {
-			return fmt.Errorf("service version is required for docker-compose")
+		if err := s.Service.Validate(); err != nil {
+			return err
 		}
 
 		s.logger.Infof("[workflow][service] use service(type: %s, name: %s) in step(%s)", s.Service.Type, s.Service.Name, s.Name)
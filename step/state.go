@@ -1,19 +1,82 @@
 package step
 
-import "time"
+import (
+	"time"
+
+	"github.com/go-idp/pipeline/cache"
+)
 
 type State struct {
 	ID     string `yaml:"id"`
-	Status string `yaml:"status"` // pending | running | succeeded | failed
+	Status string `yaml:"status"` // pending | running | succeeded | failed | failed_allowed | skipped
 	//
 	StartedAt time.Time `yaml:"started_at"`
 	SucceedAt time.Time `yaml:"succeed_at"`
 	FailedAt  time.Time `yaml:"failed_at"`
 	//
 	Error string `yaml:"error"`
+	//
+	Attempts []*Attempt `yaml:"attempts"`
+	//
+	// Outputs are the key=value lines written to $PIPELINE_OUTPUT
+	Outputs map[string]string `yaml:"outputs"`
+	//
+	Cache *cache.Result `yaml:"cache"`
+	//
+	// ExitCode is the exit code of the command, of the last attempt with retry, -1 if unknown, e.g. the command failed to start
+	ExitCode int `yaml:"exit_code"`
+	// Signal is the signal terminating the command, e.g. SIGKILL
+	Signal string `yaml:"signal"`
+	// OOMKilled is true if the container of the step was killed by out of memory, docker engine only
+	OOMKilled bool `yaml:"oom_killed"`
+	// Duration is the duration of the step, unit: second
+	Duration float64 `yaml:"duration"`
+}
+
+// finish records the duration of the step after it ended
+func (st *State) finish() {
+	ended := st.SucceedAt
+	if ended.IsZero() {
+		ended = st.FailedAt
+	}
+
+	if !ended.IsZero() {
+		st.Duration = ended.Sub(st.StartedAt).Seconds()
+	}
+}
+
+// Exclude excludes the step from the run, it is skipped when the run reaches it, e.g. by --skip
+func (s *Step) Exclude() {
+	s.excluded = true
+}
+
+// Excluded returns true if the step is excluded from the run
+func (s *Step) Excluded() bool {
+	return s.excluded
+}
+
+// Restore restores the state of the step from a previous run of the pipeline, the step is not run again,
+//
+//	its outputs are still available to the later steps
+func (s *Step) Restore(state *State) {
+	s.State = state
+	s.restored = true
+
+	if s.outputs != nil && state.Outputs != nil {
+		s.outputs.Set(s.Name, state.Outputs)
+	}
+}
+
+// Restored returns true if the state of the step is restored from a previous run
+func (s *Step) Restored() bool {
+	return s.restored
+}
+
+// Skip marks the step as skipped
+func (s *Step) Skip() {
+	if s.State == nil {
+		return
+	}
 
-	// //
-	// ExitCode int `yaml:"exit_code"`
-	// //
-	// OOMKilled bool `yaml:"oom_killed"`
+	s.State.Status = "skipped"
 }
@@ -3,6 +3,8 @@ package step
 import (
 	"io"
 
+	"github.com/go-idp/pipeline/cache"
+	"github.com/go-idp/pipeline/event"
 	"github.com/go-zoox/logger"
 )
 
@@ -34,6 +36,16 @@ type Step struct {
 	Language *Language `json:"language" yaml:"language"`
 	//
 	Service *Service `json:"service" yaml:"service"`
+	// If is the condition to run the step, e.g. "env.BRANCH == 'main'", "failure()", "always()"
+	If string `json:"if" yaml:"if"`
+	// Extends is the name of the template in templates.steps of the pipeline to inherit from, the fields set here win
+	Extends string `json:"extends" yaml:"extends"`
+	//
+	Retry *Retry `json:"retry" yaml:"retry"`
+	// AllowFailure records the failure of the step as failed_allowed, without failing the job
+	AllowFailure bool `json:"allow_failure" yaml:"allow_failure"`
+	// Cache is the cache restored before the step and saved after it succeeds
+	Cache *cache.Cache `json:"cache" yaml:"cache"`
 	//
 	State *State `json:"state" yaml:"state"`
 	//
@@ -41,6 +53,16 @@ type Step struct {
 	stderr io.Writer
 	//
 	logger *logger.Logger
+	// excluded is set by the filter of the run, e.g. --only, --skip
+	excluded bool
+	// restored is set when the state is restored from a previous run, e.g. by resume
+	restored bool
+	//
+	outputs *Outputs
+	//
+	cacheStore cache.Store
+	//
+	events event.Sink
 }
 
 // Language represents a language of the step
@@ -52,9 +74,50 @@ type Language struct {
 	Version string `json:"version" yaml:"version"`
 }
 
-func (s *Step) getLogger() *logger.Logger {
+// Clone returns a copy of the step without state
+func (s *Step) Clone() *Step {
+	c := *s
+	c.State = nil
+
+	if s.Environment != nil {
+		c.Environment = make(map[string]string, len(s.Environment))
+		for k, v := range s.Environment {
+			c.Environment[k] = v
+		}
+	}
+
+	if s.Plugin != nil {
+		plugin := *s.Plugin
+		if s.Plugin.Settings != nil {
+			plugin.Settings = make(map[string]string, len(s.Plugin.Settings))
+			for k, v := range s.Plugin.Settings {
+				plugin.Settings[k] = v
+			}
+		}
+		c.Plugin = &plugin
+	}
+
+	if s.Language != nil {
+		language := *s.Language
+		c.Language = &language
+	}
+
+	if s.Service != nil {
+		service := *s.Service
+		c.Service = &service
+	}
+
+	if s.Cache != nil {
+		cache := *s.Cache
+		c.Cache = &cache
+	}
+
+	return &c
+}
+
+func (s *Step) getLogger(id string) *logger.Logger {
 	l := logger.New()
-	l.SetStdout(s.stdout)
+	l.SetStdout(Segment(s.stdout, id))
 	return l
 }
 
@@ -67,3 +130,18 @@ func (s *Step) SetStdout(stdout io.Writer) {
 func (s *Step) SetStderr(stderr io.Writer) {
 	s.stderr = stderr
 }
+
+// SetCacheStore sets the cache store of the step
+func (s *Step) SetCacheStore(store cache.Store) {
+	s.cacheStore = store
+}
+
+// SetEventSink sets the sink of the events of the step, including its output lines
+func (s *Step) SetEventSink(sink event.Sink) {
+	s.events = sink
+}
+
+// SetOutputs sets the outputs scope of the step, used to read the outputs of other steps and to publish its own
+func (s *Step) SetOutputs(outputs *Outputs) {
+	s.outputs = outputs
+}
@@ -1,8 +1,14 @@
 package action
 
+import "encoding/json"
+
 type Action struct {
 	Type    string `json:"type"`
 	Payload string `json:"payload"`
+	// ID is the id of the run of the server, of the messages of the run
+	ID string `json:"id,omitempty"`
+	// Params are the values of the parameters of the pipeline, only for the run action
+	Params map[string]string `json:"params,omitempty"`
 }
 
 type Model[T any] struct {
@@ -19,6 +25,22 @@ func (s *Model[T]) Encode(pl T) ([]byte, error) {
 	return s.encode(pl)
 }
 
+// EncodeWithID encodes the message of the run with its id
+func (s *Model[T]) EncodeWithID(id string, pl T) ([]byte, error) {
+	msg, err := s.encode(pl)
+	if err != nil {
+		return nil, err
+	}
+
+	var act Action
+	if err := json.Unmarshal(msg, &act); err != nil {
+		return nil, err
+	}
+	act.ID = id
+
+	return json.Marshal(act)
+}
+
 func (s *Model[T]) Decode(payload []byte) (T, error) {
 	return s.decode(payload)
 }
@@ -0,0 +1,44 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const typeAttach = "attach"
+
+// AttachTarget is the run to attach to, its logs are replayed from the cursor
+type AttachTarget struct {
+	ID     string `json:"id"`
+	Cursor int    `json:"cursor,omitempty"`
+}
+
+// Attach follows the logs of a run of the server until it finishes
+var Attach = Create(
+	typeAttach,
+	func(target *AttachTarget) ([]byte, error) {
+		payload, err := json.Marshal(target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode attach action: %s", err)
+		}
+
+		act := Action{
+			Type:    typeAttach,
+			Payload: string(payload),
+		}
+
+		return json.Marshal(act)
+	},
+	func(payload []byte) (*AttachTarget, error) {
+		var target AttachTarget
+		if err := json.Unmarshal(payload, &target); err != nil {
+			return nil, fmt.Errorf("failed to decode attach action: %s", err)
+		}
+
+		if target.ID == "" {
+			return nil, fmt.Errorf("failed to decode attach action: id is required")
+		}
+
+		return &target, nil
+	},
+)
@@ -0,0 +1,36 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-idp/pipeline/event"
+)
+
+const typeEvent = "event"
+
+// Event is an event of the pipeline, the payload is the JSON of the event
+var Event = Create(
+	typeEvent,
+	func(e *event.Event) ([]byte, error) {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode event: %s", err)
+		}
+
+		act := Action{
+			Type:    typeEvent,
+			Payload: string(payload),
+		}
+
+		return json.Marshal(act)
+	},
+	func(payload []byte) (*event.Event, error) {
+		var e event.Event
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, fmt.Errorf("failed to decode event: %s", err)
+		}
+
+		return &e, nil
+	},
+)
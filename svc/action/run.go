@@ -21,16 +21,21 @@ var Run = Create(
 		act := Action{
 			Type:    typeRun,
 			Payload: string(payload),
+			Params:  pl.Params(),
 		}
 
 		return json.Marshal(act)
 	},
 	func(payload []byte) (*pipeline.Pipeline, error) {
-		pl := pipeline.Pipeline{}
-		if err := yaml.Decode(payload, &pl); err != nil {
+		// the client merges the includes before sending, see pipeline.Load,
+		//	the server never fetches the includes of the payload
+		pl, err := pipeline.LoadYAML(payload, "", func(cfg *pipeline.LoadConfig) {
+			cfg.RestrictIncludes = true
+		})
+		if err != nil {
 			return nil, fmt.Errorf("failed to decode run action: %s", err)
 		}
 
-		return &pl, nil
+		return pl, nil
 	},
 )
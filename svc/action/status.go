@@ -0,0 +1,60 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	typeQueued  = "queued"
+	typeStarted = "started"
+	typeStatus  = "status"
+)
+
+// RunStatus is the status of a run of the server
+type RunStatus struct {
+	Status string `json:"status"` // pending | running | succeeded | failed | cancelled
+	Error  string `json:"error,omitempty"`
+	// ExitCode, Signal and OOMKilled are the exit of the failed step, the exit code is -1 if unknown
+	ExitCode  int    `json:"exit_code,omitempty"`
+	Signal    string `json:"signal,omitempty"`
+	OOMKilled bool   `json:"oom_killed,omitempty"`
+	// Warnings are the allowed failures of a succeeded run, e.g. check/lint
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Queued is sent when the run is added to the queue of the server
+var Queued = createRunStatus(typeQueued)
+
+// Started is sent when the run starts
+var Started = createRunStatus(typeStarted)
+
+// Status is the final status of the run, sent before done or error
+var Status = createRunStatus(typeStatus)
+
+func createRunStatus(typ string) *Model[*RunStatus] {
+	return Create(
+		typ,
+		func(status *RunStatus) ([]byte, error) {
+			payload, err := json.Marshal(status)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode %s: %s", typ, err)
+			}
+
+			act := Action{
+				Type:    typ,
+				Payload: string(payload),
+			}
+
+			return json.Marshal(act)
+		},
+		func(payload []byte) (*RunStatus, error) {
+			var status RunStatus
+			if err := json.Unmarshal(payload, &status); err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %s", typ, err)
+			}
+
+			return &status, nil
+		},
+	)
+}
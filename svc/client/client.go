@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/go-idp/pipeline"
+	"github.com/go-idp/pipeline/svc/action"
 	"github.com/go-zoox/websocket"
 )
 
@@ -25,6 +26,8 @@ type client struct {
 	core websocket.Client
 
 	done chan error
+	// status is the final status of the run, received before done or error
+	status *action.RunStatus
 
 	stdout io.Writer
 	stderr io.Writer
@@ -39,6 +42,11 @@ func (e *ExitError) Error() string {
 	return fmt.Sprintf("%s(exit code: %d)", e.Message, e.Code)
 }
 
+// ExitCode returns the exit code of the failed step of the run, the command exits with it
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
 func New(cfg *Config) Client {
 	return &client{
 		cfg: cfg,
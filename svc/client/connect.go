@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/go-idp/pipeline/svc/action"
@@ -79,7 +80,7 @@ func (c *client) Connect() error {
 				return nil
 			}
 
-			c.done <- err
+			c.done <- c.exitError(err)
 		case action.Done.Name():
 			done, err := action.Done.Decode([]byte(act.Payload))
 			if err != nil {
@@ -87,7 +88,34 @@ func (c *client) Connect() error {
 				return nil
 			}
 
-			c.done <- done
+			c.done <- c.exitError(done)
+		case action.Queued.Name():
+			if _, err := action.Queued.Decode([]byte(act.Payload)); err != nil {
+				c.done <- fmt.Errorf("failed to decode queued message: %s", err)
+				return nil
+			}
+
+			logger.Infof("pipeline queued (id: %s)", act.ID)
+		case action.Started.Name():
+			if _, err := action.Started.Decode([]byte(act.Payload)); err != nil {
+				c.done <- fmt.Errorf("failed to decode started message: %s", err)
+				return nil
+			}
+
+			logger.Infof("pipeline started (id: %s)", act.ID)
+		case action.Status.Name():
+			status, err := action.Status.Decode([]byte(act.Payload))
+			if err != nil {
+				c.done <- fmt.Errorf("failed to decode status message: %s", err)
+				return nil
+			}
+
+			c.status = status
+			if len(status.Warnings) > 0 {
+				logger.Warnf("pipeline %s with warnings, allowed failures: %s (id: %s)", status.Status, strings.Join(status.Warnings, ", "), act.ID)
+			} else {
+				logger.Infof("pipeline %s (id: %s)", status.Status, act.ID)
+			}
 		case action.Stdout.Name():
 			log, err := action.Stdout.Decode([]byte(act.Payload))
 			if err != nil {
@@ -104,6 +132,8 @@ func (c *client) Connect() error {
 			}
 
 			c.stderr.Write(log)
+		case action.Event.Name():
+			// the events are for the programmatic consumers, the logs are already written
 		default:
 			c.stderr.Write([]byte(fmt.Sprintf("unknown message type: %v\n", act.Type)))
 		}
@@ -122,3 +152,15 @@ func (c *client) Connect() error {
 
 	return nil
 }
+
+// exitError returns the error of the run with the exit code of its failed step, if known
+func (c *client) exitError(err error) error {
+	if err == nil || c.status == nil || c.status.ExitCode <= 0 {
+		return err
+	}
+
+	return &ExitError{
+		Code:    c.status.ExitCode,
+		Message: err.Error(),
+	}
+}
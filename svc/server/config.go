@@ -13,4 +13,13 @@ type Config struct {
 	Password string
 	//
 	MaxConcurrent int // 最大并发数，默认 2
+	//
+	SecretsFile string // 加密的 secrets 文件，默认 <workdir>/.pipeline_secrets
+	SecretsKey  string // secrets 文件的密钥，为空时使用环境变量 PIPELINE_SECRET_* 提供 secrets
+	//
+	Store string // 执行历史和队列的存储：memory（默认）| sqlite，sqlite:<path> 指定数据库文件，默认 <workdir>/.pipeline.db
+	//
+	LogMaxSize int // 每次运行的日志的最大大小，单位：MB，超过后丢弃之后的日志，0 表示不限制
+	//
+	AllowedIncludes []string // 客户端提交的配置中允许的 include 的 url 前缀，默认不允许 include
 }
@@ -3,9 +3,14 @@ package server
 import (
 	"encoding/json"
 	"fmt"
+	stdio "io"
 	"os"
 
 	"github.com/go-idp/pipeline"
+	"github.com/go-idp/pipeline/artifact"
+	"github.com/go-idp/pipeline/cache"
+	"github.com/go-idp/pipeline/event"
+	"github.com/go-idp/pipeline/secret"
 	"github.com/go-idp/pipeline/svc/action"
 	"github.com/go-zoox/core-utils/io"
 	"github.com/go-zoox/debug"
@@ -24,10 +29,28 @@ type MountConfig struct {
 	Store Store
 	//
 	Queue Queue
+	//
+	Artifacts artifact.Store
+	//
+	Caches cache.Store
+	//
+	Secrets secret.Store
+	//
+	AllowedIncludes []string
 }
 
 type MountOption func(cfg *MountConfig)
 
+// allowIncludes 限制客户端提交的配置的 include，只允许运维配置的 url 前缀，
+//
+//	防止客户端让服务端请求任意的 url
+func allowIncludes(allowed []string) pipeline.LoadOption {
+	return func(cfg *pipeline.LoadConfig) {
+		cfg.RestrictIncludes = true
+		cfg.AllowedIncludes = allowed
+	}
+}
+
 func Mount(app *zoox.Application, opts ...MountOption) error {
 	cfg := MountConfig{
 		Path: "/",
@@ -52,10 +75,11 @@ func Mount(app *zoox.Application, opts ...MountOption) error {
 	})
 
 	server.OnTextMessage(func(conn conn.Conn, msg []byte) error {
+		// 消息均带有运行 ID，即连接 ID
 		sendError := func(err error) {
 			logger.Errorf("error: %s", err)
 
-			msg, errx := action.Error.Encode(err)
+			msg, errx := action.Error.EncodeWithID(conn.ID(), err)
 			if errx != nil {
 				panic(fmt.Errorf("failed to encode error: %s", errx))
 			}
@@ -64,7 +88,7 @@ func Mount(app *zoox.Application, opts ...MountOption) error {
 		}
 
 		sendDone := func() {
-			msg, err := action.Done.Encode(nil)
+			msg, err := action.Done.EncodeWithID(conn.ID(), nil)
 			if err != nil {
 				panic(fmt.Errorf("failed to encode error: %s", err))
 			}
@@ -72,7 +96,29 @@ func Mount(app *zoox.Application, opts ...MountOption) error {
 			conn.WriteTextMessage(msg)
 		}
 
-		stdout := io.WriterWrapFunc(func(b []byte) (n int, err error) {
+		sendStatus := func(model *action.Model[*action.RunStatus], status *action.RunStatus) {
+			msg, err := model.EncodeWithID(conn.ID(), status)
+			if err != nil {
+				panic(fmt.Errorf("failed to encode %s: %s", model.Name(), err))
+			}
+
+			conn.WriteTextMessage(msg)
+		}
+
+		// sendResult 发送最终状态，之后是 done 或 error
+		sendResult := func(pl *pipeline.Pipeline, status string, err error) {
+			sendStatus(action.Status, runStatus(pl, status, err))
+
+			if err != nil {
+				sendError(fmt.Errorf("failed to run pipeline: %s", err))
+				return
+			}
+
+			sendDone()
+		}
+
+		// 只发送给客户端，由队列记录日志和事件到存储
+		wsStdout := io.WriterWrapFunc(func(b []byte) (n int, err error) {
 			if debug.IsDebugMode() {
 				os.Stdout.Write(b)
 			}
@@ -83,16 +129,10 @@ func Mount(app *zoox.Application, opts ...MountOption) error {
 			}
 
 			conn.WriteTextMessage(msg)
-
-			// 记录日志到存储
-			if cfg.Store != nil {
-				cfg.Store.AddLog(conn.ID(), "stdout", string(b))
-			}
-
 			return len(b), nil
 		})
 
-		stderr := io.WriterWrapFunc(func(b []byte) (n int, err error) {
+		wsStderr := io.WriterWrapFunc(func(b []byte) (n int, err error) {
 			if debug.IsDebugMode() {
 				os.Stderr.Write(b)
 			}
@@ -103,13 +143,33 @@ func Mount(app *zoox.Application, opts ...MountOption) error {
 			}
 
 			conn.WriteTextMessage(msg)
+			return len(b), nil
+		})
+
+		wsEvents := event.SinkFunc(func(e *event.Event) {
+			msg, err := action.Event.Encode(e)
+			if err != nil {
+				panic(fmt.Errorf("failed to encode event: %s", err))
+			}
+
+			conn.WriteTextMessage(msg)
+		})
+
+		// 按行记录日志到存储
+		stdout := newLogWriter(cfg.Store, conn.ID(), "stdout", func() stdio.Writer {
+			return wsStdout
+		})
+		stderr := newLogWriter(cfg.Store, conn.ID(), "stderr", func() stdio.Writer {
+			return wsStderr
+		})
+
+		events := event.SinkFunc(func(e *event.Event) {
+			wsEvents.Emit(e)
 
-			// 记录日志到存储
+			// 记录事件到存储
 			if cfg.Store != nil {
-				cfg.Store.AddLog(conn.ID(), "stderr", string(b))
+				cfg.Store.AddEvent(conn.ID(), e)
 			}
-
-			return len(b), nil
 		})
 
 		var act action.Action
@@ -120,18 +180,18 @@ func Mount(app *zoox.Application, opts ...MountOption) error {
 
 		switch act.Type {
 		case action.Run.Name():
-			pl, err := action.Run.Decode([]byte(act.Payload))
+			// 客户端在发送前已合并 include
+			pl, err := pipeline.LoadYAML([]byte(act.Payload), "", allowIncludes(cfg.AllowedIncludes))
 			if err != nil {
-				sendError(err)
+				sendError(fmt.Errorf("failed to decode run action: %s", err))
 				return nil
 			}
 
-			// 保存原始 YAML
-			yamlPayload := act.Payload
+			// 参数值，运行时校验
+			pl.SetParams(act.Params)
 
-			// 设置输出
-			pl.SetStdout(stdout)
-			pl.SetStderr(stderr)
+			// 保存原始 YAML，隐藏其中明文的密码
+			yamlPayload := pl.Redact(act.Payload)
 
 			// 添加到队列
 			if cfg.Queue != nil {
@@ -141,9 +201,29 @@ func Mount(app *zoox.Application, opts ...MountOption) error {
 					return nil
 				}
 
-				// 发送确认消息
-				sendDone()
+				sendStatus(action.Queued, &action.RunStatus{Status: "pending"})
+
+				// 关注执行，客户端等待最终状态
+				cfg.Queue.Watch(conn.ID(), &Watcher{
+					Stdout: wsStdout,
+					Stderr: wsStderr,
+					Events: wsEvents,
+					OnStatus: func(item QueueItem) {
+						switch item.Status {
+						case "running":
+							sendStatus(action.Started, &action.RunStatus{Status: "running"})
+						case "succeeded":
+							sendResult(item.Pipeline, item.Status, nil)
+						case "failed", "cancelled":
+							sendResult(item.Pipeline, item.Status, fmt.Errorf("%s", item.Error))
+						}
+					},
+				})
 			} else {
+				// 设置输出
+				pl.SetStdout(stdout)
+				pl.SetStderr(stderr)
+
 				// 如果没有队列，直接执行（向后兼容）
 				go func() {
 					// 创建 pipeline 记录
@@ -160,12 +240,27 @@ func Mount(app *zoox.Application, opts ...MountOption) error {
 
 					pl.SetWorkdir(fmt.Sprintf("%s/%s", cfg.Workdir, conn.ID()))
 					pl.SetEnvironment(cfg.Environment)
+					if cfg.Artifacts != nil {
+						pl.SetArtifactStore(cfg.Artifacts)
+					}
+					if cfg.Caches != nil {
+						pl.SetCacheStore(cfg.Caches)
+					}
+					if cfg.Secrets != nil {
+						pl.SetSecretStore(cfg.Secrets)
+					}
+					pl.SetEventSink(events)
+
+					sendStatus(action.Started, &action.RunStatus{Status: "running"})
 
 					err := pl.Run(conn.Context(), func(cfg *pipeline.RunConfig) {
 						cfg.ID = conn.ID()
 					})
+					stdout.Flush()
+					stderr.Flush()
 
 					if cfg.Store != nil {
+						cfg.Store.SetResult(conn.ID(), NewRunResult(pl))
 						if err != nil {
 							cfg.Store.UpdateStatus(conn.ID(), "failed", err)
 						} else {
@@ -174,13 +269,60 @@ func Mount(app *zoox.Application, opts ...MountOption) error {
 					}
 
 					if err != nil {
-						sendError(fmt.Errorf("failed to run pipeline: %s", err))
+						sendResult(pl, "failed", err)
 						return
 					}
 
-					sendDone()
+					sendResult(pl, "succeeded", nil)
 				}()
 			}
+		case action.Attach.Name():
+			target, err := action.Attach.Decode([]byte(act.Payload))
+			if err != nil {
+				sendError(err)
+				return nil
+			}
+
+			streams, ok := cfg.Store.(StreamStore)
+			if !ok {
+				sendError(fmt.Errorf("failed to attach pipeline %s: the store does not support streaming logs", target.ID))
+				return nil
+			}
+
+			sub, err := streams.Subscribe(target.ID, target.Cursor)
+			if err != nil {
+				sendError(fmt.Errorf("failed to attach pipeline %s: %s", target.ID, err))
+				return nil
+			}
+
+			// 消息均带有关注的运行 ID，先回放 cursor 之后的日志，再推送新的日志，结束时发送最终状态
+			go func() {
+				defer sub.Close()
+
+				for i := range sub.Logs {
+					sendAttachedLog(conn, target.ID, &sub.Logs[i])
+				}
+
+				for {
+					select {
+					case <-conn.Context().Done():
+						return
+					case msg, ok := <-sub.Messages:
+						if !ok {
+							sendAttachError(conn, target.ID, fmt.Errorf("the client is too slow to follow the logs, attach again from the last received log"))
+							return
+						}
+
+						if msg.Status == nil {
+							sendAttachedLog(conn, target.ID, msg.Entry)
+							continue
+						}
+
+						sendAttachedStatus(conn, target.ID, msg.Status)
+						return
+					}
+				}
+			}()
 		default:
 			sendError(fmt.Errorf("unsupported action type: %s", act.Type))
 			return nil
@@ -191,3 +333,68 @@ func Mount(app *zoox.Application, opts ...MountOption) error {
 
 	return nil
 }
+
+// sendAttachedLog 发送关注的运行的日志
+func sendAttachedLog(conn conn.Conn, id string, entry *LogEntry) {
+	model := action.Stdout
+	if entry.Type == "stderr" {
+		model = action.Stderr
+	}
+
+	msg, err := model.EncodeWithID(id, []byte(entry.Message))
+	if err != nil {
+		panic(fmt.Errorf("failed to encode %s: %s", model.Name(), err))
+	}
+
+	conn.WriteTextMessage(msg)
+}
+
+// sendAttachedStatus 发送关注的运行的最终状态，之后是 done 或 error
+func sendAttachedStatus(conn conn.Conn, id string, status *action.RunStatus) {
+	msg, err := action.Status.EncodeWithID(id, status)
+	if err != nil {
+		panic(fmt.Errorf("failed to encode %s: %s", action.Status.Name(), err))
+	}
+	conn.WriteTextMessage(msg)
+
+	if status.Status != "succeeded" {
+		sendAttachError(conn, id, fmt.Errorf("pipeline %s: %s", status.Status, status.Error))
+		return
+	}
+
+	if msg, err = action.Done.EncodeWithID(id, nil); err != nil {
+		panic(fmt.Errorf("failed to encode done: %s", err))
+	}
+	conn.WriteTextMessage(msg)
+}
+
+func sendAttachError(conn conn.Conn, id string, err error) {
+	msg, errx := action.Error.EncodeWithID(id, err)
+	if errx != nil {
+		panic(fmt.Errorf("failed to encode error: %s", errx))
+	}
+
+	conn.WriteTextMessage(msg)
+}
+
+// runStatus 返回运行的最终状态，失败时带有失败 step 的退出码
+func runStatus(pl *pipeline.Pipeline, status string, err error) *action.RunStatus {
+	s := &action.RunStatus{
+		Status: status,
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+
+	if status == "failed" && pl != nil && pl.State != nil {
+		s.ExitCode = pl.State.ExitCode
+		s.Signal = pl.State.Signal
+		s.OOMKilled = pl.State.OOMKilled
+	}
+
+	if status == "succeeded" && pl != nil && pl.State != nil && pl.State.HasWarnings() {
+		s.Warnings = pl.State.Warnings
+	}
+
+	return s
+}
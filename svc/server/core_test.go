@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-idp/pipeline/svc/action"
+	"github.com/go-zoox/websocket"
+	"github.com/go-zoox/websocket/conn"
+	"github.com/go-zoox/zoox"
+)
+
+// dialTestServer connects a websocket client to a server with a queue, returns the client and the actions it receives
+func dialTestServer(t *testing.T, opts ...MountOption) (websocket.Client, chan *action.Action) {
+	t.Helper()
+
+	workdir := t.TempDir()
+	store := NewStreamStore(NewMemoryStore(workdir, 10), 0)
+	queue := NewQueue(1, store, nil, nil, nil, workdir, nil)
+
+	app := zoox.New()
+	if err := Mount(app, append([]MountOption{func(cfg *MountConfig) {
+		cfg.Workdir = workdir
+		cfg.Store = store
+		cfg.Queue = queue
+	}}, opts...)...); err != nil {
+		t.Fatalf("Mount() error: %v", err)
+	}
+
+	server := httptest.NewServer(app)
+	t.Cleanup(server.Close)
+
+	client, err := websocket.NewClient(func(opt *websocket.ClientOption) {
+		opt.Context = context.Background()
+		opt.Addr = "ws" + strings.TrimPrefix(server.URL, "http") + "/"
+		opt.ConnectTimeout = 10 * time.Second
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error: %v", err)
+	}
+
+	messages := make(chan *action.Action, 1024)
+	client.OnTextMessage(func(conn websocket.Conn, msg []byte) error {
+		var act action.Action
+		if err := json.Unmarshal(msg, &act); err != nil {
+			t.Errorf("Failed to decode message: %v", err)
+			return nil
+		}
+
+		messages <- &act
+		return nil
+	})
+
+	connected := make(chan struct{})
+	client.OnConnect(func(conn conn.Conn) error {
+		close(connected)
+		return nil
+	})
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	<-connected
+
+	return client, messages
+}
+
+// runOverWebSocket runs the pipeline on a server with a queue, returns the types of the status messages,
+//
+//	i.e. queued, started, status, done and error, and the final status
+func runOverWebSocket(t *testing.T, command string) ([]string, *action.RunStatus) {
+	t.Helper()
+
+	client, messages := dialTestServer(t)
+
+	msg, err := action.Run.Encode(newTestPipeline(t, command))
+	if err != nil {
+		t.Fatalf("Failed to encode run: %v", err)
+	}
+	if err := client.SendTextMessage(msg); err != nil {
+		t.Fatalf("Failed to send run: %v", err)
+	}
+
+	types := []string{}
+	var final *action.RunStatus
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case act := <-messages:
+			switch act.Type {
+			case action.Queued.Name(), action.Started.Name():
+				types = append(types, act.Type)
+			case action.Status.Name():
+				types = append(types, act.Type)
+				if final, err = action.Status.Decode([]byte(act.Payload)); err != nil {
+					t.Fatalf("Failed to decode status: %v", err)
+				}
+			case action.Done.Name(), action.Error.Name():
+				return append(types, act.Type), final
+			}
+		case <-timeout:
+			t.Fatalf("Timeout waiting for the run, got %v", types)
+		}
+	}
+}
+
+func TestWebSocketRunStatus(t *testing.T) {
+	t.Run("succeeded run", func(t *testing.T) {
+		types, final := runOverWebSocket(t, "echo hello")
+
+		if want := []string{"queued", "started", "status", "done"}; !reflect.DeepEqual(types, want) {
+			t.Errorf("Expected messages %v, got %v", want, types)
+		}
+
+		if final == nil || final.Status != "succeeded" {
+			t.Errorf("Expected final status succeeded, got %+v", final)
+		}
+	})
+
+	t.Run("failed run", func(t *testing.T) {
+		types, final := runOverWebSocket(t, "exit 3")
+
+		if want := []string{"queued", "started", "status", "error"}; !reflect.DeepEqual(types, want) {
+			t.Errorf("Expected messages %v, got %v", want, types)
+		}
+
+		if final == nil || final.Status != "failed" || final.ExitCode != 3 {
+			t.Errorf("Expected final status failed with exit code 3, got %+v", final)
+		}
+	})
+}
+
+func TestWebSocketRunIncludes(t *testing.T) {
+	var requests atomic.Int32
+	includes := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte("stages:\n  - name: included\n    jobs:\n      - name: included\n        steps:\n          - name: echo\n            command: echo included\n"))
+	}))
+	defer includes.Close()
+
+	config := "name: test\ninclude: [" + includes.URL + "/templates/stages.yaml]\n"
+
+	tests := []struct {
+		name     string
+		allowed  []string
+		expected string
+		requests int32
+	}{
+		{"include without allowed includes", nil, "error", 0},
+		{"include not allowed", []string{includes.URL + "/other/"}, "error", 0},
+		{"allowed include", []string{includes.URL + "/templates/"}, "queued", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			client, messages := dialTestServer(t, func(cfg *MountConfig) {
+				cfg.AllowedIncludes = tt.allowed
+			})
+
+			msg, _ := json.Marshal(action.Action{Type: action.Run.Name(), Payload: config})
+			if err := client.SendTextMessage(msg); err != nil {
+				t.Fatalf("Failed to send run: %v", err)
+			}
+
+			select {
+			case act := <-messages:
+				if act.Type != tt.expected {
+					t.Errorf("Expected %s, got %s: %s", tt.expected, act.Type, act.Payload)
+				}
+				if tt.expected == "error" && !strings.Contains(act.Payload, "is not allowed") {
+					t.Errorf("Expected the include not to be allowed, got %s", act.Payload)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("Timeout waiting for the run")
+			}
+
+			// the allowed run finishes before its workdir is removed
+			for tt.expected == "queued" {
+				select {
+				case act := <-messages:
+					if act.Type == action.Done.Name() || act.Type == action.Error.Name() {
+						tt.expected = act.Type
+					}
+				case <-time.After(10 * time.Second):
+					t.Fatal("Timeout waiting for the run")
+				}
+			}
+
+			if got := requests.Load(); got != tt.requests {
+				t.Errorf("Expected %d requests to the include, got %d", tt.requests, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,525 @@
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// logChunkSize 日志分块的大小，写满后写入新的分块，按位置读取时跳过之前的分块
+const logChunkSize = 4 * 1024 * 1024
+
+// logChunk 日志分块，Line 和 Offset 为第一条日志的行号和字节偏移，Size 为未压缩的大小
+type logChunk struct {
+	Line       int   `json:"line"`
+	Offset     int64 `json:"offset"`
+	Lines      int   `json:"lines"`
+	Size       int64 `json:"size"`
+	Compressed bool  `json:"compressed"`
+}
+
+// logIndex 日志分块的索引
+type logIndex struct {
+	Chunks []*logChunk `json:"chunks"`
+}
+
+func (i *logIndex) last() *logChunk {
+	if len(i.Chunks) == 0 {
+		return nil
+	}
+
+	return i.Chunks[len(i.Chunks)-1]
+}
+
+// lines 日志的总行数
+func (i *logIndex) lines() int {
+	if last := i.last(); last != nil {
+		return last.Line + last.Lines
+	}
+
+	return 0
+}
+
+// size 日志未压缩的总大小
+func (i *logIndex) size() int64 {
+	if last := i.last(); last != nil {
+		return last.Offset + last.Size
+	}
+
+	return 0
+}
+
+func (i *logIndex) clone() *logIndex {
+	c := &logIndex{
+		Chunks: make([]*logChunk, len(i.Chunks)),
+	}
+	for k, chunk := range i.Chunks {
+		copied := *chunk
+		c.Chunks[k] = &copied
+	}
+
+	return c
+}
+
+// logRun 正在写入的日志
+type logRun struct {
+	mu     sync.Mutex
+	index  *logIndex
+	file   *os.File
+	closed bool
+}
+
+// logFiles 按 pipeline 保存的日志文件，<dir>/<id>/ 下为 JSON Lines 格式的分块和索引，
+//
+//	只追加写入，Close 后分块以 gzip 压缩，之后再写入时（例如重试）写入新的分块
+type logFiles struct {
+	dir string
+	// chunkSize 分块的大小，默认为 logChunkSize
+	chunkSize int64
+	//
+	mu   sync.Mutex
+	runs map[string]*logRun
+}
+
+func newLogFiles(dir string) *logFiles {
+	return &logFiles{
+		dir:       dir,
+		chunkSize: logChunkSize,
+		runs:      make(map[string]*logRun),
+	}
+}
+
+// Exists 是否有 pipeline 的日志文件
+func (l *logFiles) Exists(id string) bool {
+	l.mu.Lock()
+	_, ok := l.runs[id]
+	l.mu.Unlock()
+	if ok {
+		return true
+	}
+
+	_, err := os.Stat(l.path(id, "index.json"))
+	return err == nil
+}
+
+// Append 追加日志
+func (l *logFiles) Append(id string, entry *LogEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	for {
+		run, err := l.open(id)
+		if err != nil {
+			return err
+		}
+
+		run.mu.Lock()
+		// 已结束写入，重新打开
+		if run.closed {
+			run.mu.Unlock()
+			continue
+		}
+
+		err = l.write(id, run, data)
+		run.mu.Unlock()
+		return err
+	}
+}
+
+// Close 结束写入，压缩日志的分块
+func (l *logFiles) Close(id string) error {
+	l.mu.Lock()
+	run, ok := l.runs[id]
+	delete(l.runs, id)
+	l.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	run.closed = true
+	if run.file != nil {
+		run.file.Close()
+		run.file = nil
+	}
+
+	for i, chunk := range run.index.Chunks {
+		if chunk.Compressed {
+			continue
+		}
+
+		if err := l.compress(id, i); err != nil {
+			return fmt.Errorf("failed to compress logs of pipeline %s: %s", id, err)
+		}
+		chunk.Compressed = true
+	}
+
+	return l.saveIndex(id, run.index)
+}
+
+// Delete 删除 pipeline 的日志文件
+func (l *logFiles) Delete(id string) error {
+	l.mu.Lock()
+	run, ok := l.runs[id]
+	delete(l.runs, id)
+	l.mu.Unlock()
+
+	if ok {
+		run.mu.Lock()
+		run.closed = true
+		if run.file != nil {
+			run.file.Close()
+			run.file = nil
+		}
+		run.mu.Unlock()
+	}
+
+	return os.RemoveAll(l.path(id))
+}
+
+// Read 按顺序读取日志，从第 line 行开始，cursor 大于 0 时从该字节偏移开始，
+//
+//	依次调用 fn，next 为下一条日志的字节偏移，fn 返回 false 时停止，返回日志的总行数
+func (l *logFiles) Read(id string, line int, cursor int64, fn func(index int, next int64, entry *LogEntry) bool) (int, error) {
+	index, err := l.snapshot(id)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, chunk := range index.Chunks {
+		// 跳过之前的分块
+		if cursor > 0 {
+			if chunk.Offset+chunk.Size <= cursor {
+				continue
+			}
+		} else if chunk.Line+chunk.Lines <= line {
+			continue
+		}
+
+		done, err := l.readChunk(id, i, chunk, line, cursor, fn)
+		if err != nil {
+			return 0, err
+		}
+		if done {
+			break
+		}
+	}
+
+	return index.lines(), nil
+}
+
+// readChunk 读取分块中索引记录的日志，fn 返回 false 时 done 为 true
+func (l *logFiles) readChunk(id string, i int, chunk *logChunk, line int, cursor int64, fn func(index int, next int64, entry *LogEntry) bool) (done bool, err error) {
+	r, err := l.openChunk(id, i, chunk.Compressed)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+
+	reader := bufio.NewReader(r)
+	n, offset := chunk.Line, chunk.Offset
+	for n < chunk.Line+chunk.Lines {
+		data, err := reader.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF {
+				return false, nil
+			}
+			return false, err
+		}
+
+		index, start := n, offset
+		n++
+		offset += int64(len(data))
+
+		if cursor > 0 {
+			if start < cursor {
+				continue
+			}
+		} else if index < line {
+			continue
+		}
+
+		var entry LogEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			continue
+		}
+
+		if !fn(index, offset, &entry) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// snapshot 返回日志的索引，正在写入时为当前写入的位置
+func (l *logFiles) snapshot(id string) (*logIndex, error) {
+	l.mu.Lock()
+	run, ok := l.runs[id]
+	l.mu.Unlock()
+
+	if ok {
+		run.mu.Lock()
+		defer run.mu.Unlock()
+
+		if !run.closed {
+			return run.index.clone(), nil
+		}
+	}
+
+	return l.loadIndex(id)
+}
+
+func (l *logFiles) open(id string) (*logRun, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if run, ok := l.runs[id]; ok {
+		return run, nil
+	}
+
+	index, err := l.loadIndex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// 服务进程中断时最后一条日志可能只写入了一部分，截断后再追加
+	if last := index.last(); last != nil && !last.Compressed {
+		if err := os.Truncate(l.path(id, chunkName(len(index.Chunks)-1, false)), last.Size); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	run := &logRun{
+		index: index,
+	}
+	l.runs[id] = run
+
+	return run, nil
+}
+
+// write 写入日志，当前分块已压缩或写满时写入新的分块，需持有 run 的锁
+func (l *logFiles) write(id string, run *logRun, data []byte) error {
+	chunk := run.index.last()
+	if chunk == nil || chunk.Compressed || (chunk.Lines > 0 && chunk.Size+int64(len(data)) > l.chunkSize) {
+		if run.file != nil {
+			run.file.Close()
+			run.file = nil
+		}
+
+		next := &logChunk{
+			Line:   run.index.lines(),
+			Offset: run.index.size(),
+		}
+		run.index.Chunks = append(run.index.Chunks, next)
+		if err := l.saveIndex(id, run.index); err != nil {
+			return err
+		}
+
+		chunk = next
+	}
+
+	if run.file == nil {
+		f, err := os.OpenFile(l.path(id, chunkName(len(run.index.Chunks)-1, false)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		run.file = f
+	}
+
+	if _, err := run.file.Write(data); err != nil {
+		return err
+	}
+
+	chunk.Lines++
+	chunk.Size += int64(len(data))
+	return nil
+}
+
+// loadIndex 读取日志的索引，不存在时为空，
+//
+//	未压缩的分块可能未正常结束写入，例如服务进程中断，按文件重新统计
+func (l *logFiles) loadIndex(id string) (*logIndex, error) {
+	index := &logIndex{}
+
+	data, err := os.ReadFile(l.path(id, "index.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return index, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("failed to decode log index of pipeline %s: %s", id, err)
+	}
+
+	line, offset := 0, int64(0)
+	for i, chunk := range index.Chunks {
+		chunk.Line = line
+		chunk.Offset = offset
+
+		if !chunk.Compressed {
+			if _, err := os.Stat(l.path(id, chunkName(i, false))); os.IsNotExist(err) {
+				chunk.Compressed = true
+			}
+
+			if chunk.Lines, chunk.Size, err = l.countChunk(id, i, chunk.Compressed); err != nil {
+				return nil, err
+			}
+		}
+
+		line += chunk.Lines
+		offset += chunk.Size
+	}
+
+	return index, nil
+}
+
+// countChunk 统计分块中完整的日志的行数和大小
+func (l *logFiles) countChunk(id string, i int, compressed bool) (lines int, size int64, err error) {
+	r, err := l.openChunk(id, i, compressed)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	defer r.Close()
+
+	reader := bufio.NewReader(r)
+	for {
+		data, err := reader.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF {
+				return lines, size, nil
+			}
+			return 0, 0, err
+		}
+
+		lines++
+		size += int64(len(data))
+	}
+}
+
+func (l *logFiles) saveIndex(id string, index *logIndex) error {
+	if err := os.MkdirAll(l.path(id), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	tmp := l.path(id, "index.json.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, l.path(id, "index.json"))
+}
+
+// compress 以 gzip 压缩分块，压缩完成后删除原文件，读取中的原文件不受影响
+func (l *logFiles) compress(id string, i int) error {
+	src := l.path(id, chunkName(i, false))
+	dst := l.path(id, chunkName(i, true))
+
+	in, err := os.Open(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// openChunk 打开分块，未压缩的分块在读取前可能已被压缩
+func (l *logFiles) openChunk(id string, i int, compressed bool) (io.ReadCloser, error) {
+	if !compressed {
+		f, err := os.Open(l.path(id, chunkName(i, false)))
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	f, err := os.Open(l.path(id, chunkName(i, true)))
+	if err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &gzipFile{Reader: gz, file: f}, nil
+}
+
+func (l *logFiles) path(id string, elem ...string) string {
+	return filepath.Join(append([]string{l.dir, id}, elem...)...)
+}
+
+func chunkName(i int, compressed bool) string {
+	name := fmt.Sprintf("%06d.log", i)
+	if compressed {
+		name += ".gz"
+	}
+
+	return name
+}
+
+// gzipFile 压缩的分块，关闭时同时关闭文件
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
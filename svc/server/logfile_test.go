@@ -0,0 +1,307 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestLogFiles returns log files with small chunks, so that a few lines span several chunks
+func newTestLogFiles(dir string) *logFiles {
+	l := newLogFiles(dir)
+	l.chunkSize = 256
+	return l
+}
+
+func appendLines(t *testing.T, l *logFiles, id string, from, to int) {
+	t.Helper()
+
+	for i := from; i < to; i++ {
+		if err := l.Append(id, &LogEntry{Type: "stdout", Message: fmt.Sprintf("line %d\n", i)}); err != nil {
+			t.Fatalf("Append() error: %v", err)
+		}
+	}
+}
+
+// readLines reads at most limit lines from line or cursor, returns the line numbers, the next cursor of each line and the total
+func readLines(t *testing.T, l *logFiles, id string, line int, cursor int64, limit int) ([]int, []int64, int) {
+	t.Helper()
+
+	indexes, nexts := []int{}, []int64{}
+	total, err := l.Read(id, line, cursor, func(index int, next int64, entry *LogEntry) bool {
+		if entry.Message != fmt.Sprintf("line %d\n", index) {
+			t.Fatalf("Expected line %d, got %q", index, entry.Message)
+		}
+
+		indexes = append(indexes, index)
+		nexts = append(nexts, next)
+		return len(indexes) < limit
+	})
+	if err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+
+	return indexes, nexts, total
+}
+
+func chunkFiles(t *testing.T, dir string) (plain, compressed int) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", dir, err)
+	}
+
+	for _, e := range entries {
+		switch filepath.Ext(e.Name()) {
+		case ".log":
+			plain++
+		case ".gz":
+			compressed++
+		}
+	}
+
+	return plain, compressed
+}
+
+func TestLogFilesRead(t *testing.T) {
+	const total = 100
+
+	for _, closed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("closed=%v", closed), func(t *testing.T) {
+			dir := t.TempDir()
+			l := newTestLogFiles(dir)
+			appendLines(t, l, "run", 0, total)
+
+			if closed {
+				if err := l.Close("run"); err != nil {
+					t.Fatalf("Close() error: %v", err)
+				}
+			}
+
+			// the cursor of each line, i.e. the next of the line before it
+			_, nexts, _ := readLines(t, l, "run", 0, 0, total)
+			cursors := append([]int64{0}, nexts[:total-1]...)
+
+			tests := []struct {
+				name   string
+				line   int
+				cursor int64
+				limit  int
+				first  int
+			}{
+				{"all lines", 0, 0, total + 1, 0},
+				{"by line in the first chunk", 3, 0, 5, 3},
+				{"by line in a later chunk", 57, 0, 5, 57},
+				{"by line across chunks", 60, 0, 30, 60},
+				{"by cursor in the first chunk", 0, cursors[3], 5, 3},
+				{"by cursor in a later chunk", 0, cursors[57], 5, 57},
+				{"by cursor across chunks", 0, cursors[60], 30, 60},
+				{"last line", total - 1, 0, 5, total - 1},
+				{"past the end", total, 0, 5, total},
+				{"cursor at the end", 0, nexts[total-1], 5, total},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					indexes, _, n := readLines(t, l, "run", tt.line, tt.cursor, tt.limit)
+					if n != total {
+						t.Errorf("Expected total %d, got %d", total, n)
+					}
+
+					want := tt.limit
+					if want > total-tt.first {
+						want = total - tt.first
+					}
+					if len(indexes) != want {
+						t.Fatalf("Expected %d lines, got %d", want, len(indexes))
+					}
+					for i, index := range indexes {
+						if index != tt.first+i {
+							t.Fatalf("Expected line %d, got %d", tt.first+i, index)
+						}
+					}
+				})
+			}
+
+			plain, compressed := chunkFiles(t, filepath.Join(dir, "run"))
+			if plain+compressed < 3 {
+				t.Errorf("Expected several chunks, got %d", plain+compressed)
+			}
+			if closed && plain != 0 {
+				t.Errorf("Expected all chunks to be compressed, got %d plain chunks", plain)
+			}
+			if !closed && compressed != 0 {
+				t.Errorf("Expected no compressed chunks while writing, got %d", compressed)
+			}
+		})
+	}
+}
+
+func TestLogFilesLifecycle(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, dir string) *logFiles
+		// lines is the number of lines read back, plain and compressed the chunk files
+		lines      int
+		plain      int
+		compressed int
+	}{
+		{
+			name: "append after close writes a new chunk",
+			run: func(t *testing.T, dir string) *logFiles {
+				l := newTestLogFiles(dir)
+				l.chunkSize = logChunkSize
+				appendLines(t, l, "run", 0, 10)
+				if err := l.Close("run"); err != nil {
+					t.Fatalf("Close() error: %v", err)
+				}
+
+				// e.g. a retry of the run
+				appendLines(t, l, "run", 10, 15)
+				return l
+			},
+			lines:      15,
+			plain:      1,
+			compressed: 1,
+		},
+		{
+			name: "close after a retry compresses the new chunk",
+			run: func(t *testing.T, dir string) *logFiles {
+				l := newTestLogFiles(dir)
+				l.chunkSize = logChunkSize
+				appendLines(t, l, "run", 0, 10)
+				l.Close("run")
+				appendLines(t, l, "run", 10, 15)
+				if err := l.Close("run"); err != nil {
+					t.Fatalf("Close() error: %v", err)
+				}
+				return l
+			},
+			lines:      15,
+			compressed: 2,
+		},
+		{
+			name: "restart recounts the chunks not closed",
+			run: func(t *testing.T, dir string) *logFiles {
+				appendLines(t, newTestLogFiles(dir), "run", 0, 40)
+
+				// the server stopped without closing the logs
+				return newTestLogFiles(dir)
+			},
+			lines: 40,
+			plain: -1,
+		},
+		{
+			name: "restart ignores a partial line",
+			run: func(t *testing.T, dir string) *logFiles {
+				l := newTestLogFiles(dir)
+				l.chunkSize = logChunkSize
+				appendLines(t, l, "run", 0, 5)
+
+				f, err := os.OpenFile(filepath.Join(dir, "run", chunkName(0, false)), os.O_WRONLY|os.O_APPEND, 0644)
+				if err != nil {
+					t.Fatalf("Failed to open chunk: %v", err)
+				}
+				f.WriteString(`{"type":"stdout","mess`)
+				f.Close()
+
+				return newTestLogFiles(dir)
+			},
+			lines: 5,
+			plain: 1,
+		},
+		{
+			name: "restart then append after a partial line",
+			run: func(t *testing.T, dir string) *logFiles {
+				l := newTestLogFiles(dir)
+				l.chunkSize = logChunkSize
+				appendLines(t, l, "run", 0, 5)
+
+				f, err := os.OpenFile(filepath.Join(dir, "run", chunkName(0, false)), os.O_WRONLY|os.O_APPEND, 0644)
+				if err != nil {
+					t.Fatalf("Failed to open chunk: %v", err)
+				}
+				f.WriteString(`{"type":"stdout","mess`)
+				f.Close()
+
+				l = newTestLogFiles(dir)
+				l.chunkSize = logChunkSize
+				appendLines(t, l, "run", 5, 8)
+				return l
+			},
+			lines: 8,
+			plain: 1,
+		},
+		{
+			name: "restart then append continues the lines",
+			run: func(t *testing.T, dir string) *logFiles {
+				appendLines(t, newTestLogFiles(dir), "run", 0, 40)
+
+				l := newTestLogFiles(dir)
+				appendLines(t, l, "run", 40, 50)
+				if err := l.Close("run"); err != nil {
+					t.Fatalf("Close() error: %v", err)
+				}
+				return l
+			},
+			lines:      50,
+			compressed: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			l := tt.run(t, dir)
+
+			indexes, _, total := readLines(t, l, "run", 0, 0, 1000)
+			if total != tt.lines || len(indexes) != tt.lines {
+				t.Fatalf("Expected %d lines, got %d of total %d", tt.lines, len(indexes), total)
+			}
+			for i, index := range indexes {
+				if index != i {
+					t.Fatalf("Expected line %d, got %d", i, index)
+				}
+			}
+
+			// -1 means any number of chunks, but at least one
+			plain, compressed := chunkFiles(t, filepath.Join(dir, "run"))
+			if (tt.plain >= 0 && plain != tt.plain) || (tt.plain < 0 && plain == 0) {
+				t.Errorf("Expected %d plain chunks, got %d", tt.plain, plain)
+			}
+			if (tt.compressed >= 0 && compressed != tt.compressed) || (tt.compressed < 0 && compressed == 0) {
+				t.Errorf("Expected %d compressed chunks, got %d", tt.compressed, compressed)
+			}
+		})
+	}
+}
+
+func TestLogFilesDelete(t *testing.T) {
+	dir := t.TempDir()
+	l := newTestLogFiles(dir)
+
+	if l.Exists("run") {
+		t.Fatal("Expected no logs before the first append")
+	}
+
+	appendLines(t, l, "run", 0, 10)
+	if !l.Exists("run") {
+		t.Fatal("Expected the logs to exist")
+	}
+
+	if err := l.Delete("run"); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+
+	if l.Exists("run") {
+		t.Error("Expected the logs to be deleted")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "run")); !os.IsNotExist(err) {
+		t.Errorf("Expected the directory to be removed, got %v", err)
+	}
+
+	if _, _, total := readLines(t, l, "run", 0, 0, 10); total != 0 {
+		t.Errorf("Expected no logs after delete, got %d", total)
+	}
+}
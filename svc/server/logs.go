@@ -0,0 +1,157 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLogLine 单行日志的最大长度，超过时直接记录，避免没有换行的输出占用内存
+const maxLogLine = 64 * 1024
+
+// logWriter 按行记录日志到 store，输出同时原样写入 out，
+//
+//	实现 step.Segmenter，按产生日志的 stage、job 和 step 分段记录
+type logWriter struct {
+	store Store
+	id    string
+	typ   string
+	// out 返回同时写入的输出，例如关注者的 websocket，可为空
+	out func() io.Writer
+	//
+	stage string
+	job   string
+	step  string
+	//
+	mu  sync.Mutex
+	buf []byte
+}
+
+// newLogWriter 创建 pipeline 的日志写入器，typ 为 stdout 或 stderr
+func newLogWriter(store Store, id, typ string, out func() io.Writer) *logWriter {
+	return &logWriter{
+		store: store,
+		id:    id,
+		typ:   typ,
+		out:   out,
+	}
+}
+
+func (w *logWriter) Write(p []byte) (n int, err error) {
+	if w.out != nil {
+		if out := w.out(); out != nil {
+			out.Write(p)
+		}
+	}
+
+	if w.store == nil {
+		return len(p), nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.add(string(w.buf[:i+1]))
+		w.buf = w.buf[i+1:]
+	}
+
+	if len(w.buf) >= maxLogLine {
+		w.add(string(w.buf))
+		w.buf = nil
+	}
+
+	return len(p), nil
+}
+
+// Segment 返回 stage、job 或 step 的日志写入器
+func (w *logWriter) Segment(stage, job, step string) io.Writer {
+	return &logWriter{
+		store: w.store,
+		id:    w.id,
+		typ:   w.typ,
+		out:   w.out,
+		stage: stage,
+		job:   job,
+		step:  step,
+	}
+}
+
+// Flush 记录最后没有换行的日志
+func (w *logWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.add(string(w.buf))
+		w.buf = nil
+	}
+}
+
+func (w *logWriter) add(message string) {
+	if w.store == nil {
+		return
+	}
+
+	w.store.AddLog(w.id, LogEntry{
+		Type:    w.typ,
+		Message: message,
+		Stage:   w.stage,
+		Job:     w.job,
+		Step:    w.step,
+	})
+}
+
+// logFilter 按查询参数 search、type、start_time、end_time 过滤日志，没有过滤条件时为空
+func logFilter(query url.Values) func(entry *LogEntry) bool {
+	search := strings.ToLower(query.Get("search"))
+	typeFilter := query.Get("type")
+
+	// 解析时间范围
+	var startTime, endTime *time.Time
+	if startTimeStr := query.Get("start_time"); startTimeStr != "" {
+		if t, err := time.Parse(time.RFC3339, startTimeStr); err == nil {
+			startTime = &t
+		}
+	}
+	if endTimeStr := query.Get("end_time"); endTimeStr != "" {
+		if t, err := time.Parse(time.RFC3339, endTimeStr); err == nil {
+			endTime = &t
+		}
+	}
+
+	if search == "" && typeFilter == "" && startTime == nil && endTime == nil {
+		return nil
+	}
+
+	return func(log *LogEntry) bool {
+		// 类型过滤
+		if typeFilter != "" && log.Type != typeFilter {
+			return false
+		}
+
+		// 搜索过滤
+		if search != "" && !strings.Contains(strings.ToLower(log.Message), search) {
+			return false
+		}
+
+		// 时间范围过滤
+		if startTime != nil && log.Timestamp.Before(*startTime) {
+			return false
+		}
+		if endTime != nil && log.Timestamp.After(*endTime) {
+			return false
+		}
+
+		return true
+	}
+}
@@ -3,10 +3,15 @@ package server
 import (
 	"context"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
 	"github.com/go-idp/pipeline"
+	"github.com/go-idp/pipeline/artifact"
+	"github.com/go-idp/pipeline/cache"
+	"github.com/go-idp/pipeline/event"
+	"github.com/go-idp/pipeline/secret"
 	"github.com/go-zoox/logger"
 )
 
@@ -14,15 +19,31 @@ import (
 type QueueItem struct {
 	ID        string             `json:"id"`
 	Name      string             `json:"name"`
-	Status    string             `json:"status"` // pending | running | succeeded | failed | cancelled
+	Status    string             `json:"status"` // pending | running | succeeded | failed | cancelled | interrupted
 	CreatedAt time.Time          `json:"created_at"`
 	StartedAt *time.Time         `json:"started_at,omitempty"`
 	EndedAt   *time.Time         `json:"ended_at,omitempty"`
 	Error     string             `json:"error,omitempty"`
 	YAML      string             `json:"yaml,omitempty"` // Pipeline YAML 配置
 	Pipeline  *pipeline.Pipeline `json:"-"`
+	Resume    *pipeline.RunState `json:"-"` // 重试时恢复的运行状态
 	Context   context.Context    `json:"-"`
 	Cancel    context.CancelFunc `json:"-"`
+	Watcher   *Watcher           `json:"-"` // 关注执行的客户端
+}
+
+// Watcher 关注队列项的执行，例如提交 pipeline 的 websocket 连接
+type Watcher struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Events event.Sink
+	// OnStatus 状态变化时调用（running | succeeded | failed | cancelled），参数为队列项的副本，
+	//	每个状态只调用一次，最终状态之后不再调用
+	OnStatus func(item QueueItem)
+
+	mu sync.Mutex
+	// status 最后通知的状态
+	status string
 }
 
 // Queue 队列接口
@@ -31,12 +52,16 @@ type Queue interface {
 	Enqueue(id, name string, pl *pipeline.Pipeline) error
 	// EnqueueWithYAML 添加 pipeline 到队列（带 YAML）
 	EnqueueWithYAML(id, name string, pl *pipeline.Pipeline, yaml string) error
+	// Retry 重新执行失败的 pipeline，只执行失败和未执行的单元，复用保留的工作目录
+	Retry(id string, pl *pipeline.Pipeline, state *pipeline.RunState) error
 	// Dequeue 从队列中取出 pipeline
 	Dequeue() (*QueueItem, bool)
 	// Get 获取队列项
 	Get(id string) (*QueueItem, bool)
 	// List 列出所有队列项
 	List() []*QueueItem
+	// Watch 关注队列项的执行，队列项不存在时返回 false，已开始或已结束时立即通知当前状态
+	Watch(id string, w *Watcher) bool
 	// Cancel 取消队列项
 	Cancel(id string) bool
 	// Stats 获取队列统计信息
@@ -51,6 +76,7 @@ type QueueStats struct {
 	Succeeded         int `json:"succeeded"`
 	Failed            int `json:"failed"`
 	Cancelled         int `json:"cancelled"`
+	Interrupted       int `json:"interrupted"`
 	MaxConcurrent     int `json:"max_concurrent"`
 	CurrentConcurrent int `json:"current_concurrent"`
 }
@@ -62,26 +88,38 @@ type queue struct {
 	runningItems  map[string]bool
 	maxConcurrent int
 	store         Store
+	artifacts     artifact.Store
+	caches        cache.Store
+	secrets       secret.Store
 	workdir       string
 	environment   map[string]string
+	// onChange 在队列项变化时调用，需持有锁，用于持久化队列，例如 SQLite
+	onChange func(item *QueueItem)
 }
 
 // NewQueue 创建队列
-func NewQueue(maxConcurrent int, store Store, workdir string, environment map[string]string) Queue {
-	q := &queue{
+func NewQueue(maxConcurrent int, store Store, artifacts artifact.Store, caches cache.Store, secrets secret.Store, workdir string, environment map[string]string) Queue {
+	q := newQueue(maxConcurrent, store, artifacts, caches, secrets, workdir, environment)
+
+	// 启动队列处理器
+	go q.process()
+
+	return q
+}
+
+func newQueue(maxConcurrent int, store Store, artifacts artifact.Store, caches cache.Store, secrets secret.Store, workdir string, environment map[string]string) *queue {
+	return &queue{
 		items:         make(map[string]*QueueItem),
 		pendingItems:  make([]string, 0),
 		runningItems:  make(map[string]bool),
 		maxConcurrent: maxConcurrent,
 		store:         store,
+		artifacts:     artifacts,
+		caches:        caches,
+		secrets:       secrets,
 		workdir:       workdir,
 		environment:   environment,
 	}
-
-	// 启动队列处理器
-	go q.process()
-
-	return q
 }
 
 func (q *queue) Enqueue(id, name string, pl *pipeline.Pipeline) error {
@@ -107,6 +145,7 @@ func (q *queue) EnqueueWithYAML(id, name string, pl *pipeline.Pipeline, yaml str
 
 	q.items[id] = item
 	q.pendingItems = append(q.pendingItems, id)
+	q.changed(item)
 
 	// 立即在 store 中创建记录（pending 状态）
 	if q.store != nil {
@@ -123,6 +162,50 @@ func (q *queue) EnqueueWithYAML(id, name string, pl *pipeline.Pipeline, yaml str
 	return nil
 }
 
+func (q *queue) Retry(id string, pl *pipeline.Pipeline, state *pipeline.RunState) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, exists := q.items[id]
+	if exists && (item.Status == "pending" || item.Status == "running") {
+		return fmt.Errorf("pipeline %s is %s, cannot retry", id, item.Status)
+	}
+
+	if !exists {
+		item = &QueueItem{
+			ID:   id,
+			Name: pl.Name,
+		}
+		q.items[id] = item
+	}
+
+	// 密钥参数不会持久化到运行状态，沿用队列中上次运行的参数值
+	if exists && item.Pipeline != nil {
+		pl.SetParams(item.Pipeline.Params())
+	}
+
+	item.Status = "pending"
+	item.CreatedAt = time.Now()
+	item.StartedAt = nil
+	item.EndedAt = nil
+	item.Error = ""
+	item.Pipeline = pl
+	item.Resume = state
+	item.Watcher = nil
+
+	q.pendingItems = append(q.pendingItems, id)
+	q.changed(item)
+
+	if q.store != nil {
+		q.store.UpdateStatus(id, "pending", nil)
+		q.store.AddLog(id, LogEntry{Type: "status", Message: "retry from failed"})
+	}
+
+	logger.Infof("[queue] retry pipeline %s (name: %s)", id, pl.Name)
+
+	return nil
+}
+
 func (q *queue) Dequeue() (*QueueItem, bool) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
@@ -149,6 +232,7 @@ func (q *queue) Dequeue() (*QueueItem, bool) {
 	item.Status = "running"
 	now := time.Now()
 	item.StartedAt = &now
+	q.changed(item)
 
 	return item, true
 }
@@ -173,7 +257,34 @@ func (q *queue) List() []*QueueItem {
 	return items
 }
 
+func (q *queue) Watch(id string, w *Watcher) bool {
+	var notified *QueueItem
+	defer func() {
+		notify(notified)
+	}()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, exists := q.items[id]
+	if !exists {
+		return false
+	}
+
+	item.Watcher = w
+	if item.Status != "pending" {
+		notified = snapshot(item)
+	}
+
+	return true
+}
+
 func (q *queue) Cancel(id string) bool {
+	var notified *QueueItem
+	defer func() {
+		notify(notified)
+	}()
+
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
@@ -197,6 +308,8 @@ func (q *queue) Cancel(id string) bool {
 		}
 
 		logger.Infof("[queue] pipeline %s cancelled", id)
+		q.changed(item)
+		notified = snapshot(item)
 		return true
 	}
 
@@ -221,6 +334,8 @@ func (q *queue) Cancel(id string) bool {
 		}
 
 		logger.Infof("[queue] pipeline %s cancelled (pending)", id)
+		q.changed(item)
+		notified = snapshot(item)
 		return true
 	}
 
@@ -250,6 +365,8 @@ func (q *queue) Stats() QueueStats {
 			stats.Failed++
 		case "cancelled":
 			stats.Cancelled++
+		case "interrupted":
+			stats.Interrupted++
 		}
 	}
 
@@ -296,30 +413,70 @@ func (q *queue) execute(item *QueueItem) {
 		q.store.UpdateStatus(item.ID, "running", nil)
 	}
 
+	q.mu.RLock()
+	running := snapshot(item)
+	q.mu.RUnlock()
+	notify(running)
+
 	// 设置 pipeline
 	item.Pipeline.SetWorkdir(fmt.Sprintf("%s/%s", q.workdir, item.ID))
 	item.Pipeline.SetEnvironment(q.environment)
-
-	// 设置输出，将日志记录到 store
-	if q.store != nil {
-		item.Pipeline.SetStdout(&queueWriter{
-			store: q.store,
-			id:    item.ID,
-			typ:   "stdout",
-		})
-		item.Pipeline.SetStderr(&queueWriter{
-			store: q.store,
-			id:    item.ID,
-			typ:   "stderr",
-		})
+	if q.artifacts != nil {
+		item.Pipeline.SetArtifactStore(q.artifacts)
+	}
+	if q.caches != nil {
+		item.Pipeline.SetCacheStore(q.caches)
+	}
+	if q.secrets != nil {
+		item.Pipeline.SetSecretStore(q.secrets)
 	}
 
+	// 设置输出，将日志按行记录到 store，并发送给关注者
+	stdout := newLogWriter(q.store, item.ID, "stdout", func() io.Writer {
+		if w := q.watcher(item); w != nil {
+			return w.Stdout
+		}
+		return nil
+	})
+	stderr := newLogWriter(q.store, item.ID, "stderr", func() io.Writer {
+		if w := q.watcher(item); w != nil {
+			return w.Stderr
+		}
+		return nil
+	})
+	item.Pipeline.SetStdout(stdout)
+	item.Pipeline.SetStderr(stderr)
+
+	// 记录事件到 store，并发送给关注者
+	item.Pipeline.SetEventSink(event.SinkFunc(func(e *event.Event) {
+		if q.store != nil {
+			q.store.AddEvent(item.ID, e)
+		}
+
+		if w := q.watcher(item); w != nil && w.Events != nil {
+			w.Events.Emit(e)
+		}
+	}))
+
 	// 执行 pipeline
 	err := item.Pipeline.Run(ctx, func(cfg *pipeline.RunConfig) {
 		cfg.ID = item.ID
+		cfg.Resume = item.Resume
 	})
+	stdout.Flush()
+	stderr.Flush()
+
+	// 记录执行结果
+	if q.store != nil {
+		q.store.SetResult(item.ID, NewRunResult(item.Pipeline))
+	}
+
+	// 更新状态，解锁后通知关注者
+	var notified *QueueItem
+	defer func() {
+		notify(notified)
+	}()
 
-	// 更新状态
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
@@ -327,6 +484,10 @@ func (q *queue) execute(item *QueueItem) {
 	if item.Status == "cancelled" {
 		return
 	}
+	defer func() {
+		q.changed(item)
+		notified = snapshot(item)
+	}()
 
 	delete(q.runningItems, item.ID)
 	now := time.Now()
@@ -358,16 +519,43 @@ func (q *queue) execute(item *QueueItem) {
 	}
 }
 
-// queueWriter 队列写入器，用于将日志写入 store
-type queueWriter struct {
-	store Store
-	id    string
-	typ   string
+// watcher 返回队列项的关注者
+func (q *queue) watcher(item *QueueItem) *Watcher {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return item.Watcher
 }
 
-func (w *queueWriter) Write(p []byte) (n int, err error) {
-	if w.store != nil {
-		w.store.AddLog(w.id, w.typ, string(p))
+// changed 持久化变化的队列项，需持有锁
+func (q *queue) changed(item *QueueItem) {
+	if q.onChange != nil {
+		q.onChange(item)
 	}
-	return len(p), nil
+}
+
+// snapshot 返回队列项的副本，需持有锁
+func snapshot(item *QueueItem) *QueueItem {
+	s := *item
+	return &s
+}
+
+// notify 通知关注者队列项的状态，不能持有锁，避免慢速的客户端阻塞队列，
+//
+//	Watch 和 execute 可能同时通知开始执行，已通知的状态和最终状态之后的状态被忽略
+func notify(item *QueueItem) {
+	if item == nil || item.Watcher == nil || item.Watcher.OnStatus == nil {
+		return
+	}
+
+	w := item.Watcher
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if item.Status == w.status || finished(w.status) {
+		return
+	}
+	w.status = item.Status
+
+	w.OnStatus(*item)
 }
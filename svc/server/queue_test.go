@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/go-idp/pipeline"
+)
+
+func newTestPipeline(t *testing.T, command string) *pipeline.Pipeline {
+	t.Helper()
+
+	pl, err := pipeline.LoadYAML([]byte(`
+name: test
+stages:
+  - name: build
+    jobs:
+      - name: build
+        steps:
+          - name: run
+            command: `+command+`
+`), "")
+	if err != nil {
+		t.Fatalf("Failed to load pipeline: %v", err)
+	}
+	pl.SetStdout(&bytes.Buffer{})
+	pl.SetStderr(&bytes.Buffer{})
+
+	return pl
+}
+
+// statusRecorder records the statuses notified to a watcher
+type statusRecorder struct {
+	mu       sync.Mutex
+	statuses []string
+}
+
+func (r *statusRecorder) watcher() *Watcher {
+	return &Watcher{
+		OnStatus: func(item QueueItem) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+
+			r.statuses = append(r.statuses, item.Status)
+		},
+	}
+}
+
+func (r *statusRecorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string{}, r.statuses...)
+}
+
+func TestQueueWatch(t *testing.T) {
+	t.Run("watch after start should notify running once", func(t *testing.T) {
+		q := newQueue(1, nil, nil, nil, nil, t.TempDir(), nil)
+		if err := q.Enqueue("run", "test", newTestPipeline(t, "echo hello")); err != nil {
+			t.Fatalf("Enqueue() error: %v", err)
+		}
+
+		// the run starts before the client watches it
+		item, ok := q.Dequeue()
+		if !ok {
+			t.Fatal("Expected the run to be dequeued")
+		}
+
+		recorder := &statusRecorder{}
+		if !q.Watch("run", recorder.watcher()) {
+			t.Fatal("Expected the run to be watched")
+		}
+
+		q.execute(item)
+
+		if got, want := recorder.get(), []string{"running", "succeeded"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected statuses %v, got %v", want, got)
+		}
+	})
+
+	t.Run("watch before start should notify every status", func(t *testing.T) {
+		q := newQueue(1, nil, nil, nil, nil, t.TempDir(), nil)
+		if err := q.Enqueue("run", "test", newTestPipeline(t, "exit 3")); err != nil {
+			t.Fatalf("Enqueue() error: %v", err)
+		}
+
+		recorder := &statusRecorder{}
+		q.Watch("run", recorder.watcher())
+
+		item, _ := q.Dequeue()
+		q.execute(item)
+
+		if got, want := recorder.get(), []string{"running", "failed"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected statuses %v, got %v", want, got)
+		}
+	})
+
+	t.Run("status after the final status should be ignored", func(t *testing.T) {
+		q := newQueue(1, nil, nil, nil, nil, t.TempDir(), nil)
+		if err := q.Enqueue("run", "test", newTestPipeline(t, "echo hello")); err != nil {
+			t.Fatalf("Enqueue() error: %v", err)
+		}
+
+		item, _ := q.Dequeue()
+		q.execute(item)
+
+		recorder := &statusRecorder{}
+		w := recorder.watcher()
+		q.Watch("run", w)
+
+		// a late running from a concurrent notify
+		notify(&QueueItem{ID: "run", Status: "running", Watcher: w})
+
+		if got, want := recorder.get(), []string{"succeeded"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected statuses %v, got %v", want, got)
+		}
+	})
+
+	t.Run("cancel of a pending run should notify cancelled", func(t *testing.T) {
+		q := newQueue(1, nil, nil, nil, nil, t.TempDir(), nil)
+		if err := q.Enqueue("run", "test", newTestPipeline(t, "echo hello")); err != nil {
+			t.Fatalf("Enqueue() error: %v", err)
+		}
+
+		recorder := &statusRecorder{}
+		q.Watch("run", recorder.watcher())
+
+		if !q.Cancel("run") {
+			t.Fatal("Expected the run to be cancelled")
+		}
+
+		if got, want := recorder.get(), []string{"cancelled"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected statuses %v, got %v", want, got)
+		}
+	})
+}
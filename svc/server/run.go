@@ -1,28 +1,43 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-idp/pipeline"
+	"github.com/go-idp/pipeline/event"
+	"github.com/go-idp/pipeline/secret"
 	"github.com/go-zoox/chalk"
-	"github.com/go-zoox/encoding/yaml"
 	"github.com/go-zoox/fs"
 	"github.com/go-zoox/headers"
+	"github.com/go-zoox/logger"
 	"github.com/go-zoox/zoox"
 
 	defaults "github.com/go-zoox/zoox/defaults"
 )
 
 func (s *server) Run() error {
+	if s.err != nil {
+		return s.err
+	}
+
 	if ok := fs.IsExist(s.cfg.Workdir); !ok {
 		if err := fs.Mkdirp(s.cfg.Workdir); err != nil {
 			return fmt.Errorf("failed to create workdir: %s", err)
 		}
 	}
 
+	// 密钥错误时尽早失败
+	if _, err := s.secrets.List(); err != nil {
+		return fmt.Errorf("failed to open secrets: %s", err)
+	}
+
 	app := defaults.Defaults()
 
 	app.SetBanner(fmt.Sprintf(`
@@ -57,6 +72,10 @@ func (s *server) Run() error {
 		opt.Environment = s.cfg.Environment
 		opt.Store = s.store
 		opt.Queue = s.queue
+		opt.Artifacts = s.artifacts
+		opt.Caches = s.caches
+		opt.Secrets = s.secrets
+		opt.AllowedIncludes = s.cfg.AllowedIncludes
 	})
 	if err != nil {
 		return err
@@ -215,11 +234,10 @@ func (s *server) Run() error {
 			ctx.JSON(200, record)
 		})
 
-		// 获取 pipeline 日志
+		// 获取 pipeline 日志，从磁盘分页读取，offset 为（过滤后的）行号，cursor 为上一页返回的 next_cursor
 		api.Get("/pipelines/:id/logs", func(ctx *zoox.Context) {
 			id := ctx.Param().Get("id").String()
-			record, ok := s.store.Get(id)
-			if !ok {
+			if _, ok := s.store.Get(id); !ok {
 				ctx.Status(404)
 				ctx.JSON(404, map[string]string{
 					"error": "pipeline not found",
@@ -227,81 +245,77 @@ func (s *server) Run() error {
 				return
 			}
 
-			logs := record.Logs
-
-			// 应用过滤
-			search := ctx.Request.URL.Query().Get("search")
-			typeFilter := ctx.Request.URL.Query().Get("type")
-			startTimeStr := ctx.Request.URL.Query().Get("start_time")
-			endTimeStr := ctx.Request.URL.Query().Get("end_time")
+			query := ctx.Request.URL.Query()
 			limit := 0
-			if limitStr := ctx.Request.URL.Query().Get("limit"); limitStr != "" {
+			if limitStr := query.Get("limit"); limitStr != "" {
 				if parsed, err := strconv.Atoi(limitStr); err == nil {
 					limit = parsed
 				}
 			}
 			offset := 0
-			if offsetStr := ctx.Request.URL.Query().Get("offset"); offsetStr != "" {
-				if parsed, err := strconv.Atoi(offsetStr); err == nil {
+			if offsetStr := query.Get("offset"); offsetStr != "" {
+				if parsed, err := strconv.Atoi(offsetStr); err == nil && parsed > 0 {
 					offset = parsed
 				}
 			}
-
-			// 解析时间范围
-			var startTime, endTime *time.Time
-			if startTimeStr != "" {
-				if t, err := time.Parse(time.RFC3339, startTimeStr); err == nil {
-					startTime = &t
-				}
-			}
-			if endTimeStr != "" {
-				if t, err := time.Parse(time.RFC3339, endTimeStr); err == nil {
-					endTime = &t
+			var cursor int64
+			if cursorStr := query.Get("cursor"); cursorStr != "" {
+				if parsed, err := strconv.ParseInt(cursorStr, 10, 64); err == nil && parsed > 0 {
+					cursor = parsed
 				}
 			}
 
-			// 过滤日志
-			filtered := make([]LogEntry, 0)
-			for _, log := range logs {
-				// 类型过滤
-				if typeFilter != "" && log.Type != typeFilter {
-					continue
-				}
-
-				// 搜索过滤
-				if search != "" && !strings.Contains(strings.ToLower(log.Message), strings.ToLower(search)) {
-					continue
-				}
+			logs := make([]LogEntry, 0)
+			nextCursor := cursor
+			collect := func(next int64, entry *LogEntry) {
+				logs = append(logs, *entry)
+				nextCursor = next
+			}
 
-				// 时间范围过滤
-				if startTime != nil && log.Timestamp.Before(*startTime) {
-					continue
-				}
-				if endTime != nil && log.Timestamp.After(*endTime) {
-					continue
-				}
+			var total int
+			var err error
+			if filter := logFilter(query); filter == nil {
+				// 没有过滤条件时直接从 offset 行开始读取，total 为日志的总行数
+				total, err = s.store.ReadLogs(id, offset, cursor, func(index int, next int64, entry *LogEntry) bool {
+					if limit > 0 && len(logs) >= limit {
+						return false
+					}
 
-				filtered = append(filtered, log)
-			}
+					collect(next, entry)
+					return true
+				})
+			} else {
+				// 过滤时读取全部日志，total 为过滤后的行数
+				_, err = s.store.ReadLogs(id, 0, cursor, func(index int, next int64, entry *LogEntry) bool {
+					if !filter(entry) {
+						return true
+					}
 
-			// 应用分页
-			total := len(filtered)
-			if offset > 0 && offset < len(filtered) {
-				filtered = filtered[offset:]
+					total++
+					if total > offset && (limit <= 0 || len(logs) < limit) {
+						collect(next, entry)
+					}
+					return true
+				})
 			}
-			if limit > 0 && limit < len(filtered) {
-				filtered = filtered[:limit]
+			if err != nil {
+				ctx.Status(500)
+				ctx.JSON(500, map[string]string{
+					"error": fmt.Sprintf("failed to read logs: %s", err),
+				})
+				return
 			}
 
 			ctx.JSON(200, map[string]interface{}{
-				"data":   filtered,
-				"total":  total,
-				"limit":  limit,
-				"offset": offset,
+				"data":        logs,
+				"total":       total,
+				"limit":       limit,
+				"offset":      offset,
+				"next_cursor": nextCursor,
 			})
 		})
 
-		// 导出 pipeline 日志
+		// 导出 pipeline 日志，从磁盘逐行读取并写入
 		api.Get("/pipelines/:id/logs/export", func(ctx *zoox.Context) {
 			id := ctx.Param().Get("id").String()
 			record, ok := s.store.Get(id)
@@ -318,68 +332,285 @@ func (s *server) Run() error {
 				format = "text"
 			}
 
-			logs := record.Logs
-
 			// 应用过滤（与 logs 端点相同的过滤逻辑）
-			search := ctx.Request.URL.Query().Get("search")
-			typeFilter := ctx.Request.URL.Query().Get("type")
-			startTimeStr := ctx.Request.URL.Query().Get("start_time")
-			endTimeStr := ctx.Request.URL.Query().Get("end_time")
-
-			var startTime, endTime *time.Time
-			if startTimeStr != "" {
-				if t, err := time.Parse(time.RFC3339, startTimeStr); err == nil {
-					startTime = &t
-				}
-			}
-			if endTimeStr != "" {
-				if t, err := time.Parse(time.RFC3339, endTimeStr); err == nil {
-					endTime = &t
-				}
-			}
-
-			filtered := make([]LogEntry, 0)
-			for _, log := range logs {
-				if typeFilter != "" && log.Type != typeFilter {
-					continue
-				}
-				if search != "" && !strings.Contains(strings.ToLower(log.Message), strings.ToLower(search)) {
-					continue
-				}
-				if startTime != nil && log.Timestamp.Before(*startTime) {
-					continue
-				}
-				if endTime != nil && log.Timestamp.After(*endTime) {
-					continue
-				}
-				filtered = append(filtered, log)
-			}
+			filter := logFilter(ctx.Request.URL.Query())
 
+			var err error
 			if format == "json" {
 				ctx.SetHeader(headers.ContentType, "application/json")
 				ctx.SetHeader("Content-Disposition", fmt.Sprintf("attachment; filename=pipeline-%s-logs.json", id))
-				ctx.JSON(200, map[string]interface{}{
+				ctx.Status(200)
+
+				header, _ := json.Marshal(map[string]interface{}{
 					"pipeline_id":   id,
 					"pipeline_name": record.Name,
 					"exported_at":   time.Now().Format(time.RFC3339),
-					"logs":          filtered,
 				})
+				// 在 header 对象的末尾追加 logs 数组
+				ctx.Writer.Write(header[:len(header)-1])
+				ctx.Writer.Write([]byte(`,"logs":[`))
+
+				first := true
+				_, err = s.store.ReadLogs(id, 0, 0, func(index int, next int64, entry *LogEntry) bool {
+					if filter != nil && !filter(entry) {
+						return true
+					}
+
+					data, errx := json.Marshal(entry)
+					if errx != nil {
+						return true
+					}
+					if !first {
+						ctx.Writer.Write([]byte(","))
+					}
+					first = false
+
+					ctx.Writer.Write(data)
+					return true
+				})
+
+				ctx.Writer.Write([]byte("]}"))
 			} else {
 				// 文本格式
 				ctx.SetHeader(headers.ContentType, "text/plain")
 				ctx.SetHeader("Content-Disposition", fmt.Sprintf("attachment; filename=pipeline-%s-logs.txt", id))
+				ctx.Status(200)
 
-				var sb strings.Builder
-				sb.WriteString(fmt.Sprintf("Pipeline: %s (ID: %s)\n", record.Name, id))
-				sb.WriteString(fmt.Sprintf("Exported at: %s\n", time.Now().Format(time.RFC3339)))
-				sb.WriteString(strings.Repeat("=", 80) + "\n\n")
+				fmt.Fprintf(ctx.Writer, "Pipeline: %s (ID: %s)\n", record.Name, id)
+				fmt.Fprintf(ctx.Writer, "Exported at: %s\n", time.Now().Format(time.RFC3339))
+				fmt.Fprint(ctx.Writer, strings.Repeat("=", 80)+"\n\n")
+
+				_, err = s.store.ReadLogs(id, 0, 0, func(index int, next int64, log *LogEntry) bool {
+					if filter != nil && !filter(log) {
+						return true
+					}
 
-				for _, log := range filtered {
 					timestamp := log.Timestamp.Format("2006-01-02 15:04:05")
-					sb.WriteString(fmt.Sprintf("[%s] [%s] %s\n", timestamp, log.Type, log.Message))
+					fmt.Fprintf(ctx.Writer, "[%s] [%s] %s\n", timestamp, log.Type, log.Message)
+					return true
+				})
+			}
+
+			if err != nil {
+				logger.Errorf("[server] failed to export logs of pipeline %s: %s", id, err)
+			}
+		})
+
+		// 实时推送 pipeline 日志（SSE），从 cursor 或 Last-Event-ID 之后的日志开始，结束时推送最终状态
+		api.Get("/pipelines/:id/logs/stream", func(ctx *zoox.Context) {
+			id := ctx.Param().Get("id").String()
+
+			cursor := 0
+			if cursorStr := ctx.Request.URL.Query().Get("cursor"); cursorStr != "" {
+				if parsed, err := strconv.Atoi(cursorStr); err == nil {
+					cursor = parsed
+				}
+			} else if lastID := ctx.Request.Header.Get("Last-Event-ID"); lastID != "" {
+				if parsed, err := strconv.Atoi(lastID); err == nil {
+					cursor = parsed + 1
+				}
+			}
+
+			sub, err := s.store.Subscribe(id, cursor)
+			if err != nil {
+				ctx.Status(404)
+				ctx.JSON(404, map[string]string{
+					"error": err.Error(),
+				})
+				return
+			}
+			defer sub.Close()
+
+			ctx.SetHeader(headers.ContentType, "text/event-stream")
+			ctx.SetHeader(headers.CacheControl, "no-cache")
+			ctx.SetHeader(headers.Connection, "keep-alive")
+			ctx.Status(200)
+
+			for i := range sub.Logs {
+				writeSSE(ctx.Writer, "log", sub.Cursor+i, &sub.Logs[i])
+			}
+			ctx.Writer.Flush()
+
+			keepalive := time.NewTicker(15 * time.Second)
+			defer keepalive.Stop()
+
+			for {
+				select {
+				case <-ctx.Request.Context().Done():
+					return
+				case <-keepalive.C:
+					ctx.Writer.Write([]byte(": keepalive\n\n"))
+					ctx.Writer.Flush()
+				case msg, ok := <-sub.Messages:
+					// 订阅者过慢时关闭，客户端以 Last-Event-ID 重新连接
+					if !ok {
+						return
+					}
+
+					if msg.Status != nil {
+						writeSSE(ctx.Writer, "status", -1, msg.Status)
+						ctx.Writer.Flush()
+						return
+					}
+
+					writeSSE(ctx.Writer, "log", msg.Index, msg.Entry)
+					ctx.Writer.Flush()
 				}
+			}
+		})
 
-				ctx.String(200, sb.String())
+		// 获取 step 的日志，stepId 为 step 的 State.ID，可省略 pipeline ID 的前缀，例如 0.1.2
+		api.Get("/pipelines/:id/steps/:stepId/logs", func(ctx *zoox.Context) {
+			id := ctx.Param().Get("id").String()
+			record, ok := s.store.Get(id)
+			if !ok {
+				ctx.Status(404)
+				ctx.JSON(404, map[string]string{
+					"error": "pipeline not found",
+				})
+				return
+			}
+
+			stepID := ctx.Param().Get("stepId").String()
+			if !strings.HasPrefix(stepID, id+".") {
+				stepID = id + "." + stepID
+			}
+
+			logs := make([]LogEntry, 0)
+			if _, err := s.store.ReadLogs(id, 0, 0, func(index int, next int64, entry *LogEntry) bool {
+				if entry.Step == stepID {
+					logs = append(logs, *entry)
+				}
+				return true
+			}); err != nil {
+				ctx.Status(500)
+				ctx.JSON(500, map[string]string{
+					"error": fmt.Sprintf("failed to read logs: %s", err),
+				})
+				return
+			}
+
+			var result *StepResult
+			for _, step := range record.Steps {
+				if step.ID == stepID {
+					result = step
+					break
+				}
+			}
+
+			if len(logs) == 0 && result == nil {
+				ctx.Status(404)
+				ctx.JSON(404, map[string]string{
+					"error": "step not found",
+				})
+				return
+			}
+
+			ctx.JSON(200, map[string]interface{}{
+				"step":  stepID,
+				"data":  logs,
+				"total": len(logs),
+			})
+		})
+
+		// 获取 pipeline 事件，type 按类型过滤，例如 step.finished，或按前缀过滤，例如 step
+		api.Get("/pipelines/:id/events", func(ctx *zoox.Context) {
+			id := ctx.Param().Get("id").String()
+			record, ok := s.store.Get(id)
+			if !ok {
+				ctx.Status(404)
+				ctx.JSON(404, map[string]string{
+					"error": "pipeline not found",
+				})
+				return
+			}
+
+			typeFilter := ctx.Request.URL.Query().Get("type")
+			events := make([]*event.Event, 0, len(record.Events))
+			for _, e := range record.Events {
+				if typeFilter != "" && string(e.Type) != typeFilter && !strings.HasPrefix(string(e.Type), typeFilter+".") {
+					continue
+				}
+
+				events = append(events, e)
+			}
+
+			ctx.JSON(200, map[string]interface{}{
+				"data":  events,
+				"total": len(events),
+			})
+		})
+
+		// 获取 pipeline 制品列表
+		api.Get("/pipelines/:id/artifacts", func(ctx *zoox.Context) {
+			id := ctx.Param().Get("id").String()
+			if _, ok := s.store.Get(id); !ok {
+				ctx.Status(404)
+				ctx.JSON(404, map[string]string{
+					"error": "pipeline not found",
+				})
+				return
+			}
+
+			artifacts, err := s.artifacts.List(id)
+			if err != nil {
+				ctx.Status(500)
+				ctx.JSON(500, map[string]string{
+					"error": fmt.Sprintf("failed to list artifacts: %s", err),
+				})
+				return
+			}
+
+			ctx.JSON(200, map[string]interface{}{
+				"data":  artifacts,
+				"total": len(artifacts),
+			})
+		})
+
+		// 下载 pipeline 制品（tar.gz），指定 path 时下载单个文件
+		api.Get("/pipelines/:id/artifacts/:name", func(ctx *zoox.Context) {
+			id := ctx.Param().Get("id").String()
+			name := ctx.Param().Get("name").String()
+			if _, ok := s.store.Get(id); !ok {
+				ctx.Status(404)
+				ctx.JSON(404, map[string]string{
+					"error": "pipeline not found",
+				})
+				return
+			}
+
+			if _, err := s.artifacts.Get(id, name); err != nil {
+				ctx.Status(404)
+				ctx.JSON(404, map[string]string{
+					"error": err.Error(),
+				})
+				return
+			}
+
+			// 下载单个文件
+			if path := ctx.Request.URL.Query().Get("path"); path != "" {
+				file, err := s.artifacts.Open(id, name, path)
+				if err != nil {
+					ctx.Status(404)
+					ctx.JSON(404, map[string]string{
+						"error": err.Error(),
+					})
+					return
+				}
+				defer file.Close()
+
+				ctx.SetHeader(headers.ContentType, "application/octet-stream")
+				ctx.SetHeader("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(path)))
+				ctx.Status(200)
+				io.Copy(ctx.Writer, file)
+				return
+			}
+
+			ctx.SetHeader(headers.ContentType, "application/gzip")
+			ctx.SetHeader("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s.tar.gz", name)))
+			ctx.Status(200)
+			if err := s.artifacts.Archive(id, name, ctx.Writer); err != nil {
+				logger.Errorf("[artifacts] failed to archive artifact(%s) of pipeline %s: %s", name, id, err)
 			}
 		})
 
@@ -406,7 +637,7 @@ func (s *server) Run() error {
 			}
 
 			// 如果已经是最终状态，不能取消
-			if record.Status == "succeeded" || record.Status == "failed" || record.Status == "cancelled" {
+			if record.Status == "succeeded" || record.Status == "failed" || record.Status == "cancelled" || record.Status == "interrupted" {
 				ctx.Status(400)
 				ctx.JSON(400, map[string]string{
 					"error": fmt.Sprintf("pipeline is already %s, cannot cancel", record.Status),
@@ -429,10 +660,76 @@ func (s *server) Run() error {
 			})
 		})
 
+		// 重试失败的 pipeline，只执行失败和未执行的阶段、任务和步骤
+		api.Post("/pipelines/:id/retry", func(ctx *zoox.Context) {
+			id := ctx.Param().Get("id").String()
+
+			if from := ctx.Request.URL.Query().Get("from"); from != "" && from != "failed" {
+				ctx.Status(400)
+				ctx.JSON(400, map[string]string{
+					"error": fmt.Sprintf("unsupported from %s, only support failed", from),
+				})
+				return
+			}
+
+			record, ok := s.store.Get(id)
+			if !ok {
+				ctx.Status(404)
+				ctx.JSON(404, map[string]string{
+					"error": "pipeline not found",
+				})
+				return
+			}
+
+			if record.Status != "failed" && record.Status != "cancelled" && record.Status != "interrupted" {
+				ctx.Status(400)
+				ctx.JSON(400, map[string]string{
+					"error": fmt.Sprintf("pipeline is %s, only failed, cancelled or interrupted pipelines can be retried", record.Status),
+				})
+				return
+			}
+
+			// 运行状态保存在保留的工作目录中
+			state, err := pipeline.ReadRunState(filepath.Join(s.cfg.Workdir, id))
+			if err != nil {
+				ctx.Status(400)
+				ctx.JSON(400, map[string]string{
+					"error": fmt.Sprintf("failed to read run state: %s", err),
+				})
+				return
+			}
+
+			pl, err := state.Pipeline()
+			if err != nil {
+				ctx.Status(400)
+				ctx.JSON(400, map[string]string{
+					"error": err.Error(),
+				})
+				return
+			}
+
+			if err := s.queue.Retry(id, pl, state); err != nil {
+				ctx.Status(400)
+				ctx.JSON(400, map[string]string{
+					"error": err.Error(),
+				})
+				return
+			}
+
+			ctx.JSON(200, map[string]string{
+				"id":      id,
+				"message": "pipeline retried from failed",
+			})
+		})
+
 		// 删除 pipeline 记录
 		api.Delete("/pipelines/:id", func(ctx *zoox.Context) {
 			id := ctx.Param().Get("id").String()
 			if s.store.Delete(id) {
+				if err := s.artifacts.Delete(id); err != nil {
+					logger.Warnf("[artifacts] failed to delete artifacts of pipeline %s: %s", id, err)
+				}
+
 				ctx.JSON(200, map[string]string{
 					"message": "deleted",
 				})
@@ -461,6 +758,9 @@ func (s *server) Run() error {
 			notFound := 0
 			for _, id := range req.IDs {
 				if s.store.Delete(id) {
+					if err := s.artifacts.Delete(id); err != nil {
+						logger.Warnf("[artifacts] failed to delete artifacts of pipeline %s: %s", id, err)
+					}
 					deleted++
 				} else {
 					notFound++
@@ -507,7 +807,7 @@ func (s *server) Run() error {
 				}
 
 				// 如果已经是最终状态，不能取消
-				if record.Status == "succeeded" || record.Status == "failed" || record.Status == "cancelled" {
+				if record.Status == "succeeded" || record.Status == "failed" || record.Status == "cancelled" || record.Status == "interrupted" {
 					failed++
 					continue
 				}
@@ -573,9 +873,8 @@ func (s *server) Run() error {
 				return
 			}
 
-			// 解析 pipeline 配置
-			var pl pipeline.Pipeline
-			if err := yaml.Decode([]byte(req.Config), &pl); err != nil {
+			// 解析 pipeline 配置，只允许配置的 include
+			if _, err := pipeline.LoadYAML([]byte(req.Config), "", allowIncludes(s.cfg.AllowedIncludes)); err != nil {
 				ctx.Status(400)
 				ctx.JSON(400, map[string]string{
 					"error": fmt.Sprintf("invalid pipeline config: %s", err),
@@ -599,10 +898,110 @@ func (s *server) Run() error {
 			})
 		})
 
+		// 校验 pipeline 配置，不执行
+		api.Post("/pipelines/validate", func(ctx *zoox.Context) {
+			var req struct {
+				Config string `json:"config"` // YAML 格式的 pipeline 配置
+			}
+			if err := ctx.BindJSON(&req); err != nil {
+				ctx.Status(400)
+				ctx.JSON(400, map[string]string{
+					"error": fmt.Sprintf("invalid request: %s", err),
+				})
+				return
+			}
+
+			errs := pipeline.ValidationErrors{}
+			pl, err := pipeline.ValidateYAML([]byte(req.Config), allowIncludes(s.cfg.AllowedIncludes))
+			if err != nil {
+				errs = err.(pipeline.ValidationErrors)
+			}
+
+			// 参数定义，Web Console 据此渲染参数表单
+			parameters := []*pipeline.Parameter{}
+			if pl != nil && pl.Parameters != nil {
+				parameters = pl.Parameters
+			}
+
+			ctx.JSON(200, map[string]interface{}{
+				"valid":      len(errs) == 0,
+				"errors":     errs,
+				"parameters": parameters,
+			})
+		})
+
+		// secrets 列表，只返回名称，不返回值
+		api.Get("/secrets", func(ctx *zoox.Context) {
+			names, err := s.secrets.List()
+			if err != nil {
+				ctx.Status(500)
+				ctx.JSON(500, map[string]string{
+					"error": err.Error(),
+				})
+				return
+			}
+
+			ctx.JSON(200, map[string]interface{}{
+				"secrets": names,
+				"total":   len(names),
+			})
+		})
+
+		// 创建或更新 secret，pipeline 通过 ${{ secrets.NAME }} 引用
+		api.Put("/secrets/:name", func(ctx *zoox.Context) {
+			name := ctx.Param().Get("name").String()
+
+			var req struct {
+				Value string `json:"value"`
+			}
+			if err := ctx.BindJSON(&req); err != nil {
+				ctx.Status(400)
+				ctx.JSON(400, map[string]string{
+					"error": fmt.Sprintf("invalid request: %s", err),
+				})
+				return
+			}
+
+			if err := s.secrets.Set(name, req.Value); err != nil {
+				ctx.Status(400)
+				ctx.JSON(400, map[string]string{
+					"error": err.Error(),
+				})
+				return
+			}
+
+			ctx.JSON(200, map[string]string{
+				"message": "saved",
+			})
+		})
+
+		// 删除 secret
+		api.Delete("/secrets/:name", func(ctx *zoox.Context) {
+			name := ctx.Param().Get("name").String()
+			if err := s.secrets.Delete(name); err != nil {
+				status := 400
+				if errors.Is(err, secret.ErrNotFound) {
+					status = 404
+				}
+
+				ctx.Status(status)
+				ctx.JSON(status, map[string]string{
+					"error": err.Error(),
+				})
+				return
+			}
+
+			ctx.JSON(200, map[string]string{
+				"message": "deleted",
+			})
+		})
+
 		// 获取设置
 		api.Get("/settings", func(ctx *zoox.Context) {
 			ctx.JSON(200, map[string]interface{}{
 				"max_concurrent":   s.cfg.MaxConcurrent,
+				"store":            s.cfg.Store,
+				"log_max_size":     s.cfg.LogMaxSize,
 				"max_records":      1000, // 从 store 获取
 				"refresh_interval": 5,    // 前端设置
 			})
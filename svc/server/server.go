@@ -1,14 +1,29 @@
 package server
 
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-idp/pipeline/artifact"
+	"github.com/go-idp/pipeline/cache"
+	"github.com/go-idp/pipeline/secret"
+)
+
 type Server interface {
 	Run() error
 }
 
 type server struct {
 	cfg         *Config
-	store       Store
+	store       StreamStore
 	queue       Queue
 	configStore ConfigStore
+	artifacts   artifact.Store
+	caches      cache.Store
+	secrets     secret.Store
+	// err 创建存储的错误，启动时返回
+	err error
 }
 
 func New(cfg *Config) Server {
@@ -17,14 +32,75 @@ func New(cfg *Config) Server {
 		maxConcurrent = 2 // 默认并发数为 2
 	}
 
-	store := NewMemoryStore(cfg.Workdir, 1000) // 最多保存1000条记录
-	queue := NewQueue(maxConcurrent, store, cfg.Workdir, cfg.Environment)
+	// 每次运行的日志的最大字节数
+	maxLogSize := int64(cfg.LogMaxSize) * 1024 * 1024
+
+	artifacts := artifact.NewLocalStore(filepath.Join(cfg.Workdir, ".pipeline_artifacts"))
+	caches := cache.NewLocalStore(filepath.Join(cfg.Workdir, ".pipeline_cache"))
+
+	// 配置了密钥时使用加密文件存储 secrets，否则使用服务进程的环境变量 PIPELINE_SECRET_*
+	//	创建失败时启动返回错误，不回退到环境变量
+	secrets := secret.NewEnvStore()
+	var secretsErr error
+	if cfg.SecretsKey != "" {
+		file := cfg.SecretsFile
+		if file == "" {
+			file = filepath.Join(cfg.Workdir, ".pipeline_secrets")
+		}
+
+		fileStore, err := secret.NewFileStore(file, cfg.SecretsKey)
+		if err != nil {
+			secretsErr = fmt.Errorf("failed to open secrets store(path: %s): %s", file, err)
+		} else {
+			secrets = fileStore
+		}
+	}
+
 	configStore := NewMemoryConfigStore(cfg.Workdir)
 
-	return &server{
+	s := &server{
 		cfg:         cfg,
-		store:       store,
-		queue:       queue,
 		configStore: configStore,
+		artifacts:   artifacts,
+		caches:      caches,
+		secrets:     secrets,
+	}
+	if secretsErr != nil {
+		s.err = secretsErr
+		return s
 	}
+
+	switch {
+	case cfg.Store == "" || cfg.Store == "memory":
+		s.store = NewStreamStore(NewMemoryStore(cfg.Workdir, 1000), maxLogSize) // 最多保存1000条记录
+		s.queue = NewQueue(maxConcurrent, s.store, artifacts, caches, secrets, cfg.Workdir, cfg.Environment)
+	case cfg.Store == "sqlite" || strings.HasPrefix(cfg.Store, "sqlite:"):
+		path := strings.TrimPrefix(strings.TrimPrefix(cfg.Store, "sqlite"), ":")
+		if path == "" {
+			path = filepath.Join(cfg.Workdir, ".pipeline.db")
+		}
+
+		s.err = func() error {
+			db, err := OpenSQLite(path)
+			if err != nil {
+				return err
+			}
+
+			store, err := NewSQLiteStore(db)
+			if err != nil {
+				return fmt.Errorf("failed to open sqlite store(path: %s): %s", path, err)
+			}
+			s.store = NewStreamStore(store, maxLogSize)
+
+			if s.queue, err = NewSQLiteQueue(db, maxConcurrent, s.store, artifacts, caches, secrets, cfg.Workdir, cfg.Environment); err != nil {
+				return fmt.Errorf("failed to open sqlite queue(path: %s): %s", path, err)
+			}
+
+			return nil
+		}()
+	default:
+		s.err = fmt.Errorf("unsupported store %s, only support memory | sqlite | sqlite:<path>", cfg.Store)
+	}
+
+	return s
 }
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/go-zoox/fs"
+
+	// 纯 Go 的 SQLite 驱动，不依赖 cgo
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema SQLite 存储的表结构，日志和事件保存在独立的表中
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS pipelines (
+	id           TEXT PRIMARY KEY,
+	name         TEXT NOT NULL,
+	status       TEXT NOT NULL,
+	started_at   INTEGER NOT NULL,
+	succeed_at   INTEGER,
+	failed_at    INTEGER,
+	cancelled_at INTEGER,
+	error        TEXT NOT NULL DEFAULT '',
+	config       TEXT NOT NULL DEFAULT '{}',
+	yaml         TEXT NOT NULL DEFAULT '',
+	exit_code    INTEGER,
+	signal       TEXT NOT NULL DEFAULT '',
+	oom_killed   INTEGER NOT NULL DEFAULT 0,
+	duration     REAL NOT NULL DEFAULT 0,
+	steps        TEXT NOT NULL DEFAULT '[]',
+	warnings     TEXT NOT NULL DEFAULT '[]'
+);
+CREATE INDEX IF NOT EXISTS pipelines_started_at ON pipelines (started_at);
+
+CREATE TABLE IF NOT EXISTS logs (
+	seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+	pipeline_id TEXT NOT NULL,
+	type        TEXT NOT NULL,
+	message     TEXT NOT NULL,
+	stage       TEXT NOT NULL DEFAULT '',
+	job         TEXT NOT NULL DEFAULT '',
+	step        TEXT NOT NULL DEFAULT '',
+	timestamp   INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS logs_pipeline_id ON logs (pipeline_id, seq);
+
+CREATE TABLE IF NOT EXISTS events (
+	seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+	pipeline_id TEXT NOT NULL,
+	data        TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS events_pipeline_id ON events (pipeline_id, seq);
+
+CREATE TABLE IF NOT EXISTS queue_items (
+	id         TEXT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	status     TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	started_at INTEGER,
+	yaml       TEXT NOT NULL DEFAULT '',
+	pipeline   TEXT NOT NULL,
+	params     TEXT NOT NULL DEFAULT '{}',
+	resume     INTEGER NOT NULL DEFAULT 0,
+	redacted   INTEGER NOT NULL DEFAULT 0
+);
+`
+
+// OpenSQLite 打开 SQLite 数据库，不存在时创建，SQLite 的 Store 和 Queue 共用
+func OpenSQLite(path string) (*sql.DB, error) {
+	if dir := filepath.Dir(path); !fs.IsExist(dir) {
+		if err := fs.Mkdirp(dir); err != nil {
+			return nil, fmt.Errorf("failed to create the directory of sqlite database: %s", err)
+		}
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database(path: %s): %s", path, err)
+	}
+
+	// SQLite 只支持单个写入者，串行化写入，避免 database is locked
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create sqlite tables(path: %s): %s", path, err)
+	}
+
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate sqlite tables(path: %s): %s", path, err)
+	}
+
+	return db, nil
+}
+
+// sqliteColumns 之后新增的列，打开旧的数据库时添加
+var sqliteColumns = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"logs", "stage", "TEXT NOT NULL DEFAULT ''"},
+	{"logs", "job", "TEXT NOT NULL DEFAULT ''"},
+	{"logs", "step", "TEXT NOT NULL DEFAULT ''"},
+	{"pipelines", "warnings", "TEXT NOT NULL DEFAULT '[]'"},
+	{"queue_items", "redacted", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+func migrateSQLite(db *sql.DB) error {
+	for _, c := range sqliteColumns {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, c.table, c.column).Scan(&count); err != nil {
+			return err
+		}
+
+		if count > 0 {
+			continue
+		}
+
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, c.table, c.column, c.definition)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 时间以 Unix 纳秒保存，便于排序
+func toUnixNano(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+func fromUnixNano(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+
+	t := time.Unix(0, v.Int64)
+	return &t
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/go-idp/pipeline"
+	"github.com/go-idp/pipeline/artifact"
+	"github.com/go-idp/pipeline/cache"
+	"github.com/go-idp/pipeline/secret"
+	"github.com/go-zoox/encoding/yaml"
+	"github.com/go-zoox/logger"
+)
+
+// sqliteQueue 将队列中未结束的项保存到 SQLite，结束后删除，记录由 Store 保存
+type sqliteQueue struct {
+	db      *sql.DB
+	workdir string
+}
+
+// NewSQLiteQueue 创建 SQLite 持久化的队列，
+//
+//	重启后 pending 的队列项按原顺序重新排队，running 的队列项随服务进程中断，标记为 interrupted
+func NewSQLiteQueue(db *sql.DB, maxConcurrent int, store Store, artifacts artifact.Store, caches cache.Store, secrets secret.Store, workdir string, environment map[string]string) (Queue, error) {
+	q, err := newSQLiteQueue(db, maxConcurrent, store, artifacts, caches, secrets, workdir, environment)
+	if err != nil {
+		return nil, err
+	}
+
+	// 启动队列处理器
+	go q.process()
+
+	return q, nil
+}
+
+func newSQLiteQueue(db *sql.DB, maxConcurrent int, store Store, artifacts artifact.Store, caches cache.Store, secrets secret.Store, workdir string, environment map[string]string) (*queue, error) {
+	q := newQueue(maxConcurrent, store, artifacts, caches, secrets, workdir, environment)
+	sq := &sqliteQueue{
+		db:      db,
+		workdir: workdir,
+	}
+
+	if err := sq.restore(q); err != nil {
+		return nil, err
+	}
+	q.onChange = sq.save
+
+	return q, nil
+}
+
+// save 保存变化的队列项，pending 时保存要执行的 pipeline，不含密钥参数，
+//
+//	明文的密码（例如 image_registry_password）被隐藏，这样的 pipeline 重启后不能重新排队
+func (sq *sqliteQueue) save(item *QueueItem) {
+	var err error
+	switch item.Status {
+	case "pending":
+		var data []byte
+		if data, err = yaml.Encode(item.Pipeline); err != nil {
+			break
+		}
+		config := item.Pipeline.Redact(string(data))
+
+		var params []byte
+		if params, err = json.Marshal(item.Pipeline.PersistedParams()); err != nil {
+			break
+		}
+
+		_, err = sq.db.Exec(
+			`INSERT OR REPLACE INTO queue_items (id, name, status, created_at, yaml, pipeline, params, resume, redacted) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			item.ID, item.Name, item.Status, toUnixNano(&item.CreatedAt), item.YAML, config, string(params), item.Resume != nil, config != string(data),
+		)
+	case "running":
+		_, err = sq.db.Exec(`UPDATE queue_items SET status = ?, started_at = ? WHERE id = ?`, item.Status, toUnixNano(item.StartedAt), item.ID)
+	default:
+		_, err = sq.db.Exec(`DELETE FROM queue_items WHERE id = ?`, item.ID)
+	}
+
+	if err != nil {
+		logger.Errorf("[queue] failed to save pipeline %s: %s", item.ID, err)
+	}
+}
+
+// restore 恢复上次运行时未结束的队列项
+func (sq *sqliteQueue) restore(q *queue) error {
+	rows, err := sq.db.Query(`SELECT id, name, status, created_at, yaml, pipeline, params, resume, redacted FROM queue_items ORDER BY created_at`)
+	if err != nil {
+		return fmt.Errorf("failed to read queue: %s", err)
+	}
+
+	type row struct {
+		item     *QueueItem
+		pipeline string
+		params   string
+		resume   bool
+		redacted bool
+	}
+
+	items := []*row{}
+	for rows.Next() {
+		r := &row{item: &QueueItem{}}
+		var createdAt int64
+		if err := rows.Scan(&r.item.ID, &r.item.Name, &r.item.Status, &createdAt, &r.item.YAML, &r.pipeline, &r.params, &r.resume, &r.redacted); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read queue: %s", err)
+		}
+
+		r.item.CreatedAt = time.Unix(0, createdAt)
+		items = append(items, r)
+	}
+	rows.Close()
+
+	for _, r := range items {
+		item := r.item
+
+		if item.Status == "pending" {
+			pl, state, err := sq.pipeline(item.ID, r.pipeline, r.params, r.resume, r.redacted)
+			if err == nil {
+				item.Pipeline = pl
+				item.Resume = state
+				q.items[item.ID] = item
+				q.pendingItems = append(q.pendingItems, item.ID)
+				logger.Infof("[queue] re-queued pipeline %s (name: %s)", item.ID, item.Name)
+				continue
+			}
+
+			logger.Errorf("[queue] failed to re-queue pipeline %s: %s", item.ID, err)
+			if q.store != nil {
+				q.store.UpdateStatus(item.ID, "failed", fmt.Errorf("failed to re-queue after the restart of the server: %s", err))
+			}
+		} else {
+			// 运行中的 pipeline 随服务进程中断，可通过 retry 从失败处继续
+			logger.Warnf("[queue] pipeline %s (name: %s) was interrupted by the restart of the server", item.ID, item.Name)
+			if q.store != nil {
+				q.store.UpdateStatus(item.ID, "interrupted", errInterrupted)
+			}
+		}
+
+		if _, err := sq.db.Exec(`DELETE FROM queue_items WHERE id = ?`, item.ID); err != nil {
+			return fmt.Errorf("failed to update queue: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// pipeline 解析保存的 pipeline，重试时从保留的工作目录恢复，
+//
+//	隐藏了明文密码的 pipeline 无法恢复，需通过 ${{ secrets.NAME }} 引用密码
+func (sq *sqliteQueue) pipeline(id, config, params string, resume, redacted bool) (*pipeline.Pipeline, *pipeline.RunState, error) {
+	if resume {
+		state, err := pipeline.ReadRunState(filepath.Join(sq.workdir, id))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pl, err := state.Pipeline()
+		return pl, state, err
+	}
+
+	if redacted {
+		return nil, nil, fmt.Errorf("the pipeline has passwords in plain text, which are not persisted, use ${{ secrets.NAME }} instead")
+	}
+
+	pl, err := pipeline.LoadYAML([]byte(config), "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := map[string]string{}
+	if err := json.Unmarshal([]byte(params), &values); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode params: %s", err)
+	}
+	pl.SetParams(values)
+
+	return pl, nil, nil
+}
@@ -0,0 +1,326 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-idp/pipeline/event"
+	"github.com/go-zoox/logger"
+)
+
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore 创建 SQLite 存储，记录在重启后保留，日志和事件保存在独立的表中，
+//
+//	上次运行时未结束的 pipeline 随服务进程中断，标记为 interrupted
+func NewSQLiteStore(db *sql.DB) (Store, error) {
+	s := &sqliteStore{
+		db: db,
+	}
+
+	now := time.Now()
+	if _, err := db.Exec(
+		`UPDATE pipelines SET status = 'interrupted', failed_at = ?, error = ? WHERE status = 'running'`,
+		toUnixNano(&now), errInterrupted.Error(),
+	); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *sqliteStore) Create(id, name string, config map[string]interface{}) *PipelineRecord {
+	return s.CreateWithYAML(id, name, "", config)
+}
+
+func (s *sqliteStore) CreateWithYAML(id, name, yaml string, config map[string]interface{}) *PipelineRecord {
+	record := &PipelineRecord{
+		ID:        id,
+		Name:      name,
+		Status:    "pending",
+		StartedAt: time.Now(),
+		Config:    config,
+		YAML:      yaml,
+		Logs:      make([]LogEntry, 0),
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		logger.Errorf("[store] failed to encode config of pipeline %s: %s", id, err)
+		configJSON = []byte("{}")
+	}
+
+	// 与内存存储一致，同一 ID 的记录被替换
+	s.exec("create", id, `DELETE FROM logs WHERE pipeline_id = ?`, id)
+	s.exec("create", id, `DELETE FROM events WHERE pipeline_id = ?`, id)
+	s.exec("create", id,
+		`INSERT OR REPLACE INTO pipelines (id, name, status, started_at, config, yaml) VALUES (?, ?, ?, ?, ?, ?)`,
+		id, name, record.Status, toUnixNano(&record.StartedAt), string(configJSON), yaml,
+	)
+
+	return record
+}
+
+func (s *sqliteStore) Get(id string) (*PipelineRecord, bool) {
+	rows, err := s.db.Query(sqliteRecordQuery+` WHERE id = ?`, id)
+	if err != nil {
+		logger.Errorf("[store] failed to get pipeline %s: %s", id, err)
+		return nil, false
+	}
+
+	records := s.scanRecords(rows)
+	if len(records) == 0 {
+		return nil, false
+	}
+
+	record := records[0]
+	record.Events = s.events(id)
+
+	return record, true
+}
+
+// List 列出记录，按时间倒序，不含日志和事件
+func (s *sqliteStore) List(limit int) []*PipelineRecord {
+	query := sqliteRecordQuery + ` ORDER BY started_at DESC`
+	args := []interface{}{}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		logger.Errorf("[store] failed to list pipelines: %s", err)
+		return []*PipelineRecord{}
+	}
+
+	return s.scanRecords(rows)
+}
+
+func (s *sqliteStore) UpdateStatus(id, status string, err error) {
+	at := time.Now()
+	now := toUnixNano(&at)
+
+	message := sql.NullString{}
+	if err != nil {
+		message = sql.NullString{String: err.Error(), Valid: true}
+	}
+
+	switch status {
+	case "succeeded":
+		s.exec("update status", id, `UPDATE pipelines SET status = ?, succeed_at = ? WHERE id = ?`, status, now, id)
+	case "failed", "interrupted":
+		s.exec("update status", id, `UPDATE pipelines SET status = ?, failed_at = ?, error = COALESCE(?, error) WHERE id = ?`, status, now, message, id)
+	case "cancelled":
+		s.exec("update status", id, `UPDATE pipelines SET status = ?, cancelled_at = ?, error = COALESCE(?, error) WHERE id = ?`, status, now, message, id)
+	default:
+		s.exec("update status", id, `UPDATE pipelines SET status = ? WHERE id = ?`, status, id)
+	}
+}
+
+func (s *sqliteStore) AddLog(id string, entry LogEntry) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	s.exec("add log", id,
+		`INSERT INTO logs (pipeline_id, type, message, stage, job, step, timestamp) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, entry.Type, entry.Message, entry.Stage, entry.Job, entry.Step, toUnixNano(&entry.Timestamp),
+	)
+}
+
+func (s *sqliteStore) SetResult(id string, result *RunResult) {
+	if result == nil {
+		return
+	}
+
+	steps, err := json.Marshal(result.Steps)
+	if err != nil {
+		logger.Errorf("[store] failed to encode steps of pipeline %s: %s", id, err)
+		steps = []byte("[]")
+	}
+
+	warnings, err := json.Marshal(result.Warnings)
+	if err != nil {
+		logger.Errorf("[store] failed to encode warnings of pipeline %s: %s", id, err)
+		warnings = []byte("[]")
+	}
+
+	s.exec("set result", id,
+		`UPDATE pipelines SET exit_code = ?, signal = ?, oom_killed = ?, duration = ?, steps = ?, warnings = ? WHERE id = ?`,
+		result.ExitCode, result.Signal, result.OOMKilled, result.Duration, string(steps), string(warnings), id,
+	)
+}
+
+func (s *sqliteStore) AddEvent(id string, e *event.Event) {
+	if e.Type == event.StepOutput {
+		return
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		logger.Errorf("[store] failed to encode event of pipeline %s: %s", id, err)
+		return
+	}
+
+	s.exec("add event", id, `INSERT INTO events (pipeline_id, data) VALUES (?, ?)`, id, string(data))
+}
+
+func (s *sqliteStore) Delete(id string) bool {
+	result, err := s.db.Exec(`DELETE FROM pipelines WHERE id = ?`, id)
+	if err != nil {
+		logger.Errorf("[store] failed to delete pipeline %s: %s", id, err)
+		return false
+	}
+
+	s.exec("delete", id, `DELETE FROM logs WHERE pipeline_id = ?`, id)
+	s.exec("delete", id, `DELETE FROM events WHERE pipeline_id = ?`, id)
+
+	affected, _ := result.RowsAffected()
+	return affected > 0
+}
+
+// exec 执行语句，Store 接口不返回错误，失败时记录日志
+func (s *sqliteStore) exec(action, id, query string, args ...interface{}) {
+	if _, err := s.db.Exec(query, args...); err != nil {
+		logger.Errorf("[store] failed to %s of pipeline %s: %s", action, id, err)
+	}
+}
+
+const sqliteRecordQuery = `SELECT id, name, status, started_at, succeed_at, failed_at, cancelled_at, error, config, yaml, exit_code, signal, oom_killed, duration, steps, warnings FROM pipelines`
+
+func (s *sqliteStore) scanRecords(rows *sql.Rows) []*PipelineRecord {
+	defer rows.Close()
+
+	records := []*PipelineRecord{}
+	for rows.Next() {
+		record := &PipelineRecord{}
+		var startedAt int64
+		var succeedAt, failedAt, cancelledAt, exitCode sql.NullInt64
+		var config, steps, warnings string
+		if err := rows.Scan(
+			&record.ID, &record.Name, &record.Status,
+			&startedAt, &succeedAt, &failedAt, &cancelledAt,
+			&record.Error, &config, &record.YAML,
+			&exitCode, &record.Signal, &record.OOMKilled, &record.Duration, &steps, &warnings,
+		); err != nil {
+			logger.Errorf("[store] failed to read pipeline: %s", err)
+			continue
+		}
+
+		record.StartedAt = time.Unix(0, startedAt)
+		record.SucceedAt = fromUnixNano(succeedAt)
+		record.FailedAt = fromUnixNano(failedAt)
+		record.CancelledAt = fromUnixNano(cancelledAt)
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			record.ExitCode = &code
+		}
+
+		if err := json.Unmarshal([]byte(config), &record.Config); err != nil {
+			logger.Warnf("[store] failed to decode config of pipeline %s: %s", record.ID, err)
+		}
+		if err := json.Unmarshal([]byte(steps), &record.Steps); err != nil {
+			logger.Warnf("[store] failed to decode steps of pipeline %s: %s", record.ID, err)
+		}
+		if err := json.Unmarshal([]byte(warnings), &record.Warnings); err != nil {
+			logger.Warnf("[store] failed to decode warnings of pipeline %s: %s", record.ID, err)
+		}
+
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// sqliteLogBatch 每次查询的日志数量，回调前释放连接
+const sqliteLogBatch = 1000
+
+// ReadLogs 分批读取日志，cursor 为日志的序号
+func (s *sqliteStore) ReadLogs(id string, line int, cursor int64, fn func(index int, next int64, entry *LogEntry) bool) (int, error) {
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM logs WHERE pipeline_id = ?`, id).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to read logs of pipeline %s: %s", id, err)
+	}
+
+	index, skip := line, line
+	if cursor > 0 {
+		skip = 0
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM logs WHERE pipeline_id = ? AND seq <= ?`, id, cursor).Scan(&index); err != nil {
+			return 0, fmt.Errorf("failed to read logs of pipeline %s: %s", id, err)
+		}
+	}
+
+	type row struct {
+		seq   int64
+		entry LogEntry
+	}
+
+	for {
+		rows, err := s.db.Query(
+			`SELECT seq, type, message, stage, job, step, timestamp FROM logs WHERE pipeline_id = ? AND seq > ? ORDER BY seq LIMIT ? OFFSET ?`,
+			id, cursor, sqliteLogBatch, skip,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read logs of pipeline %s: %s", id, err)
+		}
+
+		batch := make([]row, 0, sqliteLogBatch)
+		for rows.Next() {
+			var r row
+			var timestamp int64
+			if err := rows.Scan(&r.seq, &r.entry.Type, &r.entry.Message, &r.entry.Stage, &r.entry.Job, &r.entry.Step, &timestamp); err != nil {
+				rows.Close()
+				return 0, fmt.Errorf("failed to read logs of pipeline %s: %s", id, err)
+			}
+
+			r.entry.Timestamp = time.Unix(0, timestamp)
+			batch = append(batch, r)
+		}
+		rows.Close()
+
+		for i := range batch {
+			if !fn(index, batch[i].seq, &batch[i].entry) {
+				return total, nil
+			}
+			index++
+		}
+
+		if len(batch) < sqliteLogBatch {
+			return total, nil
+		}
+
+		cursor, skip = batch[len(batch)-1].seq, 0
+	}
+}
+
+func (s *sqliteStore) events(id string) []*event.Event {
+	events := []*event.Event{}
+
+	rows, err := s.db.Query(`SELECT data FROM events WHERE pipeline_id = ? ORDER BY seq`, id)
+	if err != nil {
+		logger.Errorf("[store] failed to read events of pipeline %s: %s", id, err)
+		return events
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			logger.Errorf("[store] failed to read events of pipeline %s: %s", id, err)
+			break
+		}
+
+		e := &event.Event{}
+		if err := json.Unmarshal([]byte(data), e); err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+
+	return events
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-idp/pipeline"
+)
+
+func openTestSQLite(t *testing.T, path string) (*sql.DB, Store) {
+	t.Helper()
+
+	db, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("OpenSQLite() error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store, err := NewSQLiteStore(db)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore() error: %v", err)
+	}
+
+	return db, store
+}
+
+func loadTestPipeline(t *testing.T, config string, params map[string]string) *pipeline.Pipeline {
+	t.Helper()
+
+	pl, err := pipeline.LoadYAML([]byte(config), "")
+	if err != nil {
+		t.Fatalf("Failed to load pipeline: %v", err)
+	}
+	pl.SetParams(params)
+
+	return pl
+}
+
+func TestSQLiteQueueRestore(t *testing.T) {
+	workdir := t.TempDir()
+	path := filepath.Join(workdir, ".pipeline.db")
+
+	db, store := openTestSQLite(t, path)
+	q, err := newSQLiteQueue(db, 1, store, nil, nil, nil, workdir, nil)
+	if err != nil {
+		t.Fatalf("newSQLiteQueue() error: %v", err)
+	}
+
+	withParams := `
+name: with params
+parameters:
+  - name: version
+  - name: token
+    type: secret
+stages:
+  - name: build
+    jobs:
+      - name: build
+        steps:
+          - name: echo
+            command: echo ${{ params.version }}
+`
+	withPassword := `
+name: with password
+stages:
+  - name: build
+    jobs:
+      - name: build
+        image: registry.example.com/build
+        image_registry_password: s3cr3t-pa55
+        steps:
+          - name: echo
+            command: echo build
+`
+	plain := `
+name: plain
+stages:
+  - name: build
+    jobs:
+      - name: build
+        steps:
+          - name: echo
+            command: echo build
+`
+
+	for _, item := range []struct {
+		id     string
+		config string
+		params map[string]string
+	}{
+		{"running", plain, nil},
+		{"second", withParams, map[string]string{"version": "1.0.0", "token": "xyz"}},
+		{"password", withPassword, nil},
+		{"third", plain, nil},
+	} {
+		pl := loadTestPipeline(t, item.config, item.params)
+		if err := q.EnqueueWithYAML(item.id, pl.Name, pl, ""); err != nil {
+			t.Fatalf("Enqueue(%s) error: %v", item.id, err)
+		}
+	}
+
+	// the first run is running when the server stops
+	if item, ok := q.Dequeue(); !ok || item.ID != "running" {
+		t.Fatalf("Expected the first run to be dequeued, got %v", item)
+	}
+	store.UpdateStatus("running", "running", nil)
+
+	var config string
+	if err := db.QueryRow(`SELECT pipeline FROM queue_items WHERE id = ?`, "password").Scan(&config); err != nil {
+		t.Fatalf("Failed to read queue item: %v", err)
+	}
+	if strings.Contains(config, "s3cr3t-pa55") {
+		t.Errorf("Expected the password not to be persisted, got:\n%s", config)
+	}
+
+	var params string
+	if err := db.QueryRow(`SELECT params FROM queue_items WHERE id = ?`, "second").Scan(&params); err != nil {
+		t.Fatalf("Failed to read queue item: %v", err)
+	}
+	if strings.Contains(params, "xyz") {
+		t.Errorf("Expected the secret parameter not to be persisted, got %s", params)
+	}
+	db.Close()
+
+	// restart
+	db, store = openTestSQLite(t, path)
+	q, err = newSQLiteQueue(db, 1, store, nil, nil, nil, workdir, nil)
+	if err != nil {
+		t.Fatalf("newSQLiteQueue() error: %v", err)
+	}
+
+	if want := []string{"second", "third"}; !reflect.DeepEqual(q.pendingItems, want) {
+		t.Errorf("Expected pending %v, got %v", want, q.pendingItems)
+	}
+
+	second, ok := q.Get("second")
+	if !ok {
+		t.Fatal("Expected the second run to be re-queued")
+	}
+	if want := map[string]string{"version": "1.0.0"}; !reflect.DeepEqual(second.Pipeline.Params(), want) {
+		t.Errorf("Expected params %v, got %v", want, second.Pipeline.Params())
+	}
+
+	if record, _ := store.Get("running"); record == nil || record.Status != "interrupted" {
+		t.Errorf("Expected the running run to be interrupted, got %+v", record)
+	}
+
+	record, _ := store.Get("password")
+	if record == nil || record.Status != "failed" || !strings.Contains(record.Error, "secrets.NAME") {
+		t.Errorf("Expected the run with a password to fail, got %+v", record)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM queue_items`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count queue items: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 queue items, got %d", count)
+	}
+}
+
+func TestSQLiteStoreReadLogs(t *testing.T) {
+	_, store := openTestSQLite(t, filepath.Join(t.TempDir(), ".pipeline.db"))
+
+	const total = sqliteLogBatch*2 + 500
+	store.Create("run", "test", nil)
+	for i := 0; i < total; i++ {
+		store.AddLog("run", LogEntry{Type: "stdout", Message: fmt.Sprintf("line %d\n", i)})
+	}
+
+	type page struct {
+		index   []int
+		message []string
+		next    int64
+	}
+
+	read := func(line int, cursor int64, limit int) (page, int) {
+		var p page
+		n, err := store.ReadLogs("run", line, cursor, func(index int, next int64, entry *LogEntry) bool {
+			p.index = append(p.index, index)
+			p.message = append(p.message, entry.Message)
+			p.next = next
+			return len(p.index) < limit
+		})
+		if err != nil {
+			t.Fatalf("ReadLogs() error: %v", err)
+		}
+
+		return p, n
+	}
+
+	tests := []struct {
+		name  string
+		line  int
+		limit int
+		first int
+	}{
+		{"from the start", 0, 3, 0},
+		{"by line inside a batch", 1200, 3, 1200},
+		{"by line across batches", sqliteLogBatch - 1, 3, sqliteLogBatch - 1},
+		{"all lines", 0, total + 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, n := read(tt.line, 0, tt.limit)
+			if n != total {
+				t.Errorf("Expected total %d, got %d", total, n)
+			}
+
+			want := tt.limit
+			if want > total-tt.first {
+				want = total - tt.first
+			}
+			if len(p.index) != want {
+				t.Fatalf("Expected %d lines, got %d", want, len(p.index))
+			}
+
+			for i, index := range p.index {
+				if index != tt.first+i || p.message[i] != fmt.Sprintf("line %d\n", index) {
+					t.Fatalf("Expected line %d, got %d: %q", tt.first+i, index, p.message[i])
+				}
+			}
+		})
+	}
+
+	t.Run("by cursor", func(t *testing.T) {
+		// page through all logs by cursor, no line is skipped or repeated
+		line, cursor := 0, int64(0)
+		for line < total {
+			p, _ := read(0, cursor, 700)
+			if len(p.index) == 0 {
+				t.Fatalf("Expected lines after cursor %d", cursor)
+			}
+
+			for i, index := range p.index {
+				if index != line+i || p.message[i] != fmt.Sprintf("line %d\n", index) {
+					t.Fatalf("Expected line %d after cursor %d, got %d: %q", line+i, cursor, index, p.message[i])
+				}
+			}
+
+			line += len(p.index)
+			cursor = p.next
+		}
+
+		if p, _ := read(0, cursor, 10); len(p.index) != 0 {
+			t.Errorf("Expected no lines after the last cursor, got %v", p.index)
+		}
+	})
+}
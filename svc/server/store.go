@@ -2,32 +2,117 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/go-idp/pipeline"
+	"github.com/go-idp/pipeline/event"
 	"github.com/go-zoox/fs"
+	"github.com/go-zoox/logger"
 )
 
 // PipelineRecord 记录 pipeline 执行信息
 type PipelineRecord struct {
 	ID          string                 `json:"id"`
 	Name        string                 `json:"name"`
-	Status      string                 `json:"status"` // pending | running | succeeded | failed | cancelled
+	Status      string                 `json:"status"` // pending | running | succeeded | failed | cancelled | interrupted
 	StartedAt   time.Time              `json:"started_at"`
 	SucceedAt   *time.Time             `json:"succeed_at,omitempty"`
 	FailedAt    *time.Time             `json:"failed_at,omitempty"`
 	CancelledAt *time.Time             `json:"cancelled_at,omitempty"`
 	Error       string                 `json:"error,omitempty"`
 	Config      map[string]interface{} `json:"config,omitempty"`
-	YAML        string                 `json:"yaml,omitempty"` // 完整的 pipeline YAML 配置
-	Logs        []LogEntry             `json:"logs,omitempty"`
+	YAML        string                 `json:"yaml,omitempty"`   // 完整的 pipeline YAML 配置
+	Logs        []LogEntry             `json:"logs,omitempty"`   // 旧版本的记录中保存的日志，日志通过 Store.ReadLogs 读取
+	Events      []*event.Event         `json:"events,omitempty"` // stage、job、step 的生命周期事件，不含输出行
+	// 执行结果，成功时退出码为 0，失败时为失败 step 的退出码、信号，-1 表示未知
+	ExitCode  *int          `json:"exit_code,omitempty"`
+	Signal    string        `json:"signal,omitempty"`
+	OOMKilled bool          `json:"oom_killed,omitempty"`
+	Duration  float64       `json:"duration,omitempty"` // 单位：秒
+	Steps     []*StepResult `json:"steps,omitempty"`
+	// 成功但有允许的失败时为这些失败，例如 check/lint
+	Warnings []string `json:"warnings,omitempty"`
 }
 
-// LogEntry 日志条目
+// RunResult pipeline 的执行结果
+type RunResult struct {
+	ExitCode  int
+	Signal    string
+	OOMKilled bool
+	Duration  float64
+	Steps     []*StepResult
+	Warnings  []string
+}
+
+// StepResult step 的执行结果
+type StepResult struct {
+	ID        string  `json:"id"`
+	Stage     string  `json:"stage"`
+	Job       string  `json:"job"`
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	ExitCode  int     `json:"exit_code"`
+	Signal    string  `json:"signal,omitempty"`
+	OOMKilled bool    `json:"oom_killed,omitempty"`
+	Duration  float64 `json:"duration"` // 单位：秒
+}
+
+// NewRunResult 从 pipeline 的运行状态生成执行结果，未运行时返回 nil
+func NewRunResult(p *pipeline.Pipeline) *RunResult {
+	report, err := p.Report()
+	if err != nil {
+		return nil
+	}
+
+	result := &RunResult{
+		ExitCode:  report.ExitCode,
+		Signal:    report.Signal,
+		OOMKilled: report.OOMKilled,
+		Duration:  report.Duration,
+	}
+	if report.HasWarnings {
+		result.Warnings = report.Warnings
+	}
+	for _, stages := range [][]*pipeline.ReportStage{report.Stages, report.OnSuccess, report.OnFailure, report.Finally} {
+		for _, s := range stages {
+			for _, j := range s.Jobs {
+				for _, st := range j.Steps {
+					result.Steps = append(result.Steps, &StepResult{
+						ID:        st.ID,
+						Stage:     s.Name,
+						Job:       j.Name,
+						Name:      st.Name,
+						Status:    st.Status,
+						Error:     st.Error,
+						ExitCode:  st.ExitCode,
+						Signal:    st.Signal,
+						OOMKilled: st.OOMKilled,
+						Duration:  st.Duration,
+					})
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// errInterrupted 服务重启时仍在运行的 pipeline 的错误，状态为 interrupted
+var errInterrupted = errors.New("interrupted by the restart of the server")
+
+// LogEntry 日志条目，按行记录
 type LogEntry struct {
-	Type      string    `json:"type"` // stdout | stderr | status
-	Message   string    `json:"message"`
+	Type    string `json:"type"` // stdout | stderr | status
+	Message string `json:"message"`
+	// Stage、Job 和 Step 为产生日志的 stage、job 和 step 的 State.ID，例如 <id>.0.1.2，pipeline 自身的日志为空
+	Stage     string    `json:"stage,omitempty"`
+	Job       string    `json:"job,omitempty"`
+	Step      string    `json:"step,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
 }
 
@@ -43,8 +128,15 @@ type Store interface {
 	List(limit int) []*PipelineRecord
 	// UpdateStatus 更新 pipeline 状态
 	UpdateStatus(id, status string, err error)
-	// AddLog 添加日志
-	AddLog(id string, logType, message string)
+	// AddLog 添加日志，未设置时间时使用当前时间
+	AddLog(id string, entry LogEntry)
+	// ReadLogs 按顺序读取日志，从第 line 行开始，cursor 大于 0 时从该位置之后开始，
+	//	依次调用 fn，index 为日志的行号，next 为下一页的位置，fn 返回 false 时停止，返回日志的总行数
+	ReadLogs(id string, line int, cursor int64, fn func(index int, next int64, entry *LogEntry) bool) (int, error)
+	// SetResult 设置执行结果
+	SetResult(id string, result *RunResult)
+	// AddEvent 添加事件，输出行（step.output）已作为日志记录，不再保存
+	AddEvent(id string, e *event.Event)
 	// Delete 删除 pipeline 记录
 	Delete(id string) bool
 }
@@ -54,15 +146,22 @@ type memoryStore struct {
 	records map[string]*PipelineRecord
 	maxSize int
 	workdir string
+	// logs 日志文件，日志不保存在记录中，没有 workdir 时为空，日志保存在内存中
+	logs *logFiles
 }
 
-// NewMemoryStore 创建内存存储
+// NewMemoryStore 创建内存存储，日志保存在 <workdir>/.pipeline_logs 下，pipeline 结束后压缩
 func NewMemoryStore(workdir string, maxSize int) Store {
-	return &memoryStore{
+	s := &memoryStore{
 		records: make(map[string]*PipelineRecord),
 		maxSize: maxSize,
 		workdir: workdir,
 	}
+	if workdir != "" {
+		s.logs = newLogFiles(filepath.Join(workdir, ".pipeline_logs"))
+	}
+
+	return s
 }
 
 func (s *memoryStore) Create(id, name string, config map[string]interface{}) *PipelineRecord {
@@ -89,6 +188,7 @@ func (s *memoryStore) CreateWithYAML(id, name, yaml string, config map[string]in
 	}
 
 	s.records[id] = record
+	s.deleteLogs(id)
 
 	// 保存到文件（可选）
 	s.saveToFile(id, record)
@@ -98,9 +198,9 @@ func (s *memoryStore) CreateWithYAML(id, name, yaml string, config map[string]in
 
 func (s *memoryStore) Get(id string) (*PipelineRecord, bool) {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
-
 	record, ok := s.records[id]
+	s.mu.RUnlock()
+
 	if !ok {
 		// 尝试从文件加载
 		return s.loadFromFile(id)
@@ -135,6 +235,15 @@ func (s *memoryStore) List(limit int) []*PipelineRecord {
 }
 
 func (s *memoryStore) UpdateStatus(id, status string, err error) {
+	// 结束时压缩日志文件，在解锁之后
+	if finished(status) && s.logs != nil {
+		defer func() {
+			if err := s.logs.Close(id); err != nil {
+				logger.Errorf("[store] %s", err)
+			}
+		}()
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -149,7 +258,7 @@ func (s *memoryStore) UpdateStatus(id, status string, err error) {
 	switch status {
 	case "succeeded":
 		record.SucceedAt = &now
-	case "failed":
+	case "failed", "interrupted":
 		record.FailedAt = &now
 		if err != nil {
 			record.Error = err.Error()
@@ -164,7 +273,26 @@ func (s *memoryStore) UpdateStatus(id, status string, err error) {
 	s.saveToFile(id, record)
 }
 
-func (s *memoryStore) AddLog(id string, logType, message string) {
+func (s *memoryStore) AddLog(id string, entry LogEntry) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	// 追加到日志文件，不重写记录
+	if s.logs != nil {
+		s.mu.RLock()
+		_, ok := s.records[id]
+		s.mu.RUnlock()
+		if !ok {
+			return
+		}
+
+		if err := s.logs.Append(id, &entry); err != nil {
+			logger.Errorf("[store] failed to add log of pipeline %s: %s", id, err)
+		}
+		return
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -173,12 +301,6 @@ func (s *memoryStore) AddLog(id string, logType, message string) {
 		return
 	}
 
-	entry := LogEntry{
-		Type:      logType,
-		Message:   message,
-		Timestamp: time.Now(),
-	}
-
 	record.Logs = append(record.Logs, entry)
 
 	// 限制日志数量，避免内存溢出
@@ -189,6 +311,81 @@ func (s *memoryStore) AddLog(id string, logType, message string) {
 	s.saveToFile(id, record)
 }
 
+// ReadLogs 从日志文件读取日志，cursor 为日志文件中的字节偏移，旧版本的记录从记录中读取
+func (s *memoryStore) ReadLogs(id string, line int, cursor int64, fn func(index int, next int64, entry *LogEntry) bool) (int, error) {
+	record, ok := s.Get(id)
+	if !ok {
+		return 0, fmt.Errorf("pipeline not found")
+	}
+
+	if s.logs != nil && s.logs.Exists(id) {
+		return s.logs.Read(id, line, cursor, fn)
+	}
+
+	s.mu.RLock()
+	logs := record.Logs
+	s.mu.RUnlock()
+
+	// 在内存中时，位置为下一条日志的行号
+	if cursor > 0 {
+		line = int(cursor)
+	}
+	for index := line; index < len(logs); index++ {
+		if !fn(index, int64(index+1), &logs[index]) {
+			break
+		}
+	}
+
+	return len(logs), nil
+}
+
+func (s *memoryStore) SetResult(id string, result *RunResult) {
+	if result == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[id]
+	if !ok {
+		return
+	}
+
+	exitCode := result.ExitCode
+	record.ExitCode = &exitCode
+	record.Signal = result.Signal
+	record.OOMKilled = result.OOMKilled
+	record.Duration = result.Duration
+	record.Steps = result.Steps
+	record.Warnings = result.Warnings
+
+	s.saveToFile(id, record)
+}
+
+func (s *memoryStore) AddEvent(id string, e *event.Event) {
+	if e.Type == event.StepOutput {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[id]
+	if !ok {
+		return
+	}
+
+	record.Events = append(record.Events, e)
+
+	// 限制事件数量，避免内存溢出
+	if len(record.Events) > 10000 {
+		record.Events = record.Events[len(record.Events)-10000:]
+	}
+
+	s.saveToFile(id, record)
+}
+
 func (s *memoryStore) Delete(id string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -201,6 +398,7 @@ func (s *memoryStore) Delete(id string) bool {
 
 	// 删除文件
 	s.deleteFile(id)
+	s.deleteLogs(id)
 
 	return true
 }
@@ -221,6 +419,7 @@ func (s *memoryStore) evictOldest() {
 	if oldestID != "" {
 		delete(s.records, oldestID)
 		s.deleteFile(oldestID)
+		s.deleteLogs(oldestID)
 	}
 }
 
@@ -282,3 +481,13 @@ func (s *memoryStore) deleteFile(id string) {
 		fs.RemoveFile(filepath)
 	}
 }
+
+func (s *memoryStore) deleteLogs(id string) {
+	if s.logs == nil {
+		return
+	}
+
+	if err := s.logs.Delete(id); err != nil {
+		logger.Errorf("[store] failed to delete logs of pipeline %s: %s", id, err)
+	}
+}
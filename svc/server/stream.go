@@ -0,0 +1,331 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-idp/pipeline/svc/action"
+)
+
+// StreamStore 支持订阅日志的存储，用于 SSE 和 websocket attach 实时推送日志
+type StreamStore interface {
+	Store
+	// Subscribe 从 cursor 开始订阅日志，已结束的 pipeline 只返回已有的日志和最终状态
+	Subscribe(id string, cursor int) (*LogSubscription, error)
+}
+
+// LogSubscription 日志订阅
+type LogSubscription struct {
+	// Logs 订阅时已有的日志，第一条的序号为 Cursor
+	Logs   []LogEntry
+	Cursor int
+	// Messages 新的日志，以及最终状态，之后关闭；订阅者过慢时直接关闭，需从最后的序号重新订阅
+	Messages <-chan *LogMessage
+
+	close func()
+}
+
+// LogMessage 新的日志，或者 pipeline 结束时的最终状态
+type LogMessage struct {
+	Index  int
+	Entry  *LogEntry
+	Status *action.RunStatus
+}
+
+// Close 取消订阅
+func (s *LogSubscription) Close() {
+	if s.close != nil {
+		s.close()
+	}
+}
+
+// logSubscriber 订阅者，缓冲满时关闭
+type logSubscriber struct {
+	messages chan *LogMessage
+	closed   bool
+}
+
+type streamStore struct {
+	Store
+
+	// mu 只保护 streams，日志的写入和推送持有每次运行各自的锁
+	mu      sync.Mutex
+	streams map[string]*logStream
+	// maxLogSize 每次运行的日志的最大字节数，超过后丢弃之后的日志，0 表示不限制
+	maxLogSize int64
+}
+
+// logStream 运行中的日志流，写入日志、推送和订阅按运行加锁，不同运行之间互不阻塞
+type logStream struct {
+	mu          sync.Mutex
+	subscribers map[*logSubscriber]bool
+	// seq 下一条日志的序号，ready 为 false 时从存储中读取
+	seq   int
+	ready bool
+	// size 日志的大小，dropped 超过限制后丢弃的行数
+	size    int64
+	dropped int
+	// removed 运行结束后移除，持有旧的日志流时需重新获取
+	removed bool
+}
+
+// NewStreamStore 包装存储，日志写入时推送给订阅者，
+//
+//	每次运行的日志超过 maxLogSize 字节后丢弃之后的日志，并记录截断标记，0 表示不限制
+func NewStreamStore(store Store, maxLogSize int64) StreamStore {
+	return &streamStore{
+		Store:      store,
+		streams:    make(map[string]*logStream),
+		maxLogSize: maxLogSize,
+	}
+}
+
+// lock 返回运行的日志流并加锁
+func (s *streamStore) lock(id string) *logStream {
+	for {
+		s.mu.Lock()
+		st, ok := s.streams[id]
+		if !ok {
+			st = &logStream{subscribers: make(map[*logSubscriber]bool)}
+			s.streams[id] = st
+		}
+		s.mu.Unlock()
+
+		st.mu.Lock()
+		if !st.removed {
+			return st
+		}
+		st.mu.Unlock()
+	}
+}
+
+// remove 移除运行的日志流，需持有日志流的锁
+func (s *streamStore) remove(id string, st *logStream) {
+	st.removed = true
+
+	s.mu.Lock()
+	if s.streams[id] == st {
+		delete(s.streams, id)
+	}
+	s.mu.Unlock()
+}
+
+// sequence 返回下一条日志的序号，第一次从存储中读取日志的总数，需持有日志流的锁
+func (s *streamStore) sequence(id string, st *logStream) int {
+	if !st.ready {
+		st.seq, _ = s.Store.ReadLogs(id, 0, 0, func(index int, next int64, entry *LogEntry) bool {
+			return false
+		})
+		st.ready = true
+	}
+
+	return st.seq
+}
+
+func (s *streamStore) Create(id, name string, config map[string]interface{}) *PipelineRecord {
+	return s.CreateWithYAML(id, name, "", config)
+}
+
+func (s *streamStore) CreateWithYAML(id, name, yaml string, config map[string]interface{}) *PipelineRecord {
+	st := s.lock(id)
+	defer st.mu.Unlock()
+
+	st.size, st.dropped, st.ready = 0, 0, false
+	return s.Store.CreateWithYAML(id, name, yaml, config)
+}
+
+func (s *streamStore) AddLog(id string, entry LogEntry) {
+	st := s.lock(id)
+	defer st.mu.Unlock()
+
+	if s.maxLogSize > 0 {
+		if st.dropped > 0 || st.size+int64(len(entry.Message)) > s.maxLogSize {
+			if st.dropped == 0 {
+				s.add(id, st, LogEntry{
+					Type:    "status",
+					Message: fmt.Sprintf("log truncated: the logs of the run exceed the limit of %d bytes, the following logs are dropped\n", s.maxLogSize),
+				})
+			}
+
+			st.dropped++
+			return
+		}
+
+		st.size += int64(len(entry.Message))
+	}
+
+	s.add(id, st, entry)
+}
+
+// add 写入日志，并推送给订阅者，需持有日志流的锁
+func (s *streamStore) add(id string, st *logStream, entry LogEntry) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	index := s.sequence(id, st)
+	s.Store.AddLog(id, entry)
+	st.seq++
+
+	for sub := range st.subscribers {
+		s.send(st, sub, &LogMessage{Index: index, Entry: &entry})
+	}
+}
+
+func (s *streamStore) UpdateStatus(id, status string, err error) {
+	st := s.lock(id)
+	defer st.mu.Unlock()
+
+	// 结束时记录丢弃的日志行数，并移除日志流，下次运行（例如重试）重新计算大小
+	if finished(status) {
+		if st.dropped > 0 {
+			s.add(id, st, LogEntry{
+				Type:    "status",
+				Message: fmt.Sprintf("log truncated: %d lines are dropped\n", st.dropped),
+			})
+		}
+		defer s.remove(id, st)
+	}
+
+	s.Store.UpdateStatus(id, status, err)
+
+	if !finished(status) || len(st.subscribers) == 0 {
+		return
+	}
+
+	final := &action.RunStatus{Status: status}
+	if record, ok := s.Store.Get(id); ok {
+		final = recordStatus(record)
+	} else if err != nil {
+		final.Error = err.Error()
+	}
+
+	index := s.sequence(id, st)
+	for sub := range st.subscribers {
+		s.send(st, sub, &LogMessage{Index: index, Status: final})
+		s.unsubscribe(st, sub)
+	}
+}
+
+func (s *streamStore) Subscribe(id string, cursor int) (*LogSubscription, error) {
+	st := s.lock(id)
+
+	record, ok := s.Store.Get(id)
+	if !ok {
+		s.remove(id, st)
+		st.mu.Unlock()
+		return nil, fmt.Errorf("pipeline not found")
+	}
+
+	// 在锁内确定订阅时的序号，之前的日志在锁外读取，之后的日志推送给订阅者，没有遗漏和重复
+	total := s.sequence(id, st)
+	sub := &logSubscriber{messages: make(chan *LogMessage, 1024)}
+
+	if finished(record.Status) {
+		sub.messages <- &LogMessage{Index: total, Status: recordStatus(record)}
+		close(sub.messages)
+		sub.closed = true
+		s.remove(id, st)
+	} else {
+		st.subscribers[sub] = true
+	}
+	st.mu.Unlock()
+
+	if cursor < 0 {
+		cursor = 0
+	}
+	if cursor > total {
+		cursor = total
+	}
+
+	logs := []LogEntry{}
+	if cursor < total {
+		if _, err := s.Store.ReadLogs(id, cursor, 0, func(index int, next int64, entry *LogEntry) bool {
+			if index >= total {
+				return false
+			}
+
+			logs = append(logs, *entry)
+			return true
+		}); err != nil {
+			st.mu.Lock()
+			s.unsubscribe(st, sub)
+			st.mu.Unlock()
+			return nil, err
+		}
+	}
+
+	subscription := &LogSubscription{
+		Logs:     logs,
+		Cursor:   cursor,
+		Messages: sub.messages,
+	}
+	if !finished(record.Status) {
+		subscription.close = func() {
+			st.mu.Lock()
+			defer st.mu.Unlock()
+
+			s.unsubscribe(st, sub)
+		}
+	}
+
+	return subscription, nil
+}
+
+// send 推送消息，订阅者过慢时取消订阅，需持有日志流的锁
+func (s *streamStore) send(st *logStream, sub *logSubscriber, msg *LogMessage) {
+	select {
+	case sub.messages <- msg:
+	default:
+		s.unsubscribe(st, sub)
+	}
+}
+
+// unsubscribe 取消订阅，需持有日志流的锁
+func (s *streamStore) unsubscribe(st *logStream, sub *logSubscriber) {
+	if sub.closed {
+		return
+	}
+
+	sub.closed = true
+	close(sub.messages)
+
+	delete(st.subscribers, sub)
+}
+
+// writeSSE 写入 SSE 事件，id 为日志的序号，小于 0 时不写入
+func writeSSE(w io.Writer, name string, id int, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+
+	if id >= 0 {
+		fmt.Fprintf(w, "id: %d\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
+}
+
+// finished 是否为最终状态
+func finished(status string) bool {
+	return status == "succeeded" || status == "failed" || status == "cancelled" || status == "interrupted"
+}
+
+// recordStatus 返回记录的最终状态
+func recordStatus(record *PipelineRecord) *action.RunStatus {
+	status := &action.RunStatus{
+		Status:    record.Status,
+		Error:     record.Error,
+		Signal:    record.Signal,
+		OOMKilled: record.OOMKilled,
+		Warnings:  record.Warnings,
+	}
+	if record.ExitCode != nil {
+		status.ExitCode = *record.ExitCode
+	}
+
+	return status
+}
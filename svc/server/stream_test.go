@@ -0,0 +1,377 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestStreamStore(t *testing.T, maxLogSize int64) *streamStore {
+	t.Helper()
+
+	return NewStreamStore(NewMemoryStore(t.TempDir(), 10), maxLogSize).(*streamStore)
+}
+
+func addLines(s StreamStore, id string, from, to int) {
+	for i := from; i < to; i++ {
+		s.AddLog(id, LogEntry{Type: "stdout", Message: fmt.Sprintf("line %d\n", i)})
+	}
+}
+
+// drain reads the messages until the subscription is closed
+func drain(sub *LogSubscription) []*LogMessage {
+	messages := []*LogMessage{}
+	for msg := range sub.Messages {
+		messages = append(messages, msg)
+	}
+
+	return messages
+}
+
+// follow reads the logs of the run until its final status, subscribing again from the last index when it is dropped
+func follow(s StreamStore, id string) ([]string, error) {
+	lines := []string{}
+	for {
+		sub, err := s.Subscribe(id, len(lines))
+		if err != nil {
+			return nil, err
+		}
+
+		if sub.Cursor != len(lines) {
+			return nil, fmt.Errorf("expected cursor %d, got %d", len(lines), sub.Cursor)
+		}
+		for _, entry := range sub.Logs {
+			lines = append(lines, entry.Message)
+		}
+
+		for msg := range sub.Messages {
+			if msg.Index != len(lines) {
+				return nil, fmt.Errorf("expected index %d, got %d", len(lines), msg.Index)
+			}
+
+			if msg.Status != nil {
+				return lines, nil
+			}
+			lines = append(lines, msg.Entry.Message)
+		}
+	}
+}
+
+func TestStreamStoreSubscribe(t *testing.T) {
+	t.Run("replay from cursor then follow", func(t *testing.T) {
+		s := newTestStreamStore(t, 0)
+		s.Create("run", "test", nil)
+		s.UpdateStatus("run", "running", nil)
+		addLines(s, "run", 0, 5)
+
+		sub, err := s.Subscribe("run", 2)
+		if err != nil {
+			t.Fatalf("Subscribe() error: %v", err)
+		}
+
+		if sub.Cursor != 2 || len(sub.Logs) != 3 || sub.Logs[0].Message != "line 2\n" {
+			t.Fatalf("Expected the logs from line 2, got cursor %d and %v", sub.Cursor, sub.Logs)
+		}
+
+		addLines(s, "run", 5, 7)
+		s.UpdateStatus("run", "succeeded", nil)
+
+		messages := drain(sub)
+		if len(messages) != 3 {
+			t.Fatalf("Expected 2 logs and the status, got %d messages", len(messages))
+		}
+
+		for i, msg := range messages[:2] {
+			if msg.Index != 5+i || msg.Entry == nil || msg.Entry.Message != fmt.Sprintf("line %d\n", 5+i) {
+				t.Errorf("Expected line %d, got %+v", 5+i, msg)
+			}
+		}
+
+		if last := messages[2]; last.Status == nil || last.Status.Status != "succeeded" || last.Index != 7 {
+			t.Errorf("Expected the final status at 7, got %+v", last)
+		}
+	})
+
+	t.Run("no gap or duplicate while logs are written", func(t *testing.T) {
+		s := newTestStreamStore(t, 0)
+		s.Create("run", "test", nil)
+		s.UpdateStatus("run", "running", nil)
+
+		const total = 3000
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			addLines(s, "run", 0, total)
+			s.UpdateStatus("run", "succeeded", nil)
+		}()
+
+		results := make([][]string, 5)
+		errs := make([]error, len(results))
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i], errs[i] = follow(s, "run")
+			}()
+		}
+		wg.Wait()
+
+		for i, lines := range results {
+			if errs[i] != nil {
+				t.Fatalf("Subscriber %d: %v", i, errs[i])
+			}
+
+			if len(lines) != total {
+				t.Fatalf("Subscriber %d: expected %d lines, got %d", i, total, len(lines))
+			}
+			for n, line := range lines {
+				if line != fmt.Sprintf("line %d\n", n) {
+					t.Fatalf("Subscriber %d: expected line %d, got %q", i, n, line)
+				}
+			}
+		}
+	})
+
+	t.Run("slow subscriber is dropped", func(t *testing.T) {
+		s := newTestStreamStore(t, 0)
+		s.Create("run", "test", nil)
+		s.UpdateStatus("run", "running", nil)
+
+		sub, err := s.Subscribe("run", 0)
+		if err != nil {
+			t.Fatalf("Subscribe() error: %v", err)
+		}
+
+		// nothing is read, the buffer holds 1024 messages
+		addLines(s, "run", 0, 1100)
+
+		messages := drain(sub)
+		if len(messages) != 1024 {
+			t.Fatalf("Expected the buffered 1024 messages, got %d", len(messages))
+		}
+		if last := messages[len(messages)-1]; last.Index != 1023 || last.Status != nil {
+			t.Errorf("Expected the last buffered line 1023, got %+v", last)
+		}
+
+		st := s.lock("run")
+		subscribers := len(st.subscribers)
+		st.mu.Unlock()
+		if subscribers != 0 {
+			t.Errorf("Expected the slow subscriber to be removed, got %d subscribers", subscribers)
+		}
+
+		// subscribing again from the last index continues without a gap
+		sub, err = s.Subscribe("run", 1024)
+		if err != nil {
+			t.Fatalf("Subscribe() error: %v", err)
+		}
+		defer sub.Close()
+
+		if len(sub.Logs) != 1100-1024 || sub.Logs[0].Message != "line 1024\n" {
+			t.Errorf("Expected the logs from line 1024, got %d logs", len(sub.Logs))
+		}
+	})
+
+	t.Run("finished run sends the final status only", func(t *testing.T) {
+		s := newTestStreamStore(t, 0)
+		s.Create("run", "test", nil)
+		s.UpdateStatus("run", "running", nil)
+		addLines(s, "run", 0, 3)
+		s.UpdateStatus("run", "failed", fmt.Errorf("exit status 3"))
+
+		sub, err := s.Subscribe("run", 10)
+		if err != nil {
+			t.Fatalf("Subscribe() error: %v", err)
+		}
+
+		if sub.Cursor != 3 || len(sub.Logs) != 0 {
+			t.Errorf("Expected the cursor to be clamped to 3 without logs, got %d and %d logs", sub.Cursor, len(sub.Logs))
+		}
+
+		messages := drain(sub)
+		if len(messages) != 1 || messages[0].Status == nil || messages[0].Status.Status != "failed" || messages[0].Index != 3 {
+			t.Fatalf("Expected the final status only, got %+v", messages)
+		}
+		if messages[0].Status.Error != "exit status 3" {
+			t.Errorf("Expected the error of the run, got %q", messages[0].Status.Error)
+		}
+	})
+
+	t.Run("closed subscription gets no more logs", func(t *testing.T) {
+		s := newTestStreamStore(t, 0)
+		s.Create("run", "test", nil)
+		s.UpdateStatus("run", "running", nil)
+
+		sub, err := s.Subscribe("run", 0)
+		if err != nil {
+			t.Fatalf("Subscribe() error: %v", err)
+		}
+
+		sub.Close()
+		sub.Close()
+		addLines(s, "run", 0, 3)
+		s.UpdateStatus("run", "succeeded", nil)
+
+		if messages := drain(sub); len(messages) != 0 {
+			t.Errorf("Expected no messages after close, got %d", len(messages))
+		}
+	})
+
+	t.Run("unknown run", func(t *testing.T) {
+		s := newTestStreamStore(t, 0)
+		if _, err := s.Subscribe("missing", 0); err == nil {
+			t.Error("Expected an error for an unknown run")
+		}
+	})
+}
+
+// blockingStore blocks the logs of a run until release is closed
+type blockingStore struct {
+	Store
+	id      string
+	blocked chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) AddLog(id string, entry LogEntry) {
+	if id == s.id {
+		close(s.blocked)
+		<-s.release
+	}
+
+	s.Store.AddLog(id, entry)
+}
+
+func TestStreamStoreLockPerRun(t *testing.T) {
+	store := &blockingStore{
+		Store:   NewMemoryStore(t.TempDir(), 10),
+		id:      "slow",
+		blocked: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	s := NewStreamStore(store, 0)
+	for _, id := range []string{"slow", "fast"} {
+		s.Create(id, "test", nil)
+		s.UpdateStatus(id, "running", nil)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		addLines(s, "slow", 0, 1)
+	}()
+	<-store.blocked
+
+	// the disk I/O of a run does not block the logs and the subscribers of the other runs
+	finished := make(chan error, 1)
+	go func() {
+		addLines(s, "fast", 0, 3)
+		sub, err := s.Subscribe("fast", 0)
+		if err == nil && len(sub.Logs) != 3 {
+			err = fmt.Errorf("expected 3 logs, got %d", len(sub.Logs))
+		}
+		if sub != nil {
+			sub.Close()
+		}
+		finished <- err
+	}()
+
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Errorf("Subscribe() error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the other run not to be blocked")
+	}
+
+	close(store.release)
+	<-done
+}
+
+func TestStreamStoreMaxLogSize(t *testing.T) {
+	// every line is 10 bytes
+	lines := func(from, to int) []string {
+		messages := []string{}
+		for i := from; i < to; i++ {
+			messages = append(messages, fmt.Sprintf("%09d\n", i))
+		}
+		return messages
+	}
+
+	exceeded := "log truncated: the logs of the run exceed the limit of 100 bytes, the following logs are dropped\n"
+
+	tests := []struct {
+		name       string
+		maxLogSize int64
+		lines      int
+		// retry is the number of lines of a retry after the run finished
+		retry    int
+		expected []string
+	}{
+		{"unlimited", 0, 20, 0, lines(0, 20)},
+		{"under the limit", 1000, 20, 0, lines(0, 20)},
+		{"at the limit", 100, 10, 0, lines(0, 10)},
+		{
+			"over the limit", 100, 25, 0,
+			append(append(lines(0, 10), exceeded), "log truncated: 15 lines are dropped\n"),
+		},
+		{
+			"retry after the limit", 100, 11, 3,
+			append(append(lines(0, 10), exceeded, "log truncated: 1 lines are dropped\n"), lines(11, 14)...),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStreamStore(t, tt.maxLogSize)
+			s.Create("run", "test", nil)
+			s.UpdateStatus("run", "running", nil)
+
+			sub, err := s.Subscribe("run", 0)
+			if err != nil {
+				t.Fatalf("Subscribe() error: %v", err)
+			}
+
+			for _, message := range lines(0, tt.lines) {
+				s.AddLog("run", LogEntry{Type: "stdout", Message: message})
+			}
+			s.UpdateStatus("run", "failed", fmt.Errorf("exit status 1"))
+
+			// the subscriber gets the same logs as the store
+			live := []string{}
+			for msg := range sub.Messages {
+				if msg.Entry != nil {
+					live = append(live, msg.Entry.Message)
+				}
+			}
+
+			if tt.retry > 0 {
+				s.UpdateStatus("run", "running", nil)
+				for _, message := range lines(tt.lines, tt.lines+tt.retry) {
+					s.AddLog("run", LogEntry{Type: "stdout", Message: message})
+				}
+				s.UpdateStatus("run", "succeeded", nil)
+			}
+
+			stored := []string{}
+			if _, err := s.ReadLogs("run", 0, 0, func(index int, next int64, entry *LogEntry) bool {
+				stored = append(stored, entry.Message)
+				return true
+			}); err != nil {
+				t.Fatalf("ReadLogs() error: %v", err)
+			}
+
+			if !reflect.DeepEqual(stored, tt.expected) {
+				t.Errorf("Expected logs %q, got %q", tt.expected, stored)
+			}
+
+			if want := tt.expected[:len(live)]; len(live) != len(stored)-tt.retry || !reflect.DeepEqual(live, want) {
+				t.Errorf("Expected live logs %q, got %q", want, live)
+			}
+		})
+	}
+}
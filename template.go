@@ -0,0 +1,309 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-idp/pipeline/step"
+	"github.com/go-zoox/encoding/yaml"
+)
+
+// Templates are the named stages, jobs and steps, inherited by the units with extends,
+//
+//	templates can extend templates of the same kind, cycles are rejected
+type Templates struct {
+	Stages map[string]*stage.Stage `json:"stages" yaml:"stages"`
+	Jobs   map[string]*job.Job     `json:"jobs" yaml:"jobs"`
+	Steps  map[string]*step.Step   `json:"steps" yaml:"steps"`
+}
+
+// inherit fills the unset fields of dst with those of src, the fields set in dst win,
+//
+//	maps are merged by key, nested structs (e.g. plugin, retry) are merged field by field,
+//	lists (e.g. jobs, steps, needs) are only inherited when dst has none
+func inherit(dst, src reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		if !dst.Type().Field(i).IsExported() {
+			continue
+		}
+
+		d, s := dst.Field(i), src.Field(i)
+		switch {
+		case d.IsZero():
+			d.Set(s)
+		case d.Kind() == reflect.Map:
+			for _, k := range s.MapKeys() {
+				if !d.MapIndex(k).IsValid() {
+					d.SetMapIndex(k, s.MapIndex(k))
+				}
+			}
+		case d.Kind() == reflect.Ptr && d.Elem().Kind() == reflect.Struct && !s.IsNil():
+			inherit(d.Elem(), s.Elem())
+		}
+	}
+}
+
+// copyOf returns a deep copy of the template, so that the units extending it do not share anything
+func copyOf[T any](v *T) (*T, error) {
+	data, err := yaml.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(T)
+	if err := yaml.Decode(data, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// extend makes the unit inherit from the resolved template
+func extend[T any](unit *T, template *T) error {
+	c, err := copyOf(template)
+	if err != nil {
+		return fmt.Errorf("failed to copy template: %s", err)
+	}
+
+	inherit(reflect.ValueOf(unit).Elem(), reflect.ValueOf(c).Elem())
+	return nil
+}
+
+// templateResolver resolves the extends of the templates and units of the pipeline
+type templateResolver struct {
+	templates *Templates
+	// resolved are the templates whose extends are resolved, e.g. jobs.build
+	resolved map[string]bool
+	// visiting is the chain of templates being resolved, to reject cycles
+	visiting []string
+	//
+	v *validator
+}
+
+// enter marks the template as being resolved, returns an error on a cycle
+func (r *templateResolver) enter(key string) error {
+	for i, k := range r.visiting {
+		if k == key {
+			return fmt.Errorf("template cycle: %s", strings.Join(append(append([]string{}, r.visiting[i:]...), key), " -> "))
+		}
+	}
+
+	r.visiting = append(r.visiting, key)
+	return nil
+}
+
+func (r *templateResolver) leave(key string) {
+	r.visiting = r.visiting[:len(r.visiting)-1]
+	r.resolved[key] = true
+}
+
+func (r *templateResolver) stepTemplate(name string) (*step.Step, error) {
+	key := "steps." + name
+	if r.templates == nil || r.templates.Steps[name] == nil {
+		return nil, fmt.Errorf("unknown step template %s", name)
+	}
+
+	t := r.templates.Steps[name]
+	if r.resolved[key] {
+		return t, nil
+	}
+
+	if err := r.enter(key); err != nil {
+		return nil, err
+	}
+	defer r.leave(key)
+
+	if t.Extends != "" {
+		parent, err := r.stepTemplate(t.Extends)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := extend(t, parent); err != nil {
+			return nil, err
+		}
+		t.Extends = ""
+	}
+
+	return t, nil
+}
+
+func (r *templateResolver) jobTemplate(name string) (*job.Job, error) {
+	key := "jobs." + name
+	if r.templates == nil || r.templates.Jobs[name] == nil {
+		return nil, fmt.Errorf("unknown job template %s", name)
+	}
+
+	t := r.templates.Jobs[name]
+	if r.resolved[key] {
+		return t, nil
+	}
+
+	if err := r.enter(key); err != nil {
+		return nil, err
+	}
+	defer r.leave(key)
+
+	if t.Extends != "" {
+		parent, err := r.jobTemplate(t.Extends)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := extend(t, parent); err != nil {
+			return nil, err
+		}
+		t.Extends = ""
+	}
+
+	for i, s := range t.Steps {
+		if err := r.extendStep(s); err != nil {
+			return nil, fmt.Errorf("steps[%d]: %s", i, err)
+		}
+	}
+
+	return t, nil
+}
+
+func (r *templateResolver) stageTemplate(name string) (*stage.Stage, error) {
+	key := "stages." + name
+	if r.templates == nil || r.templates.Stages[name] == nil {
+		return nil, fmt.Errorf("unknown stage template %s", name)
+	}
+
+	t := r.templates.Stages[name]
+	if r.resolved[key] {
+		return t, nil
+	}
+
+	if err := r.enter(key); err != nil {
+		return nil, err
+	}
+	defer r.leave(key)
+
+	if t.Extends != "" {
+		parent, err := r.stageTemplate(t.Extends)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := extend(t, parent); err != nil {
+			return nil, err
+		}
+		t.Extends = ""
+	}
+
+	for i, j := range t.Jobs {
+		if err := r.extendJob(j); err != nil {
+			return nil, fmt.Errorf("jobs[%d]: %s", i, err)
+		}
+
+		for k, s := range j.Steps {
+			if err := r.extendStep(s); err != nil {
+				return nil, fmt.Errorf("jobs[%d].steps[%d]: %s", i, k, err)
+			}
+		}
+	}
+
+	return t, nil
+}
+
+func (r *templateResolver) extendStep(s *step.Step) error {
+	if s.Extends == "" {
+		return nil
+	}
+
+	t, err := r.stepTemplate(s.Extends)
+	if err != nil {
+		return err
+	}
+
+	if err := extend(s, t); err != nil {
+		return err
+	}
+	s.Extends = ""
+
+	return nil
+}
+
+func (r *templateResolver) extendJob(j *job.Job) error {
+	if j.Extends == "" {
+		return nil
+	}
+
+	t, err := r.jobTemplate(j.Extends)
+	if err != nil {
+		return err
+	}
+
+	if err := extend(j, t); err != nil {
+		return err
+	}
+	j.Extends = ""
+
+	return nil
+}
+
+func (r *templateResolver) stage(path string, s *stage.Stage) {
+	if s.Extends != "" {
+		t, err := r.stageTemplate(s.Extends)
+		if err != nil {
+			r.v.add(path+".extends", "%s", err)
+			return
+		}
+
+		if err := extend(s, t); err != nil {
+			r.v.add(path+".extends", "%s", err)
+			return
+		}
+		s.Extends = ""
+	}
+
+	for i, j := range s.Jobs {
+		jpath := fmt.Sprintf("%s.jobs[%d]", path, i)
+		if err := r.extendJob(j); err != nil {
+			r.v.add(jpath+".extends", "%s", err)
+			continue
+		}
+
+		for k, st := range j.Steps {
+			if err := r.extendStep(st); err != nil {
+				r.v.add(fmt.Sprintf("%s.steps[%d].extends", jpath, k), "%s", err)
+			}
+		}
+	}
+}
+
+// resolveTemplates makes the stages, jobs and steps with extends inherit from their templates,
+//
+//	returns ValidationErrors with the unknown templates and cycles
+func (p *Pipeline) resolveTemplates() error {
+	r := &templateResolver{
+		templates: p.Templates,
+		resolved:  map[string]bool{},
+		v:         &validator{},
+	}
+
+	for _, group := range []struct {
+		path   string
+		stages []*stage.Stage
+	}{
+		{"stages", p.Stages},
+		{"on_success", p.OnSuccess},
+		{"on_failure", p.OnFailure},
+		{"finally", p.Finally},
+	} {
+		for i, s := range group.stages {
+			r.stage(fmt.Sprintf("%s[%d]", group.path, i), s)
+		}
+	}
+
+	if len(r.v.errors) > 0 {
+		return r.v.errors
+	}
+
+	return nil
+}
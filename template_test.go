@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPipelineTemplates(t *testing.T) {
+	t.Run("should inherit from templates, the fields set on the unit win", func(t *testing.T) {
+		p, err := LoadYAML([]byte(`
+name: test pipeline templates
+templates:
+  steps:
+    go:
+      image: golang:1.22
+      timeout: 600
+      environment:
+        CGO_ENABLED: "0"
+        GOFLAGS: -mod=mod
+    go-test:
+      extends: go
+      command: go test ./...
+  jobs:
+    go-build:
+      environment:
+        TARGET: linux
+      steps:
+        - name: build
+          extends: go
+          command: go build ./...
+  stages:
+    checkout:
+      name: checkout
+      jobs:
+        - name: checkout
+          steps:
+            - name: clone
+              command: git clone repo .
+stages:
+  - extends: checkout
+  - name: build
+    jobs:
+      - name: build
+        extends: go-build
+        environment:
+          TARGET: darwin
+      - name: test
+        steps:
+          - name: unit
+            extends: go-test
+            environment:
+              GOFLAGS: -race
+`), "")
+		if err != nil {
+			t.Fatalf("Failed to load pipeline: %v", err)
+		}
+
+		if err := p.resolveTemplates(); err != nil {
+			t.Fatalf("Failed to resolve templates: %v", err)
+		}
+
+		if got := p.Stages[0].Name; got != "checkout" {
+			t.Errorf("Expected stage name checkout from the template, got %s", got)
+		}
+		if got := p.Stages[0].Jobs[0].Steps[0].Command; got != "git clone repo ." {
+			t.Errorf("Expected jobs of the stage template, got %s", got)
+		}
+
+		build := p.Stages[1].Jobs[0]
+		if got := build.Environment["TARGET"]; got != "darwin" {
+			t.Errorf("Expected TARGET=darwin of the job to win, got %s", got)
+		}
+		if len(build.Steps) != 1 || build.Steps[0].Image != "golang:1.22" {
+			t.Errorf("Expected the steps of the job template with image golang:1.22, got %+v", build.Steps)
+		}
+
+		unit := p.Stages[1].Jobs[1].Steps[0]
+		if unit.Command != "go test ./..." || unit.Image != "golang:1.22" || unit.Timeout != 600 {
+			t.Errorf("Expected the chain of step templates to be inherited, got %+v", unit)
+		}
+		if unit.Environment["GOFLAGS"] != "-race" || unit.Environment["CGO_ENABLED"] != "0" {
+			t.Errorf("Expected the environment to be merged by key, got %v", unit.Environment)
+		}
+		if unit.Extends != "" {
+			t.Errorf("Expected extends to be resolved, got %s", unit.Extends)
+		}
+
+		// the units do not share the templates
+		build.Steps[0].Environment["CGO_ENABLED"] = "1"
+		if p.Templates.Steps["go"].Environment["CGO_ENABLED"] != "0" {
+			t.Errorf("Expected the template not to be changed by the unit")
+		}
+	})
+
+	t.Run("unknown templates and cycles should be rejected", func(t *testing.T) {
+		p, err := LoadYAML([]byte(`
+name: test pipeline templates
+templates:
+  steps:
+    a:
+      extends: b
+    b:
+      extends: a
+stages:
+  - name: build
+    jobs:
+      - name: build
+        extends: missing
+      - name: test
+        steps:
+          - name: test
+            extends: a
+`), "")
+		if err != nil {
+			t.Fatalf("Failed to load pipeline: %v", err)
+		}
+
+		var out bytes.Buffer
+		p.SetStdout(&out)
+		_, err = p.Plan()
+		if err == nil {
+			t.Fatalf("Expected templates errors")
+		}
+
+		for _, expected := range []string{
+			"stages[0].jobs[0].extends: unknown job template missing",
+			"stages[0].jobs[1].steps[0].extends: template cycle: steps.a -> steps.b -> steps.a",
+		} {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("Expected error containing %q, got %v", expected, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,513 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-idp/pipeline/artifact"
+	"github.com/go-idp/pipeline/expression"
+	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-idp/pipeline/step"
+	goyaml "github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// ValidationError is an error of the pipeline config
+type ValidationError struct {
+	// Path is the yaml path of the field, e.g. stages[0].jobs[1].steps[0].engine
+	Path string `json:"path"`
+	// Line and Column are the position in the yaml, only set by ValidateYAML
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	//
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+
+	if e.Line > 0 {
+		return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, e.Path, e.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors are all the errors of the pipeline config
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+var outputExpressionRe = regexp.MustCompile(`\$\{\{(.*?)\}\}`)
+
+// validator collects the errors of the pipeline config
+type validator struct {
+	errors ValidationErrors
+	// strict also checks the names and the timeouts, which do not fail the run, see Validate
+	strict bool
+}
+
+func (v *validator) add(path, format string, args ...interface{}) {
+	v.errors = append(v.errors, &ValidationError{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) check(path string, err error) {
+	if err != nil {
+		v.add(path, "%s", err)
+	}
+}
+
+func (v *validator) timeout(path string, timeout, max int64) {
+	if timeout < 0 {
+		v.add(path, "timeout should not be negative")
+	} else if max > 0 && timeout > max {
+		v.add(path, "timeout %d exceeds the timeout %d of the pipeline", timeout, max)
+	}
+}
+
+func (v *validator) condition(path, condition string) {
+	if condition == "" {
+		return
+	}
+
+	// outputs are only known at run time, e.g. "'${{ steps.check.outputs.changed }}' == 'true'"
+	_, err := expression.Parse(outputExpressionRe.ReplaceAllString(condition, ""))
+	v.check(path, err)
+}
+
+// Validate checks the whole pipeline before it runs, without changing it,
+//
+//	returns ValidationErrors with all the errors found, nil if the pipeline is valid,
+//	besides the errors which fail the run, the names of stages, jobs and steps are required and unique,
+//	and the timeouts of stages, jobs and steps do not exceed the timeout of the pipeline
+func (p *Pipeline) Validate() error {
+	return p.validate(true)
+}
+
+// validate checks the pipeline, only the errors which fail the run if not strict
+func (p *Pipeline) validate(strict bool) error {
+	v := &validator{strict: strict}
+
+	if p.Name == "" {
+		v.add("name", "name is required")
+	}
+
+	if len(p.Stages) == 0 {
+		v.add("stages", "no stages found, stages is required")
+	}
+
+	v.timeout("timeout", p.Timeout, 0)
+
+	v.validateParams(p.Parameters)
+
+	// needs and dependencies refer to the names of the jobs in the stages
+	var jobs map[string]string
+	for _, group := range []struct {
+		path   string
+		stages []*stage.Stage
+	}{
+		{"stages", p.Stages},
+		{"on_success", p.OnSuccess},
+		{"on_failure", p.OnFailure},
+		{"finally", p.Finally},
+	} {
+		stages := map[string]string{}
+		names := map[string]string{}
+		// hooks run after the stages, out of the timeout of the pipeline
+		max := int64(0)
+		if group.path == "stages" {
+			jobs = names
+			if strict {
+				max = p.Timeout
+			}
+		}
+
+		for index, s := range group.stages {
+			path := fmt.Sprintf("%s[%d]", group.path, index)
+			if previous, ok := stages[s.Name]; ok && s.Name != "" && strict {
+				v.add(path+".name", "duplicate stage name %s, already used by %s", s.Name, previous)
+			}
+			stages[s.Name] = path
+
+			v.validateStage(path, s, max, names)
+		}
+	}
+
+	for index, s := range p.Stages {
+		for jindex, j := range s.Jobs {
+			path := fmt.Sprintf("stages[%d].jobs[%d]", index, jindex)
+
+			for i, name := range j.Needs {
+				if _, ok := jobs[name]; !ok {
+					v.add(fmt.Sprintf("%s.needs[%d]", path, i), "job(%s) needs unknown job(%s)", j.Name, name)
+				}
+			}
+
+			for i, name := range j.Dependencies {
+				if _, ok := jobs[name]; !ok {
+					v.add(fmt.Sprintf("%s.dependencies[%d]", path, i), "job(%s) depends on unknown job(%s)", j.Name, name)
+				}
+			}
+		}
+	}
+
+	if len(v.errors) == 0 {
+		return nil
+	}
+
+	return v.errors
+}
+
+func (v *validator) validateStage(path string, s *stage.Stage, max int64, jobs map[string]string) {
+	if s.Name == "" && v.strict {
+		v.add(path+".name", "name is required")
+	}
+
+	switch s.RunMode {
+	case "", stage.RunModeSerial, stage.RunModeParallel:
+	default:
+		v.add(path+".run_mode", "unsupported run_mode %s, only support %s | %s", s.RunMode, stage.RunModeSerial, stage.RunModeParallel)
+	}
+
+	if len(s.Jobs) == 0 {
+		v.add(path+".jobs", "no jobs found, jobs is required")
+	}
+
+	v.timeout(path+".timeout", s.Timeout, max)
+	v.condition(path+".if", s.If)
+
+	for index, j := range s.Jobs {
+		jpath := fmt.Sprintf("%s.jobs[%d]", path, index)
+		if previous, ok := jobs[j.Name]; ok && j.Name != "" && v.strict {
+			v.add(jpath+".name", "duplicate job name %s, already used by %s", j.Name, previous)
+		}
+		jobs[j.Name] = jpath
+
+		v.validateJob(jpath, j, max)
+	}
+}
+
+func (v *validator) validateJob(path string, j *job.Job, max int64) {
+	if j.Name == "" && v.strict {
+		v.add(path+".name", "name is required")
+	}
+
+	if len(j.Steps) == 0 {
+		v.add(path+".steps", "no steps found, steps is required")
+	}
+
+	v.timeout(path+".timeout", j.Timeout, max)
+	v.condition(path+".if", j.If)
+
+	if j.MaxParallel < 0 {
+		v.add(path+".max_parallel", "max_parallel should not be negative")
+	}
+
+	if j.Matrix != nil {
+		_, err := j.Matrix.Combinations()
+		v.check(path+".matrix", err)
+	}
+
+	if j.Retry != nil {
+		v.check(path+".retry", j.Retry.Validate())
+	}
+
+	if j.Cache != nil {
+		v.check(path+".cache", j.Cache.Validate())
+	}
+
+	for index, a := range j.Artifacts {
+		v.check(fmt.Sprintf("%s.artifacts[%d]", path, index), artifact.ValidatePath(a))
+	}
+
+	// artifacts are collected from and restored to the workdir on the runner
+	if len(j.Artifacts) > 0 || len(j.Dependencies) > 0 {
+		for index, s := range j.Steps {
+			if !step.IsLocalEngine(s.Engine) {
+				v.add(fmt.Sprintf("%s.steps[%d].engine", path, index), "artifacts and dependencies are only supported on the host and docker engines, the workdir of engine %s is not on the runner", s.Engine)
+			}
+		}
+	}
+
+	steps := map[string]string{}
+	for index, s := range j.Steps {
+		spath := fmt.Sprintf("%s.steps[%d]", path, index)
+		// outputs are referenced by the name of the step
+		if previous, ok := steps[s.Name]; ok && s.Name != "" && v.strict {
+			v.add(spath+".name", "duplicate step name %s, already used by %s", s.Name, previous)
+		}
+		steps[s.Name] = spath
+
+		v.validateStep(spath, s, max)
+	}
+}
+
+func (v *validator) validateStep(path string, s *step.Step, max int64) {
+	if s.Name == "" && v.strict {
+		v.add(path+".name", "name is required")
+	}
+
+	if s.Command == "" && s.Plugin == nil && s.Language == nil && s.Service == nil {
+		v.add(path+".command", "command is required without plugin, language or service")
+	}
+
+	if s.Language != nil && s.Plugin != nil {
+		v.add(path+".language", "you can not use language and plugin at the same time")
+	}
+
+	if s.Language != nil {
+		if s.Language.Name == "" {
+			v.add(path+".language.name", "language name is required")
+		}
+
+		if s.Language.Version == "" {
+			v.add(path+".language.version", "language version is required")
+		}
+	}
+
+	if s.Plugin != nil && s.Plugin.Image == "" {
+		v.add(path+".plugin.image", "plugin image is required")
+	}
+
+	if s.Service != nil {
+		v.check(path+".service", s.Service.Validate())
+	}
+
+	if s.Engine != "" {
+		v.check(path+".engine", step.ValidateEngine(s.Engine))
+	}
+
+	v.timeout(path+".timeout", s.Timeout, max)
+	v.condition(path+".if", s.If)
+
+	v.check(path+".command", step.ValidateOutputReferences(s.Command))
+	v.check(path+".if", step.ValidateOutputReferences(s.If))
+	keys := make([]string, 0, len(s.Environment))
+	for k := range s.Environment {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v.check(fmt.Sprintf("%s.environment.%s", path, k), step.ValidateOutputReferences(s.Environment[k]))
+	}
+
+	if s.Retry != nil {
+		v.check(path+".retry", s.Retry.Validate())
+	}
+
+	if s.Cache != nil {
+		v.check(path+".cache", s.Cache.Validate())
+	}
+}
+
+// ValidateYAML parses the yaml config and validates it, the errors have the line and column in the yaml,
+//
+//	besides Validate, unknown fields are reported, e.g. a typo of run_mode,
+//	only http(s) includes are allowed, see LoadYAML
+func ValidateYAML(data []byte, opts ...LoadOption) (*Pipeline, error) {
+	cfg := &LoadConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+
+	return validateYAML(data, "", cfg)
+}
+
+// ValidateConfig validates the config like ValidateYAML, a local path or an http(s) url,
+//
+//	its includes are relative to it, the errors of the included configs have no position
+func ValidateConfig(config string) (*Pipeline, error) {
+	data, err := readConfig(config)
+	if err != nil {
+		return nil, ValidationErrors{{Message: err.Error()}}
+	}
+
+	return validateYAML(data, config, &LoadConfig{})
+}
+
+func validateYAML(data []byte, base string, cfg *LoadConfig) (*Pipeline, error) {
+	file, err := parser.ParseBytes(data, 0)
+	if err != nil {
+		return nil, ValidationErrors{{Message: fmt.Sprintf("invalid yaml: %s", err)}}
+	}
+
+	p := &Pipeline{}
+	if err := goyaml.Unmarshal(data, p); err != nil {
+		return nil, ValidationErrors{{Message: fmt.Sprintf("invalid pipeline config: %s", err)}}
+	}
+
+	v := &validator{}
+	if len(file.Docs) > 0 && file.Docs[0].Body != nil {
+		v.unknownFields(file.Docs[0].Body, reflect.TypeOf(p), "")
+	}
+
+	// the paths of the merged pipeline are not those of the yaml
+	included := len(p.Include) > 0
+	chain := []string{}
+	if base != "" {
+		chain = append(chain, base)
+	}
+
+	if err := p.include(base, chain, cfg); err != nil {
+		v.add("include", "%s", err)
+	} else if err := p.resolveTemplates(); err != nil {
+		v.errors = append(v.errors, err.(ValidationErrors)...)
+	} else if err := p.Validate(); err != nil {
+		v.errors = append(v.errors, err.(ValidationErrors)...)
+	}
+
+	if len(v.errors) == 0 {
+		return p, nil
+	}
+
+	for _, e := range v.errors {
+		if e.Line == 0 && (!included || e.Path == "include") {
+			e.Line, e.Column = position(file, e.Path)
+		}
+	}
+
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		return v.errors[i].Line < v.errors[j].Line
+	})
+
+	return p, v.errors
+}
+
+// position returns the position of the path in the yaml, or its nearest parent
+func position(file *ast.File, path string) (int, int) {
+	for path != "" {
+		if p, err := goyaml.PathString("$." + path); err == nil {
+			if node, err := p.FilterFile(file); err == nil && node != nil {
+				// a mapping starts at its first key
+				if values := mappingValues(node); len(values) > 0 {
+					node = values[0].Key
+				}
+
+				return node.GetToken().Position.Line, node.GetToken().Position.Column
+			}
+		}
+
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+
+	return 0, 0
+}
+
+var (
+	interfaceUnmarshaler = reflect.TypeOf((*goyaml.InterfaceUnmarshaler)(nil)).Elem()
+	bytesUnmarshaler     = reflect.TypeOf((*goyaml.BytesUnmarshaler)(nil)).Elem()
+)
+
+// unknownFields reports the keys of the yaml without a field in the type
+func (v *validator) unknownFields(node ast.Node, t reflect.Type, path string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// custom unmarshalers accept their own keys, e.g. the axes of matrix
+	if reflect.PtrTo(t).Implements(interfaceUnmarshaler) || reflect.PtrTo(t).Implements(bytesUnmarshaler) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *ast.AnchorNode:
+		v.unknownFields(n.Value, t, path)
+		return
+	case *ast.TagNode:
+		v.unknownFields(n.Value, t, path)
+		return
+	}
+
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		for _, mv := range mappingValues(node) {
+			key := mv.Key.GetToken().Value
+			if key == "<<" {
+				continue
+			}
+
+			field, ok := yamlField(t, key)
+			if !ok {
+				v.errors = append(v.errors, &ValidationError{
+					Path:    join(key),
+					Line:    mv.Key.GetToken().Position.Line,
+					Column:  mv.Key.GetToken().Position.Column,
+					Message: fmt.Sprintf("unknown field %s", key),
+				})
+				continue
+			}
+
+			v.unknownFields(mv.Value, field.Type, join(key))
+		}
+	case reflect.Map:
+		for _, mv := range mappingValues(node) {
+			v.unknownFields(mv.Value, t.Elem(), join(mv.Key.GetToken().Value))
+		}
+	case reflect.Slice:
+		if seq, ok := node.(*ast.SequenceNode); ok {
+			for i, item := range seq.Values {
+				v.unknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+}
+
+func mappingValues(node ast.Node) []*ast.MappingValueNode {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		return n.Values
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{n}
+	}
+
+	return nil
+}
+
+// yamlField returns the field of the struct by its yaml key
+func yamlField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		if name == key {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
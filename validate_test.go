@@ -0,0 +1,192 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-idp/pipeline/job"
+	"github.com/go-idp/pipeline/stage"
+	"github.com/go-idp/pipeline/step"
+)
+
+func TestPipelineValidate(t *testing.T) {
+	t.Run("valid pipeline should pass", func(t *testing.T) {
+		pipeline := &Pipeline{
+			Name: "test pipeline validate",
+			Stages: []*stage.Stage{
+				{
+					Name:    "build",
+					RunMode: "serial",
+					Jobs: []*job.Job{
+						{
+							Name: "build",
+							Steps: []*step.Step{
+								{Name: "build", Command: "echo build", Engine: "docker://"},
+								{Name: "check", Command: "echo check", If: "'${{ steps.build.outputs.version }}' != ''"},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		if err := pipeline.Validate(); err != nil {
+			t.Fatalf("Expected valid pipeline, got: %v", err)
+		}
+	})
+
+	t.Run("all errors should be reported", func(t *testing.T) {
+		pipeline := &Pipeline{
+			Timeout: 60,
+			Stages: []*stage.Stage{
+				{
+					Name:    "build",
+					RunMode: "seriall",
+					Jobs: []*job.Job{
+						{
+							Name:  "build",
+							Needs: []string{"missing"},
+							Steps: []*step.Step{
+								{Name: "build", Engine: "foo://x", Timeout: 120},
+								{Name: "build", Command: "true", Language: &step.Language{Name: "go", Version: "1.22"}, Plugin: &step.Plugin{Image: "x"}},
+								{Name: "deploy", Service: &step.Service{Name: "app", Type: "nomad", Version: "v1"}},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		err := pipeline.Validate()
+		errs, ok := err.(ValidationErrors)
+		if !ok {
+			t.Fatalf("Expected ValidationErrors, got: %v", err)
+		}
+
+		expected := []string{
+			"name: name is required",
+			"stages[0].run_mode: unsupported run_mode seriall",
+			"stages[0].jobs[0].steps[0].command: command is required",
+			"stages[0].jobs[0].steps[0].engine: unsupported engine: foo",
+			"stages[0].jobs[0].steps[0].timeout: timeout 120 exceeds the timeout 60 of the pipeline",
+			"stages[0].jobs[0].steps[1].name: duplicate step name build",
+			"stages[0].jobs[0].steps[1].language: you can not use language and plugin at the same time",
+			"stages[0].jobs[0].steps[2].service: unsupported service type nomad",
+			"stages[0].jobs[0].needs[0]: job(build) needs unknown job(missing)",
+		}
+		for _, e := range expected {
+			if !strings.Contains(err.Error(), e) {
+				t.Errorf("Expected error %q, got:\n%v", e, err)
+			}
+		}
+
+		if len(errs) != len(expected) {
+			t.Errorf("Expected %d errors, got %d:\n%v", len(expected), len(errs), err)
+		}
+	})
+}
+
+func TestValidateYAML(t *testing.T) {
+	config := `name: test
+stages:
+  - name: build
+    run_mod: serial
+    jobs:
+      - name: build
+        steps:
+          - name: build
+            comand: echo build
+`
+
+	_, err := ValidateYAML([]byte(config))
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected ValidationErrors, got: %v", err)
+	}
+
+	expected := []*ValidationError{
+		{Path: "stages[0].run_mod", Line: 4, Column: 5, Message: "unknown field run_mod"},
+		{Path: "stages[0].jobs[0].steps[0]", Line: 8, Column: 13},
+		{Path: "stages[0].jobs[0].steps[0].comand", Line: 9, Column: 13, Message: "unknown field comand"},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got:\n%v", len(expected), err)
+	}
+
+	for i, e := range expected {
+		if !strings.HasPrefix(errs[i].Path, e.Path) || errs[i].Line != e.Line || errs[i].Column != e.Column {
+			t.Errorf("Expected %s at %d:%d, got %s", e.Path, e.Line, e.Column, errs[i])
+		}
+	}
+
+	if _, err := ValidateYAML([]byte("name: test\nstages:\n  - name: build\n    jobs:\n      - name: build\n        matrix:\n          os: [linux]\n        steps:\n          - name: build\n            command: echo\n")); err != nil {
+		t.Errorf("Expected valid config, got: %v", err)
+	}
+}
+
+func TestPipelineRunValidation(t *testing.T) {
+	newPipeline := func() *Pipeline {
+		p := &Pipeline{
+			Name:    "test pipeline run validation",
+			Workdir: t.TempDir(),
+			Timeout: 60,
+			Stages: []*stage.Stage{
+				{
+					Name: "build",
+					Jobs: []*job.Job{
+						{
+							Name: "default",
+							Steps: []*step.Step{
+								{Name: "echo", Command: "echo build", Timeout: 120},
+								{Name: "echo", Command: "echo again"},
+							},
+						},
+					},
+				},
+				{
+					Jobs: []*job.Job{
+						{
+							Name:  "default",
+							Steps: []*step.Step{{Command: "echo deploy"}},
+						},
+					},
+				},
+			},
+		}
+		p.SetStdout(&bytes.Buffer{})
+
+		return p
+	}
+
+	t.Run("names and timeouts should only be checked by Validate", func(t *testing.T) {
+		pipeline := newPipeline()
+		if err := pipeline.Validate(); err == nil {
+			t.Fatal("Expected Validate to report the names and timeouts")
+		}
+
+		if err := pipeline.Run(context.Background()); err != nil {
+			t.Fatalf("Expected the run to succeed, got: %v", err)
+		}
+	})
+
+	t.Run("needs on a duplicate job name should fail the run", func(t *testing.T) {
+		pipeline := newPipeline()
+		pipeline.Stages = append(pipeline.Stages, &stage.Stage{
+			Name: "release",
+			Jobs: []*job.Job{
+				{
+					Name:  "release",
+					Needs: []string{"default"},
+					Steps: []*step.Step{{Name: "release", Command: "echo release"}},
+				},
+			},
+		})
+
+		err := pipeline.Run(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "needs ambiguous job(default)") {
+			t.Fatalf("Expected an ambiguous job error, got: %v", err)
+		}
+	})
+}